@@ -0,0 +1,108 @@
+package auditlogs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const auditLogEntryTimeout = 2 * time.Minute
+
+// EntryMatcher is a struct of the fields an audit log entry must have to match. Empty fields match any value, and the
+// request URI matches as a substring, so it can be a resource path without its query.
+type EntryMatcher struct {
+	Method        string
+	RequestURI    string
+	ResponseCode  int
+	UserLoginName string
+}
+
+// Matches returns true if the audit log entry has every field set on the matcher.
+func (m EntryMatcher) Matches(entry *AuditLogEntry) bool {
+	if m.Method != "" && !strings.EqualFold(m.Method, entry.Method) {
+		return false
+	}
+
+	if m.RequestURI != "" && !strings.Contains(entry.RequestURI, m.RequestURI) {
+		return false
+	}
+
+	if m.ResponseCode != 0 && m.ResponseCode != entry.ResponseCode {
+		return false
+	}
+
+	if m.UserLoginName != "" && m.UserLoginName != entry.UserLoginName {
+		return false
+	}
+
+	return true
+}
+
+// String returns the fields of the matcher, for error messages.
+func (m EntryMatcher) String() string {
+	return fmt.Sprintf("method=%q requestURI=%q responseCode=%d userLoginName=%q", m.Method, m.RequestURI, m.ResponseCode, m.UserLoginName)
+}
+
+// FindAuditLogEntries is a helper function that returns the audit log entries that match.
+func FindAuditLogEntries(entries []AuditLogEntry, matcher EntryMatcher) []AuditLogEntry {
+	var matchingEntries []AuditLogEntry
+	for i := range entries {
+		if matcher.Matches(&entries[i]) {
+			matchingEntries = append(matchingEntries, entries[i])
+		}
+	}
+
+	return matchingEntries
+}
+
+// WaitForAuditLogEntry is a helper function that waits until the audit log has an entry of the session ID that matches,
+// as the entries of a request are written after its response, and returns it. See sessionid.SetSessionIDHeader for how
+// the requests of a test client carry the session ID.
+func WaitForAuditLogEntry(client *rancher.Client, sessionID string, matcher EntryMatcher) (*AuditLogEntry, error) {
+	var matchingEntry *AuditLogEntry
+
+	err := kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Scale(auditLogEntryTimeout), true, func(context.Context) (done bool, err error) {
+		entries, err := GetAuditLogEntriesBySessionID(client, sessionID)
+		if err != nil {
+			return false, err
+		}
+
+		matchingEntries := FindAuditLogEntries(entries, matcher)
+		if len(matchingEntries) == 0 {
+			return false, nil
+		}
+
+		matchingEntry = &matchingEntries[0]
+
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("no audit log entry of session %s matches %s: %w", sessionID, matcher, err)
+	}
+
+	return matchingEntry, nil
+}
+
+// VerifyEntryLevel is a helper function that checks the audit log entry holds exactly what the audit log level logs:
+// the request body of write requests from level 2 onwards and the response body at level 3.
+func VerifyEntryLevel(entry *AuditLogEntry, level int) error {
+	hasRequestBody := len(entry.RequestBody) > 0
+	hasResponseBody := len(entry.ResponseBody) > 0
+
+	isWrite := entry.Method == http.MethodPost || entry.Method == http.MethodPut || entry.Method == http.MethodPatch
+	if isWrite && hasRequestBody != (level >= LevelRequest) {
+		return fmt.Errorf("audit log entry %s of %s %s has request body %t at level %d", entry.AuditID, entry.Method, entry.RequestURI, hasRequestBody, level)
+	}
+
+	if hasResponseBody && level < LevelRequestResponse {
+		return fmt.Errorf("audit log entry %s of %s %s has a response body at level %d", entry.AuditID, entry.Method, entry.RequestURI, level)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package auditlogs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rancher/rancher/tests/v2/actions/sessionid"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/kubeconfig"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// RancherNamespace is the namespace the rancher pods run in
+	RancherNamespace = "cattle-system"
+	// AuditLogContainerName is the sidecar container of the rancher pod that pipes the audit log to its console
+	AuditLogContainerName = "rancher-audit-log"
+
+	localCluster         = "local"
+	rancherLabelSelector = "app=rancher"
+)
+
+// AuditLogEntry is a struct of the fields the rancher audit log writes for a single API request.
+type AuditLogEntry struct {
+	AuditID           string      `json:"auditID,omitempty"`
+	RequestURI        string      `json:"requestURI,omitempty"`
+	Method            string      `json:"method,omitempty"`
+	RemoteAddr        string      `json:"remoteAddr,omitempty"`
+	RequestTimestamp  string      `json:"requestTimestamp,omitempty"`
+	ResponseTimestamp string      `json:"responseTimestamp,omitempty"`
+	ResponseCode      int         `json:"responseCode,omitempty"`
+	RequestHeader     http.Header `json:"requestHeader,omitempty"`
+	ResponseHeader    http.Header `json:"responseHeader,omitempty"`
+	// RequestBody and ResponseBody are written by rancher as raw JSON objects from audit level 2 onwards
+	RequestBody   json.RawMessage `json:"requestBody,omitempty"`
+	ResponseBody  json.RawMessage `json:"responseBody,omitempty"`
+	UserLoginName string          `json:"userLoginName,omitempty"`
+}
+
+// GetAuditLogEntries is a helper function that reads the audit log of every rancher pod in the local cluster and
+// returns all the entries that could be parsed. Audit logging must be enabled with at least level 1.
+func GetAuditLogEntries(client *rancher.Client) ([]AuditLogEntry, error) {
+	kubeConfig, err := kubeconfig.GetKubeconfig(client, localCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	restConfig, err := (*kubeConfig).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	podList, err := clientset.CoreV1().Pods(RancherNamespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: rancherLabelSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(podList.Items) == 0 {
+		return nil, fmt.Errorf("no rancher pods found in namespace %s", RancherNamespace)
+	}
+
+	var entries []AuditLogEntry
+	for _, pod := range podList.Items {
+		stream, err := clientset.CoreV1().Pods(RancherNamespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+			Container: AuditLogContainerName,
+		}).Stream(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("error streaming audit logs for pod %s/%s: %v", RancherNamespace, pod.Name, err)
+		}
+
+		var parseFailures int
+		reader := bufio.NewScanner(stream)
+		reader.Buffer(make([]byte, 0, 64*1024), 8*1024*1024)
+		for reader.Scan() {
+			line := strings.TrimSpace(reader.Text())
+			if !strings.HasPrefix(line, "{") {
+				continue
+			}
+
+			var entry AuditLogEntry
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				parseFailures++
+				continue
+			}
+
+			entries = append(entries, entry)
+		}
+
+		err = reader.Err()
+		stream.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading audit logs for pod %s/%s: %v", RancherNamespace, pod.Name, err)
+		}
+
+		if parseFailures > 0 {
+			logrus.Warnf("Unable to parse %d audit log entries of pod %s/%s", parseFailures, RancherNamespace, pod.Name)
+		}
+	}
+
+	return entries, nil
+}
+
+// GetAuditLogEntriesBySessionID is a helper function that returns the audit log entries of all the API calls that were
+// sent with the given session ID header, see sessionid.SetSessionIDHeader.
+func GetAuditLogEntriesBySessionID(client *rancher.Client, sessionID string) ([]AuditLogEntry, error) {
+	entries, err := GetAuditLogEntries(client)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessionEntries []AuditLogEntry
+	for _, entry := range entries {
+		if entry.RequestHeader.Get(sessionid.HeaderKey) == sessionID {
+			sessionEntries = append(sessionEntries, entry)
+		}
+	}
+
+	return sessionEntries, nil
+}
@@ -0,0 +1,120 @@
+package auditlogs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/rancher/rancher/tests/v2/actions/rancherupgrade"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/kubeconfig"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// LevelMetadata logs the metadata of every request
+	LevelMetadata = 1
+	// LevelRequest logs the metadata and the request body of every request
+	LevelRequest = 2
+	// LevelRequestResponse logs the metadata, the request body and the response body of every request
+	LevelRequestResponse = 3
+
+	rancherDeploymentName = "rancher"
+	rancherContainerName  = "rancher"
+	auditLevelEnvName     = "AUDIT_LEVEL"
+	auditLevelValueKey    = "auditLog.level"
+	auditDestinationKey   = "auditLog.destination"
+	sidecarDestination    = "sidecar"
+)
+
+// EnableAuditLog is a helper function that sets the audit log level of the rancher server, writing the log to the
+// sidecar container GetAuditLogEntries reads, and returns a new admin client once rancher is back. The level is set with
+// a helm upgrade of the installed rancher version, so the rancherupgrade config must be set, see rancherupgrade.Config.
+// The previous level is restored the same way by the session of the client.
+func EnableAuditLog(client *rancher.Client, level int) (*rancher.Client, error) {
+	previousLevel, err := GetAuditLevel(client)
+	if err != nil {
+		return nil, err
+	}
+
+	if previousLevel == level {
+		return client, nil
+	}
+
+	updatedClient, err := setAuditLevel(client, level)
+	if err != nil {
+		return nil, err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		_, err := setAuditLevel(updatedClient, previousLevel)
+		return err
+	})
+
+	return updatedClient, nil
+}
+
+// GetAuditLevel is a helper function that returns the audit log level the rancher deployment runs with, 0 meaning audit
+// logging is disabled.
+func GetAuditLevel(client *rancher.Client) (int, error) {
+	kubeConfig, err := kubeconfig.GetKubeconfig(client, localCluster)
+	if err != nil {
+		return 0, err
+	}
+
+	restConfig, err := (*kubeConfig).ClientConfig()
+	if err != nil {
+		return 0, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return 0, err
+	}
+
+	deployment, err := clientset.AppsV1().Deployments(RancherNamespace).Get(context.TODO(), rancherDeploymentName, metav1.GetOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name != rancherContainerName {
+			continue
+		}
+
+		for _, env := range container.Env {
+			if env.Name == auditLevelEnvName {
+				return strconv.Atoi(env.Value)
+			}
+		}
+	}
+
+	return 0, nil
+}
+
+// setAuditLevel is a private helper function that upgrades rancher to its installed version with the audit log level
+// and the sidecar destination set, and returns a new admin client.
+func setAuditLevel(client *rancher.Client, level int) (*rancher.Client, error) {
+	serverVersion, err := rancherupgrade.GetServerVersion(client)
+	if err != nil {
+		return nil, err
+	}
+
+	upgradeConfig := rancherupgrade.LoadConfig()
+	upgradeConfig.Version = serverVersion
+	upgradeConfig.Values = map[string]string{
+		auditLevelValueKey:  strconv.Itoa(level),
+		auditDestinationKey: sidecarDestination,
+	}
+
+	logrus.Infof("Setting the rancher audit log level to %d", level)
+
+	updatedClient, err := rancherupgrade.UpgradeRancher(client, upgradeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set the rancher audit log level to %d: %w", level, err)
+	}
+
+	return updatedClient, nil
+}
@@ -0,0 +1,193 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	catalogv1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const clientSecretNamespace = "cattle-system"
+
+// ClusterRepoOpts is a struct of the source of a cluster repository, either a helm repository or a branch of a git
+// repository.
+type ClusterRepoOpts struct {
+	Name string
+	// URL is the URL of the helm repository, e.g. https://charts.longhorn.io
+	URL string
+	// GitRepo is the URL of the git repository, e.g. https://github.com/rancher/ui-plugin-examples
+	GitRepo string
+	// GitBranch is the branch of the git repository, its default branch when empty
+	GitBranch string
+	// CABundle is the PEM encoded CA of the repository, for repositories with a private CA
+	CABundle []byte
+	// InsecureSkipTLSVerify disables the verification of the certificate of the repository
+	InsecureSkipTLSVerify bool
+	// ClientSecretName is the name of the secret in cattle-system with the credentials of the repository, of the
+	// kubernetes.io/basic-auth type, or kubernetes.io/tls for helm repositories and kubernetes.io/ssh-auth for git ones
+	ClientSecretName string
+}
+
+// spec is a private method that returns the repository spec of the options.
+func (o *ClusterRepoOpts) spec() catalogv1.RepoSpec {
+	spec := catalogv1.RepoSpec{
+		URL:                   o.URL,
+		GitRepo:               o.GitRepo,
+		GitBranch:             o.GitBranch,
+		CABundle:              o.CABundle,
+		InsecureSkipTLSverify: o.InsecureSkipTLSVerify,
+	}
+
+	if o.ClientSecretName != "" {
+		spec.ClientSecret = &catalogv1.SecretReference{Name: o.ClientSecretName, Namespace: clientSecretNamespace}
+	}
+
+	return spec
+}
+
+// CreateClusterRepo is a helper function that creates the cluster repository of the options in the cluster, e.g. to
+// install charts with a ChartInstallOptions RepoName of its name, and waits until its index is downloaded. A repository
+// of the same name that already exists, e.g. one created by a previous suite, is reused and left in place; otherwise the
+// repository is deleted by the session of the client.
+func CreateClusterRepo(client *rancher.Client, clusterID string, clusterRepoOpts *ClusterRepoOpts) (*catalogv1.ClusterRepo, error) {
+	catalogClient, err := client.GetClusterCatalogClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = catalogClient.ClusterRepos().Create(context.TODO(), &catalogv1.ClusterRepo{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterRepoOpts.Name},
+		Spec:       clusterRepoOpts.spec(),
+	}, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return nil, err
+	}
+
+	if err == nil {
+		client.Session.RegisterCleanupFunc(func() error {
+			return DeleteClusterRepo(client, clusterID, clusterRepoOpts.Name)
+		})
+	}
+
+	return WaitForClusterRepoDownload(client, clusterID, clusterRepoOpts.Name, time.Time{})
+}
+
+// UpdateClusterRepo is a helper function that updates the spec of the cluster repository with the mutation, e.g. to
+// switch it to another git branch, and waits until the index of the updated spec is downloaded. The previous spec is
+// restored by the session of the client.
+func UpdateClusterRepo(client *rancher.Client, clusterID, name string, mutate func(spec *catalogv1.RepoSpec)) (*catalogv1.ClusterRepo, error) {
+	catalogClient, err := client.GetClusterCatalogClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterRepo, err := catalogClient.ClusterRepos().Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	previousSpec := *clusterRepo.Spec.DeepCopy()
+	previousDownloadTime := clusterRepo.Status.DownloadTime
+	mutate(&clusterRepo.Spec)
+
+	// forcing the update makes rancher download the index again even when the mutation keeps the same source
+	clusterRepo.Spec.ForceUpdate = &metav1.Time{Time: time.Now()}
+
+	updated, err := catalogClient.ClusterRepos().Update(context.TODO(), clusterRepo, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		current, err := catalogClient.ClusterRepos().Get(context.TODO(), name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		current.Spec = previousSpec
+		_, err = catalogClient.ClusterRepos().Update(context.TODO(), current, metav1.UpdateOptions{})
+
+		return err
+	})
+
+	logrus.Infof("Updated cluster repository %s of cluster %s", name, clusterID)
+
+	return waitForClusterRepo(client, clusterID, name, func(clusterRepo *catalogv1.ClusterRepo) bool {
+		return clusterRepo.Status.ObservedGeneration >= updated.Generation && clusterRepo.Status.DownloadTime.After(previousDownloadTime.Time)
+	})
+}
+
+// DeleteClusterRepo is a helper function that deletes the cluster repository and waits until it is removed. A
+// repository that doesn't exist is not an error.
+func DeleteClusterRepo(client *rancher.Client, clusterID, name string) error {
+	catalogClient, err := client.GetClusterCatalogClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	err = catalogClient.ClusterRepos().Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Timeout(), true, func(ctx context.Context) (done bool, err error) {
+		_, err = catalogClient.ClusterRepos().Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+
+		return false, nil
+	})
+}
+
+// WaitForClusterRepoDownload is a helper function that waits until the index of the cluster repository is downloaded,
+// after the given time when it isn't zero, and returns the repository. It fails right away with the message of the
+// Downloaded condition when rancher can't download the index, e.g. for a wrong URL or branch.
+func WaitForClusterRepoDownload(client *rancher.Client, clusterID, name string, after time.Time) (*catalogv1.ClusterRepo, error) {
+	return waitForClusterRepo(client, clusterID, name, func(clusterRepo *catalogv1.ClusterRepo) bool {
+		downloadTime := clusterRepo.Status.DownloadTime
+		return !downloadTime.IsZero() && !downloadTime.Time.Before(after)
+	})
+}
+
+// waitForClusterRepo is a private helper function that waits until the cluster repository is downloaded as checked by
+// the function, failing on a false Downloaded condition.
+func waitForClusterRepo(client *rancher.Client, clusterID, name string, isDownloaded func(clusterRepo *catalogv1.ClusterRepo) bool) (*catalogv1.ClusterRepo, error) {
+	catalogClient, err := client.GetClusterCatalogClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	var clusterRepo *catalogv1.ClusterRepo
+	err = kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Timeout(), true, func(ctx context.Context) (done bool, err error) {
+		clusterRepo, err = catalogClient.ClusterRepos().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		for _, condition := range clusterRepo.Status.Conditions {
+			if condition.Type == string(catalogv1.RepoDownloaded) && condition.Status == corev1.ConditionFalse && condition.Message != "" {
+				return false, fmt.Errorf("cluster repository %s of cluster %s failed to download: %s", name, clusterID, condition.Message)
+			}
+		}
+
+		return isDownloaded(clusterRepo), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return clusterRepo, nil
+}
@@ -0,0 +1,197 @@
+package certrotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	apiv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/extensions/provisioning"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	namespace                     = "fleet-default"
+	provisioningSteveResourceType = "provisioning.cattle.io.cluster"
+	defaultRotationTimeout        = 30 * time.Minute
+)
+
+// ValidateFunc is the function type of the validations run after the certificates are rotated, e.g. that the charts of
+// the cluster and their proxied endpoints are still healthy.
+type ValidateFunc func(client *rancher.Client, clusterID string) error
+
+// RotateCertificates is a helper function that rotates the certificates of the cluster and waits until the rotation is
+// complete and the cluster is ready again. Without services all certificates are rotated, otherwise only the ones of the
+// given services, e.g. "etcd" or "kube-apiserver". RKE1 clusters rotate one service per rotation, so they are rotated in turn.
+func RotateCertificates(client *rancher.Client, clusterName string, services ...string) error {
+	clusterMeta, err := clusters.NewClusterMeta(client, clusterName)
+	if err != nil {
+		return err
+	}
+
+	if clusterMeta.Provider != clusters.KubernetesProviderRKE {
+		return rotateV2ProvCertificates(client, clusterName, services)
+	}
+
+	if len(services) == 0 {
+		return rotateRKE1Certificates(client, clusterMeta.ID, "")
+	}
+
+	for _, service := range services {
+		err = rotateRKE1Certificates(client, clusterMeta.ID, service)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RotateAndValidate is a helper function that rotates the certificates of the cluster as RotateCertificates does,
+// then runs the validations, returning the errors of all of them.
+func RotateAndValidate(client *rancher.Client, clusterName string, services []string, validations ...ValidateFunc) error {
+	err := RotateCertificates(client, clusterName, services...)
+	if err != nil {
+		return err
+	}
+
+	clusterID, err := clusters.GetClusterIDByName(client, clusterName)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, validate := range validations {
+		err = validate(client, clusterID)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// rotateV2ProvCertificates is a private helper function that bumps the certificate rotation generation of an RKE2 or K3s
+// cluster and waits for the control plane to report it and for the cluster to be ready.
+func rotateV2ProvCertificates(client *rancher.Client, clusterName string, services []string) error {
+	adminClient, err := rancher.NewClient(client.RancherConfig.AdminToken, client.Session)
+	if err != nil {
+		return err
+	}
+
+	id, err := clusters.GetV1ProvisioningClusterByName(client, clusterName)
+	if err != nil {
+		return err
+	}
+
+	cluster, err := adminClient.Steve.SteveType(provisioningSteveResourceType).ByID(id)
+	if err != nil {
+		return err
+	}
+
+	clusterSpec := &apiv1.ClusterSpec{}
+	err = v1.ConvertToK8sType(cluster.Spec, clusterSpec)
+	if err != nil {
+		return err
+	}
+
+	generation := int64(1)
+	if clusterSpec.RKEConfig.RotateCertificates != nil {
+		generation = clusterSpec.RKEConfig.RotateCertificates.Generation + 1
+	}
+
+	clusterSpec.RKEConfig.RotateCertificates = &rkev1.RotateCertificates{
+		Generation: generation,
+		Services:   services,
+	}
+
+	updatedCluster := *cluster
+	updatedCluster.Spec = *clusterSpec
+
+	_, err = adminClient.Steve.SteveType(provisioningSteveResourceType).Update(cluster, updatedCluster)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Rotating the certificates of cluster %s, generation %d", clusterName, generation)
+
+	kubeRKEClient, err := adminClient.GetKubeAPIRKEClient()
+	if err != nil {
+		return err
+	}
+
+	kubeProvisioningClient, err := adminClient.GetKubeAPIProvisioningClient()
+	if err != nil {
+		return err
+	}
+
+	isRotated := provisioning.CertRotationCompleteCheckFunc(generation)
+
+	var lastErr error
+	state := "not rotated"
+
+	return wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(defaultRotationTimeout), func(ctx context.Context) (done bool, err error) {
+		controlPlane, err := kubeRKEClient.RKEControlPlanes(namespace).Get(ctx, clusterName, metav1.GetOptions{})
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+
+		rotated, err := isRotated(watch.Event{Type: watch.Modified, Object: controlPlane})
+		if err != nil || !rotated {
+			lastErr = nil
+			state = "not rotated"
+			return false, err
+		}
+
+		provisioningCluster, err := kubeProvisioningClient.Clusters(namespace).Get(ctx, clusterName, metav1.GetOptions{})
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+
+		lastErr = nil
+		state = "rotated, cluster not ready"
+
+		return clusters.IsProvisioningClusterReady(watch.Event{Type: watch.Modified, Object: provisioningCluster})
+	}, func() string {
+		if lastErr != nil {
+			return fmt.Sprintf("certificates of cluster %s generation %d: last error: %v", clusterName, generation, lastErr)
+		}
+
+		return fmt.Sprintf("certificates of cluster %s generation %d: %s", clusterName, generation, state)
+	})
+}
+
+// rotateRKE1Certificates is a private helper function that rotates the certificates of the service of an RKE1 cluster,
+// all of them when the service is empty, and waits for the cluster to be active again.
+func rotateRKE1Certificates(client *rancher.Client, clusterID, service string) error {
+	cluster, err := client.Management.Cluster.ByID(clusterID)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Management.Cluster.ActionRotateCertificates(cluster, &management.RotateCertificateInput{CACertificates: false, Services: service})
+	if err != nil {
+		return fmt.Errorf("unable to rotate the certificates of service %q of cluster %s: %w", service, clusterID, err)
+	}
+
+	logrus.Infof("Rotating the certificates of cluster %s", clusterID)
+
+	adminClient, err := rancher.NewClient(client.RancherConfig.AdminToken, client.Session)
+	if err != nil {
+		return err
+	}
+
+	return clusters.WaitClusterToBeUpgraded(adminClient, clusterID)
+}
@@ -0,0 +1,147 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	actionsnodes "github.com/rancher/rancher/tests/v2/actions/nodes"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/pkg/nodes"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const localClusterID = "local"
+
+// KillPods is a helper function that deletes the pods of the namespace matching the label selector, e.g.
+// "app.kubernetes.io/name=prometheus", and returns the names of the deleted pods.
+func KillPods(client *rancher.Client, clusterID, namespace, labelSelector string) ([]string, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	podResource := dynamicClient.Resource(actionscharts.PodGroupVersionResource).Namespace(namespace)
+
+	podList, err := podResource.List(context.TODO(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(podList.Items) == 0 {
+		return nil, fmt.Errorf("no pods in namespace %s match %q", namespace, labelSelector)
+	}
+
+	var killedPods []string
+	for _, pod := range podList.Items {
+		logrus.Infof("Killing pod %s/%s", namespace, pod.GetName())
+
+		err = podResource.Delete(context.TODO(), pod.GetName(), metav1.DeleteOptions{})
+		if err != nil {
+			return killedPods, err
+		}
+
+		killedPods = append(killedPods, pod.GetName())
+	}
+
+	return killedPods, nil
+}
+
+// WaitForPodsRecovery is a helper function that waits until the killed pods are replaced: none of them is left, at
+// least as many pods match the label selector, and all of them are ready. It returns the time the recovery took, or an
+// error if it took longer than the SLO.
+func WaitForPodsRecovery(client *rancher.Client, clusterID, namespace, labelSelector string, killedPods []string, slo time.Duration) (time.Duration, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return 0, err
+	}
+
+	podResource := dynamicClient.Resource(actionscharts.PodGroupVersionResource).Namespace(namespace)
+
+	killed := map[string]bool{}
+	for _, name := range killedPods {
+		killed[name] = true
+	}
+
+	start := time.Now()
+	err = kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), slo, true, func(ctx context.Context) (done bool, err error) {
+		podList, err := podResource.List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+		if err != nil {
+			return false, nil
+		}
+
+		if len(podList.Items) < len(killedPods) {
+			return false, nil
+		}
+
+		for _, unstructuredPod := range podList.Items {
+			if killed[unstructuredPod.GetName()] {
+				return false, nil
+			}
+
+			pod := &corev1.Pod{}
+			err = v1.ConvertToK8sType(unstructuredPod.Object, pod)
+			if err != nil {
+				return false, err
+			}
+
+			if !isPodReady(pod) {
+				return false, nil
+			}
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		return time.Since(start), fmt.Errorf("pods of namespace %s matching %q did not recover within %s: %w", namespace, labelSelector, slo, err)
+	}
+
+	return time.Since(start), nil
+}
+
+// KillPodsAndWaitForRecovery is a helper function that kills the pods matching the label selector and waits for their
+// recovery within the SLO.
+func KillPodsAndWaitForRecovery(client *rancher.Client, clusterID, namespace, labelSelector string, slo time.Duration) error {
+	killedPods, err := KillPods(client, clusterID, namespace, labelSelector)
+	if err != nil {
+		return err
+	}
+
+	recoveryTime, err := WaitForPodsRecovery(client, clusterID, namespace, labelSelector, killedPods, slo)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Pods of namespace %s matching %q recovered in %s", namespace, labelSelector, recoveryTime.Round(time.Second))
+
+	return nil
+}
+
+// RestartNode is a helper function that reboots the node over SSH and waits for it to rejoin the cluster with a new
+// boot ID and to be ready again within the SLO. The SSH node can be built with sshkeys.GetSSHNodeFromMachine. Use
+// nodes.RebootNode to wait for the system pods of the node as well.
+func RestartNode(client *rancher.Client, clusterID, nodeName string, sshNode *nodes.Node, slo time.Duration) error {
+	_, err := actionsnodes.RebootNode(client, clusterID, nodeName, &actionsnodes.SSHRebooter{Node: sshNode}, &actionsnodes.RebootOptions{
+		Namespaces: []string{},
+		SLO:        slo,
+	})
+
+	return err
+}
+
+// isPodReady is a private helper function that checks the ready condition of the pod.
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
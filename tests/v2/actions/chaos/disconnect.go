@@ -0,0 +1,220 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/downstream"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/sirupsen/logrus"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	clusterAgentNamespace  = "cattle-system"
+	clusterAgentDeployment = "cattle-cluster-agent"
+	activeClusterState     = "active"
+)
+
+// AgentDisconnection is a struct of a cluster whose agent is disconnected by DisconnectClusterAgent.
+type AgentDisconnection struct {
+	ClusterID string
+	// Replicas are the replicas of the agent before the disconnection
+	Replicas int32
+	// clientset reaches the cluster through its authorized cluster endpoint. It is kept for the reconnection, as the
+	// token of a kubeconfig generated while the agent is down is not synced to the cluster.
+	clientset *kubernetes.Clientset
+}
+
+// DisconnectClusterAgent is a helper function that scales the cattle-cluster-agent deployment of the downstream cluster
+// to zero and waits for rancher to report the cluster as not active within the SLO. Once the agent is down rancher can't
+// reach the cluster, so the agent is scaled through the authorized cluster endpoint, which must be enabled on the
+// cluster. A cleanup restores the replicas, so a failing suite doesn't leave the cluster disconnected.
+func DisconnectClusterAgent(client *rancher.Client, clusterID string, slo time.Duration) (*AgentDisconnection, error) {
+	if clusterID == localClusterID {
+		return nil, errors.New("the agent of the local cluster can't be disconnected")
+	}
+
+	clientset, err := getDirectClientset(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	scale, err := clientset.AppsV1().Deployments(clusterAgentNamespace).GetScale(context.TODO(), clusterAgentDeployment, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := scale.Spec.Replicas
+	if replicas == 0 {
+		return nil, fmt.Errorf("%s of cluster %s is already scaled to zero", clusterAgentDeployment, clusterID)
+	}
+
+	disconnection := &AgentDisconnection{
+		ClusterID: clusterID,
+		Replicas:  replicas,
+		clientset: clientset,
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		current, err := clientset.AppsV1().Deployments(clusterAgentNamespace).GetScale(context.TODO(), clusterAgentDeployment, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if current.Spec.Replicas != 0 {
+			return nil
+		}
+
+		return scaleClusterAgent(clientset, replicas)
+	})
+
+	logrus.Infof("Disconnecting the agent of cluster %s, scaling %s from %d replicas to zero", clusterID, clusterAgentDeployment, replicas)
+
+	err = scaleClusterAgent(clientset, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var state string
+	err = kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), slo, true, func(context.Context) (done bool, err error) {
+		cluster, err := client.Management.Cluster.ByID(clusterID)
+		if err != nil {
+			return false, nil
+		}
+
+		state = cluster.State
+
+		return state != activeClusterState, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cluster %s is still %s within %s of the disconnection of its agent: %w", clusterID, state, slo, err)
+	}
+
+	logrus.Infof("Cluster %s is %s", clusterID, state)
+
+	return disconnection, nil
+}
+
+// ReconnectClusterAgent is a helper function that scales the cattle-cluster-agent deployment of the disconnected cluster
+// back to its replicas and waits for the cluster to be active and reachable through the rancher proxy again within the
+// SLO. It returns the time the reconnection took.
+func ReconnectClusterAgent(client *rancher.Client, disconnection *AgentDisconnection, slo time.Duration) (time.Duration, error) {
+	clusterID := disconnection.ClusterID
+
+	logrus.Infof("Reconnecting the agent of cluster %s, scaling %s to %d replicas", clusterID, clusterAgentDeployment, disconnection.Replicas)
+
+	err := scaleClusterAgent(disconnection.clientset, disconnection.Replicas)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	var lastErr error
+	err = kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), slo, true, func(ctx context.Context) (done bool, err error) {
+		cluster, err := client.Management.Cluster.ByID(clusterID)
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+
+		if cluster.State != activeClusterState {
+			lastErr = fmt.Errorf("cluster is %s", cluster.State)
+			return false, nil
+		}
+
+		dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+
+		_, err = dynamicClient.Resource(actionscharts.PodGroupVersionResource).Namespace(clusterAgentNamespace).List(ctx, metav1.ListOptions{Limit: 1})
+		if err != nil {
+			lastErr = fmt.Errorf("cluster is not reachable through the proxy: %w", err)
+			return false, nil
+		}
+
+		lastErr = nil
+		return true, nil
+	})
+	if err != nil {
+		return time.Since(start), fmt.Errorf("cluster %s did not reconnect within %s: %w, last error: %v", clusterID, slo, err, lastErr)
+	}
+
+	return time.Since(start), nil
+}
+
+// AssertEndpointUnavailable is a helper function that checks the endpoint of the options, e.g. grafana proxied by
+// rancher while the cluster is disconnected, fails gracefully: rancher responds with an error within the budget,
+// instead of a healthy response or a request hanging until its timeout.
+func AssertEndpointUnavailable(client *rancher.Client, options *actionscharts.EndpointOptions, budget time.Duration) error {
+	start := time.Now()
+
+	result, err := actionscharts.GetEndpoint(client, options)
+	elapsed := time.Since(start)
+	if err != nil {
+		return fmt.Errorf("request to %s failed without a response after %s: %w", options.Path, elapsed.Round(time.Millisecond), err)
+	}
+
+	if result.Ok {
+		return fmt.Errorf("%s is still available", options.Path)
+	}
+
+	if elapsed > budget {
+		return fmt.Errorf("%s failed after %s, exceeding its budget of %s", options.Path, elapsed.Round(time.Millisecond), budget)
+	}
+
+	logrus.Infof("%s failed gracefully in %s", options.Path, elapsed.Round(time.Millisecond))
+
+	return nil
+}
+
+// WaitForEndpointRecovery is a helper function that waits until the endpoint of the options responds healthy again
+// within the SLO, e.g. after the reconnection of the cluster, and returns the time the recovery took.
+func WaitForEndpointRecovery(client *rancher.Client, options *actionscharts.EndpointOptions, slo time.Duration) (time.Duration, error) {
+	start := time.Now()
+	err := kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), slo, true, func(context.Context) (done bool, err error) {
+		result, err := actionscharts.GetEndpoint(client, options)
+		if err != nil {
+			return false, nil
+		}
+
+		return result.Ok, nil
+	})
+	if err != nil {
+		return time.Since(start), fmt.Errorf("%s did not recover within %s: %w", options.Path, slo, err)
+	}
+
+	return time.Since(start), nil
+}
+
+// getDirectClientset is a private helper function that returns a clientset of the downstream cluster reaching it through
+// its authorized cluster endpoint, in the context of the downstream access configuration.
+func getDirectClientset(client *rancher.Client, clusterID string) (*kubernetes.Clientset, error) {
+	restConfig, err := downstream.GetDirectRestConfig(client, clusterID, downstream.GetConfig().Context)
+	if err != nil {
+		return nil, err
+	}
+
+	return kubernetes.NewForConfig(restConfig)
+}
+
+// scaleClusterAgent is a private helper function that sets the replicas of the cattle-cluster-agent deployment.
+func scaleClusterAgent(clientset *kubernetes.Clientset, replicas int32) error {
+	scale := &autoscalingv1.Scale{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterAgentDeployment, Namespace: clusterAgentNamespace},
+		Spec:       autoscalingv1.ScaleSpec{Replicas: replicas},
+	}
+
+	_, err := clientset.AppsV1().Deployments(clusterAgentNamespace).UpdateScale(context.TODO(), clusterAgentDeployment, scale, metav1.UpdateOptions{})
+
+	return err
+}
@@ -0,0 +1,149 @@
+package charts
+
+import (
+	"fmt"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/charts"
+)
+
+const (
+	// RancherAlertingDriversName is the name of the rancher-alerting-drivers chart and of its release
+	RancherAlertingDriversName = "rancher-alerting-drivers"
+	// RancherAlertingDriversNamespace is the namespace rancher installs the rancher-alerting-drivers chart in, the one of
+	// rancher-monitoring, so alertmanager reaches the drivers without a network policy
+	RancherAlertingDriversNamespace = charts.RancherMonitoringNamespace
+
+	prom2TeamsDriver      = "prom2teams"
+	sachetDriver          = "sachet"
+	prom2TeamsPort        = 8089
+	sachetPort            = 9876
+	defaultTeamsConnector = "Connector"
+)
+
+// AlertingDriversOpts is a struct of the drivers of the rancher-alerting-drivers chart, which translate the webhook
+// notifications of alertmanager to receivers it has no integration for.
+type AlertingDriversOpts struct {
+	// Prom2Teams enables the driver posting the alerts to Microsoft Teams
+	Prom2Teams bool
+	// TeamsConnectors are the incoming webhook URLs of the Teams channels by connector name, e.g.
+	// {"Connector": "https://example.webhook.office.com/webhookb2/..."}
+	TeamsConnectors map[string]string
+	// Sachet enables the driver sending the alerts as SMS
+	Sachet bool
+	// SachetProviders are the configurations of the SMS providers by provider name, e.g.
+	// {"twilio": {"account_sid": "...", "auth_token": "..."}}
+	SachetProviders map[string]interface{}
+	// SachetReceivers are the receivers of the SMS, e.g. [{"name": "team-sms", "provider": "twilio", "to": ["+123"]}]
+	SachetReceivers []map[string]interface{}
+}
+
+// Values returns the rancher-alerting-drivers values enabling the drivers of the options. The configuration of a
+// disabled driver is left out, so it keeps its chart defaults.
+func (o *AlertingDriversOpts) Values() map[string]interface{} {
+	prom2TeamsValues := map[string]interface{}{
+		"enabled": o.Prom2Teams,
+	}
+	if o.Prom2Teams && len(o.TeamsConnectors) > 0 {
+		connectors := map[string]interface{}{}
+		for name, url := range o.TeamsConnectors {
+			connectors[name] = url
+		}
+
+		prom2TeamsValues[prom2TeamsDriver] = map[string]interface{}{
+			"connectors": connectors,
+		}
+	}
+
+	sachetValues := map[string]interface{}{
+		"enabled": o.Sachet,
+	}
+	if o.Sachet {
+		if len(o.SachetProviders) > 0 {
+			sachetValues["providers"] = o.SachetProviders
+		}
+
+		if len(o.SachetReceivers) > 0 {
+			receivers := make([]interface{}, 0, len(o.SachetReceivers))
+			for _, receiver := range o.SachetReceivers {
+				receivers = append(receivers, receiver)
+			}
+
+			sachetValues["receivers"] = receivers
+		}
+	}
+
+	return map[string]interface{}{
+		prom2TeamsDriver: prom2TeamsValues,
+		sachetDriver:     sachetValues,
+	}
+}
+
+// InstallRancherAlertingDriversChart is a helper function that installs the rancher-alerting-drivers chart with the
+// drivers of the options and waits until their deployments are ready. When the operation options stream the logs, the
+// logs of its helm operations are written to the test log while it installs. The chart is uninstalled by the session
+// of the client.
+func InstallRancherAlertingDriversChart(client *rancher.Client, installOptions *charts.InstallOptions, alertingDriversOpts *AlertingDriversOpts, operationOptions *OperationOptions) error {
+	err := InstallChart(client, &ChartInstallOptions{
+		InstallOptions: installOptions,
+		ChartName:      RancherAlertingDriversName,
+		Namespace:      RancherAlertingDriversNamespace,
+		StreamLogs:     operationOptions.GetStreamLogs(),
+	}, alertingDriversOpts.Values())
+	if err != nil {
+		return err
+	}
+
+	return waitAlertingDrivers(client, installOptions.Cluster.ID, alertingDriversOpts)
+}
+
+// SetAlertingDrivers is a helper function that upgrades the values of the installed rancher-alerting-drivers chart to
+// enable and disable the drivers of the options, and waits until the enabled ones are ready. The previous drivers are
+// restored by the session of the client.
+func SetAlertingDrivers(client *rancher.Client, clusterID string, alertingDriversOpts *AlertingDriversOpts) error {
+	err := UpgradeReleaseValues(client, clusterID, RancherAlertingDriversNamespace, RancherAlertingDriversName, alertingDriversOpts.Values())
+	if err != nil {
+		return err
+	}
+
+	return waitAlertingDrivers(client, clusterID, alertingDriversOpts)
+}
+
+// Prom2TeamsWebhookURL returns the in-cluster URL an alertmanager webhook receiver posts the alerts to for the prom2teams
+// driver to forward them to the Teams connector, the default connector of the chart when empty.
+func Prom2TeamsWebhookURL(connector string) string {
+	if connector == "" {
+		connector = defaultTeamsConnector
+	}
+
+	return fmt.Sprintf("http://%s.%s.svc:%d/v2/%s", alertingDriverName(prom2TeamsDriver), RancherAlertingDriversNamespace, prom2TeamsPort, connector)
+}
+
+// SachetWebhookURL returns the in-cluster URL an alertmanager webhook receiver posts the alerts to for the sachet driver
+// to send them as SMS to the sachet receiver of the same name as the alertmanager receiver.
+func SachetWebhookURL() string {
+	return fmt.Sprintf("http://%s.%s.svc:%d/alert", alertingDriverName(sachetDriver), RancherAlertingDriversNamespace, sachetPort)
+}
+
+// waitAlertingDrivers is a private helper function that waits until the deployments of the enabled drivers are ready.
+func waitAlertingDrivers(client *rancher.Client, clusterID string, alertingDriversOpts *AlertingDriversOpts) error {
+	var expectedDeployments []ExpectedDeployment
+	if alertingDriversOpts.Prom2Teams {
+		expectedDeployments = append(expectedDeployments, ExpectedDeployment{Name: alertingDriverName(prom2TeamsDriver)})
+	}
+
+	if alertingDriversOpts.Sachet {
+		expectedDeployments = append(expectedDeployments, ExpectedDeployment{Name: alertingDriverName(sachetDriver)})
+	}
+
+	if len(expectedDeployments) == 0 {
+		return nil
+	}
+
+	return WatchAndWaitDeploymentsByName(client, clusterID, RancherAlertingDriversNamespace, expectedDeployments)
+}
+
+// alertingDriverName is a private helper function that returns the name of the deployment and the service of a driver.
+func alertingDriverName(driver string) string {
+	return RancherAlertingDriversName + "-" + driver
+}
@@ -0,0 +1,202 @@
+package charts
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/rancher/rancher/tests/v2/actions/nodeos"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// The json/yaml config key for the architectures supported by the charts
+const ChartArchitecturesConfigurationFileKey = "chartArchitectures"
+
+const (
+	// AMD64 is the kubernetes.io/arch label value of x86-64 nodes
+	AMD64 = "amd64"
+	// ARM64 is the kubernetes.io/arch label value of 64-bit ARM nodes
+	ARM64 = "arm64"
+)
+
+// ChartArchitecturesConfig is the configuration of the architectures supported by the charts that don't support all of
+// them, and of the values the chart installers set on the clusters with nodes of an architecture, e.g. the images of a
+// chart that are only published with per architecture tags
+//
+//	chartArchitectures:
+//	  charts:
+//	    example-chart: [amd64]
+//	  values:
+//	    other-chart:
+//	      arm64:
+//	        image:
+//	          tag: v1.0.0-arm64
+type ChartArchitecturesConfig struct {
+	Charts map[string][]string                          `json:"charts" yaml:"charts"`
+	Values map[string]map[string]map[string]interface{} `json:"values" yaml:"values"`
+}
+
+var (
+	chartArchitecturesOnce  sync.Once
+	chartArchitecturesMutex sync.RWMutex
+	// chartArchitectures are the architectures supported by the charts that don't support all of them, charts that
+	// are not in the map are considered to support every architecture
+	chartArchitectures = map[string][]string{}
+	// chartArchitectureValues are the values of the charts per architecture
+	chartArchitectureValues = map[string]map[string]map[string]interface{}{}
+)
+
+// SetChartArchitectures is a helper function that records the architectures the chart supports, overriding the ones of the
+// configuration file.
+func SetChartArchitectures(chartName string, architectures ...string) {
+	loadChartArchitectures()
+
+	chartArchitecturesMutex.Lock()
+	defer chartArchitecturesMutex.Unlock()
+
+	chartArchitectures[chartName] = architectures
+}
+
+// SetChartArchitectureValues is a helper function that records the values the chart installers set on the chart in the
+// clusters with nodes of the architecture, overriding the ones of the configuration file.
+func SetChartArchitectureValues(chartName, architecture string, values map[string]interface{}) {
+	loadChartArchitectures()
+
+	chartArchitecturesMutex.Lock()
+	defer chartArchitecturesMutex.Unlock()
+
+	if chartArchitectureValues[chartName] == nil {
+		chartArchitectureValues[chartName] = map[string]map[string]interface{}{}
+	}
+
+	chartArchitectureValues[chartName][architecture] = values
+}
+
+// GetArchitectureValues is a helper function that returns the values of the chart for the architectures of the cluster
+// nodes, deeply merged in the order of the architectures, or nil when the chart has none.
+func GetArchitectureValues(client *rancher.Client, clusterID, chartName string) (map[string]interface{}, error) {
+	loadChartArchitectures()
+
+	chartArchitecturesMutex.RLock()
+	architectureValues := chartArchitectureValues[chartName]
+	chartArchitecturesMutex.RUnlock()
+
+	if len(architectureValues) == 0 {
+		return nil, nil
+	}
+
+	nodeArchitectures, err := nodeos.GetNodeArchitectures(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	architectures := make([]string, 0, len(nodeArchitectures))
+	for architecture := range nodeArchitectures {
+		architectures = append(architectures, architecture)
+	}
+
+	sort.Strings(architectures)
+
+	var values map[string]interface{}
+	for _, architecture := range architectures {
+		if architectureValues[architecture] != nil {
+			values = mergeValues(values, architectureValues[architecture])
+		}
+	}
+
+	return values, nil
+}
+
+// ArchitectureValues is a helper function that returns the chart values scheduling the chart workloads on nodes of the
+// given architecture, for charts following the common top level nodeSelector convention.
+func ArchitectureValues(architecture string) map[string]interface{} {
+	return map[string]interface{}{
+		"nodeSelector": map[string]interface{}{
+			corev1.LabelArchStable: architecture,
+		},
+	}
+}
+
+// ArchitectureImageTag is a helper function that returns the image tag of the architecture for images that are
+// published with per architecture tags instead of a multi-arch manifest, e.g. v1.0.0-arm64.
+func ArchitectureImageTag(tag, architecture string) string {
+	if architecture == "" || architecture == AMD64 {
+		return tag
+	}
+
+	return fmt.Sprintf("%s-%s", tag, architecture)
+}
+
+// GetUnsupportedArchitectures is a helper function that returns the architectures of the cluster nodes the chart doesn't support.
+func GetUnsupportedArchitectures(client *rancher.Client, clusterID, chartName string) ([]string, error) {
+	loadChartArchitectures()
+
+	chartArchitecturesMutex.RLock()
+	supportedArchitectures, ok := chartArchitectures[chartName]
+	chartArchitecturesMutex.RUnlock()
+
+	if !ok {
+		return nil, nil
+	}
+
+	nodeArchitectures, err := nodeos.GetNodeArchitectures(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	var unsupportedArchitectures []string
+	for architecture := range nodeArchitectures {
+		if !slices.Contains(supportedArchitectures, architecture) {
+			unsupportedArchitectures = append(unsupportedArchitectures, architecture)
+		}
+	}
+
+	sort.Strings(unsupportedArchitectures)
+
+	return unsupportedArchitectures, nil
+}
+
+// SkipUnsupportedChart is a helper function that skips the test when the cluster has nodes of an architecture the chart
+// doesn't support, e.g. an arm64 cluster and a chart that only ships amd64 images.
+func SkipUnsupportedChart(t *testing.T, client *rancher.Client, clusterID, chartName string) {
+	unsupportedArchitectures, err := GetUnsupportedArchitectures(client, clusterID, chartName)
+	if err != nil {
+		t.Fatalf("failed to get the node architectures of cluster %s: %v", clusterID, err)
+	}
+
+	if len(unsupportedArchitectures) > 0 {
+		t.Skipf("Chart %s doesn't support the %v nodes of cluster %s", chartName, unsupportedArchitectures, clusterID)
+	}
+}
+
+// loadChartArchitectures is a private function that reads the configuration file once.
+func loadChartArchitectures() {
+	chartArchitecturesOnce.Do(func() {
+		architecturesConfig := new(ChartArchitecturesConfig)
+		config.LoadConfig(ChartArchitecturesConfigurationFileKey, architecturesConfig)
+
+		chartArchitecturesMutex.Lock()
+		defer chartArchitecturesMutex.Unlock()
+
+		for chartName, architectures := range architecturesConfig.Charts {
+			chartArchitectures[chartName] = architectures
+		}
+
+		for chartName, architectureValues := range architecturesConfig.Values {
+			chartArchitectureValues[chartName] = architectureValues
+		}
+
+		if len(architecturesConfig.Charts) > 0 {
+			logrus.Infof("Loaded supported architectures of %d charts", len(architecturesConfig.Charts))
+		}
+
+		if len(architecturesConfig.Values) > 0 {
+			logrus.Infof("Loaded architecture values of %d charts", len(architecturesConfig.Values))
+		}
+	})
+}
@@ -0,0 +1,453 @@
+package charts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/kubeapi/workloads/daemonsets"
+	"github.com/rancher/shepherd/extensions/kubeapi/workloads/deployments"
+	"github.com/rancher/shepherd/pkg/api/scheme"
+	"github.com/sirupsen/logrus"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// StatefulSetGroupVersionResource is the required Group Version Resource for accessing statefulsets in a cluster,
+// using the dynamic client.
+var StatefulSetGroupVersionResource = appv1.SchemeGroupVersion.WithResource("statefulsets")
+
+// PodGroupVersionResource is the required Group Version Resource for accessing pods in a cluster, using the dynamic client.
+var PodGroupVersionResource = corev1.SchemeGroupVersion.WithResource("pods")
+
+// errWatchClosed is returned by the private watch helpers when the watch ends before all workloads are ready,
+// which is the signal to list and watch the workloads again.
+var errWatchClosed = errors.New("watch closed before all workloads were ready")
+
+// workloadReadyFunc is the function type used to check if a single workload is ready.
+type workloadReadyFunc func(workload *unstructured.Unstructured) (bool, error)
+
+// namespaceWorkloadResources are the resources of the workloads WatchAndWaitWorkloads waits for, with the check of
+// their readiness.
+var namespaceWorkloadResources = []struct {
+	groupVersionResource schema.GroupVersionResource
+	isReady              workloadReadyFunc
+}{
+	{groupVersionResource: deployments.DeploymentGroupVersionResource, isReady: isDeploymentReady},
+	{groupVersionResource: daemonsets.DaemonSetGroupVersionResource, isReady: isDaemonSetReady},
+	{groupVersionResource: StatefulSetGroupVersionResource, isReady: isStatefulSetReady},
+}
+
+// WatchAndWaitDeployments is a helper function that watches the deployments in a specific namespace with a single
+// watch and waits until number of expected replicas is equal to number of available replicas for all of them.
+// If the watch can't be established or is closed by the server, the deployments are listed and watched again.
+func WatchAndWaitDeployments(client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions) error {
+	return WatchAndWaitDeploymentsWithOptions(client, clusterID, namespace, listOptions, nil)
+}
+
+// WatchAndWaitDeploymentsWithOptions is a helper function that behaves as WatchAndWaitDeployments, using the poll interval and
+// timeout of the wait options instead of the defaults of the timeouts package.
+func WatchAndWaitDeploymentsWithOptions(client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions, waitOptions *timeouts.Options) error {
+	return watchAndWaitWorkloads(context.Background(), client, clusterID, namespace, listOptions, deployments.DeploymentGroupVersionResource, isDeploymentReady, newWaitOptions(waitOptions))
+}
+
+// WatchAndWaitDaemonSets is a helper function that watches the DaemonSets in a specific namespace with a single
+// watch and waits until number of available DaemonSets is equal to number of desired scheduled DaemonSets for all of them.
+// If the watch can't be established or is closed by the server, the DaemonSets are listed and watched again.
+func WatchAndWaitDaemonSets(client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions) error {
+	return WatchAndWaitDaemonSetsWithOptions(client, clusterID, namespace, listOptions, nil)
+}
+
+// WatchAndWaitDaemonSetsWithOptions is a helper function that behaves as WatchAndWaitDaemonSets, using the poll interval and
+// timeout of the wait options instead of the defaults of the timeouts package.
+func WatchAndWaitDaemonSetsWithOptions(client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions, waitOptions *timeouts.Options) error {
+	return watchAndWaitWorkloads(context.Background(), client, clusterID, namespace, listOptions, daemonsets.DaemonSetGroupVersionResource, isDaemonSetReady, newWaitOptions(waitOptions))
+}
+
+// WatchAndWaitStatefulSets is a helper function that watches the StatefulSets in a specific namespace with a single
+// watch and waits until number of expected replicas is equal to number of ready replicas for all of them.
+// If the watch can't be established or is closed by the server, the StatefulSets are listed and watched again.
+func WatchAndWaitStatefulSets(client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions) error {
+	return WatchAndWaitStatefulSetsWithOptions(client, clusterID, namespace, listOptions, nil)
+}
+
+// WatchAndWaitStatefulSetsWithOptions is a helper function that behaves as WatchAndWaitStatefulSets, using the poll interval and
+// timeout of the wait options instead of the defaults of the timeouts package.
+func WatchAndWaitStatefulSetsWithOptions(client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions, waitOptions *timeouts.Options) error {
+	return watchAndWaitWorkloads(context.Background(), client, clusterID, namespace, listOptions, StatefulSetGroupVersionResource, isStatefulSetReady, newWaitOptions(waitOptions))
+}
+
+// ExpectedDeployment is a struct of a deployment a namespace is expected to have.
+type ExpectedDeployment struct {
+	// Name of the deployment
+	Name string
+	// MinAvailableReplicas is the number of available replicas the deployment needs to be ready, when it is 0 every
+	// desired replica must be available
+	MinAvailableReplicas int32
+}
+
+// WatchAndWaitDeploymentsByName is a helper function that watches the deployments in a specific namespace and waits until
+// each of the expected deployments exists and is ready, ignoring the other deployments of the namespace. Unlike
+// WatchAndWaitDeployments, an expected deployment that is missing, e.g. a component a chart version bump added, is
+// waited for instead of being silently ignored.
+func WatchAndWaitDeploymentsByName(client *rancher.Client, clusterID, namespace string, expectedDeployments []ExpectedDeployment) error {
+	return WatchAndWaitDeploymentsByNameWithOptions(client, clusterID, namespace, expectedDeployments, nil)
+}
+
+// WatchAndWaitDeploymentsByNameWithOptions is a helper function that behaves as WatchAndWaitDeploymentsByName, using the poll
+// interval and timeout of the wait options instead of the defaults of the timeouts package.
+func WatchAndWaitDeploymentsByNameWithOptions(client *rancher.Client, clusterID, namespace string, expectedDeployments []ExpectedDeployment, waitOptions *timeouts.Options) error {
+	adminDynamicClient, err := getAdminDynamicClient(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	minAvailableReplicas := map[string]int32{}
+	requiredWorkloads := map[string]bool{}
+	for _, expectedDeployment := range expectedDeployments {
+		minAvailableReplicas[expectedDeployment.Name] = expectedDeployment.MinAvailableReplicas
+		requiredWorkloads[expectedDeployment.Name] = true
+	}
+
+	isReady := func(workload *unstructured.Unstructured) (bool, error) {
+		minAvailable, ok := minAvailableReplicas[workload.GetName()]
+		if !ok {
+			return true, nil
+		}
+
+		if minAvailable == 0 {
+			return isDeploymentReady(workload)
+		}
+
+		deployment := &appv1.Deployment{}
+		err := scheme.Scheme.Convert(workload, deployment, workload.GroupVersionKind())
+		if err != nil {
+			return false, err
+		}
+
+		return deployment.Status.AvailableReplicas >= minAvailable, nil
+	}
+
+	return waitResourceWorkloads(context.Background(), adminDynamicClient, namespace, metav1.ListOptions{}, deployments.DeploymentGroupVersionResource, isReady, requiredWorkloads, newWaitOptions(waitOptions))
+}
+
+// WatchAndWaitWorkloads is a helper function that waits for all the deployments, DaemonSets and StatefulSets in a
+// specific namespace to be ready, within a single timeout: their available or ready replicas must equal their desired
+// ones, as WatchAndWaitDeployments, WatchAndWaitDaemonSets and WatchAndWaitStatefulSets check. The namespace must have
+// at least one of them, so a wait on a namespace whose workloads are not created yet fails instead of passing.
+func WatchAndWaitWorkloads(client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions) error {
+	return WatchAndWaitWorkloadsWithOptions(client, clusterID, namespace, listOptions, nil)
+}
+
+// WatchAndWaitWorkloadsWithOptions is a helper function that behaves as WatchAndWaitWorkloads, using the poll interval and
+// timeout of the wait options instead of the defaults of the timeouts package.
+func WatchAndWaitWorkloadsWithOptions(client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions, waitOptions *timeouts.Options) error {
+	return watchAndWaitNamespaceWorkloads(context.Background(), client, clusterID, namespace, listOptions, newWaitOptions(waitOptions))
+}
+
+// watchAndWaitWorkloads is a private helper function that waits for all the workloads of a single resource type.
+func watchAndWaitWorkloads(ctx context.Context, client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions, groupVersionResource schema.GroupVersionResource, isReady workloadReadyFunc, waitOptions *WaitOptions) error {
+	adminDynamicClient, err := getAdminDynamicClient(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	return waitResourceWorkloads(ctx, adminDynamicClient, namespace, listOptions, groupVersionResource, isReady, nil, waitOptions)
+}
+
+// watchAndWaitNamespaceWorkloads is a private helper function that waits until the namespace has workloads, then for the
+// workloads of each of the namespace workload resources, sharing the timeout of the wait options.
+func watchAndWaitNamespaceWorkloads(ctx context.Context, client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions, waitOptions *WaitOptions) error {
+	adminDynamicClient, err := getAdminDynamicClient(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	timeout := waitOptions.timeouts().GetTimeout()
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	existOptions := listOptions
+	existOptions.Limit = 1
+
+	err = kwait.PollUntilContextCancel(waitCtx, waitOptions.timeouts().GetPollInterval(), true, func(ctx context.Context) (done bool, err error) {
+		for _, workloadResource := range namespaceWorkloadResources {
+			workloadList, err := adminDynamicClient.Resource(workloadResource.groupVersionResource).Namespace(namespace).List(ctx, existOptions)
+			if err == nil && len(workloadList.Items) > 0 {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	})
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("stopped waiting for workloads in namespace %s: %w", namespace, ctx.Err())
+	}
+
+	if err != nil {
+		return fmt.Errorf("timed out after %s waiting for deployments, DaemonSets or StatefulSets in namespace %s: none found", timeout, namespace)
+	}
+
+	for _, workloadResource := range namespaceWorkloadResources {
+		err = waitResourceWorkloads(waitCtx, adminDynamicClient, namespace, listOptions, workloadResource.groupVersionResource, workloadResource.isReady, nil, waitOptions)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getAdminDynamicClient is a private helper function that returns the downstream dynamic client of the admin user.
+func getAdminDynamicClient(client *rancher.Client, clusterID string) (dynamic.Interface, error) {
+	adminClient, err := rancher.NewClient(client.RancherConfig.AdminToken, client.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	return adminClient.GetDownStreamClusterClient(clusterID)
+}
+
+// waitResourceWorkloads is a private helper function that lists the workloads of the given resource, then watches the
+// namespace from the listed resource version until every workload is ready, within the timeout of the wait options. When
+// the watch can't be established or is closed by the server, the workloads are listed and watched again after a poll
+// interval. The required workloads, which may be nil, are waited for until they exist as well. The pending workloads
+// are reported to the progress function of the wait options while waiting. The wait ends early with the error of the
+// context when it is canceled.
+func waitResourceWorkloads(ctx context.Context, dynamicClient dynamic.Interface, namespace string, listOptions metav1.ListOptions, groupVersionResource schema.GroupVersionResource, isReady workloadReadyFunc, requiredWorkloads map[string]bool, waitOptions *WaitOptions) error {
+	adminResource := dynamicClient.Resource(groupVersionResource).Namespace(namespace)
+	timeout := waitOptions.timeouts().GetTimeout()
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	progress := newWorkloadsProgress(groupVersionResource.Resource, waitOptions)
+	pendingWorkloads := map[string]bool{}
+
+	for {
+		err := listAndWatchWorkloads(waitCtx, adminResource, listOptions, pendingWorkloads, isReady, requiredWorkloads, progress)
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return newWorkloadsCanceledError(ctx, groupVersionResource, namespace, pendingWorkloads)
+		}
+
+		if waitCtx.Err() != nil {
+			return newWorkloadsTimeoutError(groupVersionResource, namespace, timeout, pendingWorkloads)
+		}
+
+		if !errors.Is(err, errWatchClosed) {
+			return err
+		}
+
+		logrus.Debugf("Listing %s in namespace %s again: %v", groupVersionResource.Resource, namespace, err)
+
+		select {
+		case <-waitCtx.Done():
+		case <-time.After(waitOptions.timeouts().GetPollInterval()):
+		}
+	}
+}
+
+// listAndWatchWorkloads is a private helper function that replaces the pending workloads by the listed workloads that
+// are not ready and the required workloads that are missing, then watches them from the listed resource version until
+// all of them are ready. It returns errWatchClosed when the list or the watch fails, or when the watch ends first, e.g.
+// when the context is done.
+func listAndWatchWorkloads(ctx context.Context, resource dynamic.ResourceInterface, listOptions metav1.ListOptions, pendingWorkloads map[string]bool, isReady workloadReadyFunc, requiredWorkloads map[string]bool, progress *workloadsProgress) error {
+	workloadList, err := resource.List(ctx, listOptions)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errWatchClosed, err)
+	}
+
+	for name := range pendingWorkloads {
+		delete(pendingWorkloads, name)
+	}
+
+	for i := range workloadList.Items {
+		ready, err := isReady(&workloadList.Items[i])
+		if err != nil {
+			return err
+		}
+
+		if !ready {
+			pendingWorkloads[workloadList.Items[i].GetName()] = true
+		}
+	}
+
+	addMissingWorkloads(workloadList, requiredWorkloads, pendingWorkloads)
+
+	if len(pendingWorkloads) == 0 {
+		return nil
+	}
+
+	progress.report(pendingWorkloads, false)
+
+	watchOptions := listOptions
+	watchOptions.ResourceVersion = workloadList.GetResourceVersion()
+
+	watchInterface, err := resource.Watch(ctx, watchOptions)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errWatchClosed, err)
+	}
+
+	return waitWorkloadEvents(watchInterface, pendingWorkloads, isReady, requiredWorkloads, progress)
+}
+
+// waitWorkloadEvents is a private helper function that consumes the watch events until all pending workloads are ready.
+// Workloads that are added or become not ready while watching are added to the pending workloads, as are the required
+// workloads that are deleted. The pending workloads are reported to the progress at its interval, even when no event
+// comes.
+func waitWorkloadEvents(watchInterface watch.Interface, pendingWorkloads map[string]bool, isReady workloadReadyFunc, requiredWorkloads map[string]bool, progress *workloadsProgress) error {
+	defer watchInterface.Stop()
+
+	ticker := time.NewTicker(progress.interval)
+	defer ticker.Stop()
+
+	for {
+		var event watch.Event
+		var ok bool
+
+		select {
+		case <-ticker.C:
+			progress.report(pendingWorkloads, true)
+			continue
+		case event, ok = <-watchInterface.ResultChan():
+			if !ok {
+				return errWatchClosed
+			}
+		}
+
+		if event.Type == watch.Error {
+			return fmt.Errorf("%w: %v", errWatchClosed, event.Object)
+		}
+
+		workload, ok := event.Object.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+
+		if event.Type == watch.Deleted {
+			if requiredWorkloads[workload.GetName()] {
+				pendingWorkloads[workload.GetName()] = true
+			} else {
+				delete(pendingWorkloads, workload.GetName())
+			}
+		} else {
+			ready, err := isReady(workload)
+			if err != nil {
+				return err
+			}
+
+			// workloads created or rolled out after the list are waited for as well
+			if ready {
+				delete(pendingWorkloads, workload.GetName())
+			} else {
+				pendingWorkloads[workload.GetName()] = true
+			}
+		}
+
+		if len(pendingWorkloads) == 0 {
+			return nil
+		}
+	}
+}
+
+// addMissingWorkloads is a private helper function that adds the required workloads that are not in the list to the
+// pending workloads.
+func addMissingWorkloads(workloadList *unstructured.UnstructuredList, requiredWorkloads, pendingWorkloads map[string]bool) {
+	if len(requiredWorkloads) == 0 {
+		return
+	}
+
+	listedWorkloads := map[string]bool{}
+	for i := range workloadList.Items {
+		listedWorkloads[workloadList.Items[i].GetName()] = true
+	}
+
+	for name := range requiredWorkloads {
+		if !listedWorkloads[name] {
+			pendingWorkloads[name] = true
+		}
+	}
+}
+
+// newWorkloadsTimeoutError is a private helper function that returns the error of a wait that timed out, naming the
+// workloads that are still not ready.
+func newWorkloadsTimeoutError(groupVersionResource schema.GroupVersionResource, namespace string, timeout time.Duration, pendingWorkloads map[string]bool) error {
+	return fmt.Errorf("timed out after %s waiting for %s in namespace %s to be ready, still pending: %s", timeout, groupVersionResource.Resource, namespace, strings.Join(sortedNames(pendingWorkloads), ", "))
+}
+
+// newWorkloadsCanceledError is a private constructor that returns the error of a wait canceled by its context, wrapping
+// the error of the context and listing the workloads that were still pending.
+func newWorkloadsCanceledError(ctx context.Context, groupVersionResource schema.GroupVersionResource, namespace string, pendingWorkloads map[string]bool) error {
+	return fmt.Errorf("stopped waiting for %s in namespace %s to be ready, still pending: %s: %w", groupVersionResource.Resource, namespace, strings.Join(sortedNames(pendingWorkloads), ", "), ctx.Err())
+}
+
+// sortedNames is a private helper function that returns the sorted names of the workloads.
+func sortedNames(workloads map[string]bool) []string {
+	names := make([]string, 0, len(workloads))
+	for name := range workloads {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// isDeploymentReady is a private helper function that checks if number of expected replicas is equal to number of available replicas.
+func isDeploymentReady(workload *unstructured.Unstructured) (bool, error) {
+	deployment := &appv1.Deployment{}
+	err := scheme.Scheme.Convert(workload, deployment, workload.GroupVersionKind())
+	if err != nil {
+		return false, err
+	}
+
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+
+	return replicas == deployment.Status.AvailableReplicas, nil
+}
+
+// isDaemonSetReady is a private helper function that checks if number of available pods is equal to number of desired scheduled pods.
+func isDaemonSetReady(workload *unstructured.Unstructured) (bool, error) {
+	daemonSet := &appv1.DaemonSet{}
+	err := scheme.Scheme.Convert(workload, daemonSet, workload.GroupVersionKind())
+	if err != nil {
+		return false, err
+	}
+
+	return daemonSet.Status.DesiredNumberScheduled == daemonSet.Status.NumberAvailable, nil
+}
+
+// isStatefulSetReady is a private helper function that checks if number of expected replicas is equal to number of ready replicas.
+func isStatefulSetReady(workload *unstructured.Unstructured) (bool, error) {
+	statefulSet := &appv1.StatefulSet{}
+	err := scheme.Scheme.Convert(workload, statefulSet, workload.GroupVersionKind())
+	if err != nil {
+		return false, err
+	}
+
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+
+	return replicas == statefulSet.Status.ReadyReplicas, nil
+}
@@ -0,0 +1,159 @@
+package charts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/sirupsen/logrus"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// ClusterScopedGroupVersionResources are the cluster scoped resources charts install, e.g. their CRDs and cluster
+// roles, which the release resources are looked up in.
+var ClusterScopedGroupVersionResources = []schema.GroupVersionResource{
+	CustomResourceDefinitionGroupVersionResource,
+	NamespaceGroupVersionResource,
+	rbacv1.SchemeGroupVersion.WithResource("clusterroles"),
+	rbacv1.SchemeGroupVersion.WithResource("clusterrolebindings"),
+	admissionregistrationv1.SchemeGroupVersion.WithResource("validatingwebhookconfigurations"),
+	admissionregistrationv1.SchemeGroupVersion.WithResource("mutatingwebhookconfigurations"),
+	schedulingv1.SchemeGroupVersion.WithResource("priorityclasses"),
+	storagev1.SchemeGroupVersion.WithResource("storageclasses"),
+}
+
+// namespacedGroupVersionResources are the namespaced resources besides the workloads whose namespace is verified.
+var namespacedGroupVersionResources = []schema.GroupVersionResource{
+	PodGroupVersionResource,
+	corev1.SchemeGroupVersion.WithResource("services"),
+	corev1.SchemeGroupVersion.WithResource("serviceaccounts"),
+	corev1.SchemeGroupVersion.WithResource("configmaps"),
+	rbacv1.SchemeGroupVersion.WithResource("roles"),
+	rbacv1.SchemeGroupVersion.WithResource("rolebindings"),
+}
+
+// ReleaseScopes is a struct of the resources a release deployed, found by the helm release annotations, by scope.
+type ReleaseScopes struct {
+	// ClusterScoped are the cluster scoped resources, e.g. clusterroles/rancher-monitoring-admin
+	ClusterScoped []string
+	// Namespaced are the namespaced resources, e.g. deployments/cattle-monitoring-system/rancher-monitoring-operator
+	Namespaced []string
+}
+
+// VerifyReleaseScopes is a helper function that returns the cluster scoped and namespaced resources the release
+// deployed, and fails when namespaced ones are outside the namespace of the release and the allowed namespaces, e.g.
+// kube-system for the exporters of rancher-monitoring, so a chart installed with the wrong target is caught.
+func VerifyReleaseScopes(client *rancher.Client, clusterID, namespace, releaseName string, allowedNamespaces ...string) (*ReleaseScopes, error) {
+	adminDynamicClient, err := getAdminDynamicClient(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterScoped, err := getClusterScopedResources(adminDynamicClient, namespace, releaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	namespacedResources := slices.Clone(namespacedGroupVersionResources)
+	for _, workloadResource := range releaseWorkloadResources {
+		namespacedResources = append(namespacedResources, workloadResource.groupVersionResource)
+	}
+
+	scopes := &ReleaseScopes{}
+	for _, resource := range clusterScoped {
+		scopes.ClusterScoped = append(scopes.ClusterScoped, resource.String())
+	}
+
+	var errs []error
+	for _, groupVersionResource := range namespacedResources {
+		resourceList, err := adminDynamicClient.Resource(groupVersionResource).Namespace("").List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, resource := range resourceList.Items {
+			if !isReleaseResource(resource.GetAnnotations(), namespace, releaseName) {
+				continue
+			}
+
+			releaseResource := releaseResource{groupVersionResource, resource.GetNamespace(), resource.GetName()}
+			scopes.Namespaced = append(scopes.Namespaced, releaseResource.String())
+
+			if resource.GetNamespace() != namespace && !slices.Contains(allowedNamespaces, resource.GetNamespace()) {
+				errs = append(errs, fmt.Errorf("%s is outside namespace %s of release %s", releaseResource, namespace, releaseName))
+			}
+		}
+	}
+
+	sort.Strings(scopes.ClusterScoped)
+	sort.Strings(scopes.Namespaced)
+
+	return scopes, errors.Join(errs...)
+}
+
+// TrackClusterScopedResources is a helper function that registers the deletion of the cluster scoped resources of the
+// release that are left once it is uninstalled, e.g. the CRDs helm keeps, with the session of the client. The cleanup
+// runs in the reverse order it is registered in, so it is called before the chart is installed.
+func TrackClusterScopedResources(client *rancher.Client, clusterID, namespace, releaseName string) {
+	client.Session.RegisterCleanupFunc(func() error {
+		adminDynamicClient, err := getAdminDynamicClient(client, clusterID)
+		if err != nil {
+			return err
+		}
+
+		leftovers, err := getClusterScopedResources(adminDynamicClient, namespace, releaseName)
+		if err != nil {
+			return err
+		}
+
+		for _, resource := range leftovers {
+			err = adminDynamicClient.Resource(resource.groupVersionResource).Delete(context.TODO(), resource.name, metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to delete %s of release %s/%s: %w", resource, namespace, releaseName, err)
+			}
+		}
+
+		if len(leftovers) > 0 {
+			logrus.Infof("Deleted %d cluster scoped resources left by release %s/%s: %s", len(leftovers), namespace, releaseName, joinResources(leftovers))
+		}
+
+		return nil
+	})
+}
+
+// getClusterScopedResources is a private helper function that returns the cluster scoped resources the release
+// deployed.
+func getClusterScopedResources(dynamicClient dynamic.Interface, namespace, releaseName string) ([]releaseResource, error) {
+	var resources []releaseResource
+	for _, groupVersionResource := range ClusterScopedGroupVersionResources {
+		resourceList, err := dynamicClient.Resource(groupVersionResource).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, resource := range resourceList.Items {
+			if isReleaseResource(resource.GetAnnotations(), namespace, releaseName) {
+				resources = append(resources, releaseResource{groupVersionResource, "", resource.GetName()})
+			}
+		}
+	}
+
+	return resources, nil
+}
+
+// isReleaseResource is a private helper function that returns true when the helm release annotations are the ones of
+// the release.
+func isReleaseResource(annotations map[string]string, namespace, releaseName string) bool {
+	return annotations[helmReleaseNameAnnotation] == releaseName && annotations[helmReleaseNamespaceAnnotation] == namespace
+}
@@ -0,0 +1,196 @@
+package charts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	catalogv1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The json/yaml config key for the handling of helm operations that are in progress
+const ChartOperationsConfigurationFileKey = "chartOperations"
+
+// InFlightPolicy is what the chart installers do when a helm operation is in progress on the release they operate on.
+type InFlightPolicy string
+
+const (
+	// WaitInFlight queues the operation until the operations in progress are done, the default
+	WaitInFlight InFlightPolicy = "wait"
+	// FailFastInFlight fails the operation with ErrOperationInProgress
+	FailFastInFlight InFlightPolicy = "failFast"
+)
+
+// ErrOperationInProgress is returned by GuardReleaseOperation with the FailFastInFlight policy when a helm operation is
+// in progress on the release, by this process or by another suite running against the same cluster.
+var ErrOperationInProgress = errors.New("a helm operation is in progress on the release")
+
+// ChartOperationsConfig is the configuration of the handling of helm operations in progress, e.g.
+//
+//	chartOperations:
+//	  inFlightPolicy: failFast
+type ChartOperationsConfig struct {
+	InFlightPolicy InFlightPolicy `json:"inFlightPolicy" yaml:"inFlightPolicy"`
+}
+
+var (
+	inFlightPolicyOnce  sync.Once
+	inFlightPolicyMutex sync.RWMutex
+	inFlightPolicy      = WaitInFlight
+
+	releaseLocksMutex sync.Mutex
+	releaseLocks      = map[string]*sync.Mutex{}
+)
+
+// pendingStatuses are the helm statuses of a release a helm operation is running on.
+var pendingStatuses = []catalogv1.Status{
+	catalogv1.StatusPendingInstall,
+	catalogv1.StatusPendingUpgrade,
+	catalogv1.StatusPendingRollback,
+	catalogv1.StatusUninstalling,
+}
+
+// GetInFlightPolicy returns the policy the chart installers apply when a helm operation is in progress on their release.
+func GetInFlightPolicy() InFlightPolicy {
+	loadInFlightPolicy()
+
+	inFlightPolicyMutex.RLock()
+	defer inFlightPolicyMutex.RUnlock()
+
+	return inFlightPolicy
+}
+
+// SetInFlightPolicy is a helper function that sets the policy the chart installers apply when a helm operation is in
+// progress on their release, overriding the one of the configuration file.
+func SetInFlightPolicy(policy InFlightPolicy) {
+	loadInFlightPolicy()
+
+	inFlightPolicyMutex.Lock()
+	defer inFlightPolicyMutex.Unlock()
+
+	inFlightPolicy = policy
+}
+
+// GetPendingReleases is a helper function that returns the releases of the namespace whose name starts with the release
+// prefix, e.g. rancher-monitoring for both the chart and its CRD chart, that a helm operation is running on, with their status.
+func GetPendingReleases(client *rancher.Client, clusterID, namespace, releasePrefix string) (map[string]catalogv1.Status, error) {
+	adminClient, err := rancher.NewClient(client.RancherConfig.AdminToken, client.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	catalogClient, err := adminClient.GetClusterCatalogClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	appList, err := catalogClient.Apps(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	pendingReleases := map[string]catalogv1.Status{}
+	for _, app := range appList.Items {
+		if !strings.HasPrefix(app.Name, releasePrefix) || app.Spec.Info == nil {
+			continue
+		}
+
+		for _, status := range pendingStatuses {
+			if app.Spec.Info.Status == status {
+				pendingReleases[app.Name] = status
+			}
+		}
+	}
+
+	return pendingReleases, nil
+}
+
+// GuardReleaseOperation is a helper function that runs the helm operation once no other operation is in progress on the
+// releases of the namespace whose name starts with the release prefix. Operations of this process on the same releases
+// are serialized, and operations of other processes are detected from the pending status of the releases. With the
+// WaitInFlight policy the operation is queued until the others are done, with FailFastInFlight ErrOperationInProgress is
+// returned instead, so suites racing on a shared cluster don't leave the release in a corrupted state.
+func GuardReleaseOperation(client *rancher.Client, clusterID, namespace, releasePrefix string, policy InFlightPolicy, operation func() error) error {
+	releaseLock := getReleaseLock(clusterID, namespace, releasePrefix)
+
+	if policy == FailFastInFlight {
+		if !releaseLock.TryLock() {
+			return fmt.Errorf("%w: %s/%s in cluster %s is operated on by this process", ErrOperationInProgress, namespace, releasePrefix, clusterID)
+		}
+	} else {
+		releaseLock.Lock()
+	}
+	defer releaseLock.Unlock()
+
+	pendingReleases, err := GetPendingReleases(client, clusterID, namespace, releasePrefix)
+	if err != nil {
+		return err
+	}
+
+	if len(pendingReleases) > 0 {
+		if policy == FailFastInFlight {
+			return fmt.Errorf("%w: %s/%s in cluster %s is %v", ErrOperationInProgress, namespace, releasePrefix, clusterID, pendingReleases)
+		}
+
+		logrus.Infof("Waiting for the helm operations in progress on %s/%s in cluster %s: %v", namespace, releasePrefix, clusterID, pendingReleases)
+
+		err = wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Timeout(), func(context.Context) (done bool, err error) {
+			pendingReleases, err = GetPendingReleases(client, clusterID, namespace, releasePrefix)
+			if err != nil {
+				return false, err
+			}
+
+			return len(pendingReleases) == 0, nil
+		}, func() string {
+			return fmt.Sprintf("releases are still pending: %v", pendingReleases)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return operation()
+}
+
+// getReleaseLock is a private function that returns the lock serializing the operations of this process on the releases.
+func getReleaseLock(clusterID, namespace, releasePrefix string) *sync.Mutex {
+	releaseLocksMutex.Lock()
+	defer releaseLocksMutex.Unlock()
+
+	key := clusterID + "/" + namespace + "/" + releasePrefix
+	if releaseLocks[key] == nil {
+		releaseLocks[key] = &sync.Mutex{}
+	}
+
+	return releaseLocks[key]
+}
+
+// loadInFlightPolicy is a private function that reads the configuration file once.
+func loadInFlightPolicy() {
+	inFlightPolicyOnce.Do(func() {
+		operationsConfig := new(ChartOperationsConfig)
+		config.LoadConfig(ChartOperationsConfigurationFileKey, operationsConfig)
+
+		if operationsConfig.InFlightPolicy == "" {
+			return
+		}
+
+		if operationsConfig.InFlightPolicy != WaitInFlight && operationsConfig.InFlightPolicy != FailFastInFlight {
+			logrus.Warnf("Ignoring unknown chart in-flight policy %q, using %q", operationsConfig.InFlightPolicy, WaitInFlight)
+			return
+		}
+
+		inFlightPolicyMutex.Lock()
+		defer inFlightPolicyMutex.Unlock()
+
+		inFlightPolicy = operationsConfig.InFlightPolicy
+	})
+}
@@ -0,0 +1,334 @@
+package charts
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/charts"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	endpointRequestTimeout = 1 * time.Minute
+	endpointBackoffFactor  = 2
+	endpointBackoffJitter  = 0.1
+	endpointBackoffCap     = 30 * time.Second
+)
+
+// EndpointAuth is how the requests to an endpoint are authenticated.
+type EndpointAuth string
+
+const (
+	// RancherAuth authenticates with the token of the client, through the http client of the management client, the default
+	RancherAuth EndpointAuth = ""
+	// NoAuth sends unauthenticated requests, e.g. to a workload exposed on a node port
+	NoAuth EndpointAuth = "none"
+	// BearerAuth authenticates with the bearer token of the options
+	BearerAuth EndpointAuth = "bearer"
+	// BasicAuth authenticates with the username and password of the options
+	BasicAuth EndpointAuth = "basic"
+)
+
+// EndpointOptions is a struct of the request GetEndpoint sends to an endpoint of any host.
+type EndpointOptions struct {
+	// Host is the host of the endpoint, with its port if any
+	Host string
+	// Path is the path of the endpoint, without the leading slash
+	Path string
+	// IsHTTPS selects https instead of http
+	IsHTTPS bool
+	// Auth is how the request is authenticated
+	Auth EndpointAuth
+	// BearerToken is the token of BearerAuth
+	BearerToken string
+	// Username is the username of BasicAuth
+	Username string
+	// Password is the password of BasicAuth
+	Password string
+	// InsecureSkipVerify skips the verification of the certificate of hosts other than rancher, e.g. self-signed ones
+	InsecureSkipVerify bool
+	// Headers are added to the request
+	Headers map[string]string
+}
+
+var (
+	// externalHTTPClient is the http client of the requests that are not authenticated by rancher, so the token and
+	// the session ID header of the client are never sent to other hosts
+	externalHTTPClient = newExternalHTTPClient(false)
+	// insecureExternalHTTPClient is the http client of the requests to other hosts whose certificate is not verified
+	insecureExternalHTTPClient = newExternalHTTPClient(true)
+)
+
+// EndpointExpectFunc is the function type of the predicates the response of an endpoint is waited for to satisfy.
+type EndpointExpectFunc func(result *charts.GetChartCaseEndpointResult) bool
+
+// JSONAssertion is the function type of an assertion on the decoded JSON body of an endpoint response.
+type JSONAssertion func(body interface{}) error
+
+// GetChartCaseEndpoint is a helper function that sends a GET request authenticated with the token of the client to the
+// given path of the host, e.g. a chart service proxied by rancher, and returns if the response was healthy with its body.
+// The request goes through the http client of the management client, so it reuses its keep-alive connections, its TLS
+// settings and the transports wrapping it, e.g. the session ID header, and probing many endpoints of the same host only
+// pays the TLS handshake once.
+func GetChartCaseEndpoint(client *rancher.Client, host, path string, isHTTPS bool) (*charts.GetChartCaseEndpointResult, error) {
+	return GetEndpoint(client, &EndpointOptions{Host: host, Path: path, IsHTTPS: isHTTPS})
+}
+
+// GetEndpoint is a helper function that sends a GET request to the path of the host of the options, authenticated as
+// the options say, and returns if the response was healthy with its body. Only RancherAuth requests go through the http
+// client of the management client; the others, e.g. to a webhook receiver or an ingress exposed on a node, use an http
+// client of their own, so the token of the client is never sent to hosts other than rancher.
+func GetEndpoint(client *rancher.Client, options *EndpointOptions) (*charts.GetChartCaseEndpointResult, error) {
+	protocol := "http"
+	if options.IsHTTPS {
+		protocol = "https"
+	}
+
+	url := fmt.Sprintf("%s://%s/%s", protocol, options.Host, options.Path)
+
+	ctx, cancel := context.WithTimeout(context.Background(), endpointRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range options.Headers {
+		req.Header.Set(key, value)
+	}
+
+	httpClient := externalHTTPClient
+	if options.InsecureSkipVerify {
+		httpClient = insecureExternalHTTPClient
+	}
+
+	switch options.Auth {
+	case RancherAuth:
+		req.Header.Set("Authorization", "Bearer "+client.Management.Opts.TokenKey)
+		httpClient = client.Management.Ops.Client
+	case NoAuth:
+	case BearerAuth:
+		req.Header.Set("Authorization", "Bearer "+options.BearerToken)
+	case BasicAuth:
+		req.SetBasicAuth(options.Username, options.Password)
+	default:
+		return nil, fmt.Errorf("unknown endpoint auth %q", options.Auth)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// the body is always read to the end, otherwise the connection can't be reused
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &charts.GetChartCaseEndpointResult{
+		Ok:   resp.StatusCode == http.StatusOK,
+		Body: string(bodyBytes),
+	}, nil
+}
+
+// ExpectHealthy is an EndpointExpectFunc that expects the response to be healthy.
+func ExpectHealthy(result *charts.GetChartCaseEndpointResult) bool {
+	return result.Ok
+}
+
+// WaitForEndpoint is a helper function that sends the request of GetChartCaseEndpoint with an exponential backoff, from
+// the configured poll interval up to 30 seconds, until its response satisfies the expect function or the timeout, scaled
+// by the timeouts configuration, expires. Failed requests are retried. It returns the last response, so callers can
+// report it on a timeout, e.g. WaitForEndpoint(client, host, path, true, ExpectHealthy, 2*time.Minute).
+func WaitForEndpoint(client *rancher.Client, host, path string, isHTTPS bool, expectFunc EndpointExpectFunc, timeout time.Duration) (*charts.GetChartCaseEndpointResult, error) {
+	return WaitForEndpointWithOptions(client, &EndpointOptions{Host: host, Path: path, IsHTTPS: isHTTPS}, expectFunc, timeout)
+}
+
+// WaitForEndpointWithOptions is a helper function that behaves as WaitForEndpoint, sending the request of GetEndpoint
+// with the options instead.
+func WaitForEndpointWithOptions(client *rancher.Client, options *EndpointOptions, expectFunc EndpointExpectFunc, timeout time.Duration) (*charts.GetChartCaseEndpointResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Scale(timeout))
+	defer cancel()
+
+	backoff := kwait.Backoff{
+		Duration: timeouts.PollInterval(),
+		Factor:   endpointBackoffFactor,
+		Jitter:   endpointBackoffJitter,
+		Steps:    math.MaxInt32,
+		Cap:      endpointBackoffCap,
+	}
+
+	var result *charts.GetChartCaseEndpointResult
+	var lastErr error
+	err := kwait.ExponentialBackoffWithContext(ctx, backoff, func(context.Context) (done bool, err error) {
+		result, lastErr = GetEndpoint(client, options)
+		if lastErr != nil {
+			return false, nil
+		}
+
+		return expectFunc(result), nil
+	})
+	if err != nil {
+		if lastErr != nil {
+			err = errors.Join(lastErr, err)
+		}
+
+		return result, fmt.Errorf("endpoint %s of %s did not return the expected response within %s: %w", options.Path, options.Host, timeouts.Scale(timeout), err)
+	}
+
+	return result, nil
+}
+
+// AssertChartCaseEndpoint is a helper function that sends the request of GetChartCaseEndpoint, checks the response is
+// healthy and runs the assertions on its JSON body, returning the errors of all the assertions that failed.
+func AssertChartCaseEndpoint(client *rancher.Client, host, path string, isHTTPS bool, assertions ...JSONAssertion) error {
+	result, err := GetChartCaseEndpoint(client, host, path, isHTTPS)
+	if err != nil {
+		return err
+	}
+
+	if !result.Ok {
+		return fmt.Errorf("failed to get a healthy response from %s", path)
+	}
+
+	var body interface{}
+	err = json.Unmarshal([]byte(result.Body), &body)
+	if err != nil {
+		return fmt.Errorf("unable to parse the response of %s: %w", path, err)
+	}
+
+	var errs []error
+	for _, assertion := range assertions {
+		err = assertion(body)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// JSONPathEquals is a constructor that returns the assertion that the field at the path equals the expected value, e.g.
+// JSONPathEquals("status", "success"). The path is made of object keys and array indexes separated by dots, e.g.
+// "data.activeTargets.0.health", and values are compared by their string representation so numbers match whatever their type.
+func JSONPathEquals(path string, expected interface{}) JSONAssertion {
+	return func(body interface{}) error {
+		value, err := getJSONPath(body, path)
+		if err != nil {
+			return err
+		}
+
+		if fmt.Sprint(value) != fmt.Sprint(expected) {
+			return fmt.Errorf("%s is %v, expected %v", path, value, expected)
+		}
+
+		return nil
+	}
+}
+
+// JSONPathMinLength is a constructor that returns the assertion that the array at the path has at least the given
+// number of elements, e.g. JSONPathMinLength("data.activeTargets", 1).
+func JSONPathMinLength(path string, minLength int) JSONAssertion {
+	return func(body interface{}) error {
+		array, err := getJSONArray(body, path)
+		if err != nil {
+			return err
+		}
+
+		if len(array) < minLength {
+			return fmt.Errorf("%s has %d elements, expected at least %d", path, len(array), minLength)
+		}
+
+		return nil
+	}
+}
+
+// JSONPathEach is a constructor that returns the assertion that the field at the element path of every element of the
+// array at the path equals the expected value, e.g. JSONPathEach("data.activeTargets", "health", "up").
+func JSONPathEach(path, elementPath string, expected interface{}) JSONAssertion {
+	return func(body interface{}) error {
+		array, err := getJSONArray(body, path)
+		if err != nil {
+			return err
+		}
+
+		var mismatches []string
+		for i, element := range array {
+			value, err := getJSONPath(element, elementPath)
+			if err != nil || fmt.Sprint(value) != fmt.Sprint(expected) {
+				mismatches = append(mismatches, fmt.Sprintf("%d: %v", i, value))
+			}
+		}
+
+		if len(mismatches) > 0 {
+			return fmt.Errorf("%s of %d of the %d elements of %s is not %v: %s", elementPath, len(mismatches), len(array), path, expected, strings.Join(mismatches, ", "))
+		}
+
+		return nil
+	}
+}
+
+// newExternalHTTPClient is a private constructor that returns an http client with the default transport settings, e.g.
+// the proxy of the environment, that skips the verification of certificates when insecure is true.
+func newExternalHTTPClient(insecure bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: insecure}
+
+	return &http.Client{Transport: transport}
+}
+
+// getJSONPath is a private helper function that returns the value at the dot separated path of the decoded JSON body.
+func getJSONPath(body interface{}, path string) (interface{}, error) {
+	value := body
+	for _, key := range strings.Split(path, ".") {
+		switch typedValue := value.(type) {
+		case map[string]interface{}:
+			field, ok := typedValue[key]
+			if !ok {
+				return nil, fmt.Errorf("%s not found: no field %q", path, key)
+			}
+
+			value = field
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(typedValue) {
+				return nil, fmt.Errorf("%s not found: no index %q in an array of %d elements", path, key, len(typedValue))
+			}
+
+			value = typedValue[index]
+		default:
+			return nil, fmt.Errorf("%s not found: %q is not an object or an array", path, key)
+		}
+	}
+
+	return value, nil
+}
+
+// getJSONArray is a private helper function that returns the array at the dot separated path of the decoded JSON body.
+func getJSONArray(body interface{}, path string) ([]interface{}, error) {
+	value, err := getJSONPath(body, path)
+	if err != nil {
+		return nil, err
+	}
+
+	array, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s is not an array", path)
+	}
+
+	return array, nil
+}
@@ -0,0 +1,102 @@
+package charts
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const targetsBody = `{
+	"status": "success",
+	"data": {
+		"activeTargets": [
+			{"health": "up", "scrapePool": "apiserver", "labels": {"job": "apiserver"}},
+			{"health": "up", "scrapePool": "kubelet", "labels": {"job": "kubelet"}}
+		],
+		"droppedTargets": [],
+		"count": 2
+	}
+}`
+
+func TestJSONPathAssertions(t *testing.T) {
+	var body interface{}
+	require.NoError(t, json.Unmarshal([]byte(targetsBody), &body))
+
+	tests := []struct {
+		name      string
+		assertion JSONAssertion
+		wantErr   bool
+	}{
+		{
+			name:      "equals string",
+			assertion: JSONPathEquals("status", "success"),
+		},
+		{
+			name:      "equals number of another type",
+			assertion: JSONPathEquals("data.count", 2),
+		},
+		{
+			name:      "equals array element",
+			assertion: JSONPathEquals("data.activeTargets.1.labels.job", "kubelet"),
+		},
+		{
+			name:      "equals mismatch",
+			assertion: JSONPathEquals("status", "error"),
+			wantErr:   true,
+		},
+		{
+			name:      "missing field",
+			assertion: JSONPathEquals("data.missing", "value"),
+			wantErr:   true,
+		},
+		{
+			name:      "index out of range",
+			assertion: JSONPathEquals("data.activeTargets.2.health", "up"),
+			wantErr:   true,
+		},
+		{
+			name:      "path through a scalar",
+			assertion: JSONPathEquals("status.code", "success"),
+			wantErr:   true,
+		},
+		{
+			name:      "min length",
+			assertion: JSONPathMinLength("data.activeTargets", 2),
+		},
+		{
+			name:      "min length not reached",
+			assertion: JSONPathMinLength("data.droppedTargets", 1),
+			wantErr:   true,
+		},
+		{
+			name:      "min length of a non array",
+			assertion: JSONPathMinLength("data.count", 1),
+			wantErr:   true,
+		},
+		{
+			name:      "each element",
+			assertion: JSONPathEach("data.activeTargets", "health", "up"),
+		},
+		{
+			name:      "each element mismatch",
+			assertion: JSONPathEach("data.activeTargets", "labels.job", "apiserver"),
+			wantErr:   true,
+		},
+		{
+			name:      "each element of an empty array",
+			assertion: JSONPathEach("data.droppedTargets", "health", "up"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.assertion(body)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
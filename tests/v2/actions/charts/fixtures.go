@@ -0,0 +1,226 @@
+package charts
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ErrFixtureOptionsNotSatisfied is returned by RequireSharedChart when the chart is already installed by another suite
+// with a version, values or options that don't cover the ones the calling suite requires. Suites are expected to skip
+// on this error.
+var ErrFixtureOptionsNotSatisfied = errors.New("shared chart is installed with options that don't satisfy the required options")
+
+// SharedChartFixture is a struct that describes a chart that can be shared by all suites running in the same process.
+type SharedChartFixture struct {
+	// Name of the chart
+	Name string
+	// Namespace the chart is installed in
+	Namespace string
+	// Options are the chart options required by the suite, they are passed to Install when the fixture installs the chart
+	Options interface{}
+	// Values are the helm values required by the suite on top of the ones generated from the options, none when nil
+	Values map[string]interface{}
+	// Install installs the chart with the given options and values
+	Install func(client *rancher.Client, installOptions *charts.InstallOptions, options interface{}, values map[string]interface{}) error
+	// Satisfies reports whether the options the chart was installed with cover the required options
+	Satisfies func(installedOptions, requiredOptions interface{}) bool
+}
+
+// sharedChart is a private struct that keeps the state of a shared chart in the registry.
+type sharedChart struct {
+	mutex   sync.Mutex
+	ready   bool
+	options interface{}
+	// external is true when the chart was installed before the registry saw it, so its options are unknown
+	external bool
+	// installErr is the error of the install that failed, the chart may be half installed so it's never reused
+	installErr error
+}
+
+var (
+	sharedChartsMutex sync.Mutex
+	sharedCharts      = map[string]*sharedChart{}
+	fixtureSession    *session.Session
+)
+
+// RequireSharedChart is a helper function that makes sure the fixture chart is installed in the cluster of the install
+// options. The first suite that requires the chart installs it, and the following suites reuse it as long as the
+// installed chart has the version of the install options, when set, and the values of the fixture, and its options
+// satisfy the required options; otherwise ErrFixtureOptionsNotSatisfied is returned. Charts installed by the registry
+// are not removed by the session of the calling suite, CleanupSharedCharts should be called once the suites are done.
+func RequireSharedChart(client *rancher.Client, installOptions *charts.InstallOptions, fixture *SharedChartFixture) error {
+	chart := getSharedChart(installOptions.Cluster.ID, fixture.Namespace, fixture.Name)
+
+	chart.mutex.Lock()
+	defer chart.mutex.Unlock()
+
+	if chart.installErr != nil {
+		return fmt.Errorf("shared chart %s in cluster [%s] failed to install: %w", fixture.Name, installOptions.Cluster.Name, chart.installErr)
+	}
+
+	chartStatus, err := GetChartStatus(client, installOptions.Cluster.ID, fixture.Namespace, fixture.Name)
+	if err != nil {
+		return err
+	}
+
+	if chartStatus.IsAlreadyInstalled {
+		if !chartStatus.IsHealthy {
+			return fmt.Errorf("shared chart %s in cluster [%s] is not healthy: %s", fixture.Name, installOptions.Cluster.Name, chartStatus)
+		}
+
+		if !chart.ready {
+			chart.ready = true
+			chart.external = true
+		}
+
+		return satisfiesSharedChart(chart, chartStatus, installOptions, fixture)
+	}
+
+	fixtureClient, err := client.WithSession(getFixtureSession())
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Installing shared chart %s in cluster [%s]", fixture.Name, installOptions.Cluster.Name)
+	err = installSharedChart(fixtureClient, installOptions, fixture)
+	if err != nil {
+		chart.installErr = err
+		return err
+	}
+
+	chart.ready = true
+	chart.external = false
+	chart.options = fixture.Options
+
+	return nil
+}
+
+// CleanupSharedCharts is a helper function that uninstalls all charts installed by the fixture registry and resets it.
+// It is meant to be called once the suites sharing the charts are done, e.g. from TestMain or TearDownSuite.
+func CleanupSharedCharts() {
+	sharedChartsMutex.Lock()
+	defer sharedChartsMutex.Unlock()
+
+	if fixtureSession != nil {
+		fixtureSession.Cleanup()
+	}
+
+	fixtureSession = nil
+	sharedCharts = map[string]*sharedChart{}
+}
+
+// NewRancherMonitoringFixture is a helper function that returns the shared fixture of the rancher-monitoring chart
+// for the given required monitoring options and values, installed by InstallRancherMonitoringChartWithValues.
+func NewRancherMonitoringFixture(requiredOpts *charts.RancherMonitoringOpts, requiredValues map[string]interface{}) *SharedChartFixture {
+	return &SharedChartFixture{
+		Name:      charts.RancherMonitoringName,
+		Namespace: charts.RancherMonitoringNamespace,
+		Options:   requiredOpts,
+		Values:    requiredValues,
+		Install: func(client *rancher.Client, installOptions *charts.InstallOptions, options interface{}, values map[string]interface{}) error {
+			return InstallRancherMonitoringChartWithValues(client, &InstallOptions{InstallOptions: installOptions, Values: values}, options.(*charts.RancherMonitoringOpts), nil)
+		},
+		Satisfies: func(installedOptions, requiredOptions interface{}) bool {
+			installed, _ := installedOptions.(*charts.RancherMonitoringOpts)
+			required, _ := requiredOptions.(*charts.RancherMonitoringOpts)
+
+			if required == nil {
+				return true
+			}
+
+			if installed == nil {
+				return false
+			}
+
+			return (!required.IngressNginx || installed.IngressNginx) &&
+				(!required.ControllerManager || installed.ControllerManager) &&
+				(!required.Etcd || installed.Etcd) &&
+				(!required.Proxy || installed.Proxy) &&
+				(!required.Scheduler || installed.Scheduler)
+		},
+	}
+}
+
+// satisfiesSharedChart is a private helper function that returns ErrFixtureOptionsNotSatisfied when the installed chart
+// doesn't have the required version, values or options. The options of charts the registry didn't install are unknown,
+// so only their version and values are checked.
+func satisfiesSharedChart(chart *sharedChart, chartStatus *ChartStatus, installOptions *charts.InstallOptions, fixture *SharedChartFixture) error {
+	installedVersion := chartStatus.ChartDetails.Spec.Chart.Metadata.Version
+	if installOptions.Version != "" && installedVersion != installOptions.Version {
+		return fmt.Errorf("%w: chart %s in cluster [%s] is installed with version %s, required %s", ErrFixtureOptionsNotSatisfied, fixture.Name, installOptions.Cluster.Name, installedVersion, installOptions.Version)
+	}
+
+	diff, err := DiffValues(fixture.Values, chartStatus.Values)
+	if err != nil {
+		return err
+	}
+
+	if len(diff) > 0 {
+		return fmt.Errorf("%w: values of chart %s in cluster [%s] differ from the required ones:\n%s", ErrFixtureOptionsNotSatisfied, fixture.Name, installOptions.Cluster.Name, diff)
+	}
+
+	if chart.external {
+		logrus.Warnf("Reusing chart %s in cluster [%s] that was not installed by the fixture registry, its options can't be verified", fixture.Name, installOptions.Cluster.Name)
+		return nil
+	}
+
+	if fixture.Satisfies != nil && !fixture.Satisfies(chart.options, fixture.Options) {
+		return fmt.Errorf("%w: chart %s in cluster [%s] is installed with %+v, required %+v", ErrFixtureOptionsNotSatisfied, fixture.Name, installOptions.Cluster.Name, chart.options, fixture.Options)
+	}
+
+	return nil
+}
+
+// installSharedChart is a private helper function that installs the fixture chart and waits for its workloads.
+func installSharedChart(client *rancher.Client, installOptions *charts.InstallOptions, fixture *SharedChartFixture) error {
+	err := fixture.Install(client, installOptions, fixture.Options, fixture.Values)
+	if err != nil {
+		return err
+	}
+
+	err = WatchAndWaitDeployments(client, installOptions.Cluster.ID, fixture.Namespace, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	err = WatchAndWaitDaemonSets(client, installOptions.Cluster.ID, fixture.Namespace, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	return WatchAndWaitStatefulSets(client, installOptions.Cluster.ID, fixture.Namespace, metav1.ListOptions{})
+}
+
+// getSharedChart is a private helper function that returns the registry entry of a chart, creating it when needed.
+func getSharedChart(clusterID, namespace, name string) *sharedChart {
+	sharedChartsMutex.Lock()
+	defer sharedChartsMutex.Unlock()
+
+	key := clusterID + "/" + namespace + "/" + name
+	chart, ok := sharedCharts[key]
+	if !ok {
+		chart = &sharedChart{}
+		sharedCharts[key] = chart
+	}
+
+	return chart
+}
+
+// getFixtureSession is a private helper function that returns the session the shared charts are installed with.
+func getFixtureSession() *session.Session {
+	sharedChartsMutex.Lock()
+	defer sharedChartsMutex.Unlock()
+
+	if fixtureSession == nil {
+		fixtureSession = session.NewSession()
+	}
+
+	return fixtureSession
+}
@@ -0,0 +1,68 @@
+package charts
+
+import (
+	"testing"
+
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRancherMonitoringFixtureSatisfies(t *testing.T) {
+	allExporters := &charts.RancherMonitoringOpts{
+		IngressNginx:      true,
+		ControllerManager: true,
+		Etcd:              true,
+		Proxy:             true,
+		Scheduler:         true,
+	}
+
+	tests := []struct {
+		name      string
+		installed interface{}
+		required  *charts.RancherMonitoringOpts
+		want      bool
+	}{
+		{
+			name:      "nothing required",
+			installed: nil,
+			required:  nil,
+			want:      true,
+		},
+		{
+			name:      "unknown installed options",
+			installed: nil,
+			required:  &charts.RancherMonitoringOpts{Etcd: true},
+			want:      false,
+		},
+		{
+			name:      "same options",
+			installed: allExporters,
+			required:  allExporters,
+			want:      true,
+		},
+		{
+			name:      "installed options cover the required ones",
+			installed: allExporters,
+			required:  &charts.RancherMonitoringOpts{IngressNginx: true, Etcd: true},
+			want:      true,
+		},
+		{
+			name:      "required exporter disabled",
+			installed: &charts.RancherMonitoringOpts{IngressNginx: true},
+			required:  &charts.RancherMonitoringOpts{IngressNginx: true, Scheduler: true},
+			want:      false,
+		},
+		{
+			name:      "installed options of another chart",
+			installed: &RancherLoggingOpts{},
+			required:  &charts.RancherMonitoringOpts{Proxy: true},
+			want:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixture := NewRancherMonitoringFixture(tt.required, nil)
+			assert.Equal(t, tt.want, fixture.Satisfies(tt.installed, fixture.Options))
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package charts
+
+import (
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/charts"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const grafanaLabelSelector = "app.kubernetes.io/name=grafana"
+
+// GrafanaRole is a role of a grafana organization.
+type GrafanaRole string
+
+const (
+	GrafanaViewer GrafanaRole = "Viewer"
+	GrafanaEditor GrafanaRole = "Editor"
+	GrafanaAdmin  GrafanaRole = "Admin"
+)
+
+// GrafanaAuthOpts is a struct of the auth settings of the rancher-monitoring grafana, which complete the
+// RancherMonitoringOpts of the shepherd charts extension. Requests through the rancher proxy carry no grafana
+// credentials, so they are anonymous ones.
+type GrafanaAuthOpts struct {
+	// AnonymousEnabled lets requests without grafana credentials in with the AnonymousRole, rancher-monitoring enables it
+	AnonymousEnabled bool
+	// AnonymousRole is the role of anonymous requests, Viewer when empty
+	AnonymousRole GrafanaRole
+	// UsersRole is the role users signing in are given in the organization, e.g. Viewer so project members can't edit
+	// dashboards, Viewer when empty
+	UsersRole GrafanaRole
+}
+
+// Values returns the rancher-monitoring values of the grafana auth settings.
+func (o *GrafanaAuthOpts) Values() map[string]interface{} {
+	anonymousRole := o.AnonymousRole
+	if anonymousRole == "" {
+		anonymousRole = GrafanaViewer
+	}
+
+	usersRole := o.UsersRole
+	if usersRole == "" {
+		usersRole = GrafanaViewer
+	}
+
+	return map[string]interface{}{
+		"grafana": map[string]interface{}{
+			"grafana.ini": map[string]interface{}{
+				"auth.anonymous": map[string]interface{}{
+					"enabled":  o.AnonymousEnabled,
+					"org_role": string(anonymousRole),
+				},
+				"users": map[string]interface{}{
+					"auto_assign_org_role": string(usersRole),
+				},
+			},
+		},
+	}
+}
+
+// ConfigureGrafanaAuth is a helper function that upgrades the rancher-monitoring chart of the cluster with the grafana
+// auth settings, keeping its version and other values, and waits until grafana is ready. The previous settings are
+// restored by the session of the client.
+func ConfigureGrafanaAuth(client *rancher.Client, clusterID string, authOpts *GrafanaAuthOpts) error {
+	err := UpgradeReleaseValues(client, clusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName, authOpts.Values())
+	if err != nil {
+		return err
+	}
+
+	return WatchAndWaitDeployments(client, clusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{LabelSelector: grafanaLabelSelector})
+}
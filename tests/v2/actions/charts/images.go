@@ -0,0 +1,212 @@
+package charts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/kubeapi/workloads/daemonsets"
+	"github.com/rancher/shepherd/extensions/kubeapi/workloads/deployments"
+	"github.com/sirupsen/logrus"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	helmReleaseNameAnnotation = "meta.helm.sh/release-name"
+	linuxOS                   = "linux"
+)
+
+// releaseWorkloadResources are the workload resources whose images are inspected, with the path of their pod spec.
+var releaseWorkloadResources = []struct {
+	groupVersionResource schema.GroupVersionResource
+	podSpecPath          []string
+}{
+	{deployments.DeploymentGroupVersionResource, []string{"spec", "template", "spec"}},
+	{daemonsets.DaemonSetGroupVersionResource, []string{"spec", "template", "spec"}},
+	{StatefulSetGroupVersionResource, []string{"spec", "template", "spec"}},
+	{batchv1.SchemeGroupVersion.WithResource("jobs"), []string{"spec", "template", "spec"}},
+	{batchv1.SchemeGroupVersion.WithResource("cronjobs"), []string{"spec", "jobTemplate", "spec", "template", "spec"}},
+}
+
+// ImageArchitectures is a struct of the linux architectures an image manifest is published for.
+type ImageArchitectures struct {
+	// Image is the image reference as it appears in the pod spec
+	Image string
+	// IsIndex is true when the reference is a multi-architecture manifest list or OCI index
+	IsIndex bool
+	// Architectures are the linux architectures of the manifest, e.g. amd64 and arm64
+	Architectures []string
+}
+
+// GetReleaseImages is a helper function that returns the sorted, unique images of the containers and init containers
+// of the workloads the chart release deployed in its namespace, found by the helm release name annotation.
+func GetReleaseImages(client *rancher.Client, clusterID, chartNamespace, chartName string) ([]string, error) {
+	adminDynamicClient, err := getAdminDynamicClient(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	images := map[string]bool{}
+	for _, workloadResource := range releaseWorkloadResources {
+		workloadList, err := adminDynamicClient.Resource(workloadResource.groupVersionResource).Namespace(chartNamespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, workload := range workloadList.Items {
+			if workload.GetAnnotations()[helmReleaseNameAnnotation] != chartName {
+				continue
+			}
+
+			for _, containersField := range []string{"initContainers", "containers"} {
+				containers, _, err := unstructured.NestedSlice(workload.Object, append(slices.Clone(workloadResource.podSpecPath), containersField)...)
+				if err != nil {
+					return nil, err
+				}
+
+				for _, container := range containers {
+					containerMap, ok := container.(map[string]interface{})
+					if !ok {
+						continue
+					}
+
+					if image, ok := containerMap["image"].(string); ok && image != "" {
+						images[image] = true
+					}
+				}
+			}
+		}
+	}
+
+	var releaseImages []string
+	for image := range images {
+		releaseImages = append(releaseImages, image)
+	}
+
+	sort.Strings(releaseImages)
+
+	return releaseImages, nil
+}
+
+// GetImageArchitectures is a helper function that reads the manifest of the image from its registry, with the credentials
+// of the docker config of the host if any, and returns the linux architectures it is published for.
+func GetImageArchitectures(image string) (*ImageArchitectures, error) {
+	reference, err := name.ParseReference(image)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse image %s: %w", image, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeouts.Timeout())
+	defer cancel()
+
+	descriptor, err := remote.Get(reference, remote.WithContext(ctx), remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the manifest of image %s: %w", image, err)
+	}
+
+	imageArchitectures := &ImageArchitectures{Image: image, IsIndex: descriptor.MediaType.IsIndex()}
+
+	if !imageArchitectures.IsIndex {
+		singleImage, err := descriptor.Image()
+		if err != nil {
+			return nil, err
+		}
+
+		configFile, err := singleImage.ConfigFile()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get the config of image %s: %w", image, err)
+		}
+
+		if configFile.OS == linuxOS {
+			imageArchitectures.Architectures = []string{configFile.Architecture}
+		}
+
+		return imageArchitectures, nil
+	}
+
+	imageIndex, err := descriptor.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	indexManifest, err := imageIndex.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the index of image %s: %w", image, err)
+	}
+
+	for _, manifest := range indexManifest.Manifests {
+		// attestation manifests are listed with an unknown platform
+		if manifest.Platform == nil || manifest.Platform.OS != linuxOS {
+			continue
+		}
+
+		if !slices.Contains(imageArchitectures.Architectures, manifest.Platform.Architecture) {
+			imageArchitectures.Architectures = append(imageArchitectures.Architectures, manifest.Platform.Architecture)
+		}
+	}
+
+	sort.Strings(imageArchitectures.Architectures)
+
+	return imageArchitectures, nil
+}
+
+// VerifyImageArchitectures is a helper function that checks the manifests of the images include every required
+// architecture, e.g. VerifyImageArchitectures(images, AMD64, ARM64), returning the errors of all the images that don't.
+func VerifyImageArchitectures(images []string, requiredArchitectures ...string) error {
+	var errs []error
+	for _, image := range images {
+		imageArchitectures, err := GetImageArchitectures(image)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		var missingArchitectures []string
+		for _, architecture := range requiredArchitectures {
+			if !slices.Contains(imageArchitectures.Architectures, architecture) {
+				missingArchitectures = append(missingArchitectures, architecture)
+			}
+		}
+
+		if len(missingArchitectures) > 0 {
+			errs = append(errs, fmt.Errorf("image %s is published for [%s], missing [%s]", image,
+				strings.Join(imageArchitectures.Architectures, ", "), strings.Join(missingArchitectures, ", ")))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// VerifyReleaseImageArchitectures is a helper function that checks the images of the workloads of the chart release are
+// published for every required architecture, so a single architecture image is caught before it fails to run on the
+// nodes of a mixed cluster, e.g. VerifyReleaseImageArchitectures(client, clusterID, namespace, name, AMD64, ARM64).
+func VerifyReleaseImageArchitectures(client *rancher.Client, clusterID, chartNamespace, chartName string, requiredArchitectures ...string) error {
+	images, err := GetReleaseImages(client, clusterID, chartNamespace, chartName)
+	if err != nil {
+		return err
+	}
+
+	if len(images) == 0 {
+		return fmt.Errorf("no workload images found for chart %s in namespace %s", chartName, chartNamespace)
+	}
+
+	logrus.Infof("Verifying the %d images of chart %s are published for %v", len(images), chartName, requiredArchitectures)
+
+	err = VerifyImageArchitectures(images, requiredArchitectures...)
+	if err != nil {
+		return fmt.Errorf("chart %s has images missing required architectures: %w", chartName, err)
+	}
+
+	return nil
+}
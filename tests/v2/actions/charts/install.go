@@ -0,0 +1,200 @@
+package charts
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/clients/rancher/catalog"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/pkg/api/steve/catalog/types"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultChartInstallTimeout = 10 * time.Minute
+	serverURLSettingID         = "server-url"
+	defaultRegistrySettingID   = "system-default-registry"
+)
+
+// ChartInstallOptions is a struct of the chart InstallChart installs. The cluster, version and project are the ones of
+// the shepherd install options.
+type ChartInstallOptions struct {
+	*charts.InstallOptions
+	// ChartName is the name of the chart in the repository
+	ChartName string
+	// ReleaseName is the name of the release, the chart name when empty
+	ReleaseName string
+	// Namespace is the namespace the chart is installed in
+	Namespace string
+	// RepoName is the name of the cluster repository of the chart, the rancher charts repository when empty
+	RepoName string
+	// Timeout is the timeout of the helm operation, 10 minutes when zero
+	Timeout time.Duration
+	// StreamLogs writes the logs of the helm operations to the test log while the chart installs
+	StreamLogs bool
+	// ClusterScoped is set for charts that install cluster scoped resources, e.g. CRDs and cluster roles, which are
+	// deleted with the session when the uninstall leaves them, and whose namespaced resources are verified to be in
+	// the namespace of the release once it is installed
+	ClusterScoped bool
+}
+
+// InstallChart is a helper function that installs any chart of any cluster repository of the cluster with the values,
+// e.g. InstallChart(client, &ChartInstallOptions{InstallOptions: installOptions, ChartName: "rancher-cis-benchmark",
+// Namespace: "cis-operator-system"}, nil). The latest version is installed when the install options have none. Charts
+// of the rancher charts repository get the global.cattle values rancher sets when it installs them from the UI, and
+// charts with architecture values get the ones of the architectures of the cluster, see GetArchitectureValues, both of
+// which the values override. Helm operations in progress on the release are handled with the policy of GetInFlightPolicy.
+// The chart is uninstalled by the session of the client, along with the cluster scoped resources it leaves when the
+// install options are ClusterScoped.
+func InstallChart(client *rancher.Client, installOptions *ChartInstallOptions, values map[string]interface{}) error {
+	catalogClient, err := client.GetClusterCatalogClient(installOptions.Cluster.ID)
+	if err != nil {
+		return err
+	}
+
+	repoName := installOptions.RepoName
+	if repoName == "" {
+		repoName = catalog.RancherChartRepo
+	}
+
+	releaseName := installOptions.ReleaseName
+	if releaseName == "" {
+		releaseName = installOptions.ChartName
+	}
+
+	version := installOptions.Version
+	if version == "" {
+		version, err = catalogClient.GetLatestChartVersion(installOptions.ChartName, repoName)
+		if err != nil {
+			return err
+		}
+	}
+
+	architectureValues, err := GetArchitectureValues(client, installOptions.Cluster.ID, installOptions.ChartName)
+	if err != nil {
+		return err
+	}
+
+	chartValues := values
+	if architectureValues != nil {
+		chartValues = mergeValues(architectureValues, values)
+	}
+
+	var annotations map[string]string
+	if repoName == catalog.RancherChartRepo {
+		chartValues, err = withCattleValues(client, installOptions.InstallOptions, chartValues)
+		if err != nil {
+			return err
+		}
+
+		annotations = map[string]string{
+			uiSourceRepoAnnotation:     catalog.RancherChartRepo,
+			uiSourceRepoTypeAnnotation: "cluster",
+		}
+	}
+
+	timeout := installOptions.Timeout
+	if timeout == 0 {
+		timeout = defaultChartInstallTimeout
+	}
+
+	installAction := &types.ChartInstallAction{
+		Timeout:   &metav1.Duration{Duration: timeouts.Scale(timeout)},
+		Wait:      true,
+		Namespace: installOptions.Namespace,
+		ProjectID: installOptions.ProjectID,
+		Charts: []types.ChartInstall{{
+			Annotations: annotations,
+			ChartName:   installOptions.ChartName,
+			ReleaseName: releaseName,
+			Version:     version,
+			Values:      chartValues,
+		}},
+	}
+
+	if installOptions.ClusterScoped {
+		TrackClusterScopedResources(client, installOptions.Cluster.ID, installOptions.Namespace, releaseName)
+	}
+
+	err = GuardReleaseOperation(client, installOptions.Cluster.ID, installOptions.Namespace, releaseName, GetInFlightPolicy(), func() error {
+		if installOptions.StreamLogs {
+			stop := StreamOperationLogs(client, installOptions.Cluster.ID, installOptions.Namespace, releaseName)
+			defer stop()
+		}
+
+		return catalogClient.InstallChart(installAction, repoName)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to install chart %s %s in cluster [%s]: %w", installOptions.ChartName, version, installOptions.Cluster.Name, err)
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		return UninstallChart(client, installOptions.Cluster.ID, installOptions.Namespace, releaseName)
+	})
+
+	logrus.Infof("Installed chart %s %s as %s/%s in cluster [%s]", installOptions.ChartName, version, installOptions.Namespace, releaseName, installOptions.Cluster.Name)
+
+	if installOptions.ClusterScoped {
+		scopes, err := VerifyReleaseScopes(client, installOptions.Cluster.ID, installOptions.Namespace, releaseName)
+		if err != nil {
+			return err
+		}
+
+		logrus.Infof("Release %s/%s deployed %d cluster scoped and %d namespaced resources", installOptions.Namespace, releaseName, len(scopes.ClusterScoped), len(scopes.Namespaced))
+	}
+
+	return nil
+}
+
+// withCattleValues is a private helper function that returns the values with the global.cattle values of the cluster
+// and the rancher settings set where the values don't set them.
+func withCattleValues(client *rancher.Client, installOptions *charts.InstallOptions, values map[string]interface{}) (map[string]interface{}, error) {
+	serverSetting, err := client.Management.Setting.ByID(serverURLSettingID)
+	if err != nil {
+		return nil, err
+	}
+
+	registrySetting, err := client.Management.Setting.ByID(defaultRegistrySettingID)
+	if err != nil {
+		return nil, err
+	}
+
+	cattleValues := map[string]interface{}{
+		"global": map[string]interface{}{
+			"cattle": map[string]interface{}{
+				"clusterId":             installOptions.Cluster.ID,
+				"clusterName":           installOptions.Cluster.Name,
+				"systemDefaultRegistry": registrySetting.Value,
+				"url":                   serverSetting.Value,
+			},
+			"systemDefaultRegistry": registrySetting.Value,
+		},
+	}
+
+	return mergeValues(cattleValues, values), nil
+}
+
+// mergeValues is a private helper function that returns the base values deeply merged with the override values, the
+// override values winning.
+func mergeValues(base, override map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for key, value := range base {
+		merged[key] = value
+	}
+
+	for key, value := range override {
+		baseMap, baseIsMap := merged[key].(map[string]interface{})
+		overrideMap, overrideIsMap := value.(map[string]interface{})
+		if baseIsMap && overrideIsMap {
+			merged[key] = mergeValues(baseMap, overrideMap)
+			continue
+		}
+
+		merged[key] = value
+	}
+
+	return merged
+}
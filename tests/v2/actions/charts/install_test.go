@@ -0,0 +1,82 @@
+package charts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     map[string]interface{}
+		override map[string]interface{}
+		want     map[string]interface{}
+	}{
+		{
+			name:     "nil maps",
+			base:     nil,
+			override: nil,
+			want:     map[string]interface{}{},
+		},
+		{
+			name:     "override only",
+			base:     nil,
+			override: map[string]interface{}{"enabled": true},
+			want:     map[string]interface{}{"enabled": true},
+		},
+		{
+			name:     "override wins",
+			base:     map[string]interface{}{"replicas": 1, "image": "rancher/shell"},
+			override: map[string]interface{}{"replicas": 3},
+			want:     map[string]interface{}{"replicas": 3, "image": "rancher/shell"},
+		},
+		{
+			name: "nested maps are merged",
+			base: map[string]interface{}{
+				"prometheus": map[string]interface{}{
+					"prometheusSpec": map[string]interface{}{"retention": "10d", "scrapeInterval": "1m"},
+				},
+			},
+			override: map[string]interface{}{
+				"prometheus": map[string]interface{}{
+					"prometheusSpec": map[string]interface{}{"retention": "2d"},
+				},
+			},
+			want: map[string]interface{}{
+				"prometheus": map[string]interface{}{
+					"prometheusSpec": map[string]interface{}{"retention": "2d", "scrapeInterval": "1m"},
+				},
+			},
+		},
+		{
+			name:     "non map override replaces a map",
+			base:     map[string]interface{}{"nodeSelector": map[string]interface{}{"kubernetes.io/os": "linux"}},
+			override: map[string]interface{}{"nodeSelector": nil},
+			want:     map[string]interface{}{"nodeSelector": nil},
+		},
+		{
+			name:     "lists are replaced",
+			base:     map[string]interface{}{"tolerations": []interface{}{"a", "b"}},
+			override: map[string]interface{}{"tolerations": []interface{}{"c"}},
+			want:     map[string]interface{}{"tolerations": []interface{}{"c"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, mergeValues(tt.base, tt.override))
+		})
+	}
+}
+
+func TestMergeValuesKeepsBase(t *testing.T) {
+	base := map[string]interface{}{
+		"grafana": map[string]interface{}{"enabled": true},
+	}
+
+	mergeValues(base, map[string]interface{}{
+		"grafana": map[string]interface{}{"enabled": false},
+	})
+
+	assert.Equal(t, map[string]interface{}{"grafana": map[string]interface{}{"enabled": true}}, base)
+}
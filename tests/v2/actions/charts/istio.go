@@ -0,0 +1,93 @@
+package charts
+
+import (
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/charts"
+)
+
+const (
+	// IstiodName is the name of the istio control plane deployment of the rancher-istio chart
+	IstiodName = "istiod"
+	// IstioIngressGatewayName is the name of the ingress gateway deployment of the rancher-istio chart
+	IstioIngressGatewayName = "istio-ingressgateway"
+	// IstioEgressGatewayName is the name of the egress gateway deployment of the rancher-istio chart
+	IstioEgressGatewayName = "istio-egressgateway"
+	// KialiName is the name of the kiali deployment of the rancher-istio chart
+	KialiName = "kiali"
+	// IstioTracingName is the name of the jaeger deployment of the rancher-istio chart
+	IstioTracingName = "tracing"
+)
+
+// InstallRancherIstioChart is a helper function that installs the rancher-istio chart like the shepherd helper does,
+// with the gateways, kiali, tracing and CNI of the feature options, and, when the operation options stream the logs,
+// writes the logs of its helm operations to the test log while it installs. Helm operations in progress on the chart
+// are handled with the policy of GetInFlightPolicy.
+func InstallRancherIstioChart(client *rancher.Client, installOptions *charts.InstallOptions, rancherIstioOpts *charts.RancherIstioOpts, operationOptions *OperationOptions) error {
+	return GuardReleaseOperation(client, installOptions.Cluster.ID, charts.RancherIstioNamespace, charts.RancherIstioName, GetInFlightPolicy(), func() error {
+		stop := operationOptions.StreamOperationLogs(client, installOptions.Cluster.ID, charts.RancherIstioNamespace, charts.RancherIstioName)
+		defer stop()
+
+		return charts.InstallRancherIstioChart(client, installOptions, rancherIstioOpts)
+	})
+}
+
+// UpgradeRancherIstioChart is a helper function that upgrades the rancher-istio chart like the shepherd helper does
+// and, when the operation options stream the logs, writes the logs of its helm operations to the test log while it
+// upgrades. Helm operations in progress on the chart are handled with the policy of GetInFlightPolicy.
+func UpgradeRancherIstioChart(client *rancher.Client, installOptions *charts.InstallOptions, rancherIstioOpts *charts.RancherIstioOpts, operationOptions *OperationOptions) error {
+	return GuardReleaseOperation(client, installOptions.Cluster.ID, charts.RancherIstioNamespace, charts.RancherIstioName, GetInFlightPolicy(), func() error {
+		stop := operationOptions.StreamOperationLogs(client, installOptions.Cluster.ID, charts.RancherIstioNamespace, charts.RancherIstioName)
+		defer stop()
+
+		return charts.UpgradeRancherIstioChart(client, installOptions, rancherIstioOpts)
+	})
+}
+
+// WaitForIstiod is a helper function that waits until the istiod deployment of the rancher-istio chart exists and is
+// ready.
+func WaitForIstiod(client *rancher.Client, clusterID string) error {
+	return WatchAndWaitDeploymentsByName(client, clusterID, charts.RancherIstioNamespace, []ExpectedDeployment{{Name: IstiodName}})
+}
+
+// WaitForIstioGateways is a helper function that waits until the gateway deployments the feature options enable exist
+// and are ready.
+func WaitForIstioGateways(client *rancher.Client, clusterID string, rancherIstioOpts *charts.RancherIstioOpts) error {
+	var gateways []ExpectedDeployment
+	if rancherIstioOpts.IngressGateways {
+		gateways = append(gateways, ExpectedDeployment{Name: IstioIngressGatewayName})
+	}
+
+	if rancherIstioOpts.EgressGateways {
+		gateways = append(gateways, ExpectedDeployment{Name: IstioEgressGatewayName})
+	}
+
+	if len(gateways) == 0 {
+		return nil
+	}
+
+	return WatchAndWaitDeploymentsByName(client, clusterID, charts.RancherIstioNamespace, gateways)
+}
+
+// WaitForIstio is a helper function that waits until istiod and the gateway, kiali and tracing deployments the feature
+// options enable exist and are ready. Unlike WatchAndWaitDeployments, a component that is missing, e.g. a gateway that
+// failed to be created, is waited for instead of being ignored.
+func WaitForIstio(client *rancher.Client, clusterID string, rancherIstioOpts *charts.RancherIstioOpts) error {
+	expectedDeployments := []ExpectedDeployment{{Name: IstiodName}}
+	if rancherIstioOpts.IngressGateways {
+		expectedDeployments = append(expectedDeployments, ExpectedDeployment{Name: IstioIngressGatewayName})
+	}
+
+	if rancherIstioOpts.EgressGateways {
+		expectedDeployments = append(expectedDeployments, ExpectedDeployment{Name: IstioEgressGatewayName})
+	}
+
+	if rancherIstioOpts.Kiali {
+		expectedDeployments = append(expectedDeployments, ExpectedDeployment{Name: KialiName})
+	}
+
+	if rancherIstioOpts.Tracing {
+		expectedDeployments = append(expectedDeployments, ExpectedDeployment{Name: IstioTracingName})
+	}
+
+	return WatchAndWaitDeploymentsByName(client, clusterID, charts.RancherIstioNamespace, expectedDeployments)
+}
@@ -0,0 +1,115 @@
+package charts
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/pkg/api/scheme"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// JobGroupVersionResource is the required Group Version Resource for accessing jobs in a cluster, using the dynamic client.
+var JobGroupVersionResource = batchv1.SchemeGroupVersion.WithResource("jobs")
+
+// CronJobGroupVersionResource is the required Group Version Resource for accessing cronjobs in a cluster, using the
+// dynamic client.
+var CronJobGroupVersionResource = batchv1.SchemeGroupVersion.WithResource("cronjobs")
+
+// WatchAndWaitJobs is a helper function that watches the jobs in a specific namespace with a single watch and waits
+// until all of them are complete, e.g. the scan jobs of rancher-cis-benchmark. A job that fails, i.e. reaches its
+// backoff limit or its active deadline, fails the wait right away with the reason and message of its failed condition.
+// If the watch can't be established or is closed by the server, the jobs are listed and watched again.
+func WatchAndWaitJobs(client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions) error {
+	return WatchAndWaitJobsWithOptions(client, clusterID, namespace, listOptions, nil)
+}
+
+// WatchAndWaitJobsWithOptions is a helper function that behaves as WatchAndWaitJobs, using the poll interval and timeout
+// of the wait options instead of the defaults of the timeouts package.
+func WatchAndWaitJobsWithOptions(client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions, waitOptions *timeouts.Options) error {
+	return watchAndWaitWorkloads(context.Background(), client, clusterID, namespace, listOptions, JobGroupVersionResource, isJobReady, newWaitOptions(waitOptions))
+}
+
+// WatchAndWaitJobsWithContext is a helper function that behaves as WatchAndWaitJobs, stopping when the context is done
+// and reporting the jobs that are still not complete to the progress function of the wait options.
+func WatchAndWaitJobsWithContext(ctx context.Context, client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions, waitOptions *WaitOptions) error {
+	return watchAndWaitWorkloads(ctx, client, clusterID, namespace, listOptions, JobGroupVersionResource, isJobReady, waitOptions)
+}
+
+// WatchAndWaitCronJobs is a helper function that watches the CronJobs in a specific namespace with a single watch and
+// waits until the last scheduled run of each of them succeeded, e.g. the backups of a rancher-backup recurring schedule.
+// CronJobs that are suspended are not waited for. A CronJob whose last run ended without succeeding fails the wait
+// right away. If the watch can't be established or is closed by the server, the CronJobs are listed and watched again.
+func WatchAndWaitCronJobs(client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions) error {
+	return WatchAndWaitCronJobsWithOptions(client, clusterID, namespace, listOptions, nil)
+}
+
+// WatchAndWaitCronJobsWithOptions is a helper function that behaves as WatchAndWaitCronJobs, using the poll interval and
+// timeout of the wait options instead of the defaults of the timeouts package.
+func WatchAndWaitCronJobsWithOptions(client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions, waitOptions *timeouts.Options) error {
+	return watchAndWaitWorkloads(context.Background(), client, clusterID, namespace, listOptions, CronJobGroupVersionResource, isCronJobReady, newWaitOptions(waitOptions))
+}
+
+// WatchAndWaitCronJobsWithContext is a helper function that behaves as WatchAndWaitCronJobs, stopping when the context
+// is done and reporting the CronJobs whose last run has not succeeded yet to the progress function of the wait options.
+func WatchAndWaitCronJobsWithContext(ctx context.Context, client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions, waitOptions *WaitOptions) error {
+	return watchAndWaitWorkloads(ctx, client, clusterID, namespace, listOptions, CronJobGroupVersionResource, isCronJobReady, waitOptions)
+}
+
+// isJobReady is a private helper function that checks if the job is complete, and returns an error if it failed.
+func isJobReady(workload *unstructured.Unstructured) (bool, error) {
+	job := &batchv1.Job{}
+	err := scheme.Scheme.Convert(workload, job, workload.GroupVersionKind())
+	if err != nil {
+		return false, err
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return true, nil
+		case batchv1.JobFailed:
+			return false, fmt.Errorf("job %s/%s failed with %d failed pods: %s: %s", job.Namespace, job.Name, job.Status.Failed, condition.Reason, condition.Message)
+		}
+	}
+
+	return false, nil
+}
+
+// isCronJobReady is a private helper function that checks if the CronJob is suspended or its last scheduled run
+// succeeded, and returns an error if its last run ended without succeeding.
+func isCronJobReady(workload *unstructured.Unstructured) (bool, error) {
+	cronJob := &batchv1.CronJob{}
+	err := scheme.Scheme.Convert(workload, cronJob, workload.GroupVersionKind())
+	if err != nil {
+		return false, err
+	}
+
+	if cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend {
+		return true, nil
+	}
+
+	lastSchedule := cronJob.Status.LastScheduleTime
+	if lastSchedule == nil {
+		return false, nil
+	}
+
+	lastSuccess := cronJob.Status.LastSuccessfulTime
+	if lastSuccess != nil && !lastSuccess.Before(lastSchedule) {
+		return true, nil
+	}
+
+	if len(cronJob.Status.Active) == 0 {
+		return false, fmt.Errorf("cronjob %s/%s run scheduled at %s ended without succeeding", cronJob.Namespace, cronJob.Name, lastSchedule.UTC())
+	}
+
+	return false, nil
+}
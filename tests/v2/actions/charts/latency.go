@@ -0,0 +1,181 @@
+package charts
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/sirupsen/logrus"
+)
+
+// The json/yaml config key for the latency budget of the proxied chart endpoints
+const EndpointLatencyConfigurationFileKey = "endpointLatency"
+
+const (
+	defaultLatencySamples    = 5
+	defaultLatencyPercentile = 90
+)
+
+// EndpointLatencyConfig is the configuration of the latency budget the proxied chart endpoints, e.g. grafana and the
+// prometheus graph, are asserted to respond within, e.g.
+//
+//	endpointLatency:
+//	  budget: 2s
+//	  samples: 10
+//	  percentile: 90
+//
+// The budget is parsed with time.ParseDuration and is not scaled by the timeouts multiplier. Without a budget, the
+// latency is not asserted.
+type EndpointLatencyConfig struct {
+	Budget     string `json:"budget" yaml:"budget"`
+	Samples    int    `json:"samples" yaml:"samples"`
+	Percentile int    `json:"percentile" yaml:"percentile"`
+}
+
+// LatencyBudget is a struct of the latency an endpoint is asserted to respond within.
+type LatencyBudget struct {
+	// Budget is the latency the percentile of the samples must not exceed
+	Budget time.Duration
+	// Samples is the number of requests that are timed, 5 when zero
+	Samples int
+	// Percentile is the nearest-rank percentile of the samples compared to the budget, 90 when zero
+	Percentile int
+}
+
+// EndpointLatency is a struct of the latencies of the requests to an endpoint.
+type EndpointLatency struct {
+	Path string
+	// Samples are the latencies of the timed requests, sorted
+	Samples []time.Duration
+	// Percentile is the latency of the percentile of the budget
+	Percentile time.Duration
+	Budget     *LatencyBudget
+}
+
+// String returns the percentile latency of the endpoint with its budget and its slowest and fastest samples.
+func (l *EndpointLatency) String() string {
+	return fmt.Sprintf("p%d latency of %s is %s for a budget of %s (min %s, max %s over %d requests)", l.Budget.percentile(),
+		l.Path, l.Percentile, l.Budget.Budget, l.Samples[0], l.Samples[len(l.Samples)-1], len(l.Samples))
+}
+
+var (
+	latencyBudgetOnce sync.Once
+	latencyBudget     *LatencyBudget
+)
+
+// GetEndpointLatencyBudget returns the latency budget of the configuration file, nil when it sets none.
+func GetEndpointLatencyBudget() *LatencyBudget {
+	latencyBudgetOnce.Do(func() {
+		latencyConfig := new(EndpointLatencyConfig)
+		config.LoadConfig(EndpointLatencyConfigurationFileKey, latencyConfig)
+
+		if latencyConfig.Budget == "" {
+			return
+		}
+
+		budget, err := time.ParseDuration(latencyConfig.Budget)
+		if err != nil {
+			logrus.Warnf("Ignoring invalid %s.budget %q: %v", EndpointLatencyConfigurationFileKey, latencyConfig.Budget, err)
+			return
+		}
+
+		latencyBudget = &LatencyBudget{
+			Budget:     budget,
+			Samples:    latencyConfig.Samples,
+			Percentile: latencyConfig.Percentile,
+		}
+	})
+
+	return latencyBudget
+}
+
+// MeasureEndpointLatency is a helper function that sends the request of GetEndpoint the number of samples times, after
+// a first request that is not timed so the connection is already established, and returns the sorted latencies. It
+// fails when a response is not healthy, so an error page returned quickly does not meet the budget.
+func MeasureEndpointLatency(client *rancher.Client, options *EndpointOptions, samples int) ([]time.Duration, error) {
+	_, err := GetEndpoint(client, options)
+	if err != nil {
+		return nil, err
+	}
+
+	latencies := make([]time.Duration, 0, samples)
+	for i := 0; i < samples; i++ {
+		start := time.Now()
+
+		result, err := GetEndpoint(client, options)
+		if err != nil {
+			return nil, err
+		}
+
+		latency := time.Since(start)
+		if !result.Ok {
+			return nil, fmt.Errorf("failed to get a healthy response from %s", options.Path)
+		}
+
+		latencies = append(latencies, latency)
+	}
+
+	slices.Sort(latencies)
+
+	return latencies, nil
+}
+
+// AssertEndpointLatency is a helper function that measures the latency of the endpoint of the options and fails when
+// the percentile of the budget exceeds it. The latency is returned in both cases, so it can be logged.
+func AssertEndpointLatency(client *rancher.Client, options *EndpointOptions, budget *LatencyBudget) (*EndpointLatency, error) {
+	samples, err := MeasureEndpointLatency(client, options, budget.samples())
+	if err != nil {
+		return nil, err
+	}
+
+	latency := &EndpointLatency{
+		Path:       options.Path,
+		Samples:    samples,
+		Percentile: nearestRankPercentile(samples, budget.percentile()),
+		Budget:     budget,
+	}
+
+	if latency.Percentile > budget.Budget {
+		return latency, fmt.Errorf("%s exceeds its latency budget: %s", options.Path, latency)
+	}
+
+	return latency, nil
+}
+
+// AssertChartCaseEndpointLatency is a helper function that behaves as AssertEndpointLatency, sending the request of
+// GetChartCaseEndpoint instead, e.g. to grafana proxied by rancher.
+func AssertChartCaseEndpointLatency(client *rancher.Client, host, path string, isHTTPS bool, budget *LatencyBudget) (*EndpointLatency, error) {
+	return AssertEndpointLatency(client, &EndpointOptions{Host: host, Path: path, IsHTTPS: isHTTPS}, budget)
+}
+
+// samples is a private method that returns the number of samples of the budget, with its default.
+func (b *LatencyBudget) samples() int {
+	if b.Samples <= 0 {
+		return defaultLatencySamples
+	}
+
+	return b.Samples
+}
+
+// percentile is a private method that returns the percentile of the budget, with its default.
+func (b *LatencyBudget) percentile() int {
+	if b.Percentile <= 0 || b.Percentile > 100 {
+		return defaultLatencyPercentile
+	}
+
+	return b.Percentile
+}
+
+// nearestRankPercentile is a private helper function that returns the nearest-rank percentile of the sorted samples.
+func nearestRankPercentile(samples []time.Duration, percentile int) time.Duration {
+	rank := int(math.Ceil(float64(percentile) / 100 * float64(len(samples))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return samples[rank-1]
+}
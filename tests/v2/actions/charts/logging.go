@@ -0,0 +1,237 @@
+package charts
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/charts"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	rancherLoggingCRDName       = "rancher-logging-crd"
+	loggingSyslogOutputName     = "rancher-logging-syslog"
+	loggingJournaldTailerName   = "rancher-logging-journald"
+	defaultJournaldPath         = "/var/log/journal"
+	defaultSyslogPort           = 514
+	defaultSyslogTransport      = "tcp"
+	loggingAPIVersion           = "logging.banzaicloud.io/v1beta1"
+	loggingExtensionsAPIVersion = "logging-extensions.banzaicloud.io/v1alpha1"
+)
+
+var (
+	// ClusterOutputGroupVersionResource is the required Group Version Resource for accessing logging cluster outputs in
+	// a cluster, using the dynamic client.
+	ClusterOutputGroupVersionResource = schema.GroupVersionResource{
+		Group:    "logging.banzaicloud.io",
+		Version:  "v1beta1",
+		Resource: "clusteroutputs",
+	}
+	// ClusterFlowGroupVersionResource is the required Group Version Resource for accessing logging cluster flows in a
+	// cluster, using the dynamic client.
+	ClusterFlowGroupVersionResource = schema.GroupVersionResource{
+		Group:    "logging.banzaicloud.io",
+		Version:  "v1beta1",
+		Resource: "clusterflows",
+	}
+	// HostTailerGroupVersionResource is the required Group Version Resource for accessing logging host tailers in a
+	// cluster, using the dynamic client.
+	HostTailerGroupVersionResource = schema.GroupVersionResource{
+		Group:    "logging-extensions.banzaicloud.io",
+		Version:  "v1alpha1",
+		Resource: "hosttailers",
+	}
+)
+
+// RancherLoggingOpts is a struct of the rancher-logging feature options, mirroring the RancherMonitoringOpts of the
+// shepherd charts extension. The logging sources are chart values; journald and the outputs are logging resources
+// created in the control namespace of the chart once it is installed.
+type RancherLoggingOpts struct {
+	// AdditionalLoggingSources enables the logging source of the kubernetes provider of the cluster, e.g. rke2
+	AdditionalLoggingSources bool
+	// Journald tails the systemd journal of the nodes with a host tailer, so it is collected like the container logs
+	Journald bool
+	// JournaldPath is the path of the systemd journal on the nodes, /var/log/journal when empty
+	JournaldPath string
+	// Syslog sends the logs of every namespace to a syslog server, none when nil
+	Syslog *SyslogOutputOpts
+	// AdditionalOutputs are cluster outputs the logs of every namespace are sent to, by output name, each one being the
+	// spec of the output, e.g. {"http": {"endpoint": "http://receiver.default.svc:8080"}}
+	AdditionalOutputs map[string]map[string]interface{}
+}
+
+// SyslogOutputOpts is a struct of the syslog server a syslog cluster output sends the logs to.
+type SyslogOutputOpts struct {
+	Host string
+	// Port is 514 when zero
+	Port int
+	// Transport is one of tcp, udp or tls, tcp when empty
+	Transport string
+}
+
+// Values returns the rancher-logging values of the feature options for the kubernetes provider of the cluster.
+func (o *RancherLoggingOpts) Values(provider string) map[string]interface{} {
+	return map[string]interface{}{
+		"additionalLoggingSources": map[string]interface{}{
+			provider: map[string]interface{}{
+				"enabled": o.AdditionalLoggingSources,
+			},
+		},
+	}
+}
+
+// InstallRancherLoggingChart is a helper function that installs the rancher-logging chart like the shepherd helper
+// does, then creates the journald host tailer and the outputs of the feature options. When the operation options stream
+// the logs, the logs of its helm operations are written to the test log while it installs. Helm operations in progress
+// on the chart are handled with the policy of GetInFlightPolicy. The resources and the chart are removed by the session
+// of the client.
+func InstallRancherLoggingChart(client *rancher.Client, installOptions *charts.InstallOptions, rancherLoggingOpts *RancherLoggingOpts, operationOptions *OperationOptions) error {
+	err := GuardReleaseOperation(client, installOptions.Cluster.ID, charts.RancherLoggingNamespace, charts.RancherLoggingName, GetInFlightPolicy(), func() error {
+		stop := operationOptions.StreamOperationLogs(client, installOptions.Cluster.ID, charts.RancherLoggingNamespace, charts.RancherLoggingName)
+		defer stop()
+
+		return charts.InstallRancherLoggingChart(client, installOptions, &charts.RancherLoggingOpts{
+			AdditionalLoggingSources: rancherLoggingOpts.AdditionalLoggingSources,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return createLoggingResources(client, installOptions.Cluster.ID, rancherLoggingOpts)
+}
+
+// UpgradeRancherLoggingChart is a helper function that upgrades the rancher-logging CRD chart and the rancher-logging
+// chart to the version of the install options, keeping their values with the logging sources of the feature options,
+// and waits until they are deployed. The shepherd charts extension has no upgrade helper for rancher-logging, so the
+// releases are upgraded through the catalog API. When the operation options stream the logs, the logs of its helm
+// operations are written to the test log while it upgrades. The journald host tailer and the outputs are left as they
+// are.
+func UpgradeRancherLoggingChart(client *rancher.Client, installOptions *charts.InstallOptions, rancherLoggingOpts *RancherLoggingOpts, operationOptions *OperationOptions) error {
+	clusterID := installOptions.Cluster.ID
+
+	stop := operationOptions.StreamOperationLogs(client, clusterID, charts.RancherLoggingNamespace, charts.RancherLoggingName)
+	defer stop()
+
+	err := UpgradeReleaseVersion(client, clusterID, charts.RancherLoggingNamespace, rancherLoggingCRDName, installOptions.Version, nil)
+	if err != nil {
+		return err
+	}
+
+	values := rancherLoggingOpts.Values(string(installOptions.Cluster.Provider))
+
+	return UpgradeReleaseVersion(client, clusterID, charts.RancherLoggingNamespace, charts.RancherLoggingName, installOptions.Version, values)
+}
+
+// createLoggingResources is a private helper function that creates the journald host tailer and the cluster outputs of
+// the feature options, each output with a cluster flow sending the logs of every namespace to it, in the control
+// namespace of rancher-logging. They are deleted by the session of the client.
+func createLoggingResources(client *rancher.Client, clusterID string, rancherLoggingOpts *RancherLoggingOpts) error {
+	dynamicClient, err := getAdminDynamicClient(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	var resources []*unstructured.Unstructured
+	var groupVersionResources []schema.GroupVersionResource
+
+	if rancherLoggingOpts.Journald {
+		journaldPath := rancherLoggingOpts.JournaldPath
+		if journaldPath == "" {
+			journaldPath = defaultJournaldPath
+		}
+
+		resources = append(resources, newLoggingResource(loggingExtensionsAPIVersion, "HostTailer", loggingJournaldTailerName, map[string]interface{}{
+			"systemdTailers": []interface{}{
+				map[string]interface{}{
+					"name": "journald",
+					"path": journaldPath,
+				},
+			},
+		}))
+		groupVersionResources = append(groupVersionResources, HostTailerGroupVersionResource)
+	}
+
+	outputs := map[string]map[string]interface{}{}
+	for name, spec := range rancherLoggingOpts.AdditionalOutputs {
+		outputs[name] = spec
+	}
+
+	if rancherLoggingOpts.Syslog != nil {
+		outputs[loggingSyslogOutputName] = rancherLoggingOpts.Syslog.outputSpec()
+	}
+
+	for name, spec := range outputs {
+		resources = append(resources, newLoggingResource(loggingAPIVersion, "ClusterOutput", name, spec))
+		groupVersionResources = append(groupVersionResources, ClusterOutputGroupVersionResource)
+
+		resources = append(resources, newLoggingResource(loggingAPIVersion, "ClusterFlow", name, map[string]interface{}{
+			"globalOutputRefs": []interface{}{name},
+		}))
+		groupVersionResources = append(groupVersionResources, ClusterFlowGroupVersionResource)
+	}
+
+	for i, resource := range resources {
+		resourceClient := dynamicClient.Resource(groupVersionResources[i]).Namespace(charts.RancherLoggingNamespace)
+
+		_, err = resourceClient.Create(context.TODO(), resource, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to create %s %s: %w", resource.GetKind(), resource.GetName(), err)
+		}
+
+		name := resource.GetName()
+		client.Session.RegisterCleanupFunc(func() error {
+			err := resourceClient.Delete(context.TODO(), name, metav1.DeleteOptions{})
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+
+			return err
+		})
+	}
+
+	return nil
+}
+
+// outputSpec is a private method that returns the spec of the syslog cluster output, sending the logs as JSON.
+func (o *SyslogOutputOpts) outputSpec() map[string]interface{} {
+	port := o.Port
+	if port == 0 {
+		port = defaultSyslogPort
+	}
+
+	transport := o.Transport
+	if transport == "" {
+		transport = defaultSyslogTransport
+	}
+
+	return map[string]interface{}{
+		"syslog": map[string]interface{}{
+			"host":      o.Host,
+			"port":      int64(port),
+			"transport": transport,
+			"insecure":  true,
+			"format": map[string]interface{}{
+				"type": "json",
+			},
+		},
+	}
+}
+
+// newLoggingResource is a private constructor that returns a logging resource of the control namespace of
+// rancher-logging.
+func newLoggingResource(apiVersion, kind, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": charts.RancherLoggingNamespace,
+		},
+		"spec": spec,
+	}}
+}
@@ -0,0 +1,70 @@
+package charts
+
+const (
+	// PodAntiAffinitySoft spreads the replicas over the nodes when possible
+	PodAntiAffinitySoft = "soft"
+	// PodAntiAffinityHard never schedules two replicas on the same node
+	PodAntiAffinityHard = "hard"
+
+	defaultPodDisruptionBudgetMinAvailable = 1
+)
+
+// MonitoringHAOpts is a struct of the high availability of the rancher-monitoring prometheus and alertmanager, which
+// completes the RancherMonitoringOpts of the shepherd charts extension. Without it, both run a single replica without a
+// pod disruption budget.
+type MonitoringHAOpts struct {
+	// PrometheusReplicas is the number of replicas of prometheus, the chart default when zero
+	PrometheusReplicas int
+	// AlertmanagerReplicas is the number of replicas of alertmanager, the chart default when zero
+	AlertmanagerReplicas int
+	// PodAntiAffinity is either PodAntiAffinitySoft or PodAntiAffinityHard, none when empty. The hard one needs as many
+	// schedulable nodes as replicas.
+	PodAntiAffinity string
+	// PodDisruptionBudget creates the pod disruption budgets keeping one replica of prometheus and alertmanager
+	// available while nodes are drained
+	PodDisruptionBudget bool
+}
+
+// Values returns the rancher-monitoring values of the high availability of prometheus and alertmanager, to set as the
+// values of the InstallOptions of InstallRancherMonitoringChartWithValues.
+func (o *MonitoringHAOpts) Values() map[string]interface{} {
+	prometheusSpec := map[string]interface{}{}
+	alertmanagerSpec := map[string]interface{}{}
+
+	if o.PrometheusReplicas > 0 {
+		prometheusSpec["replicas"] = o.PrometheusReplicas
+	}
+
+	if o.AlertmanagerReplicas > 0 {
+		alertmanagerSpec["replicas"] = o.AlertmanagerReplicas
+	}
+
+	if o.PodAntiAffinity != "" {
+		prometheusSpec["podAntiAffinity"] = o.PodAntiAffinity
+		alertmanagerSpec["podAntiAffinity"] = o.PodAntiAffinity
+	}
+
+	return map[string]interface{}{
+		"prometheus": map[string]interface{}{
+			"prometheusSpec":      prometheusSpec,
+			"podDisruptionBudget": o.podDisruptionBudgetValues(),
+		},
+		"alertmanager": map[string]interface{}{
+			"alertmanagerSpec":    alertmanagerSpec,
+			"podDisruptionBudget": o.podDisruptionBudgetValues(),
+		},
+	}
+}
+
+// podDisruptionBudgetValues is a private method that returns the values of the pod disruption budget of prometheus or
+// alertmanager.
+func (o *MonitoringHAOpts) podDisruptionBudgetValues() map[string]interface{} {
+	podDisruptionBudget := map[string]interface{}{
+		"enabled": o.PodDisruptionBudget,
+	}
+	if o.PodDisruptionBudget {
+		podDisruptionBudget["minAvailable"] = defaultPodDisruptionBudgetMinAvailable
+	}
+
+	return podDisruptionBudget
+}
@@ -0,0 +1,185 @@
+package charts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/clients/rancher/catalog"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The json/yaml config key for the monitoring chart versions of the version matrix
+const MonitoringVersionMatrixConfigurationFileKey = "monitoringVersionMatrix"
+
+const rancherMonitoringCRDName = "rancher-monitoring-crd"
+
+// MonitoringVersionMatrixConfig is the configuration of the rancher-monitoring versions the version matrix runs against,
+// either a list of versions or the number of latest versions of the rancher-charts repository, e.g.
+//
+//	monitoringVersionMatrix:
+//	  versions: [104.1.0+up57.0.3, 103.1.1+up45.31.1]
+//	  latest: 3
+type MonitoringVersionMatrixConfig struct {
+	// Versions are the chart versions to run against, in order
+	Versions []string `json:"versions" yaml:"versions"`
+	// Latest is the number of latest chart versions to run against when no versions are set
+	Latest int `json:"latest" yaml:"latest"`
+}
+
+// MonitoringVersionResult is a struct of the result of the version matrix for one rancher-monitoring version.
+type MonitoringVersionResult struct {
+	Version string
+	// Err is the error of the install, the verification or the uninstall of the version, nil when it passed
+	Err error
+	// Duration is the time the install, the verification and the uninstall of the version took
+	Duration time.Duration
+}
+
+// MonitoringVersionMatrixResults are the results of the version matrix, in the order of the versions.
+type MonitoringVersionMatrixResults []MonitoringVersionResult
+
+// Failed returns the results of the versions that failed.
+func (r MonitoringVersionMatrixResults) Failed() MonitoringVersionMatrixResults {
+	var failed MonitoringVersionMatrixResults
+	for _, result := range r {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+
+	return failed
+}
+
+// String returns the results as one line per version.
+func (r MonitoringVersionMatrixResults) String() string {
+	lines := make([]string, 0, len(r))
+	for _, result := range r {
+		status := "PASS"
+		if result.Err != nil {
+			status = "FAIL: " + result.Err.Error()
+		}
+
+		lines = append(lines, fmt.Sprintf("%s %s (%s) %s", charts.RancherMonitoringName, result.Version, result.Duration.Round(time.Second), status))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// MonitoringVerifyFunc is the verification the version matrix runs once a rancher-monitoring version is installed and
+// its workloads are ready. The client has the session of the version, so the resources the verification creates are
+// cleaned up before the chart is uninstalled.
+type MonitoringVerifyFunc func(client *rancher.Client, clusterID, version string) error
+
+// GetMonitoringMatrixVersions is a helper function that returns the rancher-monitoring versions of the configuration
+// file, the versions set or the latest ones of the rancher-charts repository. It returns none when neither is set.
+func GetMonitoringMatrixVersions(client *rancher.Client) ([]string, error) {
+	matrixConfig := new(MonitoringVersionMatrixConfig)
+	config.LoadConfig(MonitoringVersionMatrixConfigurationFileKey, matrixConfig)
+
+	if len(matrixConfig.Versions) > 0 {
+		return matrixConfig.Versions, nil
+	}
+
+	if matrixConfig.Latest <= 0 {
+		return nil, nil
+	}
+
+	versions, err := client.Catalog.GetListChartVersions(charts.RancherMonitoringName, catalog.RancherChartRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(versions) > matrixConfig.Latest {
+		versions = versions[:matrixConfig.Latest]
+	}
+
+	return versions, nil
+}
+
+// RunMonitoringVersionMatrix is a helper function that, for every version in order, installs the rancher-monitoring
+// chart with the version and the feature options, waits for its workloads, runs the verification and uninstalls the
+// chart and its CRD chart, e.g. to qualify the versions of the chart against one rancher version. A failing version
+// doesn't stop the matrix; its error is recorded in its result. The chart must not be installed on the cluster, so
+// every version is installed from scratch.
+func RunMonitoringVersionMatrix(client *rancher.Client, installOptions *charts.InstallOptions, rancherMonitoringOpts *charts.RancherMonitoringOpts, versions []string, verify MonitoringVerifyFunc) (MonitoringVersionMatrixResults, error) {
+	clusterID := installOptions.Cluster.ID
+
+	chartStatus, err := charts.GetChartStatus(client, clusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
+	if err != nil {
+		return nil, err
+	}
+
+	if chartStatus.IsAlreadyInstalled {
+		return nil, fmt.Errorf("%s is already installed in cluster %s", charts.RancherMonitoringName, clusterID)
+	}
+
+	results := make(MonitoringVersionMatrixResults, 0, len(versions))
+	for _, version := range versions {
+		logrus.Infof("Running the version matrix of %s with version %s in cluster %s", charts.RancherMonitoringName, version, clusterID)
+
+		startTime := time.Now()
+		err := runMonitoringVersion(client, installOptions, rancherMonitoringOpts, version, verify)
+		if err != nil {
+			logrus.Warnf("Version %s of %s failed: %v", version, charts.RancherMonitoringName, err)
+		}
+
+		results = append(results, MonitoringVersionResult{
+			Version:  version,
+			Err:      err,
+			Duration: time.Since(startTime),
+		})
+	}
+
+	return results, nil
+}
+
+// runMonitoringVersion is a private helper function that installs the rancher-monitoring version with a session of its
+// own, runs the verification and uninstalls the chart and its CRD chart, even when the install or the verification fail.
+func runMonitoringVersion(client *rancher.Client, installOptions *charts.InstallOptions, rancherMonitoringOpts *charts.RancherMonitoringOpts, version string, verify MonitoringVerifyFunc) (err error) {
+	clusterID := installOptions.Cluster.ID
+
+	versionSession := client.Session.NewSession()
+	versionClient, err := client.WithSession(versionSession)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		versionSession.Cleanup()
+
+		for _, releaseName := range []string{charts.RancherMonitoringName, rancherMonitoringCRDName} {
+			uninstallErr := UninstallChart(client, clusterID, charts.RancherMonitoringNamespace, releaseName)
+			if uninstallErr != nil && err == nil {
+				err = fmt.Errorf("failed to uninstall %s: %w", releaseName, uninstallErr)
+			}
+		}
+	}()
+
+	versionInstallOptions := *installOptions
+	versionInstallOptions.Version = version
+
+	err = InstallRancherMonitoringChart(versionClient, &versionInstallOptions, rancherMonitoringOpts, nil)
+	if err != nil {
+		return fmt.Errorf("failed to install: %w", err)
+	}
+
+	err = WatchAndWaitWorkloads(versionClient, clusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("workloads are not ready: %w", err)
+	}
+
+	verifySession := versionSession.NewSession()
+	verifyClient, err := versionClient.WithSession(verifySession)
+	if err != nil {
+		return err
+	}
+
+	defer verifySession.Cleanup()
+
+	return verify(verifyClient, clusterID, version)
+}
@@ -0,0 +1,189 @@
+package charts
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	catalogv1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"github.com/rancher/rancher/tests/v2/actions/downstream"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// operationLogsGracePeriod is the time the streams of the helm operations are drained for once streaming is stopped
+const operationLogsGracePeriod = 30 * time.Second
+
+// OperationOptions is a struct of the options of the helm operations of the chart helpers, e.g.
+// InstallRancherMonitoringChart, like the StreamLogs of ChartInstallOptions. Nil options are the defaults.
+type OperationOptions struct {
+	// StreamLogs writes the logs of the helm operations to the test log while the chart installs or upgrades
+	StreamLogs bool
+}
+
+// GetStreamLogs returns whether the logs of the helm operations are streamed. It is safe to call on nil options.
+func (o *OperationOptions) GetStreamLogs() bool {
+	return o != nil && o.StreamLogs
+}
+
+// StreamOperationLogs behaves as the StreamOperationLogs helper function when the options stream the logs, and returns
+// a stop function doing nothing otherwise. It is safe to call on nil options.
+func (o *OperationOptions) StreamOperationLogs(client *rancher.Client, clusterID, namespace, releasePrefix string) (stop func()) {
+	if !o.GetStreamLogs() {
+		return func() {}
+	}
+
+	return StreamOperationLogs(client, clusterID, namespace, releasePrefix)
+}
+
+// StreamOperationLogs is a helper function that follows the helm operations started from now on for the releases of the
+// namespace whose name starts with the release prefix, e.g. rancher-monitoring for both the chart and its CRD chart, and
+// writes their logs to the test log line by line. It returns the function that stops streaming: no new operation is
+// followed, and the logs of the ones being followed are drained until their helm container ends, for 30 seconds at
+// most, so the end of the log is written. Errors are logged and never fail the caller.
+func StreamOperationLogs(client *rancher.Client, clusterID, namespace, releasePrefix string) (stop func()) {
+	discoverCtx, stopDiscovery := context.WithCancel(context.Background())
+	followCtx, stopFollowing := context.WithCancel(context.Background())
+	discoverWaitGroup := &sync.WaitGroup{}
+	followWaitGroup := &sync.WaitGroup{}
+	startTime := metav1.NewTime(time.Now().Add(-time.Second))
+
+	stop = func() {
+		stopDiscovery()
+		discoverWaitGroup.Wait()
+
+		drained := make(chan struct{})
+		go func() {
+			followWaitGroup.Wait()
+			close(drained)
+		}()
+
+		select {
+		case <-drained:
+		case <-time.After(operationLogsGracePeriod):
+			logrus.Warnf("The helm operation logs of %s were not drained within %s", releasePrefix, operationLogsGracePeriod)
+		}
+
+		stopFollowing()
+		followWaitGroup.Wait()
+	}
+
+	adminClient, err := rancher.NewClient(client.RancherConfig.AdminToken, client.Session)
+	if err != nil {
+		logrus.Warnf("Unable to stream the helm operation logs of %s: %v", releasePrefix, err)
+		return stop
+	}
+
+	catalogClient, err := adminClient.GetClusterCatalogClient(clusterID)
+	if err != nil {
+		logrus.Warnf("Unable to stream the helm operation logs of %s: %v", releasePrefix, err)
+		return stop
+	}
+
+	clientset, err := downstream.GetClientset(adminClient, clusterID)
+	if err != nil {
+		logrus.Warnf("Unable to stream the helm operation logs of %s: %v", releasePrefix, err)
+		return stop
+	}
+
+	streamedOperations := map[string]bool{}
+
+	discoverWaitGroup.Add(1)
+	go func() {
+		defer discoverWaitGroup.Done()
+
+		_ = kwait.PollUntilContextCancel(discoverCtx, timeouts.PollInterval(), true, func(ctx context.Context) (done bool, err error) {
+			operationList, err := catalogClient.Operations(namespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return false, nil
+			}
+
+			for _, operation := range operationList.Items {
+				if streamedOperations[operation.Name] || operation.Status.PodName == "" || operation.CreationTimestamp.Before(&startTime) {
+					continue
+				}
+
+				if !strings.HasPrefix(operation.Status.Release, releasePrefix) {
+					continue
+				}
+
+				streamedOperations[operation.Name] = true
+
+				followWaitGroup.Add(1)
+				go func(operation catalogv1.Operation) {
+					defer followWaitGroup.Done()
+					followOperationLogs(followCtx, clientset, &operation)
+				}(operation)
+			}
+
+			return false, nil
+		})
+	}()
+
+	return stop
+}
+
+// InstallRancherMonitoringChart is a helper function that installs the rancher-monitoring chart like the shepherd helper
+// does and, when the operation options stream the logs, writes the logs of its helm operations to the test log while it
+// installs. Helm operations in progress on the chart are handled with the policy of GetInFlightPolicy.
+func InstallRancherMonitoringChart(client *rancher.Client, installOptions *charts.InstallOptions, rancherMonitoringOpts *charts.RancherMonitoringOpts, operationOptions *OperationOptions) error {
+	return GuardReleaseOperation(client, installOptions.Cluster.ID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName, GetInFlightPolicy(), func() error {
+		stop := operationOptions.StreamOperationLogs(client, installOptions.Cluster.ID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
+		defer stop()
+
+		return charts.InstallRancherMonitoringChart(client, installOptions, rancherMonitoringOpts)
+	})
+}
+
+// UpgradeRancherMonitoringChart is a helper function that upgrades the rancher-monitoring chart like the shepherd helper
+// does and, when the operation options stream the logs, writes the logs of its helm operations to the test log while it
+// upgrades. Helm operations in progress on the chart are handled with the policy of GetInFlightPolicy.
+func UpgradeRancherMonitoringChart(client *rancher.Client, installOptions *charts.InstallOptions, rancherMonitoringOpts *charts.RancherMonitoringOpts, operationOptions *OperationOptions) error {
+	return GuardReleaseOperation(client, installOptions.Cluster.ID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName, GetInFlightPolicy(), func() error {
+		stop := operationOptions.StreamOperationLogs(client, installOptions.Cluster.ID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
+		defer stop()
+
+		return charts.UpgradeRancherMonitoringChart(client, installOptions, rancherMonitoringOpts)
+	})
+}
+
+// followOperationLogs is a private helper function that waits for the helm container of the operation pod to start and
+// follows its log until it ends or the context is canceled.
+func followOperationLogs(ctx context.Context, clientset *kubernetes.Clientset, operation *catalogv1.Operation) {
+	pods := clientset.CoreV1().Pods(operation.Status.PodNamespace)
+
+	err := kwait.PollUntilContextCancel(ctx, timeouts.PollInterval(), true, func(ctx context.Context) (done bool, err error) {
+		pod, err := pods.Get(ctx, operation.Status.PodName, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		return pod.Status.Phase != corev1.PodPending, nil
+	})
+	if err != nil {
+		return
+	}
+
+	logStream, err := pods.GetLogs(operation.Status.PodName, &corev1.PodLogOptions{
+		Container: helmContainerName,
+		Follow:    true,
+	}).Stream(ctx)
+	if err != nil {
+		logrus.Warnf("Unable to follow the log of helm operation %s: %v", operation.Name, err)
+		return
+	}
+	defer logStream.Close()
+
+	scanner := bufio.NewScanner(logStream)
+	for scanner.Scan() {
+		logrus.Infof("[helm %s %s] %s", operation.Status.Action, operation.Status.Release, scanner.Text())
+	}
+}
@@ -0,0 +1,171 @@
+package charts
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/pkg/clientbase"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	localClusterID       = "local"
+	maxOwnershipDepth    = 10
+	ownershipChainFormat = "%s %s"
+)
+
+// OwnerLink is a struct of a resource of an ownership chain.
+type OwnerLink struct {
+	// SteveType is the steve type of the resource, e.g. apps.deployment
+	SteveType string
+	Kind      string
+	// Namespace is empty for cluster scoped resources
+	Namespace string
+	Name      string
+}
+
+// String returns the kind and the namespaced name of the resource.
+func (l OwnerLink) String() string {
+	if l.Namespace == "" {
+		return fmt.Sprintf(ownershipChainFormat, l.Kind, l.Name)
+	}
+
+	return fmt.Sprintf(ownershipChainFormat, l.Kind, l.Namespace+"/"+l.Name)
+}
+
+// OwnershipChain is a struct of the owners of a resource, from the resource itself up to the resource without a
+// controller owner, e.g. pod, replica set, deployment, along with the helm release that resource belongs to, if any.
+type OwnershipChain struct {
+	Links []OwnerLink
+	// ReleaseName is the helm release of the last link of the chain, empty when it has none
+	ReleaseName string
+	// ReleaseNamespace is the namespace of the helm release
+	ReleaseNamespace string
+}
+
+// String returns the links of the chain and the release they belong to.
+func (c *OwnershipChain) String() string {
+	links := make([]string, 0, len(c.Links))
+	for _, link := range c.Links {
+		links = append(links, link.String())
+	}
+
+	chain := strings.Join(links, " -> ")
+	if c.ReleaseName == "" {
+		return chain + " (no release)"
+	}
+
+	return fmt.Sprintf("%s (release %s/%s)", chain, c.ReleaseNamespace, c.ReleaseName)
+}
+
+// Root returns the last link of the chain, the resource that owns every other one, e.g. the one to delete to remove
+// them all.
+func (c *OwnershipChain) Root() OwnerLink {
+	return c.Links[len(c.Links)-1]
+}
+
+// IsOwnedByRelease returns whether the resource of the chain was created by the helm release, directly or through its
+// owners.
+func (c *OwnershipChain) IsOwnedByRelease(namespace, releaseName string) bool {
+	return c.ReleaseNamespace == namespace && c.ReleaseName == releaseName
+}
+
+// GetOwnershipChain is a helper function that walks the controller owner references of the resource of the steve type,
+// e.g. pod or apps.deployment, up through steve until a resource has no owner or belongs to a helm release, and
+// returns the chain. Operator managed resources are followed to their custom resource, e.g. a prometheus pod to its
+// statefulset and its monitoring.coreos.com prometheus, which is the one the release created.
+func GetOwnershipChain(client *rancher.Client, clusterID, steveType, namespace, name string) (*OwnershipChain, error) {
+	steveClient := client.Steve
+	if clusterID != localClusterID {
+		var err error
+		steveClient, err = client.Steve.ProxyDownstream(clusterID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	chain := &OwnershipChain{}
+	link := OwnerLink{SteveType: steveType, Namespace: namespace, Name: name}
+
+	for i := 0; i < maxOwnershipDepth; i++ {
+		object, err := getOwnerObject(steveClient, link)
+		if err != nil {
+			return chain, fmt.Errorf("unable to get %s %s of the ownership chain: %w", link.SteveType, link.Name, err)
+		}
+
+		link.Namespace = object.Namespace
+		link.Kind = object.Kind
+		chain.Links = append(chain.Links, link)
+
+		annotations := object.GetAnnotations()
+		if annotations[helmReleaseNameAnnotation] != "" {
+			chain.ReleaseName = annotations[helmReleaseNameAnnotation]
+			chain.ReleaseNamespace = annotations[helmReleaseNamespaceAnnotation]
+			return chain, nil
+		}
+
+		owner := controllerOwner(object.OwnerReferences)
+		if owner == nil {
+			return chain, nil
+		}
+
+		ownerGroupVersion, err := schema.ParseGroupVersion(owner.APIVersion)
+		if err != nil {
+			return chain, err
+		}
+
+		link = OwnerLink{
+			SteveType: ownerSteveType(ownerGroupVersion.Group, owner.Kind),
+			Kind:      owner.Kind,
+			Namespace: object.Namespace,
+			Name:      owner.Name,
+		}
+	}
+
+	return chain, fmt.Errorf("ownership chain of %s %s is longer than %d links: %s", steveType, name, maxOwnershipDepth, chain)
+}
+
+// getOwnerObject is a private helper function that returns the resource of the link. Owner references don't tell
+// whether the owner is namespaced, so an owner missing from the namespace of its dependent is looked up as a cluster
+// scoped resource.
+func getOwnerObject(steveClient *v1.Client, link OwnerLink) (*v1.SteveAPIObject, error) {
+	if link.Namespace == "" {
+		return steveClient.SteveType(link.SteveType).ByID(link.Name)
+	}
+
+	object, err := steveClient.SteveType(link.SteveType).ByID(link.Namespace + "/" + link.Name)
+	if clientbase.IsNotFound(err) {
+		return steveClient.SteveType(link.SteveType).ByID(link.Name)
+	}
+
+	return object, err
+}
+
+// controllerOwner is a private helper function that returns the owner reference of the controller of a resource, or
+// else its first owner reference, nil when it has none.
+func controllerOwner(ownerReferences []metav1.OwnerReference) *metav1.OwnerReference {
+	for i, ownerReference := range ownerReferences {
+		if ownerReference.Controller != nil && *ownerReference.Controller {
+			return &ownerReferences[i]
+		}
+	}
+
+	if len(ownerReferences) > 0 {
+		return &ownerReferences[0]
+	}
+
+	return nil
+}
+
+// ownerSteveType is a private helper function that returns the steve type of the kind of the API group, e.g. pod for a
+// core Pod and apps.replicaset for an apps ReplicaSet.
+func ownerSteveType(group, kind string) string {
+	if group == "" {
+		return strings.ToLower(kind)
+	}
+
+	return group + "." + strings.ToLower(kind)
+}
@@ -0,0 +1,103 @@
+package charts
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rancher/rancher/tests/v2/actions/sessionid"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/extensions/projects"
+	"github.com/sirupsen/logrus"
+)
+
+// ClusterInstallFunc is the function type used to install a chart in a single cluster, e.g.
+//
+//	installFunc := func(client *rancher.Client, installOptions *charts.InstallOptions) error {
+//		return charts.InstallRancherMonitoringChart(client, installOptions, monitoringOpts)
+//	}
+type ClusterInstallFunc func(client *rancher.Client, installOptions *charts.InstallOptions) error
+
+// ClusterInstallResult is a struct that InstallChartOnClusters helper function returns for each cluster.
+type ClusterInstallResult struct {
+	ClusterName string
+	ClusterID   string
+	Duration    time.Duration
+	Err         error
+}
+
+// InstallChartOnClusters is a helper function that runs the install function concurrently for each of the given clusters,
+// in the project with the given name of each cluster. Every install gets its own sub session of the client session, so the
+// chart cleanups registered by the install functions don't race with each other. All results are returned in the order
+// of clusterNames; the returned error joins the errors of the clusters that failed.
+func InstallChartOnClusters(client *rancher.Client, clusterNames []string, projectName, version string, installFunc ClusterInstallFunc) ([]*ClusterInstallResult, error) {
+	results := make([]*ClusterInstallResult, len(clusterNames))
+	clients := make([]*rancher.Client, len(clusterNames))
+
+	// sessions are not safe for concurrent use, create the sub sessions before fanning out
+	for i, clusterName := range clusterNames {
+		results[i] = &ClusterInstallResult{ClusterName: clusterName}
+
+		clusterClient, err := sessionid.WithSession(client, client.Session.NewSession())
+		if err != nil {
+			return nil, err
+		}
+
+		clients[i] = clusterClient
+	}
+
+	var waitGroup sync.WaitGroup
+	for i := range clusterNames {
+		waitGroup.Add(1)
+
+		go func(result *ClusterInstallResult, clusterClient *rancher.Client) {
+			defer waitGroup.Done()
+
+			start := time.Now()
+			result.ClusterID, result.Err = installChartOnCluster(clusterClient, result.ClusterName, projectName, version, installFunc)
+			result.Duration = time.Since(start)
+
+			if result.Err != nil {
+				logrus.Errorf("Chart install failed in cluster [%s] after %v: %v", result.ClusterName, result.Duration, result.Err)
+				return
+			}
+
+			logrus.Infof("Chart install succeeded in cluster [%s] after %v", result.ClusterName, result.Duration)
+		}(results[i], clients[i])
+	}
+
+	waitGroup.Wait()
+
+	var errs []error
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("cluster %s: %w", result.ClusterName, result.Err))
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// installChartOnCluster is a private helper function that builds the install options for a single cluster and runs the install function.
+func installChartOnCluster(client *rancher.Client, clusterName, projectName, version string, installFunc ClusterInstallFunc) (string, error) {
+	cluster, err := clusters.NewClusterMeta(client, clusterName)
+	if err != nil {
+		return "", err
+	}
+
+	project, err := projects.GetProjectByName(client, cluster.ID, projectName)
+	if err != nil {
+		return cluster.ID, err
+	}
+
+	installOptions := &charts.InstallOptions{
+		Cluster:   cluster,
+		Version:   version,
+		ProjectID: project.ID,
+	}
+
+	return cluster.ID, installFunc(client, installOptions)
+}
@@ -0,0 +1,47 @@
+package charts
+
+const defaultPrometheusStorageSize = "50Gi"
+
+// PrometheusPersistenceOpts is a struct of the persistent storage of the rancher-monitoring prometheus, which completes
+// the RancherMonitoringOpts of the shepherd charts extension. Without it, prometheus keeps its TSDB and WAL in an
+// emptyDir volume that is lost when its pod is restarted.
+type PrometheusPersistenceOpts struct {
+	// StorageClassName is the storage class of the volume of prometheus, the default one of the cluster when empty
+	StorageClassName string
+	// Size is the size of the volume of prometheus, e.g. 10Gi, 50Gi when empty
+	Size string
+}
+
+// Values returns the rancher-monitoring values of the persistent storage of prometheus, to set as the values of the
+// InstallOptions of InstallRancherMonitoringChartWithValues.
+func (o *PrometheusPersistenceOpts) Values() map[string]interface{} {
+	size := o.Size
+	if size == "" {
+		size = defaultPrometheusStorageSize
+	}
+
+	volumeClaimSpec := map[string]interface{}{
+		"accessModes": []interface{}{"ReadWriteOnce"},
+		"resources": map[string]interface{}{
+			"requests": map[string]interface{}{
+				"storage": size,
+			},
+		},
+	}
+
+	if o.StorageClassName != "" {
+		volumeClaimSpec["storageClassName"] = o.StorageClassName
+	}
+
+	return map[string]interface{}{
+		"prometheus": map[string]interface{}{
+			"prometheusSpec": map[string]interface{}{
+				"storageSpec": map[string]interface{}{
+					"volumeClaimTemplate": map[string]interface{}{
+						"spec": volumeClaimSpec,
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,122 @@
+package charts
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/kubeapi/workloads/daemonsets"
+	"github.com/rancher/shepherd/extensions/kubeapi/workloads/deployments"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WorkloadsProgressFunc is the function type the wait helpers report the workloads that are still not ready to, sorted
+// by name, along with the resource they are waiting for, e.g. deployments, and the time they have waited so far.
+type WorkloadsProgressFunc func(resource string, pending []string, elapsed time.Duration)
+
+// WaitOptions is a struct of the options of a wait for workloads. Its zero value behaves as the wait helpers without
+// options.
+type WaitOptions struct {
+	// Options are the poll interval and timeout of the wait, the defaults of the timeouts package when not set
+	timeouts.Options
+	// Progress is called with the pending workloads when the wait starts and then at every progress interval, nil to
+	// report nothing
+	Progress WorkloadsProgressFunc
+	// ProgressInterval is the time between two reports, the poll interval when zero
+	ProgressInterval time.Duration
+}
+
+// LogWorkloadsProgress is a WorkloadsProgressFunc that logs the pending workloads, e.g. to follow a long chart install
+// from the test log.
+func LogWorkloadsProgress(resource string, pending []string, elapsed time.Duration) {
+	logrus.Infof("Waiting for %d %s to be ready after %s: %s", len(pending), resource, elapsed.Round(time.Second), strings.Join(pending, ", "))
+}
+
+// WatchAndWaitDeploymentsWithContext is a helper function that behaves as WatchAndWaitDeployments, stopping when the
+// context is done and reporting the deployments that are still not ready to the progress function of the wait options.
+func WatchAndWaitDeploymentsWithContext(ctx context.Context, client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions, waitOptions *WaitOptions) error {
+	return watchAndWaitWorkloads(ctx, client, clusterID, namespace, listOptions, deployments.DeploymentGroupVersionResource, isDeploymentReady, waitOptions)
+}
+
+// WatchAndWaitDaemonSetsWithContext is a helper function that behaves as WatchAndWaitDaemonSets, stopping when the
+// context is done and reporting the DaemonSets that are still not ready to the progress function of the wait options.
+func WatchAndWaitDaemonSetsWithContext(ctx context.Context, client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions, waitOptions *WaitOptions) error {
+	return watchAndWaitWorkloads(ctx, client, clusterID, namespace, listOptions, daemonsets.DaemonSetGroupVersionResource, isDaemonSetReady, waitOptions)
+}
+
+// WatchAndWaitStatefulSetsWithContext is a helper function that behaves as WatchAndWaitStatefulSets, stopping when the
+// context is done and reporting the StatefulSets that are still not ready to the progress function of the wait options.
+func WatchAndWaitStatefulSetsWithContext(ctx context.Context, client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions, waitOptions *WaitOptions) error {
+	return watchAndWaitWorkloads(ctx, client, clusterID, namespace, listOptions, StatefulSetGroupVersionResource, isStatefulSetReady, waitOptions)
+}
+
+// WatchAndWaitWorkloadsWithContext is a helper function that behaves as WatchAndWaitWorkloads, stopping when the
+// context is done and reporting the workloads that are still not ready to the progress function of the wait options.
+func WatchAndWaitWorkloadsWithContext(ctx context.Context, client *rancher.Client, clusterID, namespace string, listOptions metav1.ListOptions, waitOptions *WaitOptions) error {
+	return watchAndWaitNamespaceWorkloads(ctx, client, clusterID, namespace, listOptions, waitOptions)
+}
+
+// newWaitOptions is a private constructor that returns the wait options of the timeouts options, without progress.
+func newWaitOptions(timeoutOptions *timeouts.Options) *WaitOptions {
+	if timeoutOptions == nil {
+		return nil
+	}
+
+	return &WaitOptions{Options: *timeoutOptions}
+}
+
+// timeouts is a private method that returns the timeouts options of the wait options. It is safe to call on nil options.
+func (o *WaitOptions) timeouts() *timeouts.Options {
+	if o == nil {
+		return nil
+	}
+
+	return &o.Options
+}
+
+// workloadsProgress is a private struct that throttles the reports of a single wait to its interval.
+type workloadsProgress struct {
+	resource   string
+	progress   WorkloadsProgressFunc
+	interval   time.Duration
+	start      time.Time
+	lastReport time.Time
+}
+
+// newWorkloadsProgress is a private constructor that returns the progress of a wait for the resource.
+func newWorkloadsProgress(resource string, waitOptions *WaitOptions) *workloadsProgress {
+	interval := waitOptions.timeouts().GetPollInterval()
+	progress := &workloadsProgress{
+		resource: resource,
+		start:    time.Now(),
+	}
+
+	if waitOptions != nil {
+		progress.progress = waitOptions.Progress
+		if waitOptions.ProgressInterval > 0 {
+			interval = waitOptions.ProgressInterval
+		}
+	}
+
+	progress.interval = interval
+
+	return progress
+}
+
+// report is a private method that reports the pending workloads when forced or when the interval has passed since the
+// last report.
+func (p *workloadsProgress) report(pendingWorkloads map[string]bool, force bool) {
+	if p.progress == nil || len(pendingWorkloads) == 0 {
+		return
+	}
+
+	if !force && time.Since(p.lastReport) < p.interval {
+		return
+	}
+
+	p.lastReport = time.Now()
+	p.progress(p.resource, sortedNames(pendingWorkloads), time.Since(p.start))
+}
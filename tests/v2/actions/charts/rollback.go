@@ -0,0 +1,220 @@
+package charts
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	catalogv1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/catalogv2/helm"
+	"github.com/rancher/rancher/tests/v2/actions/downstream"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/clients/rancher/catalog"
+	"github.com/rancher/shepherd/pkg/api/steve/catalog/types"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	uiSourceRepoAnnotation     = "catalog.cattle.io/ui-source-repo"
+	uiSourceRepoTypeAnnotation = "catalog.cattle.io/ui-source-repo-type"
+	helmReleaseSecretFormat    = "sh.helm.release.v1.%s.v%d"
+	chartUpgradeTimeout        = 10 * time.Minute
+)
+
+// RollbackChart is a helper function that rolls the release back to the revision, e.g. the revision of the release
+// before an upgrade, from the ChartDetails.Spec.Version of its chart status. The catalog API has no rollback action,
+// so like the rancher UI it upgrades the release to the chart version and values of the revision, from the repository
+// the revision was installed from. It waits for the helm operation to complete and for the release to be deployed with
+// the chart version of the revision; when the operation fails, the error holds the end of its log. Helm operations in
+// progress on the release are handled with the policy of GetInFlightPolicy.
+func RollbackChart(client *rancher.Client, clusterID, namespace, releaseName string, revision int) error {
+	adminClient, err := rancher.NewClient(client.RancherConfig.AdminToken, client.Session)
+	if err != nil {
+		return err
+	}
+
+	catalogClient, err := adminClient.GetClusterCatalogClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	clientset, err := downstream.GetClientset(adminClient, clusterID)
+	if err != nil {
+		return err
+	}
+
+	release, err := getReleaseRevision(clientset, namespace, releaseName, revision)
+	if err != nil {
+		return err
+	}
+
+	repoName := release.Chart.Metadata.Annotations[uiSourceRepoAnnotation]
+	if repoName == "" {
+		repoName = catalog.RancherChartRepo
+	}
+
+	logrus.Infof("Rolling back %s/%s in cluster %s to revision %d, %s %s", namespace, releaseName, clusterID, revision, release.Chart.Metadata.Name, release.Chart.Metadata.Version)
+
+	err = upgradeRelease(client, catalogClient, clientset, clusterID, namespace, releaseName, &types.ChartUpgrade{
+		ChartName:   release.Chart.Metadata.Name,
+		Version:     release.Chart.Metadata.Version,
+		ReleaseName: releaseName,
+		ResetValues: true,
+		Description: fmt.Sprintf("Rollback to %d", revision),
+		Values:      release.Values,
+	}, repoName)
+	if err != nil {
+		return fmt.Errorf("failed to roll back %s/%s in cluster %s to revision %d: %w", namespace, releaseName, clusterID, revision, err)
+	}
+
+	return nil
+}
+
+// upgradeRelease is a private helper function that upgrades the release with the chart upgrade, guarded with the policy
+// of GetInFlightPolicy, waits for the helm operation to complete and for the release to be deployed with the chart
+// version of the upgrade. When the operation fails, the error holds the end of its log.
+func upgradeRelease(client *rancher.Client, catalogClient *catalog.Client, clientset *kubernetes.Clientset, clusterID, namespace, releaseName string, chartUpgrade *types.ChartUpgrade, repoName string) error {
+	chartUpgrade.Annotations = map[string]string{
+		uiSourceRepoAnnotation:     repoName,
+		uiSourceRepoTypeAnnotation: "cluster",
+	}
+
+	startTime := metav1.NewTime(time.Now().Add(-time.Second))
+
+	err := GuardReleaseOperation(client, clusterID, namespace, releaseName, GetInFlightPolicy(), func() error {
+		return catalogClient.UpgradeChart(&types.ChartUpgradeAction{
+			Timeout:   &metav1.Duration{Duration: timeouts.Scale(chartUpgradeTimeout)},
+			Wait:      true,
+			Namespace: namespace,
+			Charts:    []types.ChartUpgrade{*chartUpgrade},
+		}, repoName)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = waitForOperationPod(catalogClient, clientset, namespace, releaseName, startTime)
+	if err != nil {
+		return err
+	}
+
+	var app *catalogv1.App
+	var lastErr error
+
+	return wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Timeout(), func(ctx context.Context) (done bool, err error) {
+		app, lastErr = catalogClient.Apps(namespace).Get(ctx, releaseName, metav1.GetOptions{})
+		if lastErr != nil {
+			return false, nil
+		}
+
+		if app.Spec.Info == nil || app.Spec.Chart == nil || app.Spec.Chart.Metadata == nil {
+			return false, nil
+		}
+
+		return app.Spec.Info.Status == catalogv1.StatusDeployed && app.Spec.Chart.Metadata.Version == chartUpgrade.Version, nil
+	}, func() string {
+		if lastErr != nil {
+			return fmt.Sprintf("unable to get release %s/%s: %v", namespace, releaseName, lastErr)
+		}
+
+		if app == nil || app.Spec.Info == nil || app.Spec.Chart == nil || app.Spec.Chart.Metadata == nil {
+			return fmt.Sprintf("release %s/%s has no status", namespace, releaseName)
+		}
+
+		return fmt.Sprintf("release %s/%s is %s with version %s", namespace, releaseName, app.Spec.Info.Status, app.Spec.Chart.Metadata.Version)
+	})
+}
+
+// getReleaseRevision is a private helper function that returns the revision of the release from its helm release secret.
+func getReleaseRevision(clientset *kubernetes.Clientset, namespace, releaseName string, revision int) (*catalogv1.ReleaseSpec, error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), fmt.Sprintf(helmReleaseSecretFormat, releaseName, revision), metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get revision %d of release %s/%s: %w", revision, namespace, releaseName, err)
+	}
+
+	release, err := helm.ToRelease(secret, func(schema.GroupVersionKind) bool { return true })
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode revision %d of release %s/%s: %w", revision, namespace, releaseName, err)
+	}
+
+	if release.Chart == nil || release.Chart.Metadata == nil {
+		return nil, fmt.Errorf("revision %d of release %s/%s has no chart", revision, namespace, releaseName)
+	}
+
+	return release, nil
+}
+
+// waitForOperationPod is a private helper function that waits for the helm container of the pod of the latest helm
+// operation on the release, started after the start time, to terminate. It fails with the end of the log of the
+// container when it exits with an error.
+func waitForOperationPod(catalogClient *catalog.Client, clientset *kubernetes.Clientset, namespace, releaseName string, startTime metav1.Time) error {
+	var operation *catalogv1.Operation
+	var helmState *corev1.ContainerState
+	var lastErr error
+
+	err := wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(chartUpgradeTimeout), func(ctx context.Context) (done bool, err error) {
+		helmState = nil
+
+		latestOperation, err := getLatestOperation(catalogClient, namespace, releaseName)
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+
+		lastErr = nil
+		operation = latestOperation
+
+		if operation == nil || operation.CreationTimestamp.Before(&startTime) || operation.Status.PodName == "" {
+			operation = nil
+			return false, nil
+		}
+
+		pod, err := clientset.CoreV1().Pods(operation.Status.PodNamespace).Get(ctx, operation.Status.PodName, metav1.GetOptions{})
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+
+		for i, containerStatus := range pod.Status.ContainerStatuses {
+			if containerStatus.Name == helmContainerName {
+				helmState = &pod.Status.ContainerStatuses[i].State
+			}
+		}
+
+		return helmState != nil && helmState.Terminated != nil, nil
+	}, func() string {
+		if lastErr != nil {
+			return fmt.Sprintf("helm operation on release %s/%s: last error: %v", namespace, releaseName, lastErr)
+		}
+
+		if operation == nil {
+			return fmt.Sprintf("no helm operation on release %s/%s", namespace, releaseName)
+		}
+
+		return fmt.Sprintf("helm operation %s is running in pod %s", operation.Name, operation.Status.PodName)
+	})
+	if err != nil {
+		return err
+	}
+
+	if helmState.Terminated.ExitCode == 0 {
+		return nil
+	}
+
+	tailLines := operationLogLines
+	logs, err := clientset.CoreV1().Pods(operation.Status.PodNamespace).GetLogs(operation.Status.PodName, &corev1.PodLogOptions{
+		Container: helmContainerName,
+		TailLines: &tailLines,
+	}).DoRaw(context.TODO())
+	if err != nil {
+		logrus.Warnf("Unable to get the log of helm operation %s: %v", operation.Name, err)
+	}
+
+	return fmt.Errorf("helm operation %s exited with code %d:\n%s", operation.Name, helmState.Terminated.ExitCode, string(logs))
+}
@@ -0,0 +1,232 @@
+package charts
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	catalogv1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"github.com/rancher/rancher/pkg/catalogv2/helm"
+	"github.com/rancher/rancher/tests/v2/actions/downstream"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/clients/rancher/catalog"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	helmContainerName         = "helm"
+	operationLogLines         = int64(30)
+	helmReleaseSecretSelector = "owner=helm,name=%s"
+)
+
+// ChartStatus is a struct of the status of a chart release. It extends the shepherd chart status, whose
+// IsAlreadyInstalled is true for any release, with whether the release is healthy and, when it is not, why.
+type ChartStatus struct {
+	*charts.ChartStatus
+	// IsHealthy is true when the release is installed and deployed
+	IsHealthy bool
+	// State is the helm status of the release, e.g. deployed, failed or pending-upgrade
+	State catalogv1.Status
+	// FailureReason is the helm description of the last operation on a release that is not deployed
+	FailureReason string
+	// OperationLogTail is the end of the log of the last helm operation on a release that is not deployed
+	OperationLogTail string
+	// Values are the values the release is deployed with, the ones set on install and upgrade
+	Values map[string]interface{}
+	// ComputedValues are the default values of the chart deeply merged with the values of the release
+	ComputedValues map[string]interface{}
+	// History are the revisions of the release helm keeps, the oldest first, nil when they can't be read, e.g. when the
+	// user can't read the release secrets
+	History []ReleaseRevision
+}
+
+// ReleaseRevision is a struct of a revision of a chart release.
+type ReleaseRevision struct {
+	Revision int
+	// ChartVersion is the version of the chart of the revision
+	ChartVersion string
+	// State is the helm status of the revision, e.g. superseded, deployed or failed
+	State catalogv1.Status
+	// Description is the helm description of the operation of the revision, e.g. Upgrade complete
+	Description string
+	// Deployed is when the revision was deployed
+	Deployed time.Time
+	// Values are the values the revision was deployed with
+	Values map[string]interface{}
+}
+
+// GetChartStatus is a helper function that returns the status of the chart release in the namespace of the cluster,
+// with its values and, on a best-effort basis, its revision history, e.g. to check values persist across an upgrade.
+// Use GetReleaseHistory to fail when the history can't be read. When the release is failed
+// or pending, the status holds the reason helm gives and the end of the log of its last helm operation, so a half
+// broken release is not mistaken for a healthy install.
+func GetChartStatus(client *rancher.Client, clusterID, chartNamespace, chartName string) (*ChartStatus, error) {
+	shepherdStatus, err := charts.GetChartStatus(client, clusterID, chartNamespace, chartName)
+	if err != nil {
+		return nil, err
+	}
+
+	chartStatus := &ChartStatus{ChartStatus: shepherdStatus}
+	if !shepherdStatus.IsAlreadyInstalled {
+		return chartStatus, nil
+	}
+
+	spec := shepherdStatus.ChartDetails.Spec
+	chartStatus.Values = map[string]interface{}(spec.Values)
+
+	var chartValues map[string]interface{}
+	if spec.Chart != nil {
+		chartValues = map[string]interface{}(spec.Chart.Values)
+	}
+	chartStatus.ComputedValues = mergeValues(chartValues, chartStatus.Values)
+
+	chartStatus.History, err = GetReleaseHistory(client, clusterID, chartNamespace, chartName)
+	if err != nil {
+		logrus.Warnf("Unable to get the history of chart %s: %v", chartName, err)
+	}
+
+	if spec.Info == nil {
+		return chartStatus, nil
+	}
+
+	info := shepherdStatus.ChartDetails.Spec.Info
+	chartStatus.State = info.Status
+	chartStatus.IsHealthy = info.Status == catalogv1.StatusDeployed
+	if chartStatus.IsHealthy {
+		return chartStatus, nil
+	}
+
+	chartStatus.FailureReason = info.Description
+
+	logTail, err := getOperationLogTail(client, clusterID, chartNamespace, chartName)
+	if err != nil {
+		logrus.Warnf("Unable to get the helm operation log of chart %s: %v", chartName, err)
+	}
+	chartStatus.OperationLogTail = logTail
+
+	return chartStatus, nil
+}
+
+// String returns the state of the release and, when it is not healthy, its failure reason and operation log tail.
+func (s *ChartStatus) String() string {
+	if !s.IsAlreadyInstalled {
+		return "not installed"
+	}
+
+	if s.IsHealthy {
+		return string(s.State)
+	}
+
+	return fmt.Sprintf("%s: %s\n%s", s.State, s.FailureReason, s.OperationLogTail)
+}
+
+// GetReleaseHistory is a helper function that returns the revisions of the release helm keeps in its release secrets,
+// the oldest first. The user of the client must be able to read the secrets of the namespace.
+func GetReleaseHistory(client *rancher.Client, clusterID, namespace, releaseName string) ([]ReleaseRevision, error) {
+	clientset, err := downstream.GetClientset(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	secretList, err := clientset.CoreV1().Secrets(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf(helmReleaseSecretSelector, releaseName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var history []ReleaseRevision
+	for i := range secretList.Items {
+		release, err := helm.ToRelease(&secretList.Items[i], func(schema.GroupVersionKind) bool { return true })
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode release secret %s/%s: %w", namespace, secretList.Items[i].Name, err)
+		}
+
+		revision := ReleaseRevision{
+			Revision: release.Version,
+			Values:   map[string]interface{}(release.Values),
+		}
+
+		if release.Chart != nil && release.Chart.Metadata != nil {
+			revision.ChartVersion = release.Chart.Metadata.Version
+		}
+
+		if release.Info != nil {
+			revision.State = release.Info.Status
+			revision.Description = release.Info.Description
+			if release.Info.LastDeployed != nil {
+				revision.Deployed = release.Info.LastDeployed.Time
+			}
+		}
+
+		history = append(history, revision)
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Revision < history[j].Revision
+	})
+
+	return history, nil
+}
+
+// getOperationLogTail is a private helper function that returns the last lines of the log of the latest helm operation
+// on the release.
+func getOperationLogTail(client *rancher.Client, clusterID, chartNamespace, chartName string) (string, error) {
+	catalogClient, err := client.GetClusterCatalogClient(clusterID)
+	if err != nil {
+		return "", err
+	}
+
+	operation, err := getLatestOperation(catalogClient, chartNamespace, chartName)
+	if err != nil {
+		return "", err
+	}
+
+	if operation == nil || operation.Status.PodName == "" {
+		return "", nil
+	}
+
+	clientset, err := downstream.GetClientset(client, clusterID)
+	if err != nil {
+		return "", err
+	}
+
+	tailLines := operationLogLines
+	logs, err := clientset.CoreV1().Pods(operation.Status.PodNamespace).GetLogs(operation.Status.PodName, &corev1.PodLogOptions{
+		Container: helmContainerName,
+		TailLines: &tailLines,
+	}).DoRaw(context.TODO())
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(logs)), nil
+}
+
+// getLatestOperation is a private helper function that returns the most recent helm operation on the release, or nil
+// if there is none.
+func getLatestOperation(catalogClient *catalog.Client, chartNamespace, chartName string) (*catalogv1.Operation, error) {
+	operationList, err := catalogClient.Operations(chartNamespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var latestOperation *catalogv1.Operation
+	for i, operation := range operationList.Items {
+		if operation.Status.Release != chartName {
+			continue
+		}
+
+		if latestOperation == nil || latestOperation.CreationTimestamp.Before(&operation.CreationTimestamp) {
+			latestOperation = &operationList.Items[i]
+		}
+	}
+
+	return latestOperation, nil
+}
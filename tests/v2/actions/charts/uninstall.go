@@ -0,0 +1,182 @@
+package charts
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/pkg/api/steve/catalog/types"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	helmReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+	chartRemovalTimeout            = 10 * time.Minute
+)
+
+// CustomResourceDefinitionGroupVersionResource is the required Group Version Resource for accessing custom resource
+// definitions in a cluster, using the dynamic client.
+var CustomResourceDefinitionGroupVersionResource = schema.GroupVersionResource{
+	Group:    "apiextensions.k8s.io",
+	Version:  "v1",
+	Resource: "customresourcedefinitions",
+}
+
+// NamespaceGroupVersionResource is the required Group Version Resource for accessing namespaces in a cluster, using the
+// dynamic client.
+var NamespaceGroupVersionResource = corev1.SchemeGroupVersion.WithResource("namespaces")
+
+// releaseResource is a private struct of a resource a release deployed, whose namespace is empty for cluster scoped ones.
+type releaseResource struct {
+	groupVersionResource schema.GroupVersionResource
+	namespace            string
+	name                 string
+}
+
+// String returns the resource, namespace and name of the release resource.
+func (r releaseResource) String() string {
+	if r.namespace == "" {
+		return r.groupVersionResource.Resource + "/" + r.name
+	}
+
+	return r.groupVersionResource.Resource + "/" + r.namespace + "/" + r.name
+}
+
+// UninstallChart is a helper function that uninstalls the release through the catalog API and waits until its app, the
+// workloads and the cluster scoped resources it deployed, e.g. custom resource definitions, cluster roles and
+// namespaces, found by the helm release annotations, are gone, including the time their finalizers take. Releases that
+// are not installed are ignored.
+func UninstallChart(client *rancher.Client, clusterID, namespace, releaseName string) error {
+	adminClient, err := rancher.NewClient(client.RancherConfig.AdminToken, client.Session)
+	if err != nil {
+		return err
+	}
+
+	catalogClient, err := adminClient.GetClusterCatalogClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	_, err = catalogClient.Apps(namespace).Get(context.TODO(), releaseName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	adminDynamicClient, err := adminClient.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	resources, err := getReleaseResources(adminDynamicClient, namespace, releaseName)
+	if err != nil {
+		return err
+	}
+
+	err = GuardReleaseOperation(client, clusterID, namespace, releaseName, GetInFlightPolicy(), func() error {
+		return catalogClient.UninstallChart(releaseName, namespace, &types.ChartUninstallAction{})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to uninstall release %s/%s in cluster %s: %w", namespace, releaseName, clusterID, err)
+	}
+
+	appDeleted := false
+	remaining := resources
+
+	err = wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(chartRemovalTimeout), func(ctx context.Context) (done bool, err error) {
+		if !appDeleted {
+			_, err = catalogClient.Apps(namespace).Get(ctx, releaseName, metav1.GetOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return false, err
+			}
+
+			appDeleted = apierrors.IsNotFound(err)
+		}
+
+		remaining, err = getRemainingResources(ctx, adminDynamicClient, remaining)
+		if err != nil {
+			return false, err
+		}
+
+		return appDeleted && len(remaining) == 0, nil
+	}, func() string {
+		return fmt.Sprintf("app deleted: %t, resources remaining: %s", appDeleted, joinResources(remaining))
+	})
+	if err != nil {
+		return fmt.Errorf("release %s/%s in cluster %s was not removed: %w", namespace, releaseName, clusterID, err)
+	}
+
+	logrus.Infof("Uninstalled release %s/%s and its %d resources in cluster %s", namespace, releaseName, len(resources), clusterID)
+
+	return nil
+}
+
+// getReleaseResources is a private helper function that returns the workloads of the namespace and the cluster scoped
+// resources, e.g. the custom resource definitions and the namespaces, the release deployed.
+func getReleaseResources(dynamicClient dynamic.Interface, namespace, releaseName string) ([]releaseResource, error) {
+	var resources []releaseResource
+
+	for _, workloadResource := range releaseWorkloadResources {
+		workloadList, err := dynamicClient.Resource(workloadResource.groupVersionResource).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, workload := range workloadList.Items {
+			if workload.GetAnnotations()[helmReleaseNameAnnotation] == releaseName {
+				resources = append(resources, releaseResource{workloadResource.groupVersionResource, namespace, workload.GetName()})
+			}
+		}
+	}
+
+	clusterScoped, err := getClusterScopedResources(dynamicClient, namespace, releaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(resources, clusterScoped...), nil
+}
+
+// getRemainingResources is a private helper function that returns the resources that still exist.
+func getRemainingResources(ctx context.Context, dynamicClient dynamic.Interface, resources []releaseResource) ([]releaseResource, error) {
+	var remaining []releaseResource
+	for _, resource := range resources {
+		_, err := dynamicClient.Resource(resource.groupVersionResource).Namespace(resource.namespace).Get(ctx, resource.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		remaining = append(remaining, resource)
+	}
+
+	return remaining, nil
+}
+
+// joinResources is a private helper function that returns the sorted resources as a comma separated string.
+func joinResources(resources []releaseResource) string {
+	names := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		names = append(names, resource.String())
+	}
+
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,256 @@
+package charts
+
+import (
+	"context"
+	"fmt"
+
+	catalogv1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"github.com/rancher/rancher/tests/v2/actions/downstream"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/clients/rancher/catalog"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/pkg/api/steve/catalog/types"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetReleaseValues is a helper function that returns the values the release is deployed with, the ones set on install
+// and upgrade, without the defaults of the chart.
+func GetReleaseValues(client *rancher.Client, clusterID, namespace, releaseName string) (map[string]interface{}, error) {
+	adminClient, err := rancher.NewClient(client.RancherConfig.AdminToken, client.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	catalogClient, err := adminClient.GetClusterCatalogClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	app, err := catalogClient.Apps(namespace).Get(context.TODO(), releaseName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return app.Spec.Values, nil
+}
+
+// InstallOptions is a struct of the shepherd install options completed with helm values, which are deeply merged over
+// the values the installers generate from the feature options, e.g. to enable the persistent storage of prometheus or
+// change its retention.
+type InstallOptions struct {
+	*charts.InstallOptions
+	// Values are the helm values overriding the generated ones, none when nil
+	Values map[string]interface{}
+}
+
+// InstallRancherMonitoringChartWithValues is a helper function that installs the rancher-monitoring chart like
+// InstallRancherMonitoringChart does, with the values of the install options deeply merged over the values generated
+// from the feature options. When there are values, the CRD chart and the chart are installed by InstallChart, each in
+// a single helm operation, so the chart is never deployed without them.
+func InstallRancherMonitoringChartWithValues(client *rancher.Client, installOptions *InstallOptions, rancherMonitoringOpts *charts.RancherMonitoringOpts, operationOptions *OperationOptions) error {
+	if len(installOptions.Values) == 0 {
+		return InstallRancherMonitoringChart(client, installOptions.InstallOptions, rancherMonitoringOpts, operationOptions)
+	}
+
+	err := InstallChart(client, &ChartInstallOptions{
+		InstallOptions: installOptions.InstallOptions,
+		ChartName:      rancherMonitoringCRDName,
+		Namespace:      charts.RancherMonitoringNamespace,
+		StreamLogs:     operationOptions.GetStreamLogs(),
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	return InstallChart(client, &ChartInstallOptions{
+		InstallOptions: installOptions.InstallOptions,
+		ChartName:      charts.RancherMonitoringName,
+		Namespace:      charts.RancherMonitoringNamespace,
+		StreamLogs:     operationOptions.GetStreamLogs(),
+	}, mergeValues(rancherMonitoringValues(rancherMonitoringOpts), installOptions.Values))
+}
+
+// UpgradeRancherMonitoringChartWithValues is a helper function that upgrades the rancher-monitoring chart like
+// UpgradeRancherMonitoringChart does, with the values of the install options deeply merged over the values generated
+// from the feature options. When there are values, the CRD chart and the chart are upgraded through the catalog API,
+// each in a single helm operation, keeping the values they were deployed with.
+func UpgradeRancherMonitoringChartWithValues(client *rancher.Client, installOptions *InstallOptions, rancherMonitoringOpts *charts.RancherMonitoringOpts, operationOptions *OperationOptions) error {
+	if len(installOptions.Values) == 0 {
+		return UpgradeRancherMonitoringChart(client, installOptions.InstallOptions, rancherMonitoringOpts, operationOptions)
+	}
+
+	clusterID := installOptions.Cluster.ID
+
+	stop := operationOptions.StreamOperationLogs(client, clusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
+	defer stop()
+
+	err := UpgradeReleaseVersion(client, clusterID, charts.RancherMonitoringNamespace, rancherMonitoringCRDName, installOptions.Version, nil)
+	if err != nil {
+		return err
+	}
+
+	values := mergeValues(rancherMonitoringValues(rancherMonitoringOpts), installOptions.Values)
+
+	return UpgradeReleaseVersion(client, clusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName, installOptions.Version, values)
+}
+
+// UpgradeReleaseValues is a helper function that upgrades the release, keeping its chart version, with its values deeply
+// merged with the given values, e.g. to change one setting of a chart installed by another helper, and waits until it
+// is deployed. The values the release was deployed with are restored by the session of the client.
+func UpgradeReleaseValues(client *rancher.Client, clusterID, namespace, releaseName string, values map[string]interface{}) error {
+	restore, err := MergeReleaseValues(client, clusterID, namespace, releaseName, values)
+	if err != nil {
+		return err
+	}
+
+	client.Session.RegisterCleanupFunc(restore)
+
+	return nil
+}
+
+// MergeReleaseValues is a helper function that upgrades the release, keeping its chart version, with its values deeply
+// merged with the given values and waits until it is deployed, like UpgradeReleaseValues does. It returns the function
+// that restores the values the release was deployed with, which the caller is responsible for calling, e.g. to put a
+// chart shared by several tests back as it was before the next test runs.
+func MergeReleaseValues(client *rancher.Client, clusterID, namespace, releaseName string, values map[string]interface{}) (restore func() error, err error) {
+	target, err := newReleaseUpgradeTarget(client, clusterID, namespace, releaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	previousValues := target.app.Spec.Values
+	version := target.app.Spec.Chart.Metadata.Version
+
+	logrus.Infof("Upgrading the values of %s/%s in cluster %s", namespace, releaseName, clusterID)
+
+	err = target.upgrade(version, mergeValues(previousValues, values))
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade the values of %s/%s in cluster %s: %w", namespace, releaseName, clusterID, err)
+	}
+
+	restore = func() error {
+		return target.upgrade(version, previousValues)
+	}
+
+	return restore, nil
+}
+
+// UpgradeReleaseVersion is a helper function that upgrades the release to the chart version, from the
+// repository it was installed from, with its values deeply merged with the given values, and waits until it is deployed.
+func UpgradeReleaseVersion(client *rancher.Client, clusterID, namespace, releaseName, version string, values map[string]interface{}) error {
+	target, err := newReleaseUpgradeTarget(client, clusterID, namespace, releaseName)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Upgrading %s/%s in cluster %s from version %s to %s", namespace, releaseName, clusterID, target.app.Spec.Chart.Metadata.Version, version)
+
+	err = target.upgrade(version, mergeValues(target.app.Spec.Values, values))
+	if err != nil {
+		return fmt.Errorf("failed to upgrade %s/%s in cluster %s to version %s: %w", namespace, releaseName, clusterID, version, err)
+	}
+
+	return nil
+}
+
+// releaseUpgradeTarget is a private struct of a deployed release, the admin clients upgrading it through the catalog API
+// and the repository it was installed from.
+type releaseUpgradeTarget struct {
+	client        *rancher.Client
+	catalogClient *catalog.Client
+	clientset     *kubernetes.Clientset
+	clusterID     string
+	namespace     string
+	releaseName   string
+	app           *catalogv1.App
+	repoName      string
+}
+
+// newReleaseUpgradeTarget is a private constructor that gets the release with the admin clients of the cluster. The
+// repository of the release is the one of its source annotation, the rancher charts repository when it has none.
+func newReleaseUpgradeTarget(client *rancher.Client, clusterID, namespace, releaseName string) (*releaseUpgradeTarget, error) {
+	adminClient, err := rancher.NewClient(client.RancherConfig.AdminToken, client.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	catalogClient, err := adminClient.GetClusterCatalogClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := downstream.GetClientset(adminClient, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	app, err := catalogClient.Apps(namespace).Get(context.TODO(), releaseName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if app.Spec.Chart == nil || app.Spec.Chart.Metadata == nil {
+		return nil, fmt.Errorf("release %s/%s in cluster %s has no chart", namespace, releaseName, clusterID)
+	}
+
+	repoName := app.Spec.Chart.Metadata.Annotations[uiSourceRepoAnnotation]
+	if repoName == "" {
+		repoName = catalog.RancherChartRepo
+	}
+
+	return &releaseUpgradeTarget{
+		client:        client,
+		catalogClient: catalogClient,
+		clientset:     clientset,
+		clusterID:     clusterID,
+		namespace:     namespace,
+		releaseName:   releaseName,
+		app:           app,
+		repoName:      repoName,
+	}, nil
+}
+
+// upgrade is a private method that upgrades the release to the chart version with the values, replacing the ones it is
+// deployed with, and waits until it is deployed.
+func (t *releaseUpgradeTarget) upgrade(version string, values map[string]interface{}) error {
+	return upgradeRelease(t.client, t.catalogClient, t.clientset, t.clusterID, t.namespace, t.releaseName, &types.ChartUpgrade{
+		ChartName:   t.app.Spec.Chart.Metadata.Name,
+		Version:     version,
+		ReleaseName: t.releaseName,
+		ResetValues: true,
+		Values:      values,
+	}, t.repoName)
+}
+
+// rancherMonitoringValues is a private helper function that returns the rancher-monitoring values the shepherd charts
+// extension generates from the feature options. Shepherd builds them inline in InstallRancherMonitoringChart and
+// UpgradeRancherMonitoringChart without exporting them, so this is a pinned copy of its output, checked by
+// TestRancherMonitoringValues, to update when shepherd is bumped.
+func rancherMonitoringValues(rancherMonitoringOpts *charts.RancherMonitoringOpts) map[string]interface{} {
+	return map[string]interface{}{
+		"ingressNginx": map[string]interface{}{
+			"enabled": rancherMonitoringOpts.IngressNginx,
+		},
+		"prometheus": map[string]interface{}{
+			"prometheusSpec": map[string]interface{}{
+				"evaluationInterval": "1m",
+				"retentionSize":      "50GiB",
+				"scrapeInterval":     "1m",
+			},
+		},
+		"rkeControllerManager": map[string]interface{}{
+			"enabled": rancherMonitoringOpts.ControllerManager,
+		},
+		"rkeEtcd": map[string]interface{}{
+			"enabled": rancherMonitoringOpts.Etcd,
+		},
+		"rkeProxy": map[string]interface{}{
+			"enabled": rancherMonitoringOpts.Proxy,
+		},
+		"rkeScheduler": map[string]interface{}{
+			"enabled": rancherMonitoringOpts.Scheduler,
+		},
+	}
+}
@@ -0,0 +1,75 @@
+package charts
+
+import (
+	"testing"
+
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/stretchr/testify/assert"
+)
+
+// shepherdMonitoringValues returns the values the InstallRancherMonitoringChart of the pinned shepherd version builds
+// from the feature options.
+func shepherdMonitoringValues(ingressNginx, controllerManager, etcd, proxy, scheduler bool) map[string]interface{} {
+	return map[string]interface{}{
+		"ingressNginx": map[string]interface{}{
+			"enabled": ingressNginx,
+		},
+		"prometheus": map[string]interface{}{
+			"prometheusSpec": map[string]interface{}{
+				"evaluationInterval": "1m",
+				"retentionSize":      "50GiB",
+				"scrapeInterval":     "1m",
+			},
+		},
+		"rkeControllerManager": map[string]interface{}{
+			"enabled": controllerManager,
+		},
+		"rkeEtcd": map[string]interface{}{
+			"enabled": etcd,
+		},
+		"rkeProxy": map[string]interface{}{
+			"enabled": proxy,
+		},
+		"rkeScheduler": map[string]interface{}{
+			"enabled": scheduler,
+		},
+	}
+}
+
+func TestRancherMonitoringValues(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *charts.RancherMonitoringOpts
+		want map[string]interface{}
+	}{
+		{
+			name: "all exporters enabled",
+			opts: &charts.RancherMonitoringOpts{
+				IngressNginx:      true,
+				ControllerManager: true,
+				Etcd:              true,
+				Proxy:             true,
+				Scheduler:         true,
+			},
+			want: shepherdMonitoringValues(true, true, true, true, true),
+		},
+		{
+			name: "all exporters disabled",
+			opts: &charts.RancherMonitoringOpts{},
+			want: shepherdMonitoringValues(false, false, false, false, false),
+		},
+		{
+			name: "some exporters enabled",
+			opts: &charts.RancherMonitoringOpts{
+				IngressNginx: true,
+				Etcd:         true,
+			},
+			want: shepherdMonitoringValues(true, false, true, false, false),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, rancherMonitoringValues(tt.opts))
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package charts
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+)
+
+// ValueDifference is a struct of a value of a release that differs from the expected one.
+type ValueDifference struct {
+	// Path is the dotted path of the value, e.g. prometheus.prometheusSpec.replicas
+	Path     string
+	Expected interface{}
+	// Actual is nil when the value is missing
+	Actual interface{}
+	// Missing is true when the release has no value at the path
+	Missing bool
+}
+
+// String returns the path of the difference with the expected and actual values.
+func (d ValueDifference) String() string {
+	if d.Missing {
+		return fmt.Sprintf("%s: expected %v, missing", d.Path, d.Expected)
+	}
+
+	return fmt.Sprintf("%s: expected %v, got %v", d.Path, d.Expected, d.Actual)
+}
+
+// ValuesDiff is the list of the differences between expected values and the values of a release, sorted by path.
+type ValuesDiff []ValueDifference
+
+// String returns one difference per line.
+func (d ValuesDiff) String() string {
+	lines := make([]string, 0, len(d))
+	for _, difference := range d {
+		lines = append(lines, difference.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// DiffValues is a helper function that compares the expected values to the actual ones and returns the differences.
+// The expected values are a subset of the actual ones: maps are compared key by key, so keys only the actual values
+// have are ignored, while lists and scalars are compared as a whole. Numbers are compared by value, whatever their Go
+// type, as the values of a release are decoded from JSON.
+func DiffValues(expected, actual map[string]interface{}) (ValuesDiff, error) {
+	normalizedExpected, err := normalizeValues(expected)
+	if err != nil {
+		return nil, err
+	}
+
+	normalizedActual, err := normalizeValues(actual)
+	if err != nil {
+		return nil, err
+	}
+
+	var diff ValuesDiff
+	diffValues("", normalizedExpected, normalizedActual, &diff)
+
+	sort.Slice(diff, func(i, j int) bool {
+		return diff[i].Path < diff[j].Path
+	})
+
+	return diff, nil
+}
+
+// GetValuesDiff is a helper function that returns the differences between the expected values and the values the
+// release is deployed with, as fetched by GetReleaseValues.
+func GetValuesDiff(client *rancher.Client, clusterID, namespace, releaseName string, expected map[string]interface{}) (ValuesDiff, error) {
+	actual, err := GetReleaseValues(client, clusterID, namespace, releaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffValues(expected, actual)
+}
+
+// AssertValuesEqual is a helper function that checks the release is deployed with the expected values, e.g. the values
+// of feature options like MonitoringHAOpts, and returns an error listing every difference otherwise.
+func AssertValuesEqual(client *rancher.Client, clusterID, namespace, releaseName string, expected map[string]interface{}) error {
+	diff, err := GetValuesDiff(client, clusterID, namespace, releaseName, expected)
+	if err != nil {
+		return err
+	}
+
+	if len(diff) > 0 {
+		return fmt.Errorf("values of %s/%s in cluster %s differ from the expected ones:\n%s", namespace, releaseName, clusterID, diff)
+	}
+
+	return nil
+}
+
+// normalizeValues is a private helper function that round trips the values through JSON, so typed values, e.g. int
+// or structs, compare equal to the values decoded from the release.
+func normalizeValues(values map[string]interface{}) (map[string]interface{}, error) {
+	if values == nil {
+		return map[string]interface{}{}, nil
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	normalized := map[string]interface{}{}
+	err = json.Unmarshal(data, &normalized)
+
+	return normalized, err
+}
+
+// diffValues is a private helper function that appends the differences of the expected map under the path to the
+// diff.
+func diffValues(path string, expected, actual map[string]interface{}, diff *ValuesDiff) {
+	for key, expectedValue := range expected {
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+
+		actualValue, ok := actual[key]
+		if !ok {
+			*diff = append(*diff, ValueDifference{Path: keyPath, Expected: expectedValue, Missing: true})
+			continue
+		}
+
+		expectedMap, expectedIsMap := expectedValue.(map[string]interface{})
+		actualMap, actualIsMap := actualValue.(map[string]interface{})
+		if expectedIsMap && actualIsMap {
+			diffValues(keyPath, expectedMap, actualMap, diff)
+			continue
+		}
+
+		if !reflect.DeepEqual(expectedValue, actualValue) {
+			*diff = append(*diff, ValueDifference{Path: keyPath, Expected: expectedValue, Actual: actualValue})
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package charts
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/rancher/shepherd/clients/rancher"
+)
+
+// GetChartVersionsMatching is a helper function that returns the versions of the chart in the cluster repository of
+// the local cluster that satisfy the semantic version constraint, e.g. "102.x" or ">=103.0.0 <104.0.0", latest first.
+// Versions that aren't semantic versions are skipped.
+func GetChartVersionsMatching(client *rancher.Client, chartName, repoName, constraint string) ([]string, error) {
+	versionConstraint, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version constraint %q: %w", constraint, err)
+	}
+
+	versions, err := client.Catalog.GetListChartVersions(chartName, repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	return filterVersionsMatching(versions, versionConstraint), nil
+}
+
+// GetLatestChartVersionMatching is a helper function that behaves as the GetLatestChartVersion of the catalog client,
+// returning the latest version of the chart in the cluster repository of the local cluster that satisfies the semantic
+// version constraint instead of the absolute latest one, e.g. to upgrade from the latest version of the previous
+// version line, GetLatestChartVersionMatching(client, "rancher-monitoring", catalog.RancherChartRepo, "102.x").
+func GetLatestChartVersionMatching(client *rancher.Client, chartName, repoName, constraint string) (string, error) {
+	versions, err := GetChartVersionsMatching(client, chartName, repoName, constraint)
+	if err != nil {
+		return "", err
+	}
+
+	if len(versions) == 0 {
+		return "", fmt.Errorf("no version of chart %s in repository %s satisfies %q", chartName, repoName, constraint)
+	}
+
+	return versions[0], nil
+}
+
+// filterVersionsMatching is a private helper function that returns the versions that satisfy the constraint, latest
+// first, skipping the versions that aren't semantic versions.
+func filterVersionsMatching(versions []string, versionConstraint *semver.Constraints) []string {
+	var matching []*semver.Version
+	for _, version := range versions {
+		parsedVersion, err := semver.NewVersion(version)
+		if err != nil {
+			continue
+		}
+
+		if versionConstraint.Check(parsedVersion) {
+			matching = append(matching, parsedVersion)
+		}
+	}
+
+	sort.Sort(sort.Reverse(semver.Collection(matching)))
+
+	matchingVersions := make([]string, 0, len(matching))
+	for _, version := range matching {
+		matchingVersions = append(matchingVersions, version.Original())
+	}
+
+	return matchingVersions
+}
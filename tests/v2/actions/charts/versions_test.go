@@ -0,0 +1,53 @@
+package charts
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterVersionsMatching(t *testing.T) {
+	versions := []string{"102.0.0+up40.1.2", "103.1.0+up45.31.1", "not-a-version", "103.0.3+up45.31.1", "104.0.0-rc1", "101.0.0"}
+
+	tests := []struct {
+		name       string
+		constraint string
+		want       []string
+	}{
+		{
+			name:       "version line",
+			constraint: "103.x",
+			want:       []string{"103.1.0+up45.31.1", "103.0.3+up45.31.1"},
+		},
+		{
+			name:       "range",
+			constraint: ">=102.0.0 <104.0.0",
+			want:       []string{"103.1.0+up45.31.1", "103.0.3+up45.31.1", "102.0.0+up40.1.2"},
+		},
+		{
+			name:       "before a version",
+			constraint: "<103.0.3",
+			want:       []string{"102.0.0+up40.1.2", "101.0.0"},
+		},
+		{
+			name:       "prerelease constraint",
+			constraint: ">=104.0.0-0",
+			want:       []string{"104.0.0-rc1"},
+		},
+		{
+			name:       "no match",
+			constraint: "105.x",
+			want:       []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versionConstraint, err := semver.NewConstraint(tt.constraint)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, filterVersionsMatching(versions, versionConstraint))
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package cisbenchmark
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	cis "github.com/rancher/cis-operator/pkg/apis/cis.cattle.io/v1"
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Pass is the state of the checks of a scan report that passed
+	Pass = "pass"
+	// Fail is the state of the checks of a scan report that failed
+	Fail = "fail"
+	// Warn is the state of the checks of a scan report that need a manual verification
+	Warn = "warn"
+	// Skip is the state of the checks of a scan report that the profile skips
+	Skip = "skip"
+	// NotApplicable is the state of the checks of a scan report that don't apply to the cluster
+	NotApplicable = "notApplicable"
+
+	clusterScanSteveType       = "cis.cattle.io.clusterscan"
+	clusterScanReportSteveType = "cis.cattle.io.clusterscanreport"
+	clusterScanKind            = "ClusterScan"
+	scanPrefix                 = "scan-"
+	scanTimeout                = 20 * time.Minute
+)
+
+// ScanReport is a struct of the report of a CIS benchmark scan.
+type ScanReport struct {
+	// ScanName is the name of the cluster scan the report is of
+	ScanName string `json:"-"`
+	// Version is the version of the benchmark of the scan profile, e.g. rke2-cis-1.7
+	Version       string        `json:"version"`
+	Total         int           `json:"total"`
+	Pass          int           `json:"pass"`
+	Fail          int           `json:"fail"`
+	Skip          int           `json:"skip"`
+	Warn          int           `json:"warn"`
+	NotApplicable int           `json:"notApplicable"`
+	Results       []*CheckGroup `json:"results"`
+}
+
+// CheckGroup is a struct of a section of the benchmark and of its checks.
+type CheckGroup struct {
+	ID     string   `json:"id"`
+	Text   string   `json:"text"`
+	Checks []*Check `json:"checks"`
+}
+
+// Check is a struct of the result of a check of the benchmark.
+type Check struct {
+	ID          string   `json:"id"`
+	Description string   `json:"description"`
+	Remediation string   `json:"remediation"`
+	State       string   `json:"state"`
+	Scored      bool     `json:"scored"`
+	Nodes       []string `json:"nodes"`
+}
+
+// ChecksWithState returns the checks of the report with the state, e.g. Fail, by check ID.
+func (r *ScanReport) ChecksWithState(state string) map[string]*Check {
+	checks := map[string]*Check{}
+	for _, group := range r.Results {
+		for _, check := range group.Checks {
+			if check.State == state {
+				checks[check.ID] = check
+			}
+		}
+	}
+
+	return checks
+}
+
+// String returns the summary of the report, as the counts of its checks by state.
+func (r *ScanReport) String() string {
+	return fmt.Sprintf("scan %s of %s: %d checks, %d passed, %d failed, %d warned, %d skipped, %d not applicable",
+		r.ScanName, r.Version, r.Total, r.Pass, r.Fail, r.Warn, r.Skip, r.NotApplicable)
+}
+
+// InstallCISBenchmarkChart is a helper function that installs the rancher-cis-benchmark chart like the shepherd helper
+// does, with the policy of GetInFlightPolicy for the helm operations in progress on it, and waits for its workloads.
+// When the operation options stream the logs, the logs of its helm operations are written to the test log while it
+// installs.
+func InstallCISBenchmarkChart(client *rancher.Client, installOptions *charts.InstallOptions, operationOptions *actionscharts.OperationOptions) error {
+	clusterID := installOptions.Cluster.ID
+
+	err := actionscharts.GuardReleaseOperation(client, clusterID, charts.CISBenchmarkNamespace, charts.CISBenchmarkName, actionscharts.GetInFlightPolicy(), func() error {
+		stop := operationOptions.StreamOperationLogs(client, clusterID, charts.CISBenchmarkNamespace, charts.CISBenchmarkName)
+		defer stop()
+
+		return charts.InstallCISBenchmarkChart(client, installOptions)
+	})
+	if err != nil {
+		return err
+	}
+
+	return actionscharts.WatchAndWaitWorkloads(client, clusterID, charts.CISBenchmarkNamespace, metav1.ListOptions{})
+}
+
+// RunScan is a helper function that creates a cluster scan of the scan profile, e.g. rke2-cis-1.7-profile-hardened,
+// waits until it ran and returns its report. It fails when the scan errors. The scan and its report are deleted by the
+// session of the client.
+func RunScan(client *rancher.Client, clusterID, scanProfileName string) (*ScanReport, error) {
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterScan := &cis.ClusterScan{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: namegenerator.AppendRandomString(scanPrefix),
+		},
+		Spec: cis.ClusterScanSpec{
+			ScanProfileName: scanProfileName,
+			ScoreWarning:    Pass,
+		},
+	}
+
+	logrus.Infof("Running CIS benchmark scan %s with profile %s in cluster %s", clusterScan.Name, scanProfileName, clusterID)
+
+	scanObject, err := steveClient.SteveType(clusterScanSteveType).Create(clusterScan)
+	if err != nil {
+		return nil, err
+	}
+
+	scanStatus := &cis.ClusterScanStatus{}
+	err = wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(scanTimeout), func(context.Context) (done bool, err error) {
+		scanObject, err := steveClient.SteveType(clusterScanSteveType).ByID(scanObject.ID)
+		if err != nil {
+			return false, nil
+		}
+
+		scanStatus = &cis.ClusterScanStatus{}
+		err = v1.ConvertToK8sType(scanObject.Status, scanStatus)
+		if err != nil {
+			return false, err
+		}
+
+		if scanStatus.Display != nil && scanStatus.Display.Error {
+			return false, fmt.Errorf("scan %s failed: %s", clusterScan.Name, scanStatus.Display.Message)
+		}
+
+		return scanStatus.LastRunTimestamp != "" && scanStatus.Summary != nil, nil
+	}, func() string {
+		if scanStatus.Display == nil {
+			return fmt.Sprintf("scan %s has not started", clusterScan.Name)
+		}
+
+		return fmt.Sprintf("scan %s is %s: %s", clusterScan.Name, scanStatus.Display.State, scanStatus.Display.Message)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := GetScanReport(client, clusterID, clusterScan.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Info(report.String())
+
+	return report, nil
+}
+
+// GetScanReport is a helper function that returns the report of the last run of the cluster scan.
+func GetScanReport(client *rancher.Client, clusterID, scanName string) (*ScanReport, error) {
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	reportList, err := steveClient.SteveType(clusterScanReportSteveType).List(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var latestReport *cis.ClusterScanReport
+	for _, reportObject := range reportList.Data {
+		if !isOwnedByScan(reportObject.ObjectMeta.OwnerReferences, scanName) {
+			continue
+		}
+
+		report := &cis.ClusterScanReport{}
+		err = v1.ConvertToK8sType(reportObject.JSONResp, report)
+		if err != nil {
+			return nil, err
+		}
+
+		if latestReport == nil || latestReport.CreationTimestamp.Before(&report.CreationTimestamp) {
+			latestReport = report
+		}
+	}
+
+	if latestReport == nil {
+		return nil, fmt.Errorf("scan %s in cluster %s has no report", scanName, clusterID)
+	}
+
+	scanReport := &ScanReport{ScanName: scanName}
+	err = json.Unmarshal([]byte(latestReport.Spec.ReportJSON), scanReport)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse report %s of scan %s: %w", latestReport.Name, scanName, err)
+	}
+
+	return scanReport, nil
+}
+
+// isOwnedByScan is a private helper function that returns true when the owner references have the cluster scan.
+func isOwnedByScan(ownerReferences []metav1.OwnerReference, scanName string) bool {
+	for _, ownerReference := range ownerReferences {
+		if ownerReference.Kind == clusterScanKind && ownerReference.Name == scanName {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,289 @@
+package clientcache
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/extensions/projects"
+	"github.com/rancher/shepherd/pkg/session"
+)
+
+const (
+	localCluster    = "local"
+	schemaSteveType = "schema"
+)
+
+// Cache is a cache of the lookups nearly every suite repeats during its setup: cluster IDs and metas by name, projects by
+// name, and steve schemas, the last two per cluster ID. It is shared by the clients of the same user of the same rancher
+// server, e.g. the clients of the sub sessions of a suite. Misses are never cached, so a resource created after a
+// failed lookup is found. Cluster metas and projects are returned as copies, so callers can't change the cached values.
+type Cache struct {
+	mutex        sync.RWMutex
+	client       *rancher.Client
+	clusterIDs   map[string]string
+	clusterMetas map[string]*clusters.ClusterMeta
+	projects     map[string]*management.Project
+	schemas      map[string]*v1.SteveAPIObject
+	// sessions are the sessions dropping the cache on cleanup, guarded by cachesMutex
+	sessions map[*session.Session]bool
+}
+
+// cacheKey is a private struct that identifies the clients sharing a cache.
+type cacheKey struct {
+	host  string
+	token string
+}
+
+var (
+	cachesMutex sync.Mutex
+	caches      = map[cacheKey]*Cache{}
+)
+
+// ForClient returns the cache of the user of the client on its rancher server, creating it on first use. The cache is
+// dropped when the session of any client that used it is cleaned up, as the resources it points to are usually deleted
+// by the same cleanup.
+func ForClient(client *rancher.Client) *Cache {
+	key := newCacheKey(client)
+
+	cachesMutex.Lock()
+	defer cachesMutex.Unlock()
+
+	cache, ok := caches[key]
+	if !ok {
+		cache = &Cache{client: client, sessions: map[*session.Session]bool{}}
+		cache.reset()
+		caches[key] = cache
+	}
+
+	if !cache.sessions[client.Session] {
+		cache.sessions[client.Session] = true
+		client.Session.RegisterCleanupFunc(func() error {
+			forget(key)
+			return nil
+		})
+	}
+
+	return cache
+}
+
+// Forget drops the cache of the user of the client on its rancher server.
+func Forget(client *rancher.Client) {
+	forget(newCacheKey(client))
+}
+
+// GetClusterIDByName is a helper function that returns the cached cluster ID of the cluster with the given name,
+// see clusters.GetClusterIDByName.
+func GetClusterIDByName(client *rancher.Client, clusterName string) (string, error) {
+	return ForClient(client).GetClusterIDByName(clusterName)
+}
+
+// NewClusterMeta is a helper function that returns the cached meta of the cluster with the given name,
+// see clusters.NewClusterMeta.
+func NewClusterMeta(client *rancher.Client, clusterName string) (*clusters.ClusterMeta, error) {
+	return ForClient(client).NewClusterMeta(clusterName)
+}
+
+// GetProjectByName is a helper function that returns the cached project with the given name in the cluster,
+// see projects.GetProjectByName.
+func GetProjectByName(client *rancher.Client, clusterID, projectName string) (*management.Project, error) {
+	return ForClient(client).GetProjectByName(clusterID, projectName)
+}
+
+// GetSchema is a helper function that returns the cached steve schema with the given ID of the cluster.
+func GetSchema(client *rancher.Client, clusterID, schemaID string) (*v1.SteveAPIObject, error) {
+	return ForClient(client).GetSchema(clusterID, schemaID)
+}
+
+// GetClusterIDByName returns the ID of the cluster with the given name, only calling the API on the first lookup.
+func (c *Cache) GetClusterIDByName(clusterName string) (string, error) {
+	c.mutex.RLock()
+	clusterID, ok := c.clusterIDs[clusterName]
+	c.mutex.RUnlock()
+
+	if ok {
+		return clusterID, nil
+	}
+
+	clusterID, err := clusters.GetClusterIDByName(c.client, clusterName)
+	if err != nil || clusterID == "" {
+		return clusterID, err
+	}
+
+	c.mutex.Lock()
+	c.clusterIDs[clusterName] = clusterID
+	c.mutex.Unlock()
+
+	return clusterID, nil
+}
+
+// NewClusterMeta returns the meta of the cluster with the given name, only calling the API on the first lookup.
+func (c *Cache) NewClusterMeta(clusterName string) (*clusters.ClusterMeta, error) {
+	c.mutex.RLock()
+	clusterMeta, ok := c.clusterMetas[clusterName]
+	c.mutex.RUnlock()
+
+	if ok {
+		clusterMetaCopy := *clusterMeta
+		return &clusterMetaCopy, nil
+	}
+
+	clusterMeta, err := clusters.NewClusterMeta(c.client, clusterName)
+	if err != nil || clusterMeta == nil || clusterMeta.ID == "" {
+		return clusterMeta, err
+	}
+
+	clusterMetaCopy := *clusterMeta
+
+	c.mutex.Lock()
+	c.clusterMetas[clusterName] = &clusterMetaCopy
+	c.clusterIDs[clusterName] = clusterMeta.ID
+	c.mutex.Unlock()
+
+	return clusterMeta, nil
+}
+
+// GetProjectByName returns the project with the given name in the cluster, only calling the API on the first lookup.
+func (c *Cache) GetProjectByName(clusterID, projectName string) (*management.Project, error) {
+	key := clusterID + "/" + projectName
+
+	c.mutex.RLock()
+	project, ok := c.projects[key]
+	c.mutex.RUnlock()
+
+	if ok {
+		return copyProject(project), nil
+	}
+
+	project, err := projects.GetProjectByName(c.client, clusterID, projectName)
+	if err != nil || project == nil {
+		return project, err
+	}
+
+	c.mutex.Lock()
+	c.projects[key] = copyProject(project)
+	c.mutex.Unlock()
+
+	return project, nil
+}
+
+// GetSchema returns the steve schema with the given ID of the cluster, only calling the API on the first lookup.
+func (c *Cache) GetSchema(clusterID, schemaID string) (*v1.SteveAPIObject, error) {
+	key := clusterID + "/" + schemaID
+
+	c.mutex.RLock()
+	schema, ok := c.schemas[key]
+	c.mutex.RUnlock()
+
+	if ok {
+		return schema, nil
+	}
+
+	steveClient := c.client.Steve
+	if clusterID != localCluster {
+		var err error
+		steveClient, err = c.client.Steve.ProxyDownstream(clusterID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	schema, err := steveClient.SteveType(schemaSteveType).ByID(schemaID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	c.schemas[key] = schema
+	c.mutex.Unlock()
+
+	return schema, nil
+}
+
+// InvalidateCluster drops the cached ID of the cluster with the given name, e.g. after the cluster is deleted.
+func (c *Cache) InvalidateCluster(clusterName string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.clusterIDs, clusterName)
+	delete(c.clusterMetas, clusterName)
+}
+
+// InvalidateProject drops the cached project with the given name in the cluster, e.g. after the project is updated or deleted.
+func (c *Cache) InvalidateProject(clusterID, projectName string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.projects, clusterID+"/"+projectName)
+}
+
+// InvalidateSchemas drops all cached schemas of the cluster, e.g. after a chart that adds CRDs is installed.
+func (c *Cache) InvalidateSchemas(clusterID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for key := range c.schemas {
+		if strings.HasPrefix(key, clusterID+"/") {
+			delete(c.schemas, key)
+		}
+	}
+}
+
+// Invalidate drops everything cached for the client.
+func (c *Cache) Invalidate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.reset()
+}
+
+// reset is a private method that empties the cache, the caller must hold the lock.
+func (c *Cache) reset() {
+	c.clusterIDs = map[string]string{}
+	c.clusterMetas = map[string]*clusters.ClusterMeta{}
+	c.projects = map[string]*management.Project{}
+	c.schemas = map[string]*v1.SteveAPIObject{}
+}
+
+// forget is a private helper function that drops the cache with the given key.
+func forget(key cacheKey) {
+	cachesMutex.Lock()
+	defer cachesMutex.Unlock()
+
+	delete(caches, key)
+}
+
+// newCacheKey is a private helper function that returns the key of the cache of the client, its rancher server and the
+// token it authenticates with.
+func newCacheKey(client *rancher.Client) cacheKey {
+	return cacheKey{
+		host:  client.RancherConfig.Host,
+		token: client.Management.Opts.TokenKey,
+	}
+}
+
+// copyProject is a private helper function that returns a copy of the project, including its labels and annotations.
+func copyProject(project *management.Project) *management.Project {
+	projectCopy := *project
+	projectCopy.Labels = copyMap(project.Labels)
+	projectCopy.Annotations = copyMap(project.Annotations)
+
+	return &projectCopy
+}
+
+// copyMap is a private helper function that returns a copy of the map, or nil if the map is nil.
+func copyMap(original map[string]string) map[string]string {
+	if original == nil {
+		return nil
+	}
+
+	mapCopy := make(map[string]string, len(original))
+	for key, value := range original {
+		mapCopy[key] = value
+	}
+
+	return mapCopy
+}
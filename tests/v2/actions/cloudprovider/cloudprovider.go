@@ -0,0 +1,243 @@
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/clients/rancher/catalog"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/extensions/projects"
+	"github.com/rancher/shepherd/extensions/provisioninginput"
+	"github.com/rancher/shepherd/extensions/services"
+	"github.com/rancher/shepherd/extensions/workloads"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/sirupsen/logrus"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// UninitializedTaintKey is the taint kubelet sets on the nodes of clusters with an external cloud provider, until the
+	// cloud controller manager initializes them
+	UninitializedTaintKey = "node.cloudprovider.kubernetes.io/uninitialized"
+
+	awsUpstreamCloudProviderRepo = "https://github.com/kubernetes/cloud-provider-aws.git"
+	awsUpstreamChartName         = "aws-cloud-controller-manager"
+	masterBranch                 = "master"
+	systemProject                = "System"
+	defaultNamespace             = "default"
+	nodeSteveType                = "node"
+	nginxName                    = "nginx"
+	loadBalancerPrefix           = "lb"
+	portName                     = "port"
+	initializeTimeout            = 10 * time.Minute
+	loadBalancerTimeout          = 10 * time.Minute
+)
+
+// ApplyExternalCloudProvider is a helper function that sets the out-of-tree cloud provider of the cluster config, e.g.
+// provisioninginput.AWSProviderName, and adds the uninitialized taint to its machine pools, so no workload is scheduled
+// on a node before the cloud controller manager initialized it. The cloud controller manager must then be installed,
+// with an additional manifest or InstallExternalCloudProvider once the cluster is active.
+func ApplyExternalCloudProvider(clusterConfig *clusters.ClusterConfig, providerName string) {
+	clusterConfig.CloudProvider = providerName
+
+	machinePools := make([]provisioninginput.MachinePools, 0, len(clusterConfig.MachinePools))
+	for _, machinePool := range clusterConfig.MachinePools {
+		nodeTaints := []corev1.Taint{}
+		for _, taint := range machinePool.NodeTaints {
+			if taint.Key != UninitializedTaintKey {
+				nodeTaints = append(nodeTaints, taint)
+			}
+		}
+
+		machinePool.NodeTaints = append(nodeTaints, corev1.Taint{
+			Key:    UninitializedTaintKey,
+			Value:  "true",
+			Effect: corev1.TaintEffectNoSchedule,
+		})
+		machinePools = append(machinePools, machinePool)
+	}
+
+	clusterConfig.MachinePools = machinePools
+}
+
+// InstallExternalCloudProvider is a helper function that installs the cloud controller manager charts of the out-of-tree
+// cloud provider in the cluster, the upstream AWS chart or the rancher vSphere CPI and CSI charts, and waits for every
+// node to be initialized by it.
+func InstallExternalCloudProvider(client *rancher.Client, clusterName, providerName string) error {
+	clusterMeta, err := clusters.NewClusterMeta(client, clusterName)
+	if err != nil {
+		return err
+	}
+
+	switch providerName {
+	case provisioninginput.AWSProviderName.String():
+		err = InstallAWSCloudControllerManager(client, clusterMeta, false)
+	case provisioninginput.VsphereCloudProviderName.String(), provisioninginput.VsphereProviderName.String():
+		chartConfig := new(charts.Config)
+		config.LoadConfig(charts.ConfigurationFileKey, chartConfig)
+
+		err = charts.InstallVsphereOutOfTreeCharts(client, catalog.RancherChartRepo, clusterName, !chartConfig.IsUpgradable)
+	default:
+		return fmt.Errorf("no cloud controller manager charts for cloud provider %s", providerName)
+	}
+	if err != nil {
+		return err
+	}
+
+	return WaitForNodesInitialized(client, clusterMeta.ID)
+}
+
+// InstallAWSCloudControllerManager is a helper function that adds the upstream AWS cloud provider repo to the cluster
+// and installs the latest version of its cloud controller manager chart in the System project.
+func InstallAWSCloudControllerManager(client *rancher.Client, cluster *clusters.ClusterMeta, isLeaderMigration bool) error {
+	steveclient, err := client.Steve.ProxyDownstream(cluster.ID)
+	if err != nil {
+		return err
+	}
+
+	repoName := namegenerator.AppendRandomString(provisioninginput.AWSProviderName.String())
+	err = charts.CreateChartRepoFromGithub(steveclient, awsUpstreamCloudProviderRepo, masterBranch, repoName)
+	if err != nil {
+		return err
+	}
+
+	project, err := projects.GetProjectByName(client, cluster.ID, systemProject)
+	if err != nil {
+		return err
+	}
+
+	catalogClient, err := client.GetClusterCatalogClient(cluster.ID)
+	if err != nil {
+		return err
+	}
+
+	latestVersion, err := catalogClient.GetLatestChartVersion(awsUpstreamChartName, repoName)
+	if err != nil {
+		return err
+	}
+
+	installOptions := &charts.InstallOptions{
+		Cluster:   cluster,
+		Version:   latestVersion,
+		ProjectID: project.ID,
+	}
+
+	return charts.InstallAWSOutOfTreeChart(client, installOptions, repoName, cluster.ID, isLeaderMigration)
+}
+
+// WaitForNodesInitialized is a helper function that waits until no node of the cluster has the uninitialized taint
+// anymore, which is when the cloud controller manager set their provider ID and addresses.
+func WaitForNodesInitialized(client *rancher.Client, clusterID string) error {
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return err
+	}
+
+	lastObserved := "no nodes"
+
+	return wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(initializeTimeout), func(context.Context) (done bool, err error) {
+		nodeList, err := steveClient.SteveType(nodeSteveType).List(nil)
+		if err != nil {
+			lastObserved = err.Error()
+			return false, nil
+		}
+
+		for _, nodeObject := range nodeList.Data {
+			node := &corev1.Node{}
+			err = v1.ConvertToK8sType(nodeObject.JSONResp, node)
+			if err != nil {
+				return false, err
+			}
+
+			for _, taint := range node.Spec.Taints {
+				if taint.Key == UninitializedTaintKey {
+					lastObserved = fmt.Sprintf("node %s still uninitialized", node.Name)
+					logrus.Infof("Waiting for node %s to be initialized by the cloud controller manager", node.Name)
+					return false, nil
+				}
+			}
+		}
+
+		return len(nodeList.Data) > 0, nil
+	}, func() string { return lastObserved })
+}
+
+// VerifyLoadBalancerService is a helper function that creates a nginx deployment and a LoadBalancer service for it in the
+// cluster, waits for the cloud provider to provision the load balancer and for nginx to answer through it. It returns
+// the address of the load balancer. The deployment and the service are deleted by the session of the client.
+func VerifyLoadBalancerService(client *rancher.Client, clusterID string) (string, error) {
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return "", err
+	}
+
+	containerTemplate := workloads.NewContainer(nginxName, nginxName, corev1.PullAlways, []corev1.VolumeMount{}, []corev1.EnvFromSource{}, nil, nil, nil)
+	podTemplate := workloads.NewPodTemplate([]corev1.Container{containerTemplate}, []corev1.Volume{}, []corev1.LocalObjectReference{}, nil)
+	deploymentTemplate := workloads.NewDeploymentTemplate(namegenerator.AppendRandomString(nginxName), defaultNamespace, podTemplate, true, nil)
+
+	deploymentObject, err := steveClient.SteveType(workloads.DeploymentSteveType).Create(deploymentTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	deploymentSpec := &appv1.DeploymentSpec{}
+	err = v1.ConvertToK8sType(deploymentObject.Spec, deploymentSpec)
+	if err != nil {
+		return "", err
+	}
+
+	serviceTemplate := services.NewServiceTemplate(namegenerator.AppendRandomString(loadBalancerPrefix), defaultNamespace, corev1.ServiceTypeLoadBalancer, []corev1.ServicePort{{Name: portName, Port: 80}}, deploymentSpec.Selector.MatchLabels)
+
+	serviceObject, err := steveClient.SteveType(services.ServiceSteveType).Create(serviceTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	var address string
+	err = wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(loadBalancerTimeout), func(context.Context) (done bool, err error) {
+		serviceObject, err := steveClient.SteveType(services.ServiceSteveType).ByID(serviceObject.ID)
+		if err != nil {
+			return false, nil
+		}
+
+		serviceStatus := &corev1.ServiceStatus{}
+		err = v1.ConvertToK8sType(serviceObject.Status, serviceStatus)
+		if err != nil {
+			return false, err
+		}
+
+		for _, ingress := range serviceStatus.LoadBalancer.Ingress {
+			address = ingress.Hostname
+			if address == "" {
+				address = ingress.IP
+			}
+
+			if address != "" {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}, func() string { return "no load balancer ingress" })
+	if err != nil {
+		return "", fmt.Errorf("load balancer of service %s was not provisioned: %w", serviceObject.ID, err)
+	}
+
+	logrus.Infof("Load balancer %s provisioned for service %s", address, serviceObject.ID)
+
+	_, err = actionscharts.WaitForEndpoint(client, address, "", false, actionscharts.ExpectHealthy, timeouts.Scale(loadBalancerTimeout))
+	if err != nil {
+		return "", fmt.Errorf("nginx did not answer through load balancer %s: %w", address, err)
+	}
+
+	return address, nil
+}
@@ -0,0 +1,103 @@
+package configmaps
+
+import (
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/configmaps"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CreateConfigMap is a helper function that creates a config map with the data and labels in the namespace of the
+// cluster and returns it. The config map is deleted by the session of the client.
+func CreateConfigMap(client *rancher.Client, clusterID, namespace, name string, data, labels map[string]string) (*corev1.ConfigMap, error) {
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Data: data,
+	}
+
+	configMapObject, err := steveClient.SteveType(configmaps.ConfigMapSteveType).Create(configMap)
+	if err != nil {
+		return nil, err
+	}
+
+	return toConfigMap(configMapObject)
+}
+
+// GetConfigMap is a helper function that returns the config map of the namespace of the cluster.
+func GetConfigMap(client *rancher.Client, clusterID, namespace, name string) (*corev1.ConfigMap, error) {
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	configMapObject, err := steveClient.SteveType(configmaps.ConfigMapSteveType).ByID(namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+
+	return toConfigMap(configMapObject)
+}
+
+// UpdateConfigMap is a helper function that replaces the data of the config map of the namespace of the cluster and
+// returns the updated config map.
+func UpdateConfigMap(client *rancher.Client, clusterID, namespace, name string, data map[string]string) (*corev1.ConfigMap, error) {
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	configMapObject, err := steveClient.SteveType(configmaps.ConfigMapSteveType).ByID(namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+
+	configMap, err := toConfigMap(configMapObject)
+	if err != nil {
+		return nil, err
+	}
+
+	configMap.Data = data
+
+	updatedConfigMapObject, err := steveClient.SteveType(configmaps.ConfigMapSteveType).Update(configMapObject, configMap)
+	if err != nil {
+		return nil, err
+	}
+
+	return toConfigMap(updatedConfigMapObject)
+}
+
+// DeleteConfigMap is a helper function that deletes the config map of the namespace of the cluster.
+func DeleteConfigMap(client *rancher.Client, clusterID, namespace, name string) error {
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return err
+	}
+
+	configMapObject, err := steveClient.SteveType(configmaps.ConfigMapSteveType).ByID(namespace + "/" + name)
+	if err != nil {
+		return err
+	}
+
+	return steveClient.SteveType(configmaps.ConfigMapSteveType).Delete(configMapObject)
+}
+
+// toConfigMap is a private helper function that converts the steve object of a config map to its typed config map.
+func toConfigMap(configMapObject *v1.SteveAPIObject) (*corev1.ConfigMap, error) {
+	configMap := &corev1.ConfigMap{}
+	err := v1.ConvertToK8sType(configMapObject.JSONResp, configMap)
+	if err != nil {
+		return nil, err
+	}
+
+	return configMap, nil
+}
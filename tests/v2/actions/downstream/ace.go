@@ -0,0 +1,248 @@
+package downstream
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apiv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/clusters"
+	"github.com/sirupsen/logrus"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	provisioningNamespace         = "fleet-default"
+	provisioningSteveResourceType = "provisioning.cattle.io.cluster"
+	aceTimeout                    = 30 * time.Minute
+)
+
+// AuthorizedClusterEndpoint is a struct of the authorized cluster endpoint settings of a cluster. An empty FQDN makes
+// the kubeconfig point at every control plane node instead.
+type AuthorizedClusterEndpoint struct {
+	Enabled bool
+	FQDN    string
+	CACerts string
+}
+
+// EnableAuthorizedClusterEndpoint is a helper function that enables the authorized cluster endpoint of the RKE1, RKE2 or
+// K3s cluster, waits for the cluster to be ready and for its kubeconfig to have a direct context. The previous settings
+// are restored by the session of the client.
+func EnableAuthorizedClusterEndpoint(client *rancher.Client, clusterName, fqdn, caCerts string) error {
+	previousEndpoint, err := setAuthorizedClusterEndpoint(client, clusterName, &AuthorizedClusterEndpoint{
+		Enabled: true,
+		FQDN:    fqdn,
+		CACerts: caCerts,
+	})
+	if err != nil {
+		return err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		_, err := setAuthorizedClusterEndpoint(client, clusterName, previousEndpoint)
+		return err
+	})
+
+	clusterID, err := clusters.GetClusterIDByName(client, clusterName)
+	if err != nil {
+		return err
+	}
+
+	return kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Timeout(), true, func(context.Context) (done bool, err error) {
+		contexts, err := GetDirectContexts(client, clusterID)
+		if err != nil {
+			return false, err
+		}
+
+		return len(contexts) > 0, nil
+	})
+}
+
+// VerifyDirectAccess is a helper function that checks the user of the client reaches the API of the cluster through its
+// authorized cluster endpoint, without rancher in the path, and is authenticated by it. It works for standard users as
+// well, as the check only needs the permissions every authenticated user has.
+func VerifyDirectAccess(client *rancher.Client, clusterID string) error {
+	clientset, err := getDirectClientset(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	host := clientset.RESTClient().Get().URL().Host
+	if strings.Contains(host, client.RancherConfig.Host) {
+		return fmt.Errorf("the direct kubeconfig context of cluster %s points at rancher %s", clusterID, host)
+	}
+
+	_, err = canI(clientset, "get", "namespaces", "")
+	if err != nil {
+		return fmt.Errorf("the user of the client is not authenticated by the authorized cluster endpoint of cluster %s: %w", clusterID, err)
+	}
+
+	return nil
+}
+
+// CanIDirect is a helper function that returns whether the user of the client may perform the verb on the resource,
+// e.g. "list" and "pods", in the namespace, or cluster wide when it's empty, as answered through the authorized cluster
+// endpoint of the cluster. It is meant to check the RBAC of standard users is the same with and without rancher.
+func CanIDirect(client *rancher.Client, clusterID, verb, resource, namespace string) (bool, error) {
+	clientset, err := getDirectClientset(client, clusterID)
+	if err != nil {
+		return false, err
+	}
+
+	return canI(clientset, verb, resource, namespace)
+}
+
+// getDirectClientset is a private helper function that returns a clientset of the authorized cluster endpoint of the
+// cluster, as the user of the client.
+func getDirectClientset(client *rancher.Client, clusterID string) (*kubernetes.Clientset, error) {
+	restConfig, err := GetDirectRestConfig(client, clusterID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return kubernetes.NewForConfig(restConfig)
+}
+
+// canI is a private helper function that asks the cluster whether the authenticated user may perform the verb on the
+// resource in the namespace.
+func canI(clientset *kubernetes.Clientset, verb, resource, namespace string) (bool, error) {
+	review, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(context.TODO(), &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      verb,
+				Resource:  resource,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	return review.Status.Allowed, nil
+}
+
+// setAuthorizedClusterEndpoint is a private helper function that sets the authorized cluster endpoint of the cluster,
+// waits for the cluster to be ready and returns the previous settings.
+func setAuthorizedClusterEndpoint(client *rancher.Client, clusterName string, endpoint *AuthorizedClusterEndpoint) (*AuthorizedClusterEndpoint, error) {
+	clusterMeta, err := clusters.NewClusterMeta(client, clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Setting the authorized cluster endpoint of cluster %s to enabled %t", clusterName, endpoint.Enabled)
+
+	if clusterMeta.Provider == clusters.KubernetesProviderRKE {
+		return setRKE1AuthorizedClusterEndpoint(client, clusterMeta.ID, endpoint)
+	}
+
+	return setV2ProvAuthorizedClusterEndpoint(client, clusterName, endpoint)
+}
+
+// setRKE1AuthorizedClusterEndpoint is a private helper function that sets the authorized cluster endpoint of an RKE1
+// cluster and waits for the cluster to be active again.
+func setRKE1AuthorizedClusterEndpoint(client *rancher.Client, clusterID string, endpoint *AuthorizedClusterEndpoint) (*AuthorizedClusterEndpoint, error) {
+	cluster, err := client.Management.Cluster.ByID(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	previousEndpoint := &AuthorizedClusterEndpoint{}
+	if cluster.LocalClusterAuthEndpoint != nil {
+		previousEndpoint.Enabled = cluster.LocalClusterAuthEndpoint.Enabled
+		previousEndpoint.FQDN = cluster.LocalClusterAuthEndpoint.FQDN
+		previousEndpoint.CACerts = cluster.LocalClusterAuthEndpoint.CACerts
+	}
+
+	_, err = client.Management.Cluster.Update(cluster, map[string]interface{}{
+		"localClusterAuthEndpoint": &management.LocalClusterAuthEndpoint{
+			Enabled: endpoint.Enabled,
+			FQDN:    endpoint.FQDN,
+			CACerts: endpoint.CACerts,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	adminClient, err := rancher.NewClient(client.RancherConfig.AdminToken, client.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	return previousEndpoint, clusters.WaitClusterToBeUpgraded(adminClient, clusterID)
+}
+
+// setV2ProvAuthorizedClusterEndpoint is a private helper function that sets the authorized cluster endpoint of an RKE2
+// or K3s cluster and waits for the cluster to be ready again.
+func setV2ProvAuthorizedClusterEndpoint(client *rancher.Client, clusterName string, endpoint *AuthorizedClusterEndpoint) (*AuthorizedClusterEndpoint, error) {
+	adminClient, err := rancher.NewClient(client.RancherConfig.AdminToken, client.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := clusters.GetV1ProvisioningClusterByName(adminClient, clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster, err := adminClient.Steve.SteveType(provisioningSteveResourceType).ByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterSpec := &apiv1.ClusterSpec{}
+	err = v1.ConvertToK8sType(cluster.Spec, clusterSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	previousEndpoint := &AuthorizedClusterEndpoint{
+		Enabled: clusterSpec.LocalClusterAuthEndpoint.Enabled,
+		FQDN:    clusterSpec.LocalClusterAuthEndpoint.FQDN,
+		CACerts: clusterSpec.LocalClusterAuthEndpoint.CACerts,
+	}
+
+	clusterSpec.LocalClusterAuthEndpoint = rkev1.LocalClusterAuthEndpoint{
+		Enabled: endpoint.Enabled,
+		FQDN:    endpoint.FQDN,
+		CACerts: endpoint.CACerts,
+	}
+
+	updatedCluster := *cluster
+	updatedCluster.Spec = *clusterSpec
+
+	_, err = adminClient.Steve.SteveType(provisioningSteveResourceType).Update(cluster, updatedCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	kubeProvisioningClient, err := adminClient.GetKubeAPIProvisioningClient()
+	if err != nil {
+		return nil, err
+	}
+
+	err = kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Scale(aceTimeout), true, func(ctx context.Context) (done bool, err error) {
+		provisioningCluster, err := kubeProvisioningClient.Clusters(provisioningNamespace).Get(ctx, clusterName, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		return clusters.IsProvisioningClusterReady(watch.Event{Type: watch.Modified, Object: provisioningCluster})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return previousEndpoint, nil
+}
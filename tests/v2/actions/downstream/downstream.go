@@ -0,0 +1,204 @@
+package downstream
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	frameworkDynamic "github.com/rancher/shepherd/clients/dynamic"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/kubeconfig"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// The json/yaml config key for the downstream API access mode
+const ConfigurationFileKey = "downstreamAccess"
+
+const (
+	// ProxyMode talks to the downstream cluster API through the /k8s/clusters/<id> proxy of rancher
+	ProxyMode = "proxy"
+	// DirectMode talks to the downstream cluster API through its authorized cluster endpoint, bypassing rancher
+	DirectMode = "direct"
+
+	fqdnContextSuffix = "-fqdn"
+)
+
+// Config is the configuration of how the suites reach the downstream cluster API, e.g.
+//
+//	downstreamAccess:
+//	  mode: direct
+//	  context: my-cluster-fqdn
+//
+// Mode defaults to proxy. Context is the kubeconfig context used in direct mode and defaults to the FQDN context of the
+// authorized cluster endpoint, or else the first context of a control plane node.
+type Config struct {
+	Mode    string `json:"mode" yaml:"mode"`
+	Context string `json:"context" yaml:"context"`
+}
+
+// GetConfig is a helper function that reads the downstream API access configuration.
+func GetConfig() *Config {
+	accessConfig := new(Config)
+	config.LoadConfig(ConfigurationFileKey, accessConfig)
+
+	if accessConfig.Mode == "" {
+		accessConfig.Mode = ProxyMode
+	}
+
+	return accessConfig
+}
+
+// GetDynamicClient is a helper function that returns a dynamic client of the downstream cluster in the configured access
+// mode, so a suite can switch to the authorized cluster endpoint without changing its code.
+func GetDynamicClient(client *rancher.Client, clusterID string) (dynamic.Interface, error) {
+	return GetDynamicClientWithConfig(client, clusterID, GetConfig())
+}
+
+// GetDynamicClientWithConfig is a helper function that returns a dynamic client of the downstream cluster in the access
+// mode of the given config, for suites that check both modes.
+func GetDynamicClientWithConfig(client *rancher.Client, clusterID string, accessConfig *Config) (dynamic.Interface, error) {
+	switch accessConfig.Mode {
+	case "", ProxyMode:
+		return client.GetDownStreamClusterClient(clusterID)
+	case DirectMode:
+		restConfig, err := GetDirectRestConfig(client, clusterID, accessConfig.Context)
+		if err != nil {
+			return nil, err
+		}
+
+		return frameworkDynamic.NewForConfig(client.Session, restConfig)
+	default:
+		return nil, fmt.Errorf("unknown %s mode %q, must be %q or %q", ConfigurationFileKey, accessConfig.Mode, ProxyMode, DirectMode)
+	}
+}
+
+// GetClientset is a helper function that returns a typed clientset of the downstream cluster in the configured access mode.
+func GetClientset(client *rancher.Client, clusterID string) (*kubernetes.Clientset, error) {
+	accessConfig := GetConfig()
+
+	var restConfig *rest.Config
+	var err error
+
+	switch accessConfig.Mode {
+	case "", ProxyMode:
+		restConfig, err = getProxyRestConfig(client, clusterID)
+	case DirectMode:
+		restConfig, err = GetDirectRestConfig(client, clusterID, accessConfig.Context)
+	default:
+		err = fmt.Errorf("unknown %s mode %q, must be %q or %q", ConfigurationFileKey, accessConfig.Mode, ProxyMode, DirectMode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return kubernetes.NewForConfig(restConfig)
+}
+
+// GetDirectRestConfig is a helper function that returns the rest config of the authorized cluster endpoint of the
+// downstream cluster, from the kubeconfig rancher generates for it. An empty context name selects the FQDN context, or
+// else the first context of a control plane node. The authorized cluster endpoint must be enabled on the cluster.
+func GetDirectRestConfig(client *rancher.Client, clusterID, contextName string) (*rest.Config, error) {
+	cluster, err := client.Management.Cluster.ByID(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cluster.LocalClusterAuthEndpoint == nil || !cluster.LocalClusterAuthEndpoint.Enabled {
+		return nil, fmt.Errorf("the authorized cluster endpoint of cluster %s is not enabled", clusterID)
+	}
+
+	restConfig, err := getContextRestConfig(client, clusterID, contextName)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Debugf("Reaching cluster %s directly at %s", clusterID, restConfig.Host)
+
+	return restConfig, nil
+}
+
+// GetDirectContexts is a helper function that returns the names of the kubeconfig contexts of the downstream cluster that
+// bypass rancher, sorted with the FQDN context first.
+func GetDirectContexts(client *rancher.Client, clusterID string) ([]string, error) {
+	clientConfig, err := kubeconfig.GetKubeconfig(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	rawConfig, err := (*clientConfig).RawConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return directContexts(rawConfig.Contexts, rawConfig.CurrentContext), nil
+}
+
+// getProxyRestConfig is a private helper function that returns the rest config of the current context of the kubeconfig
+// rancher generates for the downstream cluster, which goes through the rancher proxy.
+func getProxyRestConfig(client *rancher.Client, clusterID string) (*rest.Config, error) {
+	clientConfig, err := kubeconfig.GetKubeconfig(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	return (*clientConfig).ClientConfig()
+}
+
+// getContextRestConfig is a private helper function that returns the rest config of a context of the kubeconfig rancher
+// generates for the downstream cluster. An empty context name selects the first direct context.
+func getContextRestConfig(client *rancher.Client, clusterID, contextName string) (*rest.Config, error) {
+	clientConfig, err := kubeconfig.GetKubeconfig(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	rawConfig, err := (*clientConfig).RawConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if contextName == "" {
+		contexts := directContexts(rawConfig.Contexts, rawConfig.CurrentContext)
+		if len(contexts) == 0 {
+			return nil, fmt.Errorf("the kubeconfig of cluster %s has no authorized cluster endpoint context", clusterID)
+		}
+
+		contextName = contexts[0]
+	}
+
+	if _, ok := rawConfig.Contexts[contextName]; !ok {
+		return nil, fmt.Errorf("the kubeconfig of cluster %s has no context %s", clusterID, contextName)
+	}
+
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: contextName}
+
+	return clientcmd.NewNonInteractiveClientConfig(rawConfig, contextName, overrides, (*clientConfig).ConfigAccess()).ClientConfig()
+}
+
+// directContexts is a private helper function that returns every context but the current one, which goes through the
+// rancher proxy, sorted with the FQDN context first.
+func directContexts(contexts map[string]*api.Context, proxyContext string) []string {
+	var names []string
+	for name := range contexts {
+		if name != proxyContext {
+			names = append(names, name)
+		}
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		iFQDN, jFQDN := strings.HasSuffix(names[i], fqdnContextSuffix), strings.HasSuffix(names[j], fqdnContextSuffix)
+		if iFQDN != jFQDN {
+			return iFQDN
+		}
+
+		return names[i] < names[j]
+	})
+
+	return names
+}
@@ -0,0 +1,161 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
+	"github.com/rancher/shepherd/pkg/clientbase"
+	"github.com/sirupsen/logrus"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// EKSKontainerDriver is the kontainer driver of the EKS operator hosted clusters
+	EKSKontainerDriver = "amazonelasticcontainerservice"
+	// GKEKontainerDriver is the kontainer driver of the GKE operator hosted clusters
+	GKEKontainerDriver = "googlekubernetesengine"
+	// AKSKontainerDriver is the kontainer driver of the AKS operator hosted clusters
+	AKSKontainerDriver = "azurekubernetesservice"
+	// AliyunKontainerDriver is the legacy kontainer driver of Alibaba ACK clusters
+	AliyunKontainerDriver = "aliyunkubernetescontainerservice"
+	// TencentKontainerDriver is the legacy kontainer driver of Tencent TKE clusters
+	TencentKontainerDriver = "tencentkubernetesengine"
+	// HuaweiKontainerDriver is the legacy kontainer driver of Huawei CCE clusters
+	HuaweiKontainerDriver = "huaweicontainercloudengine"
+	// OracleKontainerDriver is the legacy kontainer driver of Oracle OKE clusters
+	OracleKontainerDriver = "oraclecontainerengine"
+	// LinodeKontainerDriver is the legacy kontainer driver of Linode LKE clusters
+	LinodeKontainerDriver = "linodekubernetesengine"
+
+	builtInSchemaSuffix = "config"
+	customSchemaSuffix  = "engineconfig"
+)
+
+// SetKontainerDriverActive is a helper function that activates or deactivates the kontainer driver and waits for its
+// dynamic schema to appear or go away, so hosted provider suites can enable their driver themselves. The previous state
+// is restored by the session of the client. Clients created before the change don't know the new cluster config field,
+// so a new client must be created to use it.
+func SetKontainerDriverActive(client *rancher.Client, kontainerDriverID string, active bool) (*management.KontainerDriver, error) {
+	kontainerDriver, err := client.Management.KontainerDriver.ByID(kontainerDriverID)
+	if err != nil {
+		return nil, err
+	}
+
+	previouslyActive := kontainerDriver.Active
+
+	kontainerDriver, err = setKontainerDriverActive(client, kontainerDriver, active)
+	if err != nil {
+		return nil, err
+	}
+
+	if previouslyActive != active {
+		client.Session.RegisterCleanupFunc(func() error {
+			kontainerDriver, err := client.Management.KontainerDriver.ByID(kontainerDriverID)
+			if err != nil {
+				return err
+			}
+
+			_, err = setKontainerDriverActive(client, kontainerDriver, previouslyActive)
+			return err
+		})
+	}
+
+	return kontainerDriver, nil
+}
+
+// ActivateKontainerDriver is a helper function that activates the kontainer driver, waits for rancher to install it and
+// for its dynamic schema to appear. The driver is deactivated again by the session of the client, if it was inactive.
+func ActivateKontainerDriver(client *rancher.Client, kontainerDriverID string) (*management.KontainerDriver, error) {
+	return SetKontainerDriverActive(client, kontainerDriverID, true)
+}
+
+// DeactivateKontainerDriver is a helper function that deactivates the kontainer driver and waits for its dynamic schema
+// to go away. The driver is activated again by the session of the client, if it was active.
+func DeactivateKontainerDriver(client *rancher.Client, kontainerDriverID string) (*management.KontainerDriver, error) {
+	return SetKontainerDriverActive(client, kontainerDriverID, false)
+}
+
+// GetKontainerDriverSchemaID is a helper function that returns the ID of the dynamic schema rancher creates for the
+// cluster config of the kontainer driver while it is active.
+func GetKontainerDriverSchemaID(kontainerDriver *management.KontainerDriver) string {
+	if kontainerDriver.BuiltIn {
+		return strings.ToLower(kontainerDriver.Name) + builtInSchemaSuffix
+	}
+
+	return strings.ToLower(kontainerDriver.Name) + customSchemaSuffix
+}
+
+// setKontainerDriverActive is a private helper function that activates or deactivates the kontainer driver, unless it
+// already is, and waits for its state and dynamic schema to follow.
+func setKontainerDriverActive(client *rancher.Client, kontainerDriver *management.KontainerDriver, active bool) (*management.KontainerDriver, error) {
+	if kontainerDriver.Active != active {
+		var err error
+		if active {
+			logrus.Infof("Activating kontainer driver %s", kontainerDriver.ID)
+			err = client.Management.KontainerDriver.ActionActivate(kontainerDriver)
+		} else {
+			logrus.Infof("Deactivating kontainer driver %s", kontainerDriver.ID)
+			err = client.Management.KontainerDriver.ActionDeactivate(kontainerDriver)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	state := inactiveState
+	if active {
+		state = activeState
+	}
+
+	kontainerDriver, err := waitForKontainerDriverState(client, kontainerDriver.ID, state)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaID := GetKontainerDriverSchemaID(kontainerDriver)
+
+	err = kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Scale(driverTimeout), true, func(context.Context) (done bool, err error) {
+		_, err = client.Management.DynamicSchema.ByID(schemaID)
+		if clientbase.IsNotFound(err) {
+			return !active, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		return active, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("dynamic schema %s of kontainer driver %s did not follow its activation to %t: %w", schemaID, kontainerDriver.ID, active, err)
+	}
+
+	return kontainerDriver, nil
+}
+
+// waitForKontainerDriverState is a private helper function that waits for the kontainer driver to reach the state and
+// returns it. It fails early when rancher reports an error, e.g. a download or checksum failure.
+func waitForKontainerDriverState(client *rancher.Client, kontainerDriverID, state string) (*management.KontainerDriver, error) {
+	var kontainerDriver *management.KontainerDriver
+
+	err := kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Scale(driverTimeout), true, func(context.Context) (done bool, err error) {
+		kontainerDriver, err = client.Management.KontainerDriver.ByID(kontainerDriverID)
+		if err != nil {
+			return false, err
+		}
+
+		if kontainerDriver.Transitioning == "error" {
+			return false, fmt.Errorf("kontainer driver %s failed: %s", kontainerDriverID, kontainerDriver.TransitioningMessage)
+		}
+
+		return kontainerDriver.State == state, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return kontainerDriver, nil
+}
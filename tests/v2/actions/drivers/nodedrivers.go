@@ -0,0 +1,181 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
+	"github.com/rancher/shepherd/pkg/clientbase"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/sirupsen/logrus"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+// The json/yaml config key for the custom node driver
+const NodeDriverConfigurationFileKey = "nodeDriver"
+
+const (
+	activeState              = "active"
+	inactiveState            = "inactive"
+	schemaSteveType          = "schema"
+	machineConfigSchemaIDFmt = "rke-machine-config.cattle.io.%sconfig"
+	driverTimeout            = 10 * time.Minute
+)
+
+// NodeDriverConfig is the configuration of a custom node driver, e.g.
+//
+//	nodeDriver:
+//	  url: https://example.com/docker-machine-driver-example
+//	  checksum: 5a4c0d4f4e1c...
+//	  uiUrl: https://example.com/component.js
+//	  whitelistDomains:
+//	  - example.com
+//
+// The URL is the docker-machine driver binary rancher downloads, its file name being docker-machine-driver-<name>.
+type NodeDriverConfig struct {
+	URL              string   `json:"url" yaml:"url"`
+	Checksum         string   `json:"checksum" yaml:"checksum"`
+	UIURL            string   `json:"uiUrl" yaml:"uiUrl"`
+	WhitelistDomains []string `json:"whitelistDomains" yaml:"whitelistDomains"`
+	Description      string   `json:"description" yaml:"description"`
+}
+
+// LoadNodeDriverConfig is a helper function that loads the custom node driver configuration from the config file.
+func LoadNodeDriverConfig() *NodeDriverConfig {
+	nodeDriverConfig := new(NodeDriverConfig)
+	config.LoadConfig(NodeDriverConfigurationFileKey, nodeDriverConfig)
+
+	return nodeDriverConfig
+}
+
+// RegisterNodeDriver is a helper function that registers the custom node driver, activates it and waits for its
+// machine config schema to appear. The node driver is deleted by the session of the client. Clients created before the
+// registration don't know the machine config type of the driver, so a new client must be created to use it.
+func RegisterNodeDriver(client *rancher.Client, nodeDriverConfig *NodeDriverConfig) (*management.NodeDriver, error) {
+	nodeDriver, err := client.Management.NodeDriver.Create(&management.NodeDriver{
+		URL:              nodeDriverConfig.URL,
+		Checksum:         nodeDriverConfig.Checksum,
+		UIURL:            nodeDriverConfig.UIURL,
+		WhitelistDomains: nodeDriverConfig.WhitelistDomains,
+		Description:      nodeDriverConfig.Description,
+		Active:           false,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		err := client.Management.NodeDriver.Delete(nodeDriver)
+		if clientbase.IsNotFound(err) {
+			return nil
+		}
+
+		return err
+	})
+
+	return ActivateNodeDriver(client, nodeDriver.ID)
+}
+
+// ActivateNodeDriver is a helper function that activates the node driver, waits for rancher to download it and for its
+// machine config schema to appear.
+func ActivateNodeDriver(client *rancher.Client, nodeDriverID string) (*management.NodeDriver, error) {
+	nodeDriver, err := client.Management.NodeDriver.ByID(nodeDriverID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !nodeDriver.Active {
+		logrus.Infof("Activating node driver %s", nodeDriverID)
+
+		_, err = client.Management.NodeDriver.ActionActivate(nodeDriver)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	nodeDriver, err = waitForNodeDriverState(client, nodeDriverID, activeState)
+	if err != nil {
+		return nil, err
+	}
+
+	err = WaitForMachineConfigSchema(client, nodeDriver.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return nodeDriver, nil
+}
+
+// DeactivateNodeDriver is a helper function that deactivates the node driver and waits for it to be inactive.
+func DeactivateNodeDriver(client *rancher.Client, nodeDriverID string) (*management.NodeDriver, error) {
+	nodeDriver, err := client.Management.NodeDriver.ByID(nodeDriverID)
+	if err != nil {
+		return nil, err
+	}
+
+	if nodeDriver.Active {
+		logrus.Infof("Deactivating node driver %s", nodeDriverID)
+
+		_, err = client.Management.NodeDriver.ActionDeactivate(nodeDriver)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return waitForNodeDriverState(client, nodeDriverID, inactiveState)
+}
+
+// WaitForMachineConfigSchema is a helper function that waits for the rke-machine-config schema of the node driver to be
+// served by steve, which is when RKE2 and K3s machine pools can use the driver.
+func WaitForMachineConfigSchema(client *rancher.Client, driverName string) error {
+	schemaID := fmt.Sprintf(machineConfigSchemaIDFmt, strings.ToLower(driverName))
+
+	err := kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Scale(driverTimeout), true, func(context.Context) (done bool, err error) {
+		_, err = client.Steve.SteveType(schemaSteveType).ByID(schemaID)
+		if clientbase.IsNotFound(err) {
+			return false, nil
+		}
+
+		return err == nil, err
+	})
+	if err != nil {
+		return fmt.Errorf("schema %s of node driver %s did not appear: %w", schemaID, driverName, err)
+	}
+
+	return nil
+}
+
+// waitForNodeDriverState is a private helper function that waits for the node driver to reach the state and returns it.
+// It fails early when rancher reports an error, e.g. a download or checksum failure.
+func waitForNodeDriverState(client *rancher.Client, nodeDriverID, state string) (*management.NodeDriver, error) {
+	var nodeDriver *management.NodeDriver
+
+	err := wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(driverTimeout), func(context.Context) (done bool, err error) {
+		nodeDriver, err = client.Management.NodeDriver.ByID(nodeDriverID)
+		if err != nil {
+			return false, err
+		}
+
+		if nodeDriver.Transitioning == "error" {
+			return false, fmt.Errorf("node driver %s failed: %s", nodeDriverID, nodeDriver.TransitioningMessage)
+		}
+
+		return nodeDriver.State == state, nil
+	}, func() string {
+		if nodeDriver == nil {
+			return fmt.Sprintf("no node driver %s", nodeDriverID)
+		}
+
+		return fmt.Sprintf("node driver %s %s instead of %s", nodeDriverID, nodeDriver.State, state)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return nodeDriver, nil
+}
@@ -0,0 +1,189 @@
+package features
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/tests/v2/actions/rancherupgrade"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/features"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// LegacyFeature is the feature flag of the legacy v3 features, e.g. multi-cluster apps and pipelines
+	LegacyFeature = "legacy"
+	// HarvesterFeature is the feature flag of the harvester virtualization management
+	HarvesterFeature = "harvester"
+	// RKE2Feature is the feature flag of the RKE2 and K3s provisioning
+	RKE2Feature = "rke2"
+
+	rancherNamespace     = "cattle-system"
+	rancherLabelSelector = "app=rancher"
+	rancherContainerName = "rancher"
+	podSteveType         = "pod"
+	restartTimeout       = 10 * time.Minute
+)
+
+// SetFeatureFlag is a helper function that sets the value of the feature flag and, if the flag is not dynamic, waits
+// for the rancher pods to restart and the API to answer again. It returns a new admin client, as the clients created
+// before a restart may hold stale schemas. The original value is restored the same way by the session of the client.
+func SetFeatureFlag(client *rancher.Client, featureName string, value bool) (*rancher.Client, error) {
+	_, feature, err := getFeature(client, featureName)
+	if err != nil {
+		return nil, err
+	}
+
+	originalValue := feature.Spec.Value
+
+	updatedClient, err := setFeatureValue(client, featureName, &value)
+	if err != nil {
+		return nil, err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		_, err := setFeatureValue(client, featureName, originalValue)
+		return err
+	})
+
+	return updatedClient, nil
+}
+
+// GetFeatureFlag is a helper function that returns the effective value of the feature flag: its locked value, or else
+// the value set on it, or else its default.
+func GetFeatureFlag(client *rancher.Client, featureName string) (bool, error) {
+	_, feature, err := getFeature(client, featureName)
+	if err != nil {
+		return false, err
+	}
+
+	return effectiveValue(feature), nil
+}
+
+// setFeatureValue is a private helper function that sets the value of the feature flag, nil resetting it to its
+// default, and waits for rancher to restart when the effective value of a flag that is not dynamic changes.
+func setFeatureValue(client *rancher.Client, featureName string, value *bool) (*rancher.Client, error) {
+	featureObject, feature, err := getFeature(client, featureName)
+	if err != nil {
+		return nil, err
+	}
+
+	if feature.Status.LockedValue != nil {
+		return nil, fmt.Errorf("feature %s is locked to %t", featureName, *feature.Status.LockedValue)
+	}
+
+	previousValue := effectiveValue(feature)
+
+	feature.Spec.Value = value
+	if effectiveValue(feature) == previousValue {
+		_, err = client.Steve.SteveType(features.ManagementFeature).Update(featureObject, feature)
+		return client, err
+	}
+
+	restartCounts, err := getRancherRestartCounts(client)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Setting feature %s to %t", featureName, effectiveValue(feature))
+
+	_, err = client.Steve.SteveType(features.ManagementFeature).Update(featureObject, feature)
+	if err != nil {
+		return nil, err
+	}
+
+	if feature.Status.Dynamic {
+		return client, nil
+	}
+
+	err = waitForRancherRestart(client, restartCounts)
+	if err != nil {
+		return nil, err
+	}
+
+	return rancherupgrade.WaitForRancher(client, "", timeouts.Scale(restartTimeout))
+}
+
+// getFeature is a private helper function that returns the steve object of the feature flag with the given name and
+// the feature it holds.
+func getFeature(client *rancher.Client, featureName string) (*v1.SteveAPIObject, *v3.Feature, error) {
+	featureObject, err := client.Steve.SteveType(features.ManagementFeature).ByID(featureName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	feature := &v3.Feature{}
+	err = v1.ConvertToK8sType(featureObject.JSONResp, feature)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return featureObject, feature, nil
+}
+
+// effectiveValue is a private helper function that returns the value rancher applies for the feature flag.
+func effectiveValue(feature *v3.Feature) bool {
+	if feature.Status.LockedValue != nil {
+		return *feature.Status.LockedValue
+	}
+
+	if feature.Spec.Value != nil {
+		return *feature.Spec.Value
+	}
+
+	return feature.Status.Default
+}
+
+// getRancherRestartCounts is a private helper function that returns the restart count of the rancher container of
+// every rancher pod, by pod name.
+func getRancherRestartCounts(client *rancher.Client) (map[string]int32, error) {
+	podList, err := client.Steve.SteveType(podSteveType).NamespacedSteveClient(rancherNamespace).List(map[string][]string{
+		"labelSelector": {rancherLabelSelector},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	restartCounts := map[string]int32{}
+	for _, podObject := range podList.Data {
+		pod := &corev1.Pod{}
+		err = v1.ConvertToK8sType(podObject.JSONResp, pod)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			if containerStatus.Name == rancherContainerName {
+				restartCounts[pod.Name] = containerStatus.RestartCount
+			}
+		}
+	}
+
+	return restartCounts, nil
+}
+
+// waitForRancherRestart is a private helper function that waits until every rancher pod was restarted or replaced since
+// the restart counts were taken. Errors are ignored while polling, as the API goes away during the restart.
+func waitForRancherRestart(client *rancher.Client, previousRestartCounts map[string]int32) error {
+	return kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Scale(restartTimeout), false, func(context.Context) (done bool, err error) {
+		restartCounts, err := getRancherRestartCounts(client)
+		if err != nil || len(restartCounts) == 0 {
+			return false, nil
+		}
+
+		for podName, restartCount := range restartCounts {
+			previousRestartCount, ok := previousRestartCounts[podName]
+			if ok && restartCount <= previousRestartCount {
+				return false, nil
+			}
+		}
+
+		return true, nil
+	})
+}
@@ -0,0 +1,333 @@
+package fixtures
+
+import (
+	"fmt"
+	"time"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/hardened"
+	"github.com/rancher/rancher/tests/v2/actions/namer"
+	"github.com/rancher/rancher/tests/v2/actions/nodeos"
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/services"
+	"github.com/rancher/shepherd/extensions/workloads"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// MetricsPort is the port the metrics app serves its prometheus metrics on, at /metrics
+	MetricsPort = 8080
+	// MetricsPortName is the name of the metrics port of the metrics app and of its service
+	MetricsPortName = "metrics"
+	// FixtureLabelKey is the label of the pods of a fixture, whose value is the name of the fixture
+	FixtureLabelKey = "fixture"
+
+	metricsAppImage          = "quay.io/brancz/prometheus-example-app:v0.3.0"
+	scriptImage              = "busybox:1.36"
+	serviceMonitorSteveType  = "monitoring.coreos.com.servicemonitor"
+	serviceMonitorAPIVersion = "monitoring.coreos.com/v1"
+	serviceMonitorKind       = "ServiceMonitor"
+	defaultLogMessage        = "noisy logger line"
+	defaultLogInterval       = time.Second
+	defaultCrashAfter        = 5 * time.Second
+	defaultExitCode          = 1
+	defaultCPULimit          = "50m"
+	// fixtureUser is the user the fixtures run as, so they are admitted with the restricted pod security standard even
+	// though their images run as root by default
+	fixtureUser int64 = 65534
+)
+
+// App is a struct of a fixture deployed in a cluster.
+type App struct {
+	Name      string
+	Namespace string
+	ClusterID string
+	// Labels are the labels of the pods of the fixture
+	Labels     map[string]string
+	Deployment *v1.SteveAPIObject
+	// Service is nil for fixtures without one
+	Service *v1.SteveAPIObject
+}
+
+// MetricsAppOpts is a struct of the options of the metrics app, which serves the http_requests_total and version
+// metrics of the prometheus example app.
+type MetricsAppOpts struct {
+	// Name is the name of the fixture, generated when empty
+	Name string
+	// Replicas is 1 when zero
+	Replicas int32
+	// ServiceMonitor creates a service monitor scraping the app, which needs the rancher-monitoring CRDs
+	ServiceMonitor bool
+}
+
+// NoisyLoggerOpts is a struct of the options of the noisy logger, which writes a numbered line to its stdout at every
+// interval, e.g. to check logs are shipped.
+type NoisyLoggerOpts struct {
+	// Name is the name of the fixture, generated when empty
+	Name string
+	// Message is the line the logger writes, followed by its number
+	Message string
+	// Interval is the time between two lines, 1s when zero
+	Interval time.Duration
+	// Replicas is 1 when zero
+	Replicas int32
+}
+
+// CrashingAppOpts is a struct of the options of the crashing app, whose container exits with an error after a delay and
+// ends up in CrashLoopBackOff, e.g. to fire the KubePodCrashLooping alert.
+type CrashingAppOpts struct {
+	// Name is the name of the fixture, generated when empty
+	Name string
+	// CrashAfter is the time the container runs before it exits, 5s when zero
+	CrashAfter time.Duration
+	// ExitCode is the exit code of the container, 1 when zero
+	ExitCode int
+}
+
+// CPUBurnerOpts is a struct of the options of the CPU burner, whose container spins in a busy loop under a CPU limit so
+// it is constantly throttled, e.g. to fire the CPUThrottlingHigh alert.
+type CPUBurnerOpts struct {
+	// Name is the name of the fixture, generated when empty
+	Name string
+	// CPULimit is the CPU limit of the container, 50m when empty
+	CPULimit string
+}
+
+// NewMetricsAppDeployment is a constructor that returns the deployment of the metrics app, with a metrics port.
+func NewMetricsAppDeployment(name, namespace string, replicas int32) (*appv1.Deployment, error) {
+	container := workloads.NewContainer(name, metricsAppImage, corev1.PullIfNotPresent, nil, nil, nil, nil, nil)
+	container.Ports = []corev1.ContainerPort{{Name: MetricsPortName, ContainerPort: MetricsPort, Protocol: corev1.ProtocolTCP}}
+
+	return newFixtureDeployment(name, namespace, replicas, container)
+}
+
+// NewNoisyLoggerDeployment is a constructor that returns the deployment of the noisy logger.
+func NewNoisyLoggerDeployment(name, namespace string, opts *NoisyLoggerOpts) (*appv1.Deployment, error) {
+	message := opts.Message
+	if message == "" {
+		message = defaultLogMessage
+	}
+
+	interval := opts.Interval
+	if interval == 0 {
+		interval = defaultLogInterval
+	}
+
+	script := fmt.Sprintf(`i=0; while true; do i=$((i+1)); echo "%s $i"; sleep %g; done`, message, interval.Seconds())
+	container := workloads.NewContainer(name, scriptImage, corev1.PullIfNotPresent, nil, nil, nodeos.ShellCommand(nodeos.Linux, script), nil, nil)
+
+	return newFixtureDeployment(name, namespace, opts.Replicas, container)
+}
+
+// NewCrashingAppDeployment is a constructor that returns the deployment of the crashing app.
+func NewCrashingAppDeployment(name, namespace string, opts *CrashingAppOpts) (*appv1.Deployment, error) {
+	crashAfter := opts.CrashAfter
+	if crashAfter == 0 {
+		crashAfter = defaultCrashAfter
+	}
+
+	exitCode := opts.ExitCode
+	if exitCode == 0 {
+		exitCode = defaultExitCode
+	}
+
+	script := fmt.Sprintf(`echo "crashing in %s"; sleep %g; exit %d`, crashAfter, crashAfter.Seconds(), exitCode)
+	container := workloads.NewContainer(name, scriptImage, corev1.PullIfNotPresent, nil, nil, nodeos.ShellCommand(nodeos.Linux, script), nil, nil)
+
+	return newFixtureDeployment(name, namespace, 1, container)
+}
+
+// NewCPUBurnerDeployment is a constructor that returns the deployment of the CPU burner.
+func NewCPUBurnerDeployment(name, namespace string, opts *CPUBurnerOpts) (*appv1.Deployment, error) {
+	cpuLimit := opts.CPULimit
+	if cpuLimit == "" {
+		cpuLimit = defaultCPULimit
+	}
+
+	limit, err := resource.ParseQuantity(cpuLimit)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CPU limit %q: %w", cpuLimit, err)
+	}
+
+	container := workloads.NewContainer(name, scriptImage, corev1.PullIfNotPresent, nil, nil, nodeos.ShellCommand(nodeos.Linux, "while true; do :; done"), nil, nil)
+	container.Resources = corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: limit},
+		Limits:   corev1.ResourceList{corev1.ResourceCPU: limit},
+	}
+
+	return newFixtureDeployment(name, namespace, 1, container)
+}
+
+// DeployMetricsApp is a helper function that deploys the metrics app in the namespace of the cluster with a service
+// exposing its metrics port and, when the options say so, a service monitor, and waits until it is ready. The
+// resources are deleted by the session of the client.
+func DeployMetricsApp(client *rancher.Client, clusterID, namespace string, opts *MetricsAppOpts) (*App, error) {
+	name := fixtureName(client, opts.Name, "metrics-app")
+
+	deployment, err := NewMetricsAppDeployment(name, namespace, opts.Replicas)
+	if err != nil {
+		return nil, err
+	}
+
+	app, err := deployFixture(client, clusterID, deployment, true)
+	if err != nil {
+		return nil, err
+	}
+
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := []corev1.ServicePort{{Name: MetricsPortName, Port: MetricsPort, TargetPort: intstr.FromString(MetricsPortName)}}
+	service := services.NewServiceTemplate(name, namespace, corev1.ServiceTypeClusterIP, ports, app.Labels)
+	service.Labels = app.Labels
+
+	app.Service, err = steveClient.SteveType(services.ServiceSteveType).Create(service)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ServiceMonitor {
+		_, err = steveClient.SteveType(serviceMonitorSteveType).Create(newServiceMonitor(name, namespace, app.Labels))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return app, nil
+}
+
+// DeployNoisyLogger is a helper function that deploys the noisy logger in the namespace of the cluster and waits until
+// it is ready. The deployment is deleted by the session of the client.
+func DeployNoisyLogger(client *rancher.Client, clusterID, namespace string, opts *NoisyLoggerOpts) (*App, error) {
+	name := fixtureName(client, opts.Name, "noisy-logger")
+
+	deployment, err := NewNoisyLoggerDeployment(name, namespace, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return deployFixture(client, clusterID, deployment, true)
+}
+
+// DeployCrashingApp is a helper function that deploys the crashing app in the namespace of the cluster. It is never
+// ready, so it is not waited for. The deployment is deleted by the session of the client.
+func DeployCrashingApp(client *rancher.Client, clusterID, namespace string, opts *CrashingAppOpts) (*App, error) {
+	name := fixtureName(client, opts.Name, "crashing-app")
+
+	deployment, err := NewCrashingAppDeployment(name, namespace, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return deployFixture(client, clusterID, deployment, false)
+}
+
+// DeployCPUBurner is a helper function that deploys the CPU burner in the namespace of the cluster and waits until it
+// is ready. The deployment is deleted by the session of the client.
+func DeployCPUBurner(client *rancher.Client, clusterID, namespace string, opts *CPUBurnerOpts) (*App, error) {
+	name := fixtureName(client, opts.Name, "cpu-burner")
+
+	deployment, err := NewCPUBurnerDeployment(name, namespace, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return deployFixture(client, clusterID, deployment, true)
+}
+
+// newFixtureDeployment is a private constructor that returns the deployment of the container labeled with the name of
+// the fixture, admitted with the restricted pod security standard and pinned to linux nodes, as the images are linux
+// only.
+func newFixtureDeployment(name, namespace string, replicas int32, container corev1.Container) (*appv1.Deployment, error) {
+	labels := map[string]string{FixtureLabelKey: name}
+
+	podTemplate := workloads.NewPodTemplate([]corev1.Container{container}, nil, nil, labels)
+
+	runAsUser := fixtureUser
+	podTemplate.Spec.SecurityContext = &corev1.PodSecurityContext{RunAsUser: &runAsUser, RunAsGroup: &runAsUser}
+	hardened.ApplyPodTemplate(&podTemplate)
+
+	err := nodeos.SetPodTemplateOS(&podTemplate, nodeos.Linux)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment := workloads.NewDeploymentTemplate(name, namespace, podTemplate, false, labels)
+	if replicas > 0 {
+		deployment.Spec.Replicas = &replicas
+	}
+
+	return deployment, nil
+}
+
+// deployFixture is a private helper function that creates the deployment of a fixture and, when ready is true, waits
+// until it is ready.
+func deployFixture(client *rancher.Client, clusterID string, deployment *appv1.Deployment, ready bool) (*App, error) {
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	deploymentObject, err := steveClient.SteveType(workloads.DeploymentSteveType).Create(deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	app := &App{
+		Name:       deployment.Name,
+		Namespace:  deployment.Namespace,
+		ClusterID:  clusterID,
+		Labels:     deployment.Spec.Template.Labels,
+		Deployment: deploymentObject,
+	}
+
+	if !ready {
+		return app, nil
+	}
+
+	err = actionscharts.WatchAndWaitDeployments(client, clusterID, deployment.Namespace, metav1.ListOptions{
+		FieldSelector: "metadata.name=" + deployment.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return app, nil
+}
+
+// newServiceMonitor is a private constructor that returns the service monitor scraping the metrics port of the
+// services with the labels.
+func newServiceMonitor(name, namespace string, labels map[string]string) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": serviceMonitorAPIVersion,
+		"kind":       serviceMonitorKind,
+		"metadata": metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		"spec": map[string]interface{}{
+			"selector": metav1.LabelSelector{MatchLabels: labels},
+			"endpoints": []map[string]interface{}{
+				{"port": MetricsPortName},
+			},
+		},
+	}
+}
+
+// fixtureName is a private helper function that returns the name of the options, or else a name generated from the
+// prefix.
+func fixtureName(client *rancher.Client, name, prefix string) string {
+	if name != "" {
+		return name
+	}
+
+	return namer.New(client).Name(prefix)
+}
@@ -0,0 +1,322 @@
+package gatekeeper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ConstraintsGroup is the API group of the constraints of the constraint templates
+	ConstraintsGroup = "constraints.gatekeeper.sh"
+
+	constraintTemplateKind = "ConstraintTemplate"
+	constraintReadyTimeout = 2 * time.Minute
+	auditResultsTimeout    = 5 * time.Minute
+)
+
+// ConstraintTemplateGroupVersionResource is the required Group Version Resource for accessing gatekeeper constraint
+// templates in a cluster, using the dynamic client.
+var ConstraintTemplateGroupVersionResource = schema.GroupVersionResource{
+	Group:    "templates.gatekeeper.sh",
+	Version:  "v1",
+	Resource: "constrainttemplates",
+}
+
+// Constraint is a struct of the kind and name a constraint is looked up with, e.g. K8sRequiredLabels and
+// ns-must-have-label.
+type Constraint struct {
+	Kind string
+	Name string
+	// Version is the version of the constraints API, e.g. v1beta1
+	Version string
+}
+
+// AuditResults is a struct of the results of the last audit of a constraint.
+type AuditResults struct {
+	// AuditTimestamp is when the last audit ran
+	AuditTimestamp string `json:"auditTimestamp"`
+	// TotalViolations is the number of resources violating the constraint, the violations being capped by the
+	// constraint violations limit of the audit, 20 by default
+	TotalViolations int64       `json:"totalViolations"`
+	Violations      []Violation `json:"violations"`
+}
+
+// Violation is a struct of a resource violating a constraint.
+type Violation struct {
+	Kind              string `json:"kind"`
+	Name              string `json:"name"`
+	Namespace         string `json:"namespace"`
+	Message           string `json:"message"`
+	EnforcementAction string `json:"enforcementAction"`
+}
+
+// GroupVersionResource returns the Group Version Resource for accessing the constraints of the kind of the
+// constraint, using the dynamic client.
+func (c *Constraint) GroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    ConstraintsGroup,
+		Version:  c.Version,
+		Resource: strings.ToLower(c.Kind),
+	}
+}
+
+// InstallRancherGatekeeperChart is a helper function that installs the rancher-gatekeeper chart like the shepherd
+// helper does, with the policy of GetInFlightPolicy for the helm operations in progress on it, and waits for its
+// deployments and daemon sets. When the operation options stream the logs, the logs of its helm operations are written
+// to the test log while it installs.
+func InstallRancherGatekeeperChart(client *rancher.Client, installOptions *charts.InstallOptions, operationOptions *actionscharts.OperationOptions) error {
+	clusterID := installOptions.Cluster.ID
+
+	err := actionscharts.GuardReleaseOperation(client, clusterID, charts.RancherGatekeeperNamespace, charts.RancherGatekeeperName, actionscharts.GetInFlightPolicy(), func() error {
+		stop := operationOptions.StreamOperationLogs(client, clusterID, charts.RancherGatekeeperNamespace, charts.RancherGatekeeperName)
+		defer stop()
+
+		return charts.InstallRancherGatekeeperChart(client, installOptions)
+	})
+	if err != nil {
+		return err
+	}
+
+	return waitGatekeeperWorkloads(client, clusterID)
+}
+
+// UpgradeRancherGatekeeperChart is a helper function that upgrades the rancher-gatekeeper chart to the version of the
+// install options like the shepherd helper does, with the policy of GetInFlightPolicy for the helm operations in
+// progress on it, and waits for its deployments and daemon sets.
+func UpgradeRancherGatekeeperChart(client *rancher.Client, installOptions *charts.InstallOptions, operationOptions *actionscharts.OperationOptions) error {
+	clusterID := installOptions.Cluster.ID
+
+	err := actionscharts.GuardReleaseOperation(client, clusterID, charts.RancherGatekeeperNamespace, charts.RancherGatekeeperName, actionscharts.GetInFlightPolicy(), func() error {
+		stop := operationOptions.StreamOperationLogs(client, clusterID, charts.RancherGatekeeperNamespace, charts.RancherGatekeeperName)
+		defer stop()
+
+		return charts.UpgradeRancherGatekeeperChart(client, installOptions)
+	})
+	if err != nil {
+		return err
+	}
+
+	return waitGatekeeperWorkloads(client, clusterID)
+}
+
+// ApplyConstraintTemplate is a helper function that creates the constraint template of the YAML manifest and waits
+// until gatekeeper created the CRD of its constraints, so constraints of its kind can be applied, and returns its name.
+// The constraint template is deleted by the session of the client.
+func ApplyConstraintTemplate(client *rancher.Client, clusterID, manifest string) (string, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return "", err
+	}
+
+	template, err := createFromManifest(client, dynamicClient, manifest, func(resource *unstructured.Unstructured) (schema.GroupVersionResource, error) {
+		if resource.GetKind() != constraintTemplateKind {
+			return schema.GroupVersionResource{}, fmt.Errorf("manifest is a %s, not a %s", resource.GetKind(), constraintTemplateKind)
+		}
+
+		return ConstraintTemplateGroupVersionResource, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	templateResource := dynamicClient.Resource(ConstraintTemplateGroupVersionResource)
+
+	var created bool
+	err = wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(constraintReadyTimeout), func(ctx context.Context) (done bool, err error) {
+		template, err := templateResource.Get(ctx, template.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		created, _, _ = unstructured.NestedBool(template.Object, "status", "created")
+
+		return created, nil
+	}, func() string {
+		return fmt.Sprintf("constraint template %s created: %t", template.GetName(), created)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	logrus.Infof("Applied constraint template %s", template.GetName())
+
+	return template.GetName(), nil
+}
+
+// ApplyConstraint is a helper function that creates the constraint of the YAML manifest, whose constraint template
+// must be applied first, and waits until the gatekeeper controller enforces it, then returns it. The constraint is
+// deleted by the session of the client.
+func ApplyConstraint(client *rancher.Client, clusterID, manifest string) (*Constraint, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	var constraint *Constraint
+	_, err = createFromManifest(client, dynamicClient, manifest, func(resource *unstructured.Unstructured) (schema.GroupVersionResource, error) {
+		groupVersion, err := schema.ParseGroupVersion(resource.GetAPIVersion())
+		if err != nil {
+			return schema.GroupVersionResource{}, err
+		}
+
+		if groupVersion.Group != ConstraintsGroup {
+			return schema.GroupVersionResource{}, fmt.Errorf("manifest is a %s of group %s, not a constraint", resource.GetKind(), groupVersion.Group)
+		}
+
+		constraint = &Constraint{Kind: resource.GetKind(), Name: resource.GetName(), Version: groupVersion.Version}
+
+		return constraint.GroupVersionResource(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	constraintResource := dynamicClient.Resource(constraint.GroupVersionResource())
+
+	var enforced bool
+	err = wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(constraintReadyTimeout), func(ctx context.Context) (done bool, err error) {
+		resource, err := constraintResource.Get(ctx, constraint.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		byPod, _, _ := unstructured.NestedSlice(resource.Object, "status", "byPod")
+		for _, podStatus := range byPod {
+			podStatusMap, ok := podStatus.(map[string]interface{})
+			if ok && podStatusMap["enforced"] == true {
+				enforced = true
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}, func() string {
+		return fmt.Sprintf("constraint %s %s enforced: %t", constraint.Kind, constraint.Name, enforced)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Applied constraint %s %s", constraint.Kind, constraint.Name)
+
+	return constraint, nil
+}
+
+// GetAuditResults is a helper function that returns the results of the last audit of the constraint, with an empty
+// audit timestamp when it was not audited yet.
+func GetAuditResults(client *rancher.Client, clusterID string, constraint *Constraint) (*AuditResults, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	resource, err := dynamicClient.Resource(constraint.GroupVersionResource()).Get(context.TODO(), constraint.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	status, _, _ := unstructured.NestedMap(resource.Object, "status")
+
+	results := &AuditResults{}
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(status, results)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// WaitForAuditResults is a helper function that waits until the audit of gatekeeper ran on the constraint after the
+// given time, e.g. when the constraint was applied, and returns its results.
+func WaitForAuditResults(client *rancher.Client, clusterID string, constraint *Constraint, after time.Time) (*AuditResults, error) {
+	var results *AuditResults
+	var lastErr error
+
+	err := wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(auditResultsTimeout), func(context.Context) (done bool, err error) {
+		results, lastErr = GetAuditResults(client, clusterID, constraint)
+		if lastErr != nil || results.AuditTimestamp == "" {
+			return false, nil
+		}
+
+		auditTime, err := time.Parse(time.RFC3339, results.AuditTimestamp)
+		if err != nil {
+			return false, err
+		}
+
+		return !auditTime.Before(after.Truncate(time.Second)), nil
+	}, func() string {
+		if results == nil {
+			return fmt.Sprintf("constraint %s %s has no status: %v", constraint.Kind, constraint.Name, lastErr)
+		}
+
+		return fmt.Sprintf("last audit of constraint %s %s ran at %q", constraint.Kind, constraint.Name, results.AuditTimestamp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Audit of constraint %s %s found %d violations", constraint.Kind, constraint.Name, results.TotalViolations)
+
+	return results, nil
+}
+
+// createFromManifest is a private helper function that creates the cluster scoped resource of the YAML manifest with
+// the Group Version Resource returned by resourceFor and registers its deletion with the session of the client.
+func createFromManifest(client *rancher.Client, dynamicClient dynamic.Interface, manifest string, resourceFor func(*unstructured.Unstructured) (schema.GroupVersionResource, error)) (*unstructured.Unstructured, error) {
+	resource := &unstructured.Unstructured{}
+	err := yaml.Unmarshal([]byte(manifest), &resource.Object)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse manifest: %w", err)
+	}
+
+	groupVersionResource, err := resourceFor(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceClient := dynamicClient.Resource(groupVersionResource)
+
+	created, err := resourceClient.Create(context.TODO(), resource, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s %s: %w", resource.GetKind(), resource.GetName(), err)
+	}
+
+	name := created.GetName()
+	client.Session.RegisterCleanupFunc(func() error {
+		err := resourceClient.Delete(context.TODO(), name, metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		return err
+	})
+
+	return created, nil
+}
+
+// waitGatekeeperWorkloads is a private helper function that waits for the deployments and the daemon sets of
+// rancher-gatekeeper.
+func waitGatekeeperWorkloads(client *rancher.Client, clusterID string) error {
+	err := actionscharts.WatchAndWaitDeployments(client, clusterID, charts.RancherGatekeeperNamespace, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	return actionscharts.WatchAndWaitDaemonSets(client, clusterID, charts.RancherGatekeeperNamespace, metav1.ListOptions{})
+}
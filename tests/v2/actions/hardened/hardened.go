@@ -0,0 +1,217 @@
+package hardened
+
+import (
+	"sync"
+
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/extensions/provisioninginput"
+	"github.com/rancher/shepherd/extensions/workloads"
+	"github.com/rancher/shepherd/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// The json/yaml config key for the hardened mode
+const ConfigurationFileKey = "hardened"
+
+const (
+	// defaultImage is the image that replaces the images of the suites when no image is configured, the default images
+	// of the suites usually run as root, which the restricted pod security standard doesn't allow
+	defaultImage = "nginxinc/nginx-unprivileged"
+	// defaultRunAsUser is the non-root user of the default image
+	defaultRunAsUser int64 = 101
+	// defaultImagePort is the port the default image listens on, as it can't bind port 80 without root
+	defaultImagePort int32 = 8080
+)
+
+// Config is the configuration of the hardened mode, e.g.
+//
+//	hardened:
+//	  enabled: true
+//	  image: registry.example.com/nginx-unprivileged
+//	  runAsUser: 101
+//	  adjustChartOptions: true
+//	  restricted: true
+//
+// When the hardened key is missing, the mode follows the hardened flag of the provisioning input, so suites running
+// against the cluster they just provisioned don't need to repeat it. The image must run as a non-root user: when it's not
+// set, nginxinc/nginx-unprivileged is used with its user 101; when it's set without runAsUser, the user of the image applies.
+// Chart options are only adjusted when adjustChartOptions is set.
+//
+// The workload builders emit pods compliant with the restricted pod security standard when the hardened mode is
+// enabled, so suites run unmodified on hardened clusters. Setting restricted to true does the same on clusters that
+// are not hardened, e.g. to check the suites are ready for it; otherwise the images and security contexts of the
+// suites are kept.
+type Config struct {
+	Enabled            bool   `json:"enabled" yaml:"enabled"`
+	Image              string `json:"image" yaml:"image"`
+	RunAsUser          int64  `json:"runAsUser" yaml:"runAsUser"`
+	AdjustChartOptions bool   `json:"adjustChartOptions" yaml:"adjustChartOptions"`
+	Restricted         *bool  `json:"restricted" yaml:"restricted"`
+}
+
+var (
+	loadOnce       sync.Once
+	hardenedConfig *Config
+)
+
+// Enabled returns true when the target cluster is CIS hardened and the workloads built by the tests must comply
+// with the restricted pod security standard.
+func Enabled() bool {
+	return getConfig().Enabled
+}
+
+// Restricted returns true when the workload builders must emit pods compliant with the restricted pod security
+// standard, which is when the hardened mode is enabled or restricted is set to true.
+func Restricted() bool {
+	hardenedConfig := getConfig()
+
+	return hardenedConfig.Enabled || (hardenedConfig.Restricted != nil && *hardenedConfig.Restricted)
+}
+
+// Image returns the configured non-root image when the pods are restricted, otherwise the given image. The default
+// images of the suites usually run as root, which the restricted pod security standard doesn't allow.
+func Image(image string) string {
+	if !Restricted() {
+		return image
+	}
+
+	return getConfig().Image
+}
+
+// TargetPort returns the port the default non-root image listens on when the pods are restricted and no image is
+// configured, otherwise the given port of the image of the suite. Services of the workloads built with the default
+// image must target it.
+func TargetPort(port int32) int32 {
+	if !Restricted() || getConfig().Image != defaultImage {
+		return port
+	}
+
+	return defaultImagePort
+}
+
+// NewContainer is a constructor that behaves as workloads.NewContainer, replacing the image with the non-root one and
+// setting a restricted security context on the container when the pods are restricted. The configured user only
+// applies to the containers whose image is replaced.
+func NewContainer(containerName, image string, imagePullPolicy corev1.PullPolicy, volumeMounts []corev1.VolumeMount, envFrom []corev1.EnvFromSource, command []string, securityContext *corev1.SecurityContext, args []string) corev1.Container {
+	container := workloads.NewContainer(containerName, Image(image), imagePullPolicy, volumeMounts, envFrom, command, securityContext, args)
+	if !Restricted() {
+		return container
+	}
+
+	if runAsUser := getConfig().RunAsUser; container.Image != image && runAsUser > 0 {
+		if container.SecurityContext == nil {
+			container.SecurityContext = &corev1.SecurityContext{}
+		}
+
+		container.SecurityContext.RunAsUser = &runAsUser
+	}
+
+	if !requiresRoot(&container) {
+		restrictContainer(&container)
+	}
+
+	return container
+}
+
+// NewPodTemplate is a constructor that behaves as workloads.NewPodTemplate, making the pod template compliant with
+// the restricted pod security standard when the pods are restricted.
+func NewPodTemplate(containers []corev1.Container, volumes []corev1.Volume, imagePullSecrets []corev1.LocalObjectReference, labels map[string]string) corev1.PodTemplateSpec {
+	template := workloads.NewPodTemplate(containers, volumes, imagePullSecrets, labels)
+	ApplyPodTemplate(&template)
+
+	return template
+}
+
+// ApplyPodTemplate is a helper function that makes the pod template compliant with the restricted pod security standard
+// when the pods are restricted: non-root with the runtime default seccomp profile, no privilege escalation and no
+// capabilities. The users of the pod and of its containers are kept, and containers explicitly running as root, e.g. a
+// kubectl sidecar, are left as they are, as is the non-root requirement of their pod.
+func ApplyPodTemplate(template *corev1.PodTemplateSpec) {
+	if !Restricted() {
+		return
+	}
+
+	if template.Spec.SecurityContext == nil {
+		template.Spec.SecurityContext = &corev1.PodSecurityContext{}
+	}
+
+	template.Spec.SecurityContext.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+
+	rootRequired := false
+	for _, containers := range [][]corev1.Container{template.Spec.InitContainers, template.Spec.Containers} {
+		for i := range containers {
+			if requiresRoot(&containers[i]) {
+				rootRequired = true
+				continue
+			}
+
+			restrictContainer(&containers[i])
+		}
+	}
+
+	if !rootRequired {
+		runAsNonRoot := true
+		template.Spec.SecurityContext.RunAsNonRoot = &runAsNonRoot
+	}
+}
+
+// AdjustMonitoringOpts is a helper function that disables the monitoring of the components that only expose their
+// metrics on localhost on CIS hardened clusters, as their targets can't be scraped there. The options are only adjusted
+// when the hardened mode is enabled and adjustChartOptions is set, otherwise they are returned unchanged.
+func AdjustMonitoringOpts(opts *charts.RancherMonitoringOpts) *charts.RancherMonitoringOpts {
+	hardenedConfig := getConfig()
+	if !hardenedConfig.Enabled || !hardenedConfig.AdjustChartOptions || opts == nil {
+		return opts
+	}
+
+	adjustedOpts := *opts
+	adjustedOpts.ControllerManager = false
+	adjustedOpts.Scheduler = false
+	adjustedOpts.Proxy = false
+
+	return &adjustedOpts
+}
+
+// restrictContainer is a private helper function that sets a restricted security context on the container.
+func restrictContainer(container *corev1.Container) {
+	allowPrivilegeEscalation := false
+	privileged := false
+	runAsNonRoot := true
+
+	if container.SecurityContext == nil {
+		container.SecurityContext = &corev1.SecurityContext{}
+	}
+
+	container.SecurityContext.AllowPrivilegeEscalation = &allowPrivilegeEscalation
+	container.SecurityContext.Privileged = &privileged
+	container.SecurityContext.RunAsNonRoot = &runAsNonRoot
+	container.SecurityContext.Capabilities = &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}}
+	container.SecurityContext.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+}
+
+// requiresRoot is a private helper function that returns true when the container explicitly runs as root.
+func requiresRoot(container *corev1.Container) bool {
+	return container.SecurityContext != nil && container.SecurityContext.RunAsUser != nil && *container.SecurityContext.RunAsUser == 0
+}
+
+// getConfig is a private function that reads the configuration file once.
+func getConfig() *Config {
+	loadOnce.Do(func() {
+		hardenedConfig = new(Config)
+		config.LoadConfig(ConfigurationFileKey, hardenedConfig)
+
+		if !hardenedConfig.Enabled {
+			provisioningConfig := new(provisioninginput.Config)
+			config.LoadConfig(provisioninginput.ConfigurationFileKey, provisioningConfig)
+
+			hardenedConfig.Enabled = provisioningConfig.Hardened
+		}
+
+		if hardenedConfig.Image == "" {
+			hardenedConfig.Image = defaultImage
+			hardenedConfig.RunAsUser = defaultRunAsUser
+		}
+	})
+
+	return hardenedConfig
+}
@@ -0,0 +1,307 @@
+package kubectl
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+)
+
+const (
+	shellPathFormat     = "/k8s/clusters/%s/v1/management.cattle.io.clusters/%s"
+	shellSubprotocol    = "base64.channel.k8s.io"
+	stdinChannel        = '0'
+	resizeChannel       = '4'
+	terminalWidth       = 4096
+	terminalHeight      = 100
+	markerSuffixLength  = 8
+	commandTimeout      = 2 * time.Minute
+	shellOpenTimeout    = 5 * time.Minute
+	shellExitCommand    = "exit\n"
+	beginMarkerFormat   = "BEGIN_%s"
+	endMarkerFormat     = "END_%s"
+	markerCommandFormat = `echo "BEGIN""_%[1]s"; %[2]s; echo "END""_%[1]s:$?"` + "\n"
+)
+
+// ansiEscapeRegex matches the terminal control sequences the shell writes, e.g. colors and bracketed paste mode
+var ansiEscapeRegex = regexp.MustCompile(`\x1b\[[0-9;?]*[a-zA-Z]`)
+
+// ShellResult is a struct of the output and the exit code of a command run in the kubectl shell.
+type ShellResult struct {
+	Output   string
+	ExitCode int
+}
+
+// Shell is a kubectl shell session of a cluster, the same the rancher UI opens. It runs in a pod rancher creates in the
+// cluster with the identity of the user of the client, so commands have that user's permissions.
+type Shell struct {
+	conn   *websocket.Conn
+	closed bool
+}
+
+// OpenShell is a helper function that opens a kubectl shell session of the cluster as the user of the client. The
+// session is closed by the session of the client, which makes rancher delete its pod.
+func OpenShell(client *rancher.Client, clusterID string) (*Shell, error) {
+	shellURL := url.URL{
+		Scheme:   "wss",
+		Host:     client.RancherConfig.Host,
+		Path:     fmt.Sprintf(shellPathFormat, clusterID, clusterID),
+		RawQuery: "link=shell",
+	}
+
+	shell, err := openShell(client, shellURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the kubectl shell of cluster %s: %w", clusterID, err)
+	}
+
+	return shell, nil
+}
+
+// OpenShellLink is a helper function that opens the kubectl shell session of the shell link of a steve object, e.g. the
+// https://<host>/v1/management.cattle.io.clusters/<cluster>?link=shell link of a cluster, as the user of the client.
+// The session is closed by the session of the client.
+func OpenShellLink(client *rancher.Client, shellLink string) (*Shell, error) {
+	shellURL, err := url.Parse(shellLink)
+	if err != nil {
+		return nil, err
+	}
+
+	shellURL.Scheme = "wss"
+	if shellURL.Query().Get("link") == "" {
+		query := shellURL.Query()
+		query.Set("link", "shell")
+		shellURL.RawQuery = query.Encode()
+	}
+
+	shell, err := openShell(client, *shellURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the kubectl shell of %s: %w", shellLink, err)
+	}
+
+	return shell, nil
+}
+
+// openShell is a private helper function that dials the websocket of the shell URL and sizes its terminal. The session
+// is closed by the session of the client.
+func openShell(client *rancher.Client, shellURL url.URL) (*Shell, error) {
+	tlsConfig, err := newTLSConfig(client)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &websocket.Dialer{
+		TLSClientConfig:  tlsConfig,
+		Subprotocols:     []string{shellSubprotocol},
+		HandshakeTimeout: timeouts.Scale(shellOpenTimeout),
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+client.Management.Opts.TokenKey)
+
+	conn, response, err := dialer.Dial(shellURL.String(), header)
+	if err != nil {
+		if response != nil {
+			return nil, fmt.Errorf("%w: %s", err, response.Status)
+		}
+
+		return nil, err
+	}
+
+	shell := &Shell{conn: conn}
+
+	client.Session.RegisterCleanupFunc(shell.Close)
+
+	// a wide terminal keeps the shell from wrapping the echo of long commands
+	resize, err := json.Marshal(map[string]int{"Width": terminalWidth, "Height": terminalHeight})
+	if err != nil {
+		return nil, err
+	}
+
+	err = shell.write(resizeChannel, resize)
+	if err != nil {
+		return nil, err
+	}
+
+	return shell, nil
+}
+
+// Run runs the command in the shell and returns its output, without the echo of the command and the prompt, and its
+// exit code. The shell state, e.g. the working directory and variables, is kept between commands.
+func (s *Shell) Run(command string) (*ShellResult, error) {
+	suffix := namegenerator.RandStringLower(markerSuffixLength)
+	beginMarker := fmt.Sprintf(beginMarkerFormat, suffix)
+	endMarker := fmt.Sprintf(endMarkerFormat, suffix)
+
+	// the markers are split with quotes in the command, so only the output of echo contains them and not its echo
+	err := s.write(stdinChannel, []byte(fmt.Sprintf(markerCommandFormat, suffix, command)))
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.conn.SetReadDeadline(time.Now().Add(timeouts.Scale(commandTimeout)))
+	if err != nil {
+		return nil, err
+	}
+
+	var output strings.Builder
+	for {
+		_, message, err := s.conn.ReadMessage()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the output of %q: %w: %s", command, err, output.String())
+		}
+
+		// the first byte is the channel, stdout and stderr are both written to the terminal
+		if len(message) < 2 {
+			continue
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(string(message[1:]))
+		if err != nil {
+			return nil, err
+		}
+
+		output.Write(decoded)
+
+		result, ok := parseOutput(output.String(), beginMarker, endMarker)
+		if ok {
+			return result, nil
+		}
+	}
+}
+
+// Close ends the shell session, if it is still open.
+func (s *Shell) Close() error {
+	if s.closed {
+		return nil
+	}
+
+	s.closed = true
+	_ = s.write(stdinChannel, []byte(shellExitCommand))
+
+	return s.conn.Close()
+}
+
+// RunShellCommand is a helper function that opens a kubectl shell of the cluster as the user of the client, runs the
+// command in it and closes it.
+func RunShellCommand(client *rancher.Client, clusterID, command string) (*ShellResult, error) {
+	shell, err := OpenShell(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	defer shell.Close()
+
+	return shell.Run(command)
+}
+
+// Kubectl is a helper function that runs kubectl with the arguments in the kubectl shell of the cluster as the user of
+// the client and returns its output, or an error with the output if kubectl fails.
+func Kubectl(client *rancher.Client, clusterID string, args ...string) (string, error) {
+	quotedArgs := []string{"kubectl"}
+	for _, arg := range args {
+		quotedArgs = append(quotedArgs, "'"+strings.ReplaceAll(arg, "'", `'\''`)+"'")
+	}
+
+	result, err := RunShellCommand(client, clusterID, strings.Join(quotedArgs, " "))
+	if err != nil {
+		return "", err
+	}
+
+	if result.ExitCode != 0 {
+		return result.Output, fmt.Errorf("kubectl %s exited with code %d: %s", strings.Join(args, " "), result.ExitCode, result.Output)
+	}
+
+	return result.Output, nil
+}
+
+// KubectlJSON is a helper function that runs kubectl with the arguments and -o json in the kubectl shell of the cluster
+// as the user of the client and unmarshals its output into the object, e.g. to compare what kubectl reports with what
+// steve reports.
+func KubectlJSON(client *rancher.Client, clusterID string, object interface{}, args ...string) error {
+	output, err := Kubectl(client, clusterID, append(args, "-o", "json")...)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(output), object)
+}
+
+// write is a private method that writes the data to the channel of the shell.
+func (s *Shell) write(channel byte, data []byte) error {
+	message := append([]byte{channel}, []byte(base64.StdEncoding.EncodeToString(data))...)
+
+	return s.conn.WriteMessage(websocket.TextMessage, message)
+}
+
+// parseOutput is a private helper function that returns the output written between the begin and end markers and the
+// exit code written after the end marker, once the end marker and the exit code were fully written.
+func parseOutput(output, beginMarker, endMarker string) (*ShellResult, bool) {
+	output = strings.ReplaceAll(ansiEscapeRegex.ReplaceAllString(output, ""), "\r", "")
+
+	beginIndex := strings.Index(output, beginMarker+"\n")
+	if beginIndex < 0 {
+		return nil, false
+	}
+
+	output = output[beginIndex+len(beginMarker)+1:]
+
+	endIndex := strings.Index(output, endMarker+":")
+	if endIndex < 0 {
+		return nil, false
+	}
+
+	exitCodeLine, _, found := strings.Cut(output[endIndex+len(endMarker)+1:], "\n")
+	if !found {
+		return nil, false
+	}
+
+	exitCode, err := strconv.Atoi(strings.TrimSpace(exitCodeLine))
+	if err != nil {
+		return nil, false
+	}
+
+	return &ShellResult{
+		Output:   strings.TrimSuffix(output[:endIndex], "\n"),
+		ExitCode: exitCode,
+	}, true
+}
+
+// newTLSConfig is a private helper function that returns the TLS config of the connection to rancher, following the
+// insecure and CA settings of the rancher config of the client.
+func newTLSConfig(client *rancher.Client) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if client.RancherConfig.Insecure != nil && *client.RancherConfig.Insecure {
+		tlsConfig.InsecureSkipVerify = true
+		return tlsConfig, nil
+	}
+
+	caCerts := []byte(client.RancherConfig.CACerts)
+	if client.RancherConfig.CAFile != "" {
+		var err error
+		caCerts, err = os.ReadFile(client.RancherConfig.CAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(caCerts) > 0 {
+		certPool := x509.NewCertPool()
+		certPool.AppendCertsFromPEM(caCerts)
+		tlsConfig.RootCAs = certPool
+	}
+
+	return tlsConfig, nil
+}
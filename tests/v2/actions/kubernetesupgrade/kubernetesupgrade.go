@@ -0,0 +1,219 @@
+package kubernetesupgrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/extensions/clusters/bundledclusters"
+	"github.com/rancher/shepherd/extensions/kubeapi/workloads/daemonsets"
+	"github.com/rancher/shepherd/extensions/kubeapi/workloads/deployments"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const deployedState = "deployed"
+
+// VerifyFunc is the function type of the verification run before and after the upgrade, e.g. the checks of a chart suite.
+type VerifyFunc func(client *rancher.Client, clusterID string) error
+
+// State is a struct of the charts and workloads of a cluster, captured before the upgrade to be compared with the state
+// after it.
+type State struct {
+	KubernetesVersion string
+	// Charts are the versions of the deployed charts, keyed by namespace/name
+	Charts map[string]string
+	// Workloads are the deployments, DaemonSets and StatefulSets of the captured namespaces, keyed by resource/namespace/name
+	Workloads map[string]bool
+}
+
+// CaptureState is a helper function that captures the kubernetes version, the deployed charts and the workloads of the
+// given namespaces of the cluster.
+func CaptureState(client *rancher.Client, clusterID string, namespaces ...string) (*State, error) {
+	cluster, err := client.Management.Cluster.ByID(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &State{
+		Charts:    map[string]string{},
+		Workloads: map[string]bool{},
+	}
+
+	if cluster.Version != nil {
+		state.KubernetesVersion = cluster.Version.GitVersion
+	}
+
+	catalogClient, err := client.GetClusterCatalogClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	appList, err := catalogClient.Apps("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, app := range appList.Items {
+		if app.Status.Summary.State != deployedState || app.Spec.Chart == nil || app.Spec.Chart.Metadata == nil {
+			continue
+		}
+
+		state.Charts[app.Namespace+"/"+app.Name] = app.Spec.Chart.Metadata.Version
+	}
+
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	workloadResources := []schema.GroupVersionResource{
+		deployments.DeploymentGroupVersionResource,
+		daemonsets.DaemonSetGroupVersionResource,
+		actionscharts.StatefulSetGroupVersionResource,
+	}
+
+	for _, namespace := range namespaces {
+		for _, workloadResource := range workloadResources {
+			workloadList, err := dynamicClient.Resource(workloadResource).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+
+			for _, workload := range workloadList.Items {
+				state.Workloads[workloadResource.Resource+"/"+namespace+"/"+workload.GetName()] = true
+			}
+		}
+	}
+
+	return state, nil
+}
+
+// CompareState is a helper function that returns an error listing the charts that are no longer deployed or changed
+// version, and the workloads that are gone after the upgrade.
+func CompareState(before, after *State) error {
+	var errs []error
+	for _, chart := range sortedKeys(before.Charts) {
+		version, ok := after.Charts[chart]
+		if !ok {
+			errs = append(errs, fmt.Errorf("chart %s is no longer deployed", chart))
+		} else if version != before.Charts[chart] {
+			errs = append(errs, fmt.Errorf("chart %s changed version from %s to %s", chart, before.Charts[chart], version))
+		}
+	}
+
+	for _, workload := range sortedKeys(before.Workloads) {
+		if !after.Workloads[workload] {
+			errs = append(errs, fmt.Errorf("workload %s is gone", workload))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// UpgradeKubernetes is a helper function that upgrades the kubernetes version of the cluster, node pools included for
+// hosted clusters, and waits for the cluster to be upgraded.
+func UpgradeKubernetes(client *rancher.Client, clusterName, version string) error {
+	clusterMeta, err := clusters.NewClusterMeta(client, clusterName)
+	if err != nil {
+		return err
+	}
+
+	initCluster, err := bundledclusters.NewWithClusterMeta(clusterMeta)
+	if err != nil {
+		return err
+	}
+
+	cluster, err := initCluster.Get(client)
+	if err != nil {
+		return err
+	}
+
+	updatedCluster, err := cluster.UpdateKubernetesVersion(client, &version)
+	if err != nil {
+		return err
+	}
+
+	err = clusters.WaitClusterToBeUpgraded(client, clusterMeta.ID)
+	if err != nil {
+		return err
+	}
+
+	if !clusterMeta.IsHosted {
+		return nil
+	}
+
+	_, err = updatedCluster.UpdateNodepoolKubernetesVersions(client, &version)
+	if err != nil {
+		return err
+	}
+
+	return clusters.WaitClusterToBeUpgraded(client, clusterMeta.ID)
+}
+
+// RunWithUpgrade is a helper function that adds a kubernetes upgrade dimension to a suite: it runs the verification,
+// captures the state of the cluster and of the given namespaces, upgrades the cluster to the version, waits for the
+// workloads of the namespaces, compares the state and runs the verification again.
+func RunWithUpgrade(client *rancher.Client, clusterName, version string, verify VerifyFunc, namespaces ...string) error {
+	clusterID, err := clusters.GetClusterIDByName(client, clusterName)
+	if err != nil {
+		return err
+	}
+
+	err = verify(client, clusterID)
+	if err != nil {
+		return fmt.Errorf("verification before the upgrade: %w", err)
+	}
+
+	before, err := CaptureState(client, clusterID, namespaces...)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Upgrading cluster %s from %s to %s", clusterName, before.KubernetesVersion, version)
+	err = UpgradeKubernetes(client, clusterName, version)
+	if err != nil {
+		return err
+	}
+
+	for _, namespace := range namespaces {
+		err = actionscharts.WatchAndWaitWorkloads(client, clusterID, namespace, metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	after, err := CaptureState(client, clusterID, namespaces...)
+	if err != nil {
+		return err
+	}
+
+	err = CompareState(before, after)
+	if err != nil {
+		return err
+	}
+
+	err = verify(client, clusterID)
+	if err != nil {
+		return fmt.Errorf("verification after the upgrade: %w", err)
+	}
+
+	return nil
+}
+
+// sortedKeys is a private helper function that returns the keys of the map in order, so errors are reported the same between runs.
+func sortedKeys[V any](m map[string]V) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
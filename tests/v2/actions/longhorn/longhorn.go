@@ -0,0 +1,333 @@
+package longhorn
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/clients/rancher/catalog"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// LonghornNamespace is the namespace the longhorn chart is installed in
+	LonghornNamespace = "longhorn-system"
+	// LonghornChartName is the name of the longhorn chart of the rancher charts repository and of its release
+	LonghornChartName = "longhorn"
+	// LonghornStorageClassName is the name of the default storage class the longhorn chart creates
+	LonghornStorageClassName = "longhorn"
+
+	longhornCRDChartName        = "longhorn-crd"
+	pvcSteveType                = "persistentvolumeclaim"
+	podSteveType                = "pod"
+	volumePrefix                = "longhorn-volume-"
+	volumeImage                 = "registry.k8s.io/pause:3.9"
+	volumeMountName             = "data"
+	volumeLabelKey              = "longhornvolume"
+	volumeAttachedState         = "attached"
+	volumeHealthy               = "healthy"
+	replicaRunningState         = "running"
+	defaultSettingsKey          = "defaultSettings"
+	deletingConfirmationFlagKey = "deletingConfirmationFlag"
+	longhornInstallTimeout      = 15 * time.Minute
+	volumeHealthyTimeout        = 10 * time.Minute
+)
+
+var (
+	// VolumeGroupVersionResource is the required Group Version Resource for accessing longhorn volumes in a cluster,
+	// using the dynamic client.
+	VolumeGroupVersionResource = schema.GroupVersionResource{
+		Group:    "longhorn.io",
+		Version:  "v1beta2",
+		Resource: "volumes",
+	}
+	// ReplicaGroupVersionResource is the required Group Version Resource for accessing longhorn replicas in a cluster,
+	// using the dynamic client.
+	ReplicaGroupVersionResource = schema.GroupVersionResource{
+		Group:    "longhorn.io",
+		Version:  "v1beta2",
+		Resource: "replicas",
+	}
+)
+
+// VolumeStatus is a struct of the health of a longhorn volume and of its replicas.
+type VolumeStatus struct {
+	Name string
+	// State is the attachment state of the volume, e.g. attached
+	State string
+	// Robustness is the health of the volume, healthy when all of its replicas are running
+	Robustness string
+	// NumberOfReplicas is the number of replicas the volume is configured with
+	NumberOfReplicas int64
+	// RunningReplicas are the names of the replicas of the volume that are running, by node
+	RunningReplicas map[string]string
+}
+
+// InstallLonghornChart is a helper function that installs the longhorn CRD chart, when the rancher charts repository
+// has one for the version, and the longhorn chart with the values, then waits for the workloads of longhorn. The
+// latest version is installed when the install options have none. The charts are uninstalled by the session of the
+// client.
+func InstallLonghornChart(client *rancher.Client, installOptions *charts.InstallOptions, values map[string]interface{}) error {
+	catalogClient, err := client.GetClusterCatalogClient(installOptions.Cluster.ID)
+	if err != nil {
+		return err
+	}
+
+	longhornInstallOptions := *installOptions
+	if longhornInstallOptions.Version == "" {
+		longhornInstallOptions.Version, err = catalogClient.GetLatestChartVersion(LonghornChartName, catalog.RancherChartRepo)
+		if err != nil {
+			return err
+		}
+	}
+
+	if hasCRDChartVersion(catalogClient, longhornInstallOptions.Version) {
+		err = actionscharts.InstallChart(client, &actionscharts.ChartInstallOptions{
+			InstallOptions: &longhornInstallOptions,
+			ChartName:      longhornCRDChartName,
+			Namespace:      LonghornNamespace,
+			Timeout:        longhornInstallTimeout,
+		}, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = actionscharts.InstallChart(client, &actionscharts.ChartInstallOptions{
+		InstallOptions: &longhornInstallOptions,
+		ChartName:      LonghornChartName,
+		Namespace:      LonghornNamespace,
+		Timeout:        longhornInstallTimeout,
+	}, withDeletingConfirmation(values))
+	if err != nil {
+		return err
+	}
+
+	return actionscharts.WatchAndWaitWorkloads(client, installOptions.Cluster.ID, LonghornNamespace, metav1.ListOptions{})
+}
+
+// UpgradeLonghornChart is a helper function that upgrades the longhorn CRD chart, when it is installed, and the longhorn
+// chart to the version of the install options, keeping their values with the values deeply merged over them, then waits
+// for the workloads of longhorn.
+func UpgradeLonghornChart(client *rancher.Client, installOptions *charts.InstallOptions, values map[string]interface{}) error {
+	clusterID := installOptions.Cluster.ID
+
+	crdChart, err := charts.GetChartStatus(client, clusterID, LonghornNamespace, longhornCRDChartName)
+	if err != nil {
+		return err
+	}
+
+	if crdChart.IsAlreadyInstalled {
+		err = actionscharts.UpgradeReleaseVersion(client, clusterID, LonghornNamespace, longhornCRDChartName, installOptions.Version, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = actionscharts.UpgradeReleaseVersion(client, clusterID, LonghornNamespace, LonghornChartName, installOptions.Version, values)
+	if err != nil {
+		return err
+	}
+
+	return actionscharts.WatchAndWaitWorkloads(client, clusterID, LonghornNamespace, metav1.ListOptions{})
+}
+
+// CreateVolume is a helper function that creates a persistent volume claim of the longhorn storage class in the
+// namespace and a pod mounting it, so the volume is attached, and returns the name of the longhorn volume once the claim
+// is bound. The claim and the pod are deleted by the session of the client.
+func CreateVolume(client *rancher.Client, clusterID, namespace, size string) (string, error) {
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return "", err
+	}
+
+	name := namegenerator.AppendRandomString(volumePrefix)
+	storageClassName := LonghornStorageClassName
+
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			StorageClassName: &storageClassName,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse(size),
+				},
+			},
+		},
+	}
+
+	pvcObject, err := steveClient.SteveType(pvcSteveType).Create(pvc)
+	if err != nil {
+		return "", err
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:         name,
+				Image:        volumeImage,
+				VolumeMounts: []corev1.VolumeMount{{Name: volumeMountName, MountPath: "/" + volumeMountName}},
+			}},
+			Volumes: []corev1.Volume{{
+				Name: volumeMountName,
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: name},
+				},
+			}},
+		},
+	}
+
+	_, err = steveClient.SteveType(podSteveType).Create(pod)
+	if err != nil {
+		return "", err
+	}
+
+	pvcSpec := &corev1.PersistentVolumeClaimSpec{}
+	pvcStatus := &corev1.PersistentVolumeClaimStatus{}
+	err = wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(volumeHealthyTimeout), func(context.Context) (done bool, err error) {
+		pvcObject, err := steveClient.SteveType(pvcSteveType).ByID(pvcObject.ID)
+		if err != nil {
+			return false, nil
+		}
+
+		err = v1.ConvertToK8sType(pvcObject.Spec, pvcSpec)
+		if err != nil {
+			return false, err
+		}
+
+		err = v1.ConvertToK8sType(pvcObject.Status, pvcStatus)
+		if err != nil {
+			return false, err
+		}
+
+		return pvcStatus.Phase == corev1.ClaimBound && pvcSpec.VolumeName != "", nil
+	}, func() string { return fmt.Sprintf("claim phase %q", pvcStatus.Phase) })
+	if err != nil {
+		return "", fmt.Errorf("claim %s of storage class %s was not bound: %w", pvcObject.ID, storageClassName, err)
+	}
+
+	logrus.Infof("Claim %s is bound to longhorn volume %s", pvcObject.ID, pvcSpec.VolumeName)
+
+	return pvcSpec.VolumeName, nil
+}
+
+// GetVolumeStatus is a helper function that returns the attachment state and the robustness of the longhorn volume and
+// the replicas of it that are running.
+func GetVolumeStatus(client *rancher.Client, clusterID, volumeName string) (*VolumeStatus, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	volume, err := dynamicClient.Resource(VolumeGroupVersionResource).Namespace(LonghornNamespace).Get(context.TODO(), volumeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	status := &VolumeStatus{
+		Name:            volumeName,
+		RunningReplicas: map[string]string{},
+	}
+
+	status.State, _, _ = unstructured.NestedString(volume.Object, "status", "state")
+	status.Robustness, _, _ = unstructured.NestedString(volume.Object, "status", "robustness")
+	status.NumberOfReplicas, _, _ = unstructured.NestedInt64(volume.Object, "spec", "numberOfReplicas")
+
+	replicas, err := dynamicClient.Resource(ReplicaGroupVersionResource).Namespace(LonghornNamespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", volumeLabelKey, volumeName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, replica := range replicas.Items {
+		state, _, _ := unstructured.NestedString(replica.Object, "status", "currentState")
+		if state != replicaRunningState {
+			continue
+		}
+
+		nodeID, _, _ := unstructured.NestedString(replica.Object, "spec", "nodeID")
+		status.RunningReplicas[nodeID] = replica.GetName()
+	}
+
+	return status, nil
+}
+
+// WaitForHealthyVolume is a helper function that waits until the longhorn volume is attached and healthy, with as many
+// running replicas, on distinct nodes, as it is configured with.
+func WaitForHealthyVolume(client *rancher.Client, clusterID, volumeName string) error {
+	var status *VolumeStatus
+	var lastErr error
+
+	return wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(volumeHealthyTimeout), func(context.Context) (done bool, err error) {
+		status, lastErr = GetVolumeStatus(client, clusterID, volumeName)
+		if lastErr != nil {
+			return false, nil
+		}
+
+		return status.State == volumeAttachedState && status.Robustness == volumeHealthy &&
+			int64(len(status.RunningReplicas)) >= status.NumberOfReplicas, nil
+	}, func() string {
+		if status == nil {
+			return fmt.Sprintf("longhorn volume %s has no status: %v", volumeName, lastErr)
+		}
+
+		return fmt.Sprintf("longhorn volume %s is %s and %s with %d of %d replicas running", volumeName, status.State, status.Robustness, len(status.RunningReplicas), status.NumberOfReplicas)
+	})
+}
+
+// hasCRDChartVersion is a private helper function that returns true when the rancher charts repository has the version
+// of the longhorn CRD chart, which older longhorn versions don't ship.
+func hasCRDChartVersion(catalogClient *catalog.Client, version string) bool {
+	versions, err := catalogClient.GetListChartVersions(longhornCRDChartName, catalog.RancherChartRepo)
+	if err != nil {
+		return false
+	}
+
+	return slices.Contains(versions, version)
+}
+
+// withDeletingConfirmation is a private helper function that returns a copy of the values with the deleting
+// confirmation flag of longhorn set, unless the values set it, as longhorn refuses to be uninstalled without it.
+func withDeletingConfirmation(values map[string]interface{}) map[string]interface{} {
+	chartValues := map[string]interface{}{}
+	for key, value := range values {
+		chartValues[key] = value
+	}
+
+	defaultSettings := map[string]interface{}{}
+	if settings, ok := chartValues[defaultSettingsKey].(map[string]interface{}); ok {
+		for key, value := range settings {
+			defaultSettings[key] = value
+		}
+	}
+
+	if _, ok := defaultSettings[deletingConfirmationFlagKey]; !ok {
+		defaultSettings[deletingConfirmationFlagKey] = true
+	}
+
+	chartValues[defaultSettingsKey] = defaultSettings
+
+	return chartValues
+}
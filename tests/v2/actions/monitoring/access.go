@@ -0,0 +1,105 @@
+package monitoring
+
+import (
+	"errors"
+	"fmt"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/shepherd/clients/rancher"
+)
+
+// Endpoint is the name of a rancher-monitoring UI proxied by the kubernetes API.
+type Endpoint string
+
+const (
+	GrafanaEndpoint      Endpoint = "grafana"
+	PrometheusEndpoint   Endpoint = "prometheus"
+	AlertmanagerEndpoint Endpoint = "alertmanager"
+
+	grafanaHealthPath       = "/api/health"
+	prometheusBuildInfoPath = "/api/v1/status/buildinfo"
+	endpointAccessAllowed   = "allowed"
+	endpointAccessDenied    = "denied"
+)
+
+// Endpoints are the rancher-monitoring UIs VerifyEndpointAccess checks
+var Endpoints = []Endpoint{GrafanaEndpoint, PrometheusEndpoint, AlertmanagerEndpoint}
+
+// endpointPaths are the paths of a read-only API of each rancher-monitoring UI
+var endpointPaths = map[Endpoint]string{
+	GrafanaEndpoint:      GrafanaServicePath + grafanaHealthPath,
+	PrometheusEndpoint:   PrometheusServicePath + prometheusBuildInfoPath,
+	AlertmanagerEndpoint: AlertmanagerServicePath + alertmanagerStatusPath,
+}
+
+// EndpointAccess is a struct of the expected access of a user to the rancher-monitoring UIs, one row of the access
+// matrix. Role describes the cluster or project role of the user in the errors, e.g. "project-member".
+type EndpointAccess struct {
+	Role    string
+	Client  *rancher.Client
+	Allowed map[Endpoint]bool
+}
+
+// AllowAll is a helper function that returns the expected access of a user allowed to reach every rancher-monitoring
+// UI, e.g. a cluster owner.
+func AllowAll() map[Endpoint]bool {
+	return expectAll(true)
+}
+
+// DenyAll is a helper function that returns the expected access of a user denied every rancher-monitoring UI, e.g. a
+// project member.
+func DenyAll() map[Endpoint]bool {
+	return expectAll(false)
+}
+
+// VerifyEndpointAccess is a helper function that checks every user of the access matrix reaches the rancher-monitoring
+// UIs of the cluster it is allowed to and is denied the others, endpoints missing from Allowed being expected denied.
+// It returns every mismatch of the matrix, not only the first one.
+func VerifyEndpointAccess(clusterID string, accessMatrix []EndpointAccess) error {
+	var errs []error
+	for _, access := range accessMatrix {
+		for _, endpoint := range Endpoints {
+			allowed, err := canAccessEndpoint(access.Client, clusterID, endpoint)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s accessing %s: %w", access.Role, endpoint, err))
+				continue
+			}
+
+			if allowed != access.Allowed[endpoint] {
+				errs = append(errs, fmt.Errorf("%s is %s %s, expected %s", access.Role, accessString(allowed), endpoint, accessString(access.Allowed[endpoint])))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// canAccessEndpoint is a private helper function that returns true when the client gets a healthy response from the
+// rancher-monitoring UI of the cluster through the rancher proxy.
+func canAccessEndpoint(client *rancher.Client, clusterID string, endpoint Endpoint) (bool, error) {
+	result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, ClusterProxyPath(clusterID, endpointPaths[endpoint]), true)
+	if err != nil {
+		return false, err
+	}
+
+	return result.Ok, nil
+}
+
+// expectAll is a private helper function that returns the same expected access to every rancher-monitoring UI.
+func expectAll(allowed bool) map[Endpoint]bool {
+	expected := map[Endpoint]bool{}
+	for _, endpoint := range Endpoints {
+		expected[endpoint] = allowed
+	}
+
+	return expected
+}
+
+// accessString is a private helper function that returns the word of the access in the errors.
+func accessString(allowed bool) string {
+	if allowed {
+		return endpointAccessAllowed
+	}
+
+	return endpointAccessDenied
+}
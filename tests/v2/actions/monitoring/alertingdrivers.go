@@ -0,0 +1,40 @@
+package monitoring
+
+import (
+	"github.com/rancher/shepherd/clients/rancher"
+)
+
+// NewAlertingDriverReceiver is a constructor that returns the webhook receiver posting the alerts, resolved ones
+// included, to a driver of the rancher-alerting-drivers chart, e.g. to charts.Prom2TeamsWebhookURL or
+// charts.SachetWebhookURL of the actions charts package. The sachet driver sends the alerts to its receiver of the same
+// name as the alertmanager receiver.
+func NewAlertingDriverReceiver(receiverName, url string) *Receiver {
+	sendResolved := true
+
+	return &Receiver{
+		Name: receiverName,
+		WebhookConfigs: []*WebhookConfig{
+			{
+				VSendResolved: &sendResolved,
+				URL:           url,
+			},
+		},
+	}
+}
+
+// AddAlertingDriverReceiver is a helper function that adds the receiver of NewAlertingDriverReceiver to the
+// rancher-monitoring alertmanager of the cluster, with the route, e.g. built with NewClusterRouteBuilder to the receiver,
+// and waits until alertmanager reloaded it.
+func AddAlertingDriverReceiver(client *rancher.Client, clusterID, receiverName, url string, route *Route) error {
+	return UpdateAlertmanagerConfig(client, clusterID, func(alertmanagerConfig *AlertmanagerConfig) *AlertmanagerConfig {
+		alertmanagerConfig.Receivers = append(alertmanagerConfig.Receivers, NewAlertingDriverReceiver(receiverName, url))
+
+		if alertmanagerConfig.Route == nil {
+			alertmanagerConfig.Route = &Route{}
+		}
+
+		alertmanagerConfig.Route.Routes = append(alertmanagerConfig.Route.Routes, route)
+
+		return alertmanagerConfig
+	})
+}
@@ -0,0 +1,277 @@
+package monitoring
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/charts"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// AlertmanagerServicePath is the path of the rancher-monitoring alertmanager service proxied by the kubernetes API
+	AlertmanagerServicePath = "api/v1/namespaces/cattle-monitoring-system/services/http:rancher-monitoring-alertmanager:9093/proxy"
+	// AlertmanagerConfigKey is the key of the alertmanager configuration in the rancher-monitoring alertmanager secret
+	AlertmanagerConfigKey = "alertmanager.yaml"
+
+	alertmanagerStatusPath  = "/api/v2/status"
+	alertmanagerMetricsPath = "/metrics"
+
+	configHashMetric          = "alertmanager_config_hash"
+	reloadSuccessfulMetric    = "alertmanager_config_last_reload_successful"
+	reloadSuccessTimeMetric   = "alertmanager_config_last_reload_success_timestamp_seconds"
+	alertmanagerReloadTimeout = 5 * time.Minute
+)
+
+var secretGroupVersionResource = schema.GroupVersionResource{
+	Group:    "",
+	Version:  "v1",
+	Resource: "secrets",
+}
+
+// alertmanagerStatus is a private struct of the parts of the alertmanager status API holding the loaded configuration
+// and the version.
+type alertmanagerStatus struct {
+	Config struct {
+		Original string `json:"original"`
+	} `json:"config"`
+	VersionInfo struct {
+		Version string `json:"version"`
+	} `json:"versionInfo"`
+}
+
+// AlertmanagerReloadStatus is a struct of the state of the last configuration reload of alertmanager, from its metrics.
+type AlertmanagerReloadStatus struct {
+	// ConfigHash is the hash of the loaded configuration
+	ConfigHash string
+	// LastReloadSuccessful is false when alertmanager rejected the last configuration it tried to load
+	LastReloadSuccessful bool
+	// LastReloadSuccessTime is when a configuration was last loaded successfully
+	LastReloadSuccessTime time.Time
+}
+
+// UpdateAlertmanagerConfig is a helper function that decodes the configuration of the rancher-monitoring alertmanager
+// secret of the cluster, updates it with the configuration returned by mutate and waits until alertmanager reloaded it.
+// The secret is read again and mutated again on conflicts, so mutate must only depend on the configuration it is given.
+// When the configuration changed, the hash of the loaded configuration is waited for to change first, so alert delivery
+// assertions that follow don't race the reload.
+func UpdateAlertmanagerConfig(client *rancher.Client, clusterID string, mutate func(alertmanagerConfig *AlertmanagerConfig) *AlertmanagerConfig) error {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	previousReload, err := GetAlertmanagerReloadStatus(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	secretResource := dynamicClient.Resource(secretGroupVersionResource).Namespace(charts.RancherMonitoringNamespace)
+
+	var updatedConfig *AlertmanagerConfig
+	var configChanged bool
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		unstructuredSecret, err := secretResource.Get(context.TODO(), charts.RancherMonitoringAlertSecret, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		secret := &corev1.Secret{}
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredSecret.Object, secret)
+		if err != nil {
+			return err
+		}
+
+		alertmanagerConfig := &AlertmanagerConfig{}
+		err = yaml.Unmarshal(secret.Data[AlertmanagerConfigKey], alertmanagerConfig)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal alertmanager config: %w", err)
+		}
+
+		// mutate may update the configuration in place, marshal it before to tell whether it changed
+		originalBytes, err := yaml.Marshal(alertmanagerConfig)
+		if err != nil {
+			return err
+		}
+
+		updatedConfig = mutate(alertmanagerConfig)
+
+		configBytes, err := yaml.Marshal(updatedConfig)
+		if err != nil {
+			return err
+		}
+
+		configChanged = !bytes.Equal(originalBytes, configBytes)
+
+		secret.Data[AlertmanagerConfigKey] = configBytes
+
+		secretObject, err := runtime.DefaultUnstructuredConverter.ToUnstructured(secret)
+		if err != nil {
+			return err
+		}
+
+		_, err = secretResource.Update(context.TODO(), &unstructured.Unstructured{Object: secretObject}, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if configChanged {
+		err = WaitForAlertmanagerReload(client, clusterID, previousReload)
+		if err != nil {
+			return err
+		}
+	}
+
+	return WaitForAlertmanagerConfig(client, clusterID, updatedConfig)
+}
+
+// GetAlertmanagerReloadStatus is a helper function that returns the state of the last configuration reload of the
+// rancher-monitoring alertmanager of the cluster, read from its metrics through the rancher proxy.
+func GetAlertmanagerReloadStatus(client *rancher.Client, clusterID string) (*AlertmanagerReloadStatus, error) {
+	path := ClusterProxyPath(clusterID, AlertmanagerServicePath) + alertmanagerMetricsPath
+
+	result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if !result.Ok {
+		return nil, fmt.Errorf("metrics of the alertmanager of cluster %s are not available: %s", clusterID, result.Body)
+	}
+
+	reloadStatus := &AlertmanagerReloadStatus{}
+	scanner := bufio.NewScanner(strings.NewReader(result.Body))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case configHashMetric:
+			reloadStatus.ConfigHash = fields[1]
+		case reloadSuccessfulMetric:
+			reloadStatus.LastReloadSuccessful = fields[1] == "1"
+		case reloadSuccessTimeMetric:
+			seconds, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse %s: %w", reloadSuccessTimeMetric, err)
+			}
+
+			whole, fraction := math.Modf(seconds)
+			reloadStatus.LastReloadSuccessTime = time.Unix(int64(whole), int64(fraction*float64(time.Second)))
+		}
+	}
+
+	if reloadStatus.ConfigHash == "" {
+		return nil, fmt.Errorf("alertmanager of cluster %s doesn't report %s", clusterID, configHashMetric)
+	}
+
+	return reloadStatus, nil
+}
+
+// WaitForAlertmanagerReload is a helper function that waits until the rancher-monitoring alertmanager of the cluster
+// loaded a configuration with another hash than the one of the previous reload status, taken before the alertmanager
+// secret was updated. It fails as soon as alertmanager reports it rejected the new configuration.
+func WaitForAlertmanagerReload(client *rancher.Client, clusterID string, previousReload *AlertmanagerReloadStatus) error {
+	var reloadStatus *AlertmanagerReloadStatus
+	var lastErr error
+
+	err := wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(alertmanagerReloadTimeout), func(context.Context) (done bool, err error) {
+		reloadStatus, lastErr = GetAlertmanagerReloadStatus(client, clusterID)
+		if lastErr != nil {
+			return false, nil
+		}
+
+		if previousReload.LastReloadSuccessful && !reloadStatus.LastReloadSuccessful {
+			return false, fmt.Errorf("alertmanager of cluster %s rejected the updated config, check the logs of its pods", clusterID)
+		}
+
+		return reloadStatus.ConfigHash != previousReload.ConfigHash, nil
+	}, func() string {
+		if lastErr != nil {
+			return lastErr.Error()
+		}
+
+		return fmt.Sprintf("config hash is still %s", previousReload.ConfigHash)
+	})
+	if err != nil {
+		return fmt.Errorf("alertmanager of cluster %s did not reload the updated config: %w", clusterID, err)
+	}
+
+	return nil
+}
+
+// WaitForAlertmanagerConfig is a helper function that waits until the configuration loaded by the rancher-monitoring
+// alertmanager of the cluster has every receiver and route of the expected configuration.
+func WaitForAlertmanagerConfig(client *rancher.Client, clusterID string, expectedConfig *AlertmanagerConfig) error {
+	path := ClusterProxyPath(clusterID, AlertmanagerServicePath) + alertmanagerStatusPath
+
+	err := kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Timeout(), true, func(context.Context) (done bool, err error) {
+		result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, path, true)
+		if err != nil || !result.Ok {
+			return false, nil
+		}
+
+		status := &alertmanagerStatus{}
+		err = json.Unmarshal([]byte(result.Body), status)
+		if err != nil {
+			return false, err
+		}
+
+		loadedConfig := &AlertmanagerConfig{}
+		err = yaml.Unmarshal([]byte(status.Config.Original), loadedConfig)
+		if err != nil {
+			return false, err
+		}
+
+		return hasAlertmanagerConfig(loadedConfig, expectedConfig), nil
+	})
+	if err != nil {
+		return fmt.Errorf("alertmanager of cluster %s did not reload the updated config: %w", clusterID, err)
+	}
+
+	return nil
+}
+
+// hasAlertmanagerConfig is a private helper function that checks the loaded configuration has the receivers and as many
+// routes as the expected configuration.
+func hasAlertmanagerConfig(loadedConfig, expectedConfig *AlertmanagerConfig) bool {
+	var loadedReceivers []string
+	for _, receiver := range loadedConfig.Receivers {
+		loadedReceivers = append(loadedReceivers, receiver.Name)
+	}
+
+	for _, receiver := range expectedConfig.Receivers {
+		if !slices.Contains(loadedReceivers, receiver.Name) {
+			return false
+		}
+	}
+
+	if expectedConfig.Route == nil {
+		return true
+	}
+
+	return loadedConfig.Route != nil && len(loadedConfig.Route.Routes) == len(expectedConfig.Route.Routes)
+}
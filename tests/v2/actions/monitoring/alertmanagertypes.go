@@ -1,6 +1,7 @@
-package resources
+package monitoring
 
 import (
+	"net"
 	"net/url"
 	"time"
 
@@ -18,6 +19,28 @@ type HostPort struct {
 	Port string
 }
 
+// MarshalYAML implements the yaml.Marshaler interface, alertmanager expects a "host:port" string.
+func (hp HostPort) MarshalYAML() (interface{}, error) {
+	if hp.Host == "" && hp.Port == "" {
+		return "", nil
+	}
+
+	return net.JoinHostPort(hp.Host, hp.Port), nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (hp *HostPort) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var hostPort string
+	err := unmarshal(&hostPort)
+	if err != nil || hostPort == "" {
+		return err
+	}
+
+	hp.Host, hp.Port, err = net.SplitHostPort(hostPort)
+
+	return err
+}
+
 // Customization of Config type from alertmanager repo:
 // https://github.com/prometheus/alertmanager/blob/main/config/config.go
 //
@@ -27,7 +50,7 @@ type HostPort struct {
 type AlertmanagerConfig struct {
 	Global            *GlobalConfig   `yaml:"global,omitempty" json:"global,omitempty"`
 	Route             *Route          `yaml:"route,omitempty" json:"route,omitempty"`
-	InhibitRules      []*inhibitRule  `yaml:"inhibit_rules,omitempty" json:"inhibit_rules,omitempty"`
+	InhibitRules      []*InhibitRule  `yaml:"inhibit_rules,omitempty" json:"inhibit_rules,omitempty"`
 	Receivers         []*Receiver     `yaml:"receivers,omitempty" json:"receivers,omitempty"`
 	MuteTimeIntervals []*timeInterval `yaml:"mute_time_intervals,omitempty" json:"mute_time_intervals,omitempty"`
 	TimeIntervals     []*timeInterval `yaml:"time_intervals,omitempty" json:"time_intervals,omitempty"`
@@ -83,7 +106,9 @@ type Route struct {
 	ActiveTimeIntervals []string          `yaml:"active_time_intervals,omitempty" json:"active_time_intervals,omitempty"`
 }
 
-type inhibitRule struct {
+// InhibitRule is a rule muting the alerts matching the target matchers while an alert matching the source matchers
+// fires with the same values of the equal labels.
+type InhibitRule struct {
 	TargetMatch    map[string]string `yaml:"target_match,omitempty" json:"target_match,omitempty"`
 	TargetMatchRE  map[string]string `yaml:"target_match_re,omitempty" json:"target_match_re,omitempty"`
 	TargetMatchers []string          `yaml:"target_matchers,omitempty" json:"target_matchers,omitempty"`
@@ -203,6 +228,8 @@ type HTTPClientConfig struct {
 	BearerToken     string         `yaml:"bearer_token,omitempty"`
 	BearerTokenFile string         `yaml:"bearer_token_file,omitempty"`
 	ProxyURL        string         `yaml:"proxy_url,omitempty"`
+	NoProxy         string         `yaml:"no_proxy,omitempty"`
+	ProxyFromEnv    bool           `yaml:"proxy_from_environment,omitempty"`
 	TLSConfig       *tlsConfig     `yaml:"tls_config,omitempty"`
 	FollowRedirects *bool          `yaml:"follow_redirects,omitempty"`
 	EnableHTTP2     *bool          `yaml:"enable_http2,omitempty"`
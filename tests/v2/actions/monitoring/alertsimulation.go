@@ -0,0 +1,154 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rancher/rancher/tests/v2/actions/downstream"
+	"github.com/rancher/rancher/tests/v2/actions/fixtures"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// KubePodCrashLoopingAlert is the built-in alert of rancher-monitoring firing for a container restarting in a loop
+	KubePodCrashLoopingAlert = "KubePodCrashLooping"
+	// CPUThrottlingHighAlert is the built-in alert of rancher-monitoring firing for a container throttled most of the time
+	CPUThrottlingHighAlert = "CPUThrottlingHigh"
+
+	alertNameLabel      = "alertname"
+	alertNamespaceLabel = "namespace"
+	alertContainerLabel = "container"
+	activeAlertState    = "active"
+)
+
+// alertFiringBounds are the times the built-in alerts take to fire once their workload is deployed: the for duration
+// of their rule, 15m for both, with the time their expression takes to match and a margin for the evaluation interval.
+var alertFiringBounds = map[string]time.Duration{
+	KubePodCrashLoopingAlert: 20 * time.Minute,
+	CPUThrottlingHighAlert:   25 * time.Minute,
+}
+
+// AlertSimulation is a struct of a workload deployed to fire a built-in alert.
+type AlertSimulation struct {
+	AlertName string
+	ClusterID string
+	App       *fixtures.App
+	// Labels are the labels of the alert fired for the workload
+	Labels map[string]string
+	// Bound is the time the alert fires within
+	Bound time.Duration
+}
+
+// SimulateAlert is a helper function that deploys in the namespace of the cluster the workload firing the built-in
+// alert, one of KubePodCrashLoopingAlert and CPUThrottlingHighAlert, so alert pipelines can be tested with the rules
+// rancher-monitoring ships rather than with always firing ones. The workload is removed by Stop or else by the session
+// of the client.
+func SimulateAlert(client *rancher.Client, clusterID, namespace, alertName string) (*AlertSimulation, error) {
+	bound, ok := alertFiringBounds[alertName]
+	if !ok {
+		return nil, fmt.Errorf("alert %s can't be simulated", alertName)
+	}
+
+	var app *fixtures.App
+	var err error
+	switch alertName {
+	case KubePodCrashLoopingAlert:
+		app, err = fixtures.DeployCrashingApp(client, clusterID, namespace, &fixtures.CrashingAppOpts{})
+	case CPUThrottlingHighAlert:
+		app, err = fixtures.DeployCPUBurner(client, clusterID, namespace, &fixtures.CPUBurnerOpts{})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Deployed %s/%s to fire %s within %s", namespace, app.Name, alertName, timeouts.Scale(bound))
+
+	return &AlertSimulation{
+		AlertName: alertName,
+		ClusterID: clusterID,
+		App:       app,
+		Labels: map[string]string{
+			alertNameLabel:      alertName,
+			alertNamespaceLabel: namespace,
+			alertContainerLabel: app.Name,
+		},
+		Bound: timeouts.Scale(bound),
+	}, nil
+}
+
+// WaitForSimulatedAlert is a helper function that waits within the bound of the simulation until the
+// rancher-monitoring alertmanager of the cluster has the alert of the workload active, and returns it.
+func WaitForSimulatedAlert(client *rancher.Client, simulation *AlertSimulation) (*AlertmanagerAlert, error) {
+	var firing *AlertmanagerAlert
+	var matching []AlertmanagerAlert
+
+	err := wait.For(context.TODO(), timeouts.PollInterval(), simulation.Bound, func(context.Context) (done bool, err error) {
+		alerts, err := GetAlertmanagerAlerts(client, simulation.ClusterID)
+		if err != nil {
+			return false, nil
+		}
+
+		matching = nil
+		for i, alert := range alerts {
+			if !hasLabels(alert.Labels, simulation.Labels) {
+				continue
+			}
+
+			matching = append(matching, alert)
+			if alert.Status.State == activeAlertState {
+				firing = &alerts[i]
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}, func() string {
+		if len(matching) == 0 {
+			return fmt.Sprintf("no alert with labels %v", simulation.Labels)
+		}
+
+		return fmt.Sprintf("alert with labels %v is %s", simulation.Labels, matching[0].Status.State)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return firing, nil
+}
+
+// Stop removes the workload of the simulation, so its alert resolves.
+func (s *AlertSimulation) Stop(client *rancher.Client) error {
+	clientset, err := downstream.GetClientset(client, s.ClusterID)
+	if err != nil {
+		return err
+	}
+
+	err = clientset.AppsV1().Deployments(s.App.Namespace).Delete(context.TODO(), s.App.Name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+
+	return err
+}
+
+// SimulateAlertAndWait is a helper function that simulates the built-in alert, waits until it fires and removes the
+// workload firing it, and returns the alert.
+func SimulateAlertAndWait(client *rancher.Client, clusterID, namespace, alertName string) (*AlertmanagerAlert, error) {
+	simulation, err := SimulateAlert(client, clusterID, namespace, alertName)
+	if err != nil {
+		return nil, err
+	}
+
+	alert, err := WaitForSimulatedAlert(client, simulation)
+	if err != nil {
+		return nil, err
+	}
+
+	return alert, simulation.Stop(client)
+}
@@ -0,0 +1,90 @@
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+// sampleCheckFunc is a private function type of the checks of the samples returned by a query.
+type sampleCheckFunc func(samples []Sample) error
+
+// AssertMetricExists is a helper function that runs the query against the rancher-monitoring prometheus of the cluster
+// until it returns at least one sample, e.g. AssertMetricExists(client, clusterID, `up{job="kube-etcd"}`, time.Minute).
+func AssertMetricExists(client *rancher.Client, clusterID, query string, within time.Duration) error {
+	return assertQuery(client, clusterID, query, within, func(samples []Sample) error {
+		if len(samples) == 0 {
+			return fmt.Errorf("query %q returned no samples", query)
+		}
+
+		return nil
+	})
+}
+
+// AssertValueAbove is a helper function that runs the query until every sample it returns is above the threshold, and
+// it returns at least one.
+func AssertValueAbove(client *rancher.Client, clusterID, query string, threshold float64, within time.Duration) error {
+	return assertQuery(client, clusterID, query, within, compareSamples(query, threshold, "above", func(value float64) bool {
+		return value > threshold
+	}))
+}
+
+// AssertValueBelow is a helper function that runs the query until every sample it returns is below the threshold, and
+// it returns at least one.
+func AssertValueBelow(client *rancher.Client, clusterID, query string, threshold float64, within time.Duration) error {
+	return assertQuery(client, clusterID, query, within, compareSamples(query, threshold, "below", func(value float64) bool {
+		return value < threshold
+	}))
+}
+
+// AssertValueEquals is a helper function that runs the query until every sample it returns equals the value, and it
+// returns at least one.
+func AssertValueEquals(client *rancher.Client, clusterID, query string, value float64, within time.Duration) error {
+	return assertQuery(client, clusterID, query, within, compareSamples(query, value, "equal to", func(sampleValue float64) bool {
+		return sampleValue == value
+	}))
+}
+
+// assertQuery is a private helper function that runs the query until the check of its samples passes, and returns the
+// error of the last check if it doesn't pass within the duration, scaled by the timeouts configuration.
+func assertQuery(client *rancher.Client, clusterID, query string, within time.Duration, check sampleCheckFunc) error {
+	var lastErr error
+	err := kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Scale(within), true, func(context.Context) (done bool, err error) {
+		samples, err := QueryPrometheus(client, clusterID, query)
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+
+		lastErr = check(samples)
+		return lastErr == nil, nil
+	})
+	if err != nil && lastErr != nil {
+		return errors.Join(lastErr, err)
+	}
+
+	return err
+}
+
+// compareSamples is a private constructor that returns the check that the query returned samples and every one of
+// them satisfies the comparison to the reference value.
+func compareSamples(query string, reference float64, comparison string, satisfies func(value float64) bool) sampleCheckFunc {
+	return func(samples []Sample) error {
+		if len(samples) == 0 {
+			return fmt.Errorf("query %q returned no samples", query)
+		}
+
+		for _, sample := range samples {
+			if !satisfies(sample.Value) {
+				return fmt.Errorf("query %q returned %v for %v, expected a value %s %v", query, sample.Value, sample.Metric, comparison, reference)
+			}
+		}
+
+		return nil
+	}
+}
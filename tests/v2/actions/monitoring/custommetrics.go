@@ -0,0 +1,293 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	actionscatalog "github.com/rancher/rancher/tests/v2/actions/catalog"
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/downstream"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/clients/rancher/catalog"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/pkg/api/steve/catalog/types"
+	"github.com/sirupsen/logrus"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// PrometheusAdapterName is the name of the prometheus-adapter chart and release
+	PrometheusAdapterName = "prometheus-adapter"
+	// LoadCustomMetric is the custom metric the default rules of prometheus-adapter derive from the http_requests_total
+	// counter of the pods of the monitoring load
+	LoadCustomMetric = "http_requests"
+
+	prometheusAdapterRepoName      = "prometheus-community"
+	prometheusAdapterRepoURL       = "https://prometheus-community.github.io/helm-charts"
+	prometheusAdapterLabelSelector = "app.kubernetes.io/name=prometheus-adapter"
+	prometheusServiceURL           = "http://rancher-monitoring-prometheus.cattle-monitoring-system.svc"
+	prometheusServicePort          = 9090
+	customMetricsAPIPath           = "apis/custom.metrics.k8s.io/v1beta1"
+	prometheusAdapterTimeout       = 10 * time.Minute
+	customMetricTimeout            = 5 * time.Minute
+)
+
+// CustomMetricValue is a struct of the value of a custom metric of an object, as the custom metrics API reports it.
+type CustomMetricValue struct {
+	Kind  string
+	Name  string
+	Value resource.Quantity
+}
+
+// customMetricsListResponse is a private struct of the metric value list of the custom metrics API.
+type customMetricsListResponse struct {
+	Items []struct {
+		DescribedObject struct {
+			Kind string `json:"kind"`
+			Name string `json:"name"`
+		} `json:"describedObject"`
+		Value resource.Quantity `json:"value"`
+	} `json:"items"`
+}
+
+// InstallPrometheusAdapter is a helper function that installs the upstream prometheus-adapter chart next to the
+// rancher-monitoring prometheus of the cluster, with its default rules, and waits until the custom metrics API is served.
+// When the custom metrics API is served already, e.g. by the prometheus-adapter bundled with rancher-monitoring, that
+// adapter is used and nothing is installed. The chart and its repository are removed by the session of the client.
+func InstallPrometheusAdapter(client *rancher.Client, clusterID string) error {
+	served, err := isCustomMetricsAPIServed(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	if served {
+		logrus.Infof("The custom metrics API of cluster %s is served already, not installing %s", clusterID, PrometheusAdapterName)
+		return nil
+	}
+
+	catalogClient, err := client.GetClusterCatalogClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	_, err = actionscatalog.CreateClusterRepo(client, clusterID, &actionscatalog.ClusterRepoOpts{
+		Name: prometheusAdapterRepoName,
+		URL:  prometheusAdapterRepoURL,
+	})
+	if err != nil {
+		return err
+	}
+
+	version, err := catalogClient.GetLatestChartVersion(PrometheusAdapterName, prometheusAdapterRepoName)
+	if err != nil {
+		return err
+	}
+
+	err = catalogClient.InstallChart(&types.ChartInstallAction{
+		Timeout:   &metav1.Duration{Duration: timeouts.Scale(prometheusAdapterTimeout)},
+		Wait:      true,
+		Namespace: charts.RancherMonitoringNamespace,
+		Charts: []types.ChartInstall{{
+			ChartName:   PrometheusAdapterName,
+			ReleaseName: PrometheusAdapterName,
+			Version:     version,
+			Values: map[string]interface{}{
+				"prometheus": map[string]interface{}{
+					"url":  prometheusServiceURL,
+					"port": prometheusServicePort,
+				},
+			},
+		}},
+	}, prometheusAdapterRepoName)
+	if err != nil {
+		return err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		return actionscharts.UninstallChart(client, clusterID, charts.RancherMonitoringNamespace, PrometheusAdapterName)
+	})
+
+	logrus.Infof("Installed %s %s, waiting for the custom metrics API", PrometheusAdapterName, version)
+
+	err = actionscharts.WatchAndWaitDeployments(client, clusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{LabelSelector: prometheusAdapterLabelSelector})
+	if err != nil {
+		return err
+	}
+
+	return wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(customMetricTimeout), func(context.Context) (done bool, err error) {
+		return isCustomMetricsAPIServed(client, clusterID)
+	}, func() string {
+		return "the custom metrics API is not served"
+	})
+}
+
+// GetPodCustomMetric is a helper function that returns the values of the custom metric of every pod of the namespace
+// of the cluster from the custom metrics API, through the rancher proxy, e.g. LoadCustomMetric.
+func GetPodCustomMetric(client *rancher.Client, clusterID, namespace, metricName string) ([]CustomMetricValue, error) {
+	path := fmt.Sprintf("%s/namespaces/%s/pods/*/%s", customMetricsAPIPath, namespace, metricName)
+
+	result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, ClusterProxyPath(clusterID, path), true)
+	if err != nil {
+		return nil, err
+	}
+
+	if !result.Ok {
+		return nil, fmt.Errorf("custom metric %s of the pods of namespace %s is not available: %s", metricName, namespace, result.Body)
+	}
+
+	response := &customMetricsListResponse{}
+	err = json.Unmarshal([]byte(result.Body), response)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the response of the custom metrics API: %w", err)
+	}
+
+	var values []CustomMetricValue
+	for _, item := range response.Items {
+		values = append(values, CustomMetricValue{
+			Kind:  item.DescribedObject.Kind,
+			Name:  item.DescribedObject.Name,
+			Value: item.Value,
+		})
+	}
+
+	return values, nil
+}
+
+// WaitForPodCustomMetric is a helper function that waits until the custom metrics API reports the custom metric for at
+// least one pod of the namespace of the cluster, which takes a few scrapes of prometheus for a new pod.
+func WaitForPodCustomMetric(client *rancher.Client, clusterID, namespace, metricName string) ([]CustomMetricValue, error) {
+	var values []CustomMetricValue
+	var lastErr error
+
+	err := wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(customMetricTimeout), func(context.Context) (done bool, err error) {
+		values, lastErr = GetPodCustomMetric(client, clusterID, namespace, metricName)
+		if lastErr != nil {
+			return false, nil
+		}
+
+		return len(values) > 0, nil
+	}, func() string {
+		if lastErr != nil {
+			return lastErr.Error()
+		}
+
+		return fmt.Sprintf("no pod of namespace %s has custom metric %s", namespace, metricName)
+	})
+
+	return values, err
+}
+
+// CreateCustomMetricHPA is a helper function that creates a horizontal pod autoscaler of the deployment scaling on the
+// average value of the custom metric of its pods, e.g. the LoadCustomMetric of the deployment of a load. The autoscaler
+// is deleted by the session of the client.
+func CreateCustomMetricHPA(client *rancher.Client, clusterID, namespace, deploymentName, metricName string, averageValue resource.Quantity, maxReplicas int32) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	clientset, err := downstream.GetClientset(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	autoscalers := clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace)
+
+	autoscaler, err := autoscalers.Create(context.TODO(), &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: deploymentName, Namespace: namespace},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       deploymentName,
+			},
+			MaxReplicas: maxReplicas,
+			Metrics: []autoscalingv2.MetricSpec{{
+				Type: autoscalingv2.PodsMetricSourceType,
+				Pods: &autoscalingv2.PodsMetricSource{
+					Metric: autoscalingv2.MetricIdentifier{Name: metricName},
+					Target: autoscalingv2.MetricTarget{
+						Type:         autoscalingv2.AverageValueMetricType,
+						AverageValue: &averageValue,
+					},
+				},
+			}},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		err := autoscalers.Delete(context.TODO(), autoscaler.Name, metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		return err
+	})
+
+	return autoscaler, nil
+}
+
+// VerifyCustomMetricHPA is a helper function that waits until the horizontal pod autoscaler reads the current value of
+// its custom metric and is able to scale on it, which validates the whole pipeline from the prometheus scrape through
+// prometheus-adapter to the autoscaler.
+func VerifyCustomMetricHPA(client *rancher.Client, clusterID, namespace, name string) error {
+	clientset, err := downstream.GetClientset(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	var autoscaler *autoscalingv2.HorizontalPodAutoscaler
+	var lastErr error
+
+	return wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(customMetricTimeout), func(ctx context.Context) (done bool, err error) {
+		autoscaler, lastErr = clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).Get(ctx, name, metav1.GetOptions{})
+		if lastErr != nil {
+			return false, nil
+		}
+
+		if len(autoscaler.Status.CurrentMetrics) == 0 {
+			return false, nil
+		}
+
+		for _, condition := range autoscaler.Status.Conditions {
+			if condition.Type == autoscalingv2.ScalingActive {
+				return condition.Status == corev1.ConditionTrue, nil
+			}
+		}
+
+		return false, nil
+	}, func() string {
+		if lastErr != nil {
+			return lastErr.Error()
+		}
+
+		if autoscaler == nil {
+			return "no autoscaler"
+		}
+
+		for _, condition := range autoscaler.Status.Conditions {
+			if condition.Type == autoscalingv2.ScalingActive {
+				return fmt.Sprintf("autoscaler %s/%s is not scaling: %s: %s", namespace, name, condition.Reason, condition.Message)
+			}
+		}
+
+		return fmt.Sprintf("autoscaler %s/%s has no current metrics", namespace, name)
+	})
+}
+
+// isCustomMetricsAPIServed is a private helper function that checks if the custom metrics API of the cluster answers
+// through the rancher proxy.
+func isCustomMetricsAPIServed(client *rancher.Client, clusterID string) (bool, error) {
+	result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, ClusterProxyPath(clusterID, customMetricsAPIPath), true)
+	if err != nil {
+		return false, err
+	}
+
+	return result.Ok, nil
+}
@@ -0,0 +1,176 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rancher/rancher/tests/v2/actions/downstream"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// UsageSnapshot is a struct of the resource usage of a cluster at a point in time.
+type UsageSnapshot struct {
+	// Time is when the snapshot was taken
+	Time time.Time
+	// Nodes is the usage of every node, by node name
+	Nodes map[string]ResourceUsage
+	// Total is the usage of all the nodes
+	Total ResourceUsage
+	// Pods is the number of pods that are not terminated, by namespace
+	Pods map[string]int
+}
+
+// PodCount returns the number of pods of the snapshot that are not terminated, in all namespaces.
+func (s *UsageSnapshot) PodCount() int {
+	count := 0
+	for _, pods := range s.Pods {
+		count += pods
+	}
+
+	return count
+}
+
+// FootprintReport is a struct of the difference of the resource usage of a cluster before and after a chart was
+// installed.
+type FootprintReport struct {
+	ChartName string
+	Version   string
+	Before    *UsageSnapshot
+	After     *UsageSnapshot
+	// CPU is the difference of the CPU usage of all the nodes, negative when the usage went down
+	CPU resource.Quantity
+	// Memory is the difference of the memory usage of all the nodes, negative when the usage went down
+	Memory resource.Quantity
+	// Pods is the difference of the number of pods, by namespace, for the namespaces whose number changed
+	Pods map[string]int
+}
+
+// String returns the report as the lines of the footprint of the chart and of its pods by namespace.
+func (r *FootprintReport) String() string {
+	lines := []string{fmt.Sprintf("Footprint of %s %s: CPU %s (%s -> %s), memory %s (%s -> %s), pods %+d (%d -> %d)",
+		r.ChartName, r.Version,
+		r.CPU.String(), r.Before.Total.CPU.String(), r.After.Total.CPU.String(),
+		r.Memory.String(), r.Before.Total.Memory.String(), r.After.Total.Memory.String(),
+		r.After.PodCount()-r.Before.PodCount(), r.Before.PodCount(), r.After.PodCount())}
+
+	namespaces := make([]string, 0, len(r.Pods))
+	for namespace := range r.Pods {
+		namespaces = append(namespaces, namespace)
+	}
+
+	sort.Strings(namespaces)
+
+	for _, namespace := range namespaces {
+		lines = append(lines, fmt.Sprintf("  %s: pods %+d", namespace, r.Pods[namespace]))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// TakeUsageSnapshot is a helper function that records the CPU and memory usage of the nodes of the cluster, from the
+// metrics-server, and the number of pods of every namespace that are not terminated. When since is not zero, it waits
+// until the metrics-server measured the usage of every node after it, so the usage of pods started before it, e.g.
+// the pods of a chart that was just installed, is included.
+func TakeUsageSnapshot(client *rancher.Client, clusterID string, since time.Time) (*UsageSnapshot, error) {
+	var nodeMetrics []NodeMetrics
+	var lastErr error
+
+	err := wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(metricsServerTimeout), func(context.Context) (done bool, err error) {
+		nodeMetrics, lastErr = GetNodeMetrics(client, clusterID)
+		if lastErr != nil {
+			return false, nil
+		}
+
+		if len(nodeMetrics) == 0 {
+			return false, nil
+		}
+
+		for _, node := range nodeMetrics {
+			if node.Timestamp.Before(since) {
+				return false, nil
+			}
+		}
+
+		return true, nil
+	}, func() string {
+		if lastErr != nil {
+			return lastErr.Error()
+		}
+
+		return fmt.Sprintf("node metrics of cluster %s are not measured after %s: %v", clusterID, since.Format(time.RFC3339), nodeMetrics)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &UsageSnapshot{
+		Time:  time.Now(),
+		Nodes: map[string]ResourceUsage{},
+		Pods:  map[string]int{},
+	}
+
+	for _, node := range nodeMetrics {
+		snapshot.Nodes[node.Name] = node.Usage
+		snapshot.Total.CPU.Add(node.Usage.CPU)
+		snapshot.Total.Memory.Add(node.Usage.Memory)
+	}
+
+	clientset, err := downstream.GetClientset(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	podList, err := clientset.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pod := range podList.Items {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+
+		snapshot.Pods[pod.Namespace]++
+	}
+
+	return snapshot, nil
+}
+
+// NewFootprintReport is a constructor that returns the report of the difference of the snapshots taken before and after
+// the chart was installed, e.g. to compare the footprint of system charts like rancher-monitoring across versions.
+func NewFootprintReport(chartName, version string, before, after *UsageSnapshot) *FootprintReport {
+	report := &FootprintReport{
+		ChartName: chartName,
+		Version:   version,
+		Before:    before,
+		After:     after,
+		CPU:       after.Total.CPU.DeepCopy(),
+		Memory:    after.Total.Memory.DeepCopy(),
+		Pods:      map[string]int{},
+	}
+
+	report.CPU.Sub(before.Total.CPU)
+	report.Memory.Sub(before.Total.Memory)
+
+	for namespace, pods := range after.Pods {
+		if delta := pods - before.Pods[namespace]; delta != 0 {
+			report.Pods[namespace] = delta
+		}
+	}
+
+	for namespace, pods := range before.Pods {
+		if _, ok := after.Pods[namespace]; !ok {
+			report.Pods[namespace] = -pods
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,226 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/shepherd/clients/rancher"
+)
+
+const (
+	// GrafanaServicePath is the path of the rancher-monitoring grafana service proxied by the kubernetes API
+	GrafanaServicePath = "api/v1/namespaces/cattle-monitoring-system/services/http:rancher-monitoring-grafana:80/proxy"
+
+	grafanaSearchPath           = "/api/search?type=dash-db&query="
+	grafanaDashboardPath        = "/api/dashboards/uid/"
+	grafanaFrontendSettingsPath = "/api/frontend/settings"
+	grafanaDatasourceHealthPath = "/api/datasources/uid/%s/health"
+	grafanaVariablePrefix       = "$"
+	grafanaHealthStatusOK       = "OK"
+	grafanaOrgUsersPath         = "/api/org/users"
+)
+
+// GrafanaHealthCheckedDatasourceTypes are the types of the grafana datasources VerifyGrafanaDatasources runs the health check of
+var GrafanaHealthCheckedDatasourceTypes = []string{"prometheus", "loki", "alertmanager"}
+
+// grafanaDashboard is a private struct of the parts of a grafana dashboard holding the queries of its panels.
+type grafanaDashboard struct {
+	Dashboard struct {
+		Title  string         `json:"title"`
+		Panels []grafanaPanel `json:"panels"`
+	} `json:"dashboard"`
+}
+
+// grafanaPanel is a private struct of a grafana panel, rows nest their panels.
+type grafanaPanel struct {
+	Title   string `json:"title"`
+	Targets []struct {
+		Expr string `json:"expr"`
+	} `json:"targets"`
+	Panels []grafanaPanel `json:"panels"`
+}
+
+// grafanaFrontendSettings is a private struct of the parts of the grafana frontend settings listing the datasources,
+// which are readable by viewers unlike the datasources API.
+type grafanaFrontendSettings struct {
+	Datasources map[string]struct {
+		UID  string `json:"uid"`
+		Type string `json:"type"`
+	} `json:"datasources"`
+}
+
+// grafanaDatasourceHealth is a private struct of the response of the grafana datasource health check.
+type grafanaDatasourceHealth struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// VerifyGrafanaDatasources is a helper function that runs the health check of every datasource of the rancher-monitoring
+// grafana of the cluster whose type is one of GrafanaHealthCheckedDatasourceTypes, returning the error message of every
+// datasource that is not healthy.
+func VerifyGrafanaDatasources(client *rancher.Client, clusterID string) error {
+	settings := &grafanaFrontendSettings{}
+	err := getGrafana(client, clusterID, grafanaFrontendSettingsPath, settings)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range settings.Datasources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		datasource := settings.Datasources[name]
+		if !slices.Contains(GrafanaHealthCheckedDatasourceTypes, datasource.Type) {
+			continue
+		}
+
+		path := ClusterProxyPath(clusterID, GrafanaServicePath) + fmt.Sprintf(grafanaDatasourceHealthPath, datasource.UID)
+
+		// failed health checks answer with an error status code, so the body is decoded whatever the status
+		result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, path, true)
+		if err != nil {
+			return err
+		}
+
+		health := &grafanaDatasourceHealth{}
+		err = json.Unmarshal([]byte(result.Body), health)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("grafana datasource %s returned an invalid health check response: %s", name, result.Body))
+			continue
+		}
+
+		if health.Status != grafanaHealthStatusOK {
+			errs = append(errs, fmt.Errorf("grafana datasource %s (%s) is not healthy: %s", name, datasource.Type, health.Message))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// SearchGrafanaDashboards is a helper function that returns the UIDs of the dashboards of the rancher-monitoring grafana
+// of the cluster whose title matches the query.
+func SearchGrafanaDashboards(client *rancher.Client, clusterID, query string) ([]string, error) {
+	var results []struct {
+		UID string `json:"uid"`
+	}
+
+	err := getGrafana(client, clusterID, grafanaSearchPath+url.QueryEscape(query), &results)
+	if err != nil {
+		return nil, err
+	}
+
+	var uids []string
+	for _, result := range results {
+		uids = append(uids, result.UID)
+	}
+
+	return uids, nil
+}
+
+// VerifyGrafanaDashboardData is a helper function that runs the queries of the panels of the grafana dashboard against
+// the rancher-monitoring prometheus and checks at least one of them returns data. Queries using dashboard variables
+// are skipped, as their values are only known to grafana.
+func VerifyGrafanaDashboardData(client *rancher.Client, clusterID, uid string) error {
+	dashboard := &grafanaDashboard{}
+	err := getGrafana(client, clusterID, grafanaDashboardPath+uid, dashboard)
+	if err != nil {
+		return err
+	}
+
+	var queries []string
+	collectPanelQueries(dashboard.Dashboard.Panels, &queries)
+
+	for _, query := range queries {
+		samples, err := QueryPrometheus(client, clusterID, query)
+		if err == nil && len(samples) > 0 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("none of the %d queries of grafana dashboard %q returned data", len(queries), dashboard.Dashboard.Title)
+}
+
+// VerifyGrafanaAuth is a helper function that checks the rancher-monitoring grafana of the cluster applies the auth
+// settings to the requests of the client through the rancher proxy, which are anonymous ones: with anonymous access
+// disabled the dashboards can't be searched, with it enabled they can and only the Admin role lists the users of the
+// organization.
+func VerifyGrafanaAuth(client *rancher.Client, clusterID string, authOpts *actionscharts.GrafanaAuthOpts) error {
+	canSearch, err := canGetGrafana(client, clusterID, grafanaSearchPath)
+	if err != nil {
+		return err
+	}
+
+	if !authOpts.AnonymousEnabled {
+		if canSearch {
+			return errors.New("anonymous grafana requests are allowed, anonymous access is expected to be disabled")
+		}
+
+		return nil
+	}
+
+	if !canSearch {
+		return errors.New("anonymous grafana requests are denied, anonymous access is expected to be enabled")
+	}
+
+	canListUsers, err := canGetGrafana(client, clusterID, grafanaOrgUsersPath)
+	if err != nil {
+		return err
+	}
+
+	expectListUsers := authOpts.AnonymousRole == actionscharts.GrafanaAdmin
+	if canListUsers != expectListUsers {
+		return fmt.Errorf("anonymous grafana requests listing the organization users are %s, expected %s for role %q", accessString(canListUsers), accessString(expectListUsers), authOpts.AnonymousRole)
+	}
+
+	return nil
+}
+
+// collectPanelQueries is a private helper function that appends the queries of the panels, and of the panels nested in
+// rows, that don't use dashboard variables.
+func collectPanelQueries(panels []grafanaPanel, queries *[]string) {
+	for _, panel := range panels {
+		for _, target := range panel.Targets {
+			if target.Expr != "" && !strings.Contains(target.Expr, grafanaVariablePrefix) {
+				*queries = append(*queries, target.Expr)
+			}
+		}
+
+		collectPanelQueries(panel.Panels, queries)
+	}
+}
+
+// getGrafana is a private helper function that sends a GET request to the path of the grafana API of the cluster and
+// decodes the response.
+func getGrafana(client *rancher.Client, clusterID, path string, response any) error {
+	result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, ClusterProxyPath(clusterID, GrafanaServicePath)+path, true)
+	if err != nil {
+		return err
+	}
+
+	if !result.Ok {
+		return fmt.Errorf("grafana request %s of cluster %s failed: %s", path, clusterID, result.Body)
+	}
+
+	return json.Unmarshal([]byte(result.Body), response)
+}
+
+// canGetGrafana is a private helper function that returns true when a GET request to the path of the grafana API of
+// the cluster succeeds.
+func canGetGrafana(client *rancher.Client, clusterID, path string) (bool, error) {
+	result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, ClusterProxyPath(clusterID, GrafanaServicePath)+path, true)
+	if err != nil {
+		return false, err
+	}
+
+	return result.Ok, nil
+}
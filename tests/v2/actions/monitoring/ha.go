@@ -0,0 +1,150 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/charts"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var podDisruptionBudgetGroupVersionResource = schema.GroupVersionResource{
+	Group:    "policy",
+	Version:  "v1",
+	Resource: "poddisruptionbudgets",
+}
+
+// haTarget is a private struct of a replicated component of the rancher-monitoring chart, with the name of its pod
+// disruption budget and the label selector of its pods.
+type haTarget struct {
+	name             string
+	podLabelSelector string
+	replicas         int
+}
+
+// VerifyMonitoringHA is a helper function that checks the rancher-monitoring prometheus and alertmanager of the cluster
+// run as configured by the high availability options, e.g. when installed with MonitoringHAOpts: every replica is
+// ready, the pod anti-affinity is set on their pods and, when hard, no two replicas share a node, and the pod disruption
+// budgets cover every replica and allow the disruption of the ones above their minimum. Components whose replicas are
+// not set in the options are not checked.
+func VerifyMonitoringHA(client *rancher.Client, clusterID string, haOpts *actionscharts.MonitoringHAOpts) error {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	targets := []haTarget{
+		{PrometheusName, prometheusPodSelector, haOpts.PrometheusReplicas},
+		{alertmanagerName, "app.kubernetes.io/name=alertmanager", haOpts.AlertmanagerReplicas},
+	}
+
+	for _, target := range targets {
+		if target.replicas == 0 {
+			continue
+		}
+
+		err = verifyHAReplicas(dynamicClient, target, haOpts.PodAntiAffinity)
+		if err != nil {
+			return err
+		}
+
+		if haOpts.PodDisruptionBudget {
+			err = verifyPodDisruptionBudget(dynamicClient, target)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// verifyHAReplicas is a private helper function that checks the pods of the target are its replicas, all ready, with
+// the pod anti-affinity, and on distinct nodes when it is hard.
+func verifyHAReplicas(dynamicClient dynamic.Interface, target haTarget, podAntiAffinity string) error {
+	podList, err := dynamicClient.Resource(actionscharts.PodGroupVersionResource).Namespace(charts.RancherMonitoringNamespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: target.podLabelSelector,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(podList.Items) != target.replicas {
+		return fmt.Errorf("%s has %d pods, expected %d replicas", target.name, len(podList.Items), target.replicas)
+	}
+
+	nodes := map[string]string{}
+	for _, unstructuredPod := range podList.Items {
+		pod := &corev1.Pod{}
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredPod.Object, pod)
+		if err != nil {
+			return err
+		}
+
+		if !isPodReady(pod) {
+			return fmt.Errorf("pod %s of %s is not ready", pod.Name, target.name)
+		}
+
+		var antiAffinity *corev1.PodAntiAffinity
+		if pod.Spec.Affinity != nil {
+			antiAffinity = pod.Spec.Affinity.PodAntiAffinity
+		}
+
+		switch podAntiAffinity {
+		case actionscharts.PodAntiAffinityHard:
+			if antiAffinity == nil || len(antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution) == 0 {
+				return fmt.Errorf("pod %s of %s has no required pod anti-affinity", pod.Name, target.name)
+			}
+
+			if otherPod, ok := nodes[pod.Spec.NodeName]; ok {
+				return fmt.Errorf("pods %s and %s of %s run on the same node %s", otherPod, pod.Name, target.name, pod.Spec.NodeName)
+			}
+		case actionscharts.PodAntiAffinitySoft:
+			if antiAffinity == nil || len(antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution) == 0 {
+				return fmt.Errorf("pod %s of %s has no preferred pod anti-affinity", pod.Name, target.name)
+			}
+		}
+
+		nodes[pod.Spec.NodeName] = pod.Name
+	}
+
+	return nil
+}
+
+// verifyPodDisruptionBudget is a private helper function that checks the pod disruption budget of the target expects
+// its replicas and allows the disruption of the healthy ones above its minimum.
+func verifyPodDisruptionBudget(dynamicClient dynamic.Interface, target haTarget) error {
+	unstructuredBudget, err := dynamicClient.Resource(podDisruptionBudgetGroupVersionResource).Namespace(charts.RancherMonitoringNamespace).Get(context.TODO(), target.name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("pod disruption budget of %s: %w", target.name, err)
+	}
+
+	budget := &policyv1.PodDisruptionBudget{}
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredBudget.Object, budget)
+	if err != nil {
+		return err
+	}
+
+	if int(budget.Status.ExpectedPods) != target.replicas {
+		return fmt.Errorf("pod disruption budget of %s expects %d pods, expected %d replicas", target.name, budget.Status.ExpectedPods, target.replicas)
+	}
+
+	allowed := budget.Status.CurrentHealthy - budget.Status.DesiredHealthy
+	if allowed < 0 {
+		allowed = 0
+	}
+
+	if budget.Status.DisruptionsAllowed != allowed {
+		return fmt.Errorf("pod disruption budget of %s allows %d disruptions, expected %d with %d healthy pods out of %d desired",
+			target.name, budget.Status.DisruptionsAllowed, allowed, budget.Status.CurrentHealthy, budget.Status.DesiredHealthy)
+	}
+
+	return nil
+}
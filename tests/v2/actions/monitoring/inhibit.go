@@ -0,0 +1,176 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+)
+
+const (
+	alertmanagerAlertsPath = "/api/v2/alerts"
+	suppressedAlertState   = "suppressed"
+	inhibitedAlertTimeout  = 5 * time.Minute
+)
+
+// AlertmanagerAlert is a struct of an alert of the alertmanager alerts API.
+type AlertmanagerAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Fingerprint string            `json:"fingerprint"`
+	Status      struct {
+		// State is one of unprocessed, active or suppressed
+		State string `json:"state"`
+		// InhibitedBy are the fingerprints of the alerts inhibiting the alert
+		InhibitedBy []string `json:"inhibitedBy"`
+		// SilencedBy are the IDs of the silences muting the alert
+		SilencedBy []string `json:"silencedBy"`
+	} `json:"status"`
+}
+
+// NewInhibitRule is a constructor that returns the rule inhibiting the alerts matching the target matchers while an
+// alert matching the source matchers fires with the same values of the equal labels, in the syntax of the alertmanager
+// version, e.g. 0.27.0. Versions before 0.22.0 get the legacy source_match and target_match syntax, which can't express
+// negative matchers.
+func NewInhibitRule(alertmanagerVersion string, source, target []Matcher, equal ...string) (*InhibitRule, error) {
+	legacy, err := isLegacyMatchVersion(alertmanagerVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &InhibitRule{Equal: equal}
+	if !legacy {
+		for _, matcher := range source {
+			rule.SourceMatchers = append(rule.SourceMatchers, matcher.String())
+		}
+
+		for _, matcher := range target {
+			rule.TargetMatchers = append(rule.TargetMatchers, matcher.String())
+		}
+
+		return rule, nil
+	}
+
+	rule.SourceMatch, rule.SourceMatchRE, err = legacyMatch(source)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.TargetMatch, rule.TargetMatchRE, err = legacyMatch(target)
+	if err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+// NewClusterInhibitRule is a constructor that returns the inhibit rule of NewInhibitRule for the version of the
+// rancher-monitoring alertmanager of the cluster.
+func NewClusterInhibitRule(client *rancher.Client, clusterID string, source, target []Matcher, equal ...string) (*InhibitRule, error) {
+	alertmanagerVersion, err := GetAlertmanagerVersion(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewInhibitRule(alertmanagerVersion, source, target, equal...)
+}
+
+// AddInhibitRules returns the mutation of UpdateAlertmanagerConfig appending the inhibit rules to the configuration.
+func AddInhibitRules(rules ...*InhibitRule) func(alertmanagerConfig *AlertmanagerConfig) *AlertmanagerConfig {
+	return func(alertmanagerConfig *AlertmanagerConfig) *AlertmanagerConfig {
+		alertmanagerConfig.InhibitRules = append(alertmanagerConfig.InhibitRules, rules...)
+		return alertmanagerConfig
+	}
+}
+
+// RemoveInhibitRules returns the mutation of UpdateAlertmanagerConfig removing the inhibit rules from the configuration.
+// Rules are compared by their content, as the configuration is decoded again from the alertmanager secret.
+func RemoveInhibitRules(rules ...*InhibitRule) func(alertmanagerConfig *AlertmanagerConfig) *AlertmanagerConfig {
+	return func(alertmanagerConfig *AlertmanagerConfig) *AlertmanagerConfig {
+		removed := map[string]bool{}
+		for _, rule := range rules {
+			ruleBytes, err := json.Marshal(rule)
+			if err == nil {
+				removed[string(ruleBytes)] = true
+			}
+		}
+
+		var inhibitRules []*InhibitRule
+		for _, rule := range alertmanagerConfig.InhibitRules {
+			ruleBytes, err := json.Marshal(rule)
+			if err != nil || !removed[string(ruleBytes)] {
+				inhibitRules = append(inhibitRules, rule)
+			}
+		}
+		alertmanagerConfig.InhibitRules = inhibitRules
+
+		return alertmanagerConfig
+	}
+}
+
+// GetAlertmanagerAlerts is a helper function that returns the alerts of the rancher-monitoring alertmanager of the
+// cluster, including the inhibited and silenced ones, from its alerts API.
+func GetAlertmanagerAlerts(client *rancher.Client, clusterID string) ([]AlertmanagerAlert, error) {
+	path := ClusterProxyPath(clusterID, AlertmanagerServicePath) + alertmanagerAlertsPath
+
+	result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if !result.Ok {
+		return nil, fmt.Errorf("alerts of the alertmanager of cluster %s are not available: %s", clusterID, result.Body)
+	}
+
+	var alerts []AlertmanagerAlert
+	err = json.Unmarshal([]byte(result.Body), &alerts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the alerts of the alertmanager of cluster %s: %w", clusterID, err)
+	}
+
+	return alerts, nil
+}
+
+// WaitForInhibitedAlert is a helper function that waits until the rancher-monitoring alertmanager of the cluster has an
+// alert having all the labels that is suppressed by an inhibit rule, and returns it.
+func WaitForInhibitedAlert(client *rancher.Client, clusterID string, labels map[string]string) (*AlertmanagerAlert, error) {
+	var inhibited *AlertmanagerAlert
+	var matching []AlertmanagerAlert
+
+	err := wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(inhibitedAlertTimeout), func(context.Context) (done bool, err error) {
+		alerts, err := GetAlertmanagerAlerts(client, clusterID)
+		if err != nil {
+			return false, nil
+		}
+
+		matching = nil
+		for i, alert := range alerts {
+			if !hasLabels(alert.Labels, labels) {
+				continue
+			}
+
+			matching = append(matching, alert)
+			if alert.Status.State == suppressedAlertState && len(alert.Status.InhibitedBy) > 0 {
+				inhibited = &alerts[i]
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}, func() string {
+		if len(matching) == 0 {
+			return fmt.Sprintf("no alert with labels %v", labels)
+		}
+
+		return fmt.Sprintf("alert with labels %v is %s, inhibited by %v", labels, matching[0].Status.State, matching[0].Status.InhibitedBy)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return inhibited, nil
+}
@@ -0,0 +1,223 @@
+package monitoring
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
+	"github.com/rancher/shepherd/extensions/namespaces"
+	"github.com/rancher/shepherd/extensions/services"
+	"github.com/rancher/shepherd/extensions/workloads"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// The json/yaml config key for the monitoring load
+const LoadConfigurationFileKey = "monitoringLoad"
+
+const (
+	defaultLoadNamespaces       = 10
+	defaultLoadPodsPerNamespace = 10
+	defaultLoadImage            = "quay.io/brancz/prometheus-example-app:v0.3.0"
+
+	loadLabelKey         = "monitoring-load"
+	loadMetricsPort      = 8080
+	loadMetricsPortName  = "metrics"
+	serviceMonitorType   = "monitoring.coreos.com.servicemonitor"
+	serviceMonitorAPI    = "monitoring.coreos.com/v1"
+	serviceMonitorKind   = "ServiceMonitor"
+	loadNamePrefixLength = 5
+)
+
+// LoadConfig is the configuration of the load generated to stress the service discovery of prometheus, e.g.
+//
+//	monitoringLoad:
+//	  namespaces: 20
+//	  podsPerNamespace: 10
+//	  maxPrometheusMemoryBytes: 4294967296
+//	  maxScrapeDuration: 10s
+//
+// Every namespace gets as many services as pods, all selecting every pod of the namespace, and a service monitor scraping
+// them, so each namespace adds podsPerNamespace² targets to the service discovery of prometheus.
+type LoadConfig struct {
+	Namespaces               int     `json:"namespaces" yaml:"namespaces"`
+	PodsPerNamespace         int     `json:"podsPerNamespace" yaml:"podsPerNamespace"`
+	Image                    string  `json:"image" yaml:"image"`
+	MaxPrometheusMemoryBytes float64 `json:"maxPrometheusMemoryBytes" yaml:"maxPrometheusMemoryBytes"`
+	MaxScrapeDuration        string  `json:"maxScrapeDuration" yaml:"maxScrapeDuration"`
+}
+
+// Load is a struct of the resources created by GenerateLoad.
+type Load struct {
+	Name       string
+	Namespaces []string
+	Targets    int
+}
+
+// PrometheusLoadStats is a struct of the prometheus figures that grow with the number of targets.
+type PrometheusLoadStats struct {
+	MemoryBytes       float64
+	Targets           int
+	LoadTargets       int
+	MaxScrapeDuration time.Duration
+}
+
+// GetLoadConfig is a helper function that reads the monitoring load configuration and fills in the defaults.
+func GetLoadConfig() *LoadConfig {
+	loadConfig := new(LoadConfig)
+	config.LoadConfig(LoadConfigurationFileKey, loadConfig)
+
+	if loadConfig.Namespaces <= 0 {
+		loadConfig.Namespaces = defaultLoadNamespaces
+	}
+
+	if loadConfig.PodsPerNamespace <= 0 {
+		loadConfig.PodsPerNamespace = defaultLoadPodsPerNamespace
+	}
+
+	if loadConfig.Image == "" {
+		loadConfig.Image = defaultLoadImage
+	}
+
+	return loadConfig
+}
+
+// GenerateLoad is a helper function that creates the namespaces of the load in the project, each with a deployment of
+// the configured number of pods, as many services selecting them and a service monitor scraping the services.
+// The namespaces are deleted by the session of the client.
+func GenerateLoad(client *rancher.Client, project *management.Project, loadConfig *LoadConfig) (*Load, error) {
+	steveClient, err := client.Steve.ProxyDownstream(project.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	load := &Load{Name: "load-" + namegenerator.RandStringLower(loadNamePrefixLength)}
+	labels := map[string]string{loadLabelKey: load.Name}
+
+	for i := 0; i < loadConfig.Namespaces; i++ {
+		namespaceName := fmt.Sprintf("%s-%d", load.Name, i)
+
+		_, err := namespaces.CreateNamespace(client, namespaceName, "", labels, nil, project)
+		if err != nil {
+			return load, err
+		}
+
+		load.Namespaces = append(load.Namespaces, namespaceName)
+
+		container := workloads.NewContainer(load.Name, loadConfig.Image, corev1.PullIfNotPresent, nil, nil, nil, nil, nil)
+		container.Ports = []corev1.ContainerPort{{Name: loadMetricsPortName, ContainerPort: loadMetricsPort}}
+
+		replicas := int32(loadConfig.PodsPerNamespace)
+		deployment := workloads.NewDeploymentTemplate(load.Name, namespaceName, workloads.NewPodTemplate([]corev1.Container{container}, nil, nil, labels), false, labels)
+		deployment.Spec.Replicas = &replicas
+
+		_, err = steveClient.SteveType(workloads.DeploymentSteveType).Create(deployment)
+		if err != nil {
+			return load, err
+		}
+
+		for j := 0; j < loadConfig.PodsPerNamespace; j++ {
+			ports := []corev1.ServicePort{{Name: loadMetricsPortName, Port: loadMetricsPort, TargetPort: intstr.FromString(loadMetricsPortName)}}
+			service := services.NewServiceTemplate(fmt.Sprintf("%s-%d", load.Name, j), namespaceName, corev1.ServiceTypeClusterIP, ports, labels)
+			service.Labels = labels
+
+			_, err = steveClient.SteveType(services.ServiceSteveType).Create(service)
+			if err != nil {
+				return load, err
+			}
+		}
+
+		_, err = steveClient.SteveType(serviceMonitorType).Create(newLoadServiceMonitor(load.Name, namespaceName, labels))
+		if err != nil {
+			return load, err
+		}
+
+		load.Targets += loadConfig.PodsPerNamespace * loadConfig.PodsPerNamespace
+	}
+
+	logrus.Infof("Generated monitoring load %s: %d namespaces, %d pods each", load.Name, loadConfig.Namespaces, loadConfig.PodsPerNamespace)
+
+	return load, nil
+}
+
+// GetPrometheusLoadStats is a helper function that returns the memory, target counts and the longest scrape duration of
+// the rancher-monitoring prometheus of the cluster. Load targets are the ones of the namespaces of the load.
+func GetPrometheusLoadStats(client *rancher.Client, clusterID string, load *Load) (*PrometheusLoadStats, error) {
+	memoryBytes, err := QueryPrometheusValue(client, clusterID, `sum(process_resident_memory_bytes{job="rancher-monitoring-prometheus"})`)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := QueryPrometheusValue(client, clusterID, `count(up)`)
+	if err != nil {
+		return nil, err
+	}
+
+	loadTargets, err := QueryPrometheusValue(client, clusterID, fmt.Sprintf(`count(up{namespace=~"%s-.*"}) or vector(0)`, load.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	maxScrapeDuration, err := QueryPrometheusValue(client, clusterID, `max(scrape_duration_seconds)`)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PrometheusLoadStats{
+		MemoryBytes:       memoryBytes,
+		Targets:           int(targets),
+		LoadTargets:       int(loadTargets),
+		MaxScrapeDuration: time.Duration(maxScrapeDuration * float64(time.Second)),
+	}, nil
+}
+
+// VerifyPrometheusLoad is a helper function that checks that prometheus discovered every target of the load and stays
+// within the memory and scrape duration limits of the configuration, the limits that are not set are not checked.
+func VerifyPrometheusLoad(stats *PrometheusLoadStats, load *Load, loadConfig *LoadConfig) error {
+	var errs []error
+	if stats.LoadTargets < load.Targets {
+		errs = append(errs, fmt.Errorf("prometheus discovered %d of the %d targets of load %s", stats.LoadTargets, load.Targets, load.Name))
+	}
+
+	if loadConfig.MaxPrometheusMemoryBytes > 0 && stats.MemoryBytes > loadConfig.MaxPrometheusMemoryBytes {
+		errs = append(errs, fmt.Errorf("prometheus uses %.0f bytes of memory, more than the limit of %.0f", stats.MemoryBytes, loadConfig.MaxPrometheusMemoryBytes))
+	}
+
+	if loadConfig.MaxScrapeDuration != "" {
+		maxScrapeDuration, err := time.ParseDuration(loadConfig.MaxScrapeDuration)
+		if err != nil {
+			return err
+		}
+
+		if stats.MaxScrapeDuration > maxScrapeDuration {
+			errs = append(errs, fmt.Errorf("the longest scrape took %s, more than the limit of %s", stats.MaxScrapeDuration, maxScrapeDuration))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// newLoadServiceMonitor is a private constructor that returns the service monitor scraping the services of the load in the namespace.
+func newLoadServiceMonitor(name, namespace string, labels map[string]string) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": serviceMonitorAPI,
+		"kind":       serviceMonitorKind,
+		"metadata": metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		"spec": map[string]interface{}{
+			"selector": metav1.LabelSelector{MatchLabels: labels},
+			"endpoints": []map[string]interface{}{
+				{"port": loadMetricsPortName},
+			},
+		},
+	}
+}
@@ -0,0 +1,69 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// LonghornNamespace is the namespace the longhorn chart is installed in
+	LonghornNamespace = "longhorn-system"
+
+	longhornDashboardQuery = "longhorn"
+)
+
+// ServiceMonitorGroupVersionResource is the required Group Version Resource for accessing service monitors in a cluster,
+// using the dynamic client.
+var ServiceMonitorGroupVersionResource = schema.GroupVersionResource{
+	Group:    "monitoring.coreos.com",
+	Version:  "v1",
+	Resource: "servicemonitors",
+}
+
+// VerifyLonghornMetrics is a helper function that checks the longhorn service monitors exist, the rancher-monitoring
+// prometheus of the cluster discovered their targets and all of them are up, and that the longhorn grafana dashboards,
+// if any is provisioned, return data. Both longhorn and rancher-monitoring must be installed in the cluster.
+func VerifyLonghornMetrics(client *rancher.Client, clusterID string) error {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	serviceMonitors, err := dynamicClient.Resource(ServiceMonitorGroupVersionResource).Namespace(LonghornNamespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	if len(serviceMonitors.Items) == 0 {
+		return fmt.Errorf("no service monitors in namespace %s, longhorn metrics must be enabled", LonghornNamespace)
+	}
+
+	err = WaitForHealthyTargets(client, clusterID, fmt.Sprintf(`namespace=%q`, LonghornNamespace))
+	if err != nil {
+		return err
+	}
+
+	dashboardUIDs, err := SearchGrafanaDashboards(client, clusterID, longhornDashboardQuery)
+	if err != nil {
+		return err
+	}
+
+	if len(dashboardUIDs) == 0 {
+		logrus.Infof("No longhorn grafana dashboard is provisioned in cluster %s", clusterID)
+		return nil
+	}
+
+	for _, uid := range dashboardUIDs {
+		err = VerifyGrafanaDashboardData(client, clusterID, uid)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// PrometheusPodLabelSelector is the label selector of the rancher-monitoring prometheus pods
+	PrometheusPodLabelSelector = "app.kubernetes.io/name=prometheus"
+
+	metricsAPIPath       = "apis/metrics.k8s.io/v1beta1"
+	metricsServerTimeout = 5 * time.Minute
+)
+
+// ResourceUsage is a struct of the CPU and memory usage reported by the metrics API.
+type ResourceUsage struct {
+	CPU    resource.Quantity `json:"cpu"`
+	Memory resource.Quantity `json:"memory"`
+}
+
+// NodeMetrics is a struct of the usage of a node, as kubectl top node shows it.
+type NodeMetrics struct {
+	Name  string
+	Usage ResourceUsage
+	// Timestamp is the end of the window the usage was measured over
+	Timestamp time.Time
+}
+
+// PodMetrics is a struct of the usage of the containers of a pod, as kubectl top pod --containers shows it.
+type PodMetrics struct {
+	Name       string
+	Namespace  string
+	Containers map[string]ResourceUsage
+}
+
+// metricsListResponse is a private struct of the node and pod metrics lists of the metrics API.
+type metricsListResponse struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Timestamp  time.Time     `json:"timestamp"`
+		Usage      ResourceUsage `json:"usage"`
+		Containers []struct {
+			Name  string        `json:"name"`
+			Usage ResourceUsage `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+// Usage returns the usage of the pod, the sum of the usage of its containers.
+func (p *PodMetrics) Usage() ResourceUsage {
+	usage := ResourceUsage{}
+	for _, containerUsage := range p.Containers {
+		usage.CPU.Add(containerUsage.CPU)
+		usage.Memory.Add(containerUsage.Memory)
+	}
+
+	return usage
+}
+
+// GetNodeMetrics is a helper function that returns the usage of the nodes of the cluster from the metrics API served by
+// the metrics-server, through the rancher proxy.
+func GetNodeMetrics(client *rancher.Client, clusterID string) ([]NodeMetrics, error) {
+	response, err := getMetrics(client, clusterID, metricsAPIPath+"/nodes")
+	if err != nil {
+		return nil, err
+	}
+
+	var nodeMetrics []NodeMetrics
+	for _, item := range response.Items {
+		nodeMetrics = append(nodeMetrics, NodeMetrics{
+			Name:      item.Metadata.Name,
+			Usage:     item.Usage,
+			Timestamp: item.Timestamp,
+		})
+	}
+
+	return nodeMetrics, nil
+}
+
+// GetPodMetrics is a helper function that returns the usage of the pods of the namespace of the cluster matching the
+// label selector, which may be empty, from the metrics API served by the metrics-server, through the rancher proxy.
+func GetPodMetrics(client *rancher.Client, clusterID, namespace, labelSelector string) ([]PodMetrics, error) {
+	path := fmt.Sprintf("%s/namespaces/%s/pods", metricsAPIPath, namespace)
+	if labelSelector != "" {
+		path += "?labelSelector=" + url.QueryEscape(labelSelector)
+	}
+
+	response, err := getMetrics(client, clusterID, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var podMetrics []PodMetrics
+	for _, item := range response.Items {
+		pod := PodMetrics{
+			Name:       item.Metadata.Name,
+			Namespace:  item.Metadata.Namespace,
+			Containers: map[string]ResourceUsage{},
+		}
+
+		for _, container := range item.Containers {
+			pod.Containers[container.Name] = container.Usage
+		}
+
+		podMetrics = append(podMetrics, pod)
+	}
+
+	return podMetrics, nil
+}
+
+// VerifyMetricsServer is a helper function that waits until the metrics-server of the cluster reports the usage of at
+// least one node, which is when kubectl top and the horizontal pod autoscalers work.
+func VerifyMetricsServer(client *rancher.Client, clusterID string) error {
+	var lastErr error
+
+	return wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(metricsServerTimeout), func(context.Context) (done bool, err error) {
+		nodeMetrics, err := GetNodeMetrics(client, clusterID)
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+
+		lastErr = nil
+
+		return len(nodeMetrics) > 0, nil
+	}, func() string {
+		if lastErr != nil {
+			return lastErr.Error()
+		}
+
+		return "no node metrics"
+	})
+}
+
+// AssertPodMemoryBelow is a helper function that checks the memory usage of every pod of the namespace of the cluster
+// matching the label selector is below the limit, e.g. the rancher-monitoring prometheus with
+// PrometheusPodLabelSelector. It fails when no pod matches.
+func AssertPodMemoryBelow(client *rancher.Client, clusterID, namespace, labelSelector string, limit resource.Quantity) error {
+	podMetrics, err := GetPodMetrics(client, clusterID, namespace, labelSelector)
+	if err != nil {
+		return err
+	}
+
+	if len(podMetrics) == 0 {
+		return fmt.Errorf("no pod metrics in namespace %s matching %q", namespace, labelSelector)
+	}
+
+	var overLimit []string
+	for _, pod := range podMetrics {
+		memory := pod.Usage().Memory
+		if memory.Cmp(limit) >= 0 {
+			overLimit = append(overLimit, fmt.Sprintf("%s (%s)", pod.Name, memory.String()))
+		}
+	}
+
+	if len(overLimit) > 0 {
+		return fmt.Errorf("pods use more than %s of memory: %s", limit.String(), strings.Join(overLimit, ", "))
+	}
+
+	return nil
+}
+
+// getMetrics is a private helper function that gets the path of the metrics API of the cluster through the rancher proxy.
+func getMetrics(client *rancher.Client, clusterID, path string) (*metricsListResponse, error) {
+	result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, ClusterProxyPath(clusterID, path), true)
+	if err != nil {
+		return nil, err
+	}
+
+	if !result.Ok {
+		return nil, fmt.Errorf("metrics API of cluster %s is not available: %s", clusterID, result.Body)
+	}
+
+	response := &metricsListResponse{}
+	err = json.Unmarshal([]byte(result.Body), response)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the response of the metrics API: %w", err)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,103 @@
+package monitoring
+
+import (
+	"fmt"
+	"time"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/clients/rancher/catalog"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/pkg/api/steve/catalog/types"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// NeuVectorNamespace is the namespace the neuvector and neuvector-monitor charts are installed in
+	NeuVectorNamespace = "cattle-neuvector-system"
+	// NeuVectorMonitorName is the name of the chart deploying the neuvector prometheus exporter and its service monitor
+	NeuVectorMonitorName = "neuvector-monitor"
+
+	neuVectorExporterLabelSelector = "app=neuvector-prometheus-exporter-pod"
+	neuVectorMetricsQuery          = `count({__name__=~"nv_.+"})`
+	neuVectorInstallTimeout        = 10 * time.Minute
+	neuVectorMetricsTimeout        = 2 * time.Minute
+	serverURLSettingID             = "server-url"
+	defaultRegistrySettingID       = "system-default-registry"
+)
+
+// InstallNeuVectorMonitorChart is a helper function that installs the neuvector-monitor chart with the prometheus exporter
+// and its service monitor enabled, and waits for the exporter. The neuvector chart must be installed in the cluster
+// already. The chart is uninstalled by the session of the client.
+func InstallNeuVectorMonitorChart(client *rancher.Client, installOptions *charts.InstallOptions) error {
+	serverSetting, err := client.Management.Setting.ByID(serverURLSettingID)
+	if err != nil {
+		return err
+	}
+
+	registrySetting, err := client.Management.Setting.ByID(defaultRegistrySettingID)
+	if err != nil {
+		return err
+	}
+
+	catalogClient, err := client.GetClusterCatalogClient(installOptions.Cluster.ID)
+	if err != nil {
+		return err
+	}
+
+	err = catalogClient.InstallChart(&types.ChartInstallAction{
+		Timeout:   &metav1.Duration{Duration: neuVectorInstallTimeout},
+		Wait:      true,
+		Namespace: NeuVectorNamespace,
+		ProjectID: installOptions.ProjectID,
+		Charts: []types.ChartInstall{{
+			Annotations: map[string]string{
+				"catalog.cattle.io/ui-source-repo":      catalog.RancherChartRepo,
+				"catalog.cattle.io/ui-source-repo-type": "cluster",
+			},
+			ChartName:   NeuVectorMonitorName,
+			ReleaseName: NeuVectorMonitorName,
+			Version:     installOptions.Version,
+			Values: map[string]interface{}{
+				"global": map[string]interface{}{
+					"cattle": map[string]string{
+						"clusterId":             installOptions.Cluster.ID,
+						"clusterName":           installOptions.Cluster.Name,
+						"systemDefaultRegistry": registrySetting.Value,
+						"url":                   serverSetting.Value,
+					},
+					"systemDefaultRegistry": registrySetting.Value,
+				},
+				"exporter": map[string]interface{}{
+					"enabled": true,
+					"serviceMonitor": map[string]interface{}{
+						"enabled": true,
+					},
+				},
+			},
+		}},
+	}, catalog.RancherChartRepo)
+	if err != nil {
+		return err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		return actionscharts.UninstallChart(client, installOptions.Cluster.ID, NeuVectorNamespace, NeuVectorMonitorName)
+	})
+
+	logrus.Infof("Installed %s %s, waiting for the exporter", NeuVectorMonitorName, installOptions.Version)
+
+	return actionscharts.WatchAndWaitDeployments(client, installOptions.Cluster.ID, NeuVectorNamespace, metav1.ListOptions{LabelSelector: neuVectorExporterLabelSelector})
+}
+
+// VerifyNeuVectorMetrics is a helper function that checks the rancher-monitoring prometheus of the cluster discovered the
+// neuvector exporter, its targets are up and neuvector metrics are stored.
+func VerifyNeuVectorMetrics(client *rancher.Client, clusterID string) error {
+	err := WaitForHealthyTargets(client, clusterID, fmt.Sprintf(`namespace=%q`, NeuVectorNamespace))
+	if err != nil {
+		return err
+	}
+
+	return AssertValueAbove(client, clusterID, neuVectorMetricsQuery, 0, neuVectorMetricsTimeout)
+}
@@ -0,0 +1,180 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rancher/rancher/tests/v2/actions/downstream"
+	"github.com/rancher/rancher/tests/v2/actions/fixtures"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/extensions/namespaces"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	persistenceLabelKey       = "monitoring-persistence"
+	prometheusPodSelector     = "app.kubernetes.io/name=prometheus"
+	persistenceSeriesTimeout  = 5 * time.Minute
+	prometheusRestartTimeout  = 10 * time.Minute
+	persistenceSeriesQuery    = `count_over_time(up{job=%q}[1h])`
+	persistenceNameSuffixSize = 5
+)
+
+// VerifyPrometheusPersistence is a helper function that checks the rancher-monitoring prometheus of the cluster keeps
+// its series across a restart, e.g. when it is installed with PrometheusPersistenceOpts. It creates a namespace in the
+// project with an exporter whose target has a job of its own, waits until prometheus scrapes it, removes the exporter
+// so no new sample is written, restarts the prometheus pods and checks every sample of the job scraped before the
+// restart can still be queried. The namespace is deleted by the session of the client.
+func VerifyPrometheusPersistence(client *rancher.Client, project *management.Project) error {
+	clusterID := project.ClusterID
+
+	jobName, err := deployPersistenceExporter(client, project)
+	if err != nil {
+		return err
+	}
+
+	err = AssertValueEquals(client, clusterID, fmt.Sprintf(`up{job=%q}`, jobName), 1, persistenceSeriesTimeout)
+	if err != nil {
+		return fmt.Errorf("prometheus doesn't scrape the persistence exporter: %w", err)
+	}
+
+	clientset, err := downstream.GetClientset(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	err = clientset.AppsV1().Deployments(jobName).Delete(context.TODO(), jobName, metav1.DeleteOptions{})
+	if err != nil {
+		return err
+	}
+
+	// the target is gone once its series is stale, so no sample is written after the count
+	err = assertQuery(client, clusterID, fmt.Sprintf(`up{job=%q}`, jobName), persistenceSeriesTimeout, func(samples []Sample) error {
+		if len(samples) > 0 {
+			return fmt.Errorf("prometheus still scrapes the persistence exporter %s", jobName)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sampleCount, err := QueryPrometheusValue(client, clusterID, fmt.Sprintf(persistenceSeriesQuery, jobName))
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Prometheus has %.0f samples of job %s before the restart", sampleCount, jobName)
+
+	err = RestartPrometheus(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	err = AssertMetricExists(client, clusterID, fmt.Sprintf(persistenceSeriesQuery+" >= %v", jobName, sampleCount), persistenceSeriesTimeout)
+	if err != nil {
+		return fmt.Errorf("prometheus lost samples of job %s on restart: %w", jobName, err)
+	}
+
+	return nil
+}
+
+// RestartPrometheus is a helper function that deletes the pods of the rancher-monitoring prometheus of the cluster and
+// waits until the statefulset recreated them and they are ready.
+func RestartPrometheus(client *rancher.Client, clusterID string) error {
+	clientset, err := downstream.GetClientset(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	pods := clientset.CoreV1().Pods(charts.RancherMonitoringNamespace)
+	listOptions := metav1.ListOptions{LabelSelector: prometheusPodSelector}
+
+	podList, err := pods.List(context.TODO(), listOptions)
+	if err != nil {
+		return err
+	}
+
+	if len(podList.Items) == 0 {
+		return fmt.Errorf("no prometheus pods in cluster %s", clusterID)
+	}
+
+	restartedPods := map[k8stypes.UID]bool{}
+	for _, pod := range podList.Items {
+		logrus.Infof("Restarting prometheus pod %s", pod.Name)
+
+		err = pods.Delete(context.TODO(), pod.Name, metav1.DeleteOptions{})
+		if err != nil {
+			return err
+		}
+
+		restartedPods[pod.UID] = true
+	}
+
+	var notReady []string
+
+	return wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(prometheusRestartTimeout), func(ctx context.Context) (done bool, err error) {
+		podList, err := pods.List(ctx, listOptions)
+		if err != nil {
+			return false, nil
+		}
+
+		notReady = nil
+		for _, pod := range podList.Items {
+			if restartedPods[pod.UID] || !isPodReady(&pod) {
+				notReady = append(notReady, pod.Name)
+			}
+		}
+
+		return len(podList.Items) == len(restartedPods) && len(notReady) == 0, nil
+	}, func() string {
+		return fmt.Sprintf("prometheus pods %v are not restarted and ready", notReady)
+	})
+}
+
+// deployPersistenceExporter is a private helper function that creates a namespace in the project with the metrics app
+// fixture, its service and a service monitor scraping it, all named after the job of the target, which it returns.
+func deployPersistenceExporter(client *rancher.Client, project *management.Project) (string, error) {
+	name := "persistence-" + namegenerator.RandStringLower(persistenceNameSuffixSize)
+	labels := map[string]string{persistenceLabelKey: name}
+
+	_, err := namespaces.CreateNamespace(client, name, "", labels, nil, project)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = fixtures.DeployMetricsApp(client, project.ClusterID, name, &fixtures.MetricsAppOpts{
+		Name:           name,
+		ServiceMonitor: true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// isPodReady is a private helper function that returns true when the pod is running with its ready condition true.
+func isPodReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,201 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/charts"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	alertmanagerName       = "rancher-monitoring-alertmanager"
+	operatorDeploymentName = "rancher-monitoring-operator"
+	nodeSteveType          = "node"
+)
+
+var (
+	alertmanagerGroupVersionResource = schema.GroupVersionResource{
+		Group:    "monitoring.coreos.com",
+		Version:  "v1",
+		Resource: "alertmanagers",
+	}
+	deploymentGroupVersionResource = schema.GroupVersionResource{
+		Group:    "apps",
+		Version:  "v1",
+		Resource: "deployments",
+	}
+)
+
+// Placement is a struct of the tolerations and node selector the rancher-monitoring prometheus, alertmanager and
+// operator are scheduled with.
+type Placement struct {
+	Tolerations  []corev1.Toleration
+	NodeSelector map[string]string
+}
+
+// placementTarget is a private struct of a resource of the rancher-monitoring chart whose pods are placed, with the
+// path of the pod spec fields in it and the label selector of its pods.
+type placementTarget struct {
+	groupVersionResource schema.GroupVersionResource
+	name                 string
+	specPath             []string
+	podLabelSelector     string
+}
+
+var placementTargets = []placementTarget{
+	{PrometheusGroupVersionResource, PrometheusName, []string{"spec"}, "app.kubernetes.io/name=prometheus"},
+	{alertmanagerGroupVersionResource, alertmanagerName, []string{"spec"}, "app.kubernetes.io/name=alertmanager"},
+	{deploymentGroupVersionResource, operatorDeploymentName, []string{"spec", "template", "spec"}, "app=" + operatorDeploymentName},
+}
+
+// NewControlPlanePlacement is a constructor that returns the placement tolerating the control plane and etcd taints of
+// RKE1, RKE2 and K3s nodes, with the node selector of the control plane nodes of the cluster, e.g.
+// {"node-role.kubernetes.io/control-plane": "true"}, for small clusters whose workers can't fit the monitoring stack.
+func NewControlPlanePlacement(nodeSelector map[string]string) *Placement {
+	var tolerations []corev1.Toleration
+	for _, taintKey := range []string{
+		"node-role.kubernetes.io/control-plane",
+		"node-role.kubernetes.io/controlplane",
+		"node-role.kubernetes.io/master",
+		"node-role.kubernetes.io/etcd",
+	} {
+		tolerations = append(tolerations, corev1.Toleration{Key: taintKey, Operator: corev1.TolerationOpExists})
+	}
+
+	return &Placement{
+		Tolerations:  tolerations,
+		NodeSelector: nodeSelector,
+	}
+}
+
+// SetPlacement is a helper function that sets the tolerations and node selector of the rancher-monitoring prometheus,
+// alertmanager and operator of the cluster and waits for their pods to be rescheduled. The shepherd monitoring options
+// can't carry them, so they are set on the installed chart. The previous placement is restored by the session of the client.
+func SetPlacement(client *rancher.Client, clusterID string, placement *Placement) error {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range placementTargets {
+		resource := dynamicClient.Resource(target.groupVersionResource).Namespace(charts.RancherMonitoringNamespace)
+
+		object, err := resource.Get(context.TODO(), target.name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		originalTolerations, _, err := unstructured.NestedFieldNoCopy(object.Object, append(target.specPath, "tolerations")...)
+		if err != nil {
+			return err
+		}
+
+		originalNodeSelector, _, err := unstructured.NestedStringMap(object.Object, append(target.specPath, "nodeSelector")...)
+		if err != nil {
+			return err
+		}
+
+		// keys only added by the placement are removed by setting them to null in the merge patch
+		restoredNodeSelector := map[string]interface{}{}
+		for key := range placement.NodeSelector {
+			restoredNodeSelector[key] = nil
+		}
+		for key, value := range originalNodeSelector {
+			restoredNodeSelector[key] = value
+		}
+
+		client.Session.RegisterCleanupFunc(func() error {
+			return patchPlacement(resource, target, originalTolerations, restoredNodeSelector)
+		})
+
+		err = patchPlacement(resource, target, placement.Tolerations, placement.NodeSelector)
+		if err != nil {
+			return err
+		}
+	}
+
+	return actionscharts.WatchAndWaitWorkloads(client, clusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
+}
+
+// VerifyPlacement is a helper function that checks every pod of the rancher-monitoring prometheus, alertmanager and
+// operator of the cluster runs on a node with the labels of the node selector.
+func VerifyPlacement(client *rancher.Client, clusterID string, nodeSelector map[string]string) error {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return err
+	}
+
+	podResource := dynamicClient.Resource(actionscharts.PodGroupVersionResource).Namespace(charts.RancherMonitoringNamespace)
+
+	for _, target := range placementTargets {
+		podList, err := podResource.List(context.TODO(), metav1.ListOptions{LabelSelector: target.podLabelSelector})
+		if err != nil {
+			return err
+		}
+
+		if len(podList.Items) == 0 {
+			return fmt.Errorf("no pods of %s match %q", target.name, target.podLabelSelector)
+		}
+
+		for _, pod := range podList.Items {
+			nodeName, _, err := unstructured.NestedString(pod.Object, "spec", "nodeName")
+			if err != nil {
+				return err
+			}
+
+			nodeObject, err := steveClient.SteveType(nodeSteveType).ByID(nodeName)
+			if err != nil {
+				return err
+			}
+
+			node := &corev1.Node{}
+			err = v1.ConvertToK8sType(nodeObject.JSONResp, node)
+			if err != nil {
+				return err
+			}
+
+			for key, value := range nodeSelector {
+				if node.Labels[key] != value {
+					return fmt.Errorf("pod %s of %s runs on node %s, which doesn't have label %s=%s", pod.GetName(), target.name, nodeName, key, value)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// patchPlacement is a private helper function that merge patches the tolerations and node selector of the pod spec of
+// the placement target.
+func patchPlacement(resource dynamic.ResourceInterface, target placementTarget, tolerations, nodeSelector any) error {
+	patch := map[string]interface{}{
+		"tolerations":  tolerations,
+		"nodeSelector": nodeSelector,
+	}
+	for i := len(target.specPath) - 1; i >= 0; i-- {
+		patch = map[string]interface{}{target.specPath[i]: patch}
+	}
+
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	_, err = resource.Patch(context.TODO(), target.name, k8stypes.MergePatchType, patchBytes, metav1.PatchOptions{})
+	return err
+}
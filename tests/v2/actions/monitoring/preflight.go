@@ -0,0 +1,41 @@
+package monitoring
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rancher/shepherd/pkg/nodes"
+)
+
+// NodeExporterPort is the host port the rancher-monitoring node-exporter listens on
+const NodeExporterPort = 9796
+
+const listeningSocketsCommandFormat = `sudo ss -Htlnp "sport = :%d"`
+
+// CheckNodeExporterPort is a helper function that checks the node-exporter host port is free on every node before
+// rancher-monitoring is installed, so a conflicting process fails the suite with a clear error instead of a node-exporter
+// stuck in a crash loop. The SSH nodes can be built with sshkeys.GetSSHNodeFromMachine.
+func CheckNodeExporterPort(sshNodes []*nodes.Node) error {
+	return CheckHostPortFree(sshNodes, NodeExporterPort)
+}
+
+// CheckHostPortFree is a helper function that checks no process listens on the TCP port of every node, returning the
+// processes listening on it, as reported by ss, of every node where it is taken.
+func CheckHostPortFree(sshNodes []*nodes.Node, port int) error {
+	var errs []error
+	for _, sshNode := range sshNodes {
+		output, err := sshNode.ExecuteCommand(fmt.Sprintf(listeningSocketsCommandFormat, port))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to list the sockets listening on port %d of node %s: %w", port, sshNode.NodeID, err))
+			continue
+		}
+
+		output = strings.TrimSpace(output)
+		if output != "" {
+			errs = append(errs, fmt.Errorf("port %d of node %s is already in use: %s", port, sshNode.NodeID, output))
+		}
+	}
+
+	return errors.Join(errs...)
+}
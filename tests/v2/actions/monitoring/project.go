@@ -0,0 +1,125 @@
+package monitoring
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/shepherd/clients/rancher"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	projectHelmChartSteveType  = "helm.cattle.io.projecthelmchart"
+	projectHelmChartAPIVersion = "helm.cattle.io/v1alpha1"
+	projectHelmChartKind       = "ProjectHelmChart"
+	projectHelmChartName       = "project-monitoring"
+	projectMonitoringAPI       = "monitoring.cattle.io/v1alpha1"
+	projectNamespacePrefix     = "cattle-project-"
+	projectMonitoringSuffix    = "-monitoring"
+
+	projectPrometheusPathFormat = "api/v1/namespaces/%[1]s/services/http:%[1]s-prometheus:9090/proxy"
+	projectGrafanaPathFormat    = "api/v1/namespaces/%[1]s/services/http:%[1]s-grafana:80/proxy/api/health"
+)
+
+// ProjectRegistrationNamespace is a helper function that returns the namespace prometheus-federator registers the
+// project monitoring of the project in, e.g. cattle-project-p-abcde for project c-abcde:p-abcde.
+func ProjectRegistrationNamespace(projectID string) string {
+	return projectNamespacePrefix + projectName(projectID)
+}
+
+// ProjectMonitoringNamespace is a helper function that returns the namespace the project monitoring stack of the project
+// is deployed in, e.g. cattle-project-p-abcde-monitoring.
+func ProjectMonitoringNamespace(projectID string) string {
+	return ProjectRegistrationNamespace(projectID) + projectMonitoringSuffix
+}
+
+// EnableProjectMonitoring is a helper function that creates the ProjectHelmChart deploying the project monitoring stack of
+// the project and waits for its workloads. The prometheus-federator chart must be installed in the cluster.
+func EnableProjectMonitoring(client *rancher.Client, clusterID, projectID string) error {
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return err
+	}
+
+	projectHelmChart := map[string]interface{}{
+		"apiVersion": projectHelmChartAPIVersion,
+		"kind":       projectHelmChartKind,
+		"metadata": metav1.ObjectMeta{
+			Name:      projectHelmChartName,
+			Namespace: ProjectRegistrationNamespace(projectID),
+		},
+		"spec": map[string]interface{}{
+			"helmApiVersion": projectMonitoringAPI,
+			"values":         map[string]interface{}{},
+		},
+	}
+
+	_, err = steveClient.SteveType(projectHelmChartSteveType).Create(projectHelmChart)
+	if err != nil {
+		return err
+	}
+
+	return actionscharts.WatchAndWaitWorkloads(client, clusterID, ProjectMonitoringNamespace(projectID), metav1.ListOptions{})
+}
+
+// QueryProjectPrometheus is a helper function that runs the instant query against the project prometheus of the project.
+func QueryProjectPrometheus(client *rancher.Client, clusterID, projectID, query string) ([]Sample, error) {
+	return queryPrometheus(client, clusterID, fmt.Sprintf(projectPrometheusPathFormat, ProjectMonitoringNamespace(projectID)), query)
+}
+
+// VerifyProjectPrometheusIsolation is a helper function that checks the project prometheus only scrapes and stores
+// metrics of the given namespaces of the project and of its own monitoring namespace.
+func VerifyProjectPrometheusIsolation(client *rancher.Client, clusterID, projectID string, projectNamespaces []string) error {
+	allowedNamespaces := append([]string{ProjectMonitoringNamespace(projectID)}, projectNamespaces...)
+
+	for _, query := range []string{`count by (namespace) (up)`, `count by (namespace) ({namespace!=""})`} {
+		samples, err := QueryProjectPrometheus(client, clusterID, projectID, query)
+		if err != nil {
+			return err
+		}
+
+		var foreignNamespaces []string
+		for _, sample := range samples {
+			namespace := sample.Metric["namespace"]
+			if namespace != "" && !slices.Contains(allowedNamespaces, namespace) {
+				foreignNamespaces = append(foreignNamespaces, namespace)
+			}
+		}
+
+		if len(foreignNamespaces) > 0 {
+			sort.Strings(foreignNamespaces)
+			return fmt.Errorf("project prometheus of %s returned namespaces outside of the project for %q: %s", projectID, query, strings.Join(foreignNamespaces, ", "))
+		}
+	}
+
+	return nil
+}
+
+// VerifyProjectGrafanaDenied is a helper function that checks the client, e.g. of a member of another project, can't
+// reach the project grafana of the project.
+func VerifyProjectGrafanaDenied(client *rancher.Client, clusterID, projectID string) error {
+	path := ClusterProxyPath(clusterID, fmt.Sprintf(projectGrafanaPathFormat, ProjectMonitoringNamespace(projectID)))
+
+	result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, path, true)
+	if err != nil {
+		return err
+	}
+
+	if result.Ok {
+		return fmt.Errorf("project grafana of %s is reachable by a user outside of the project", projectID)
+	}
+
+	return nil
+}
+
+// projectName is a private helper function that returns the name of the project from its ID, e.g. p-abcde for c-abcde:p-abcde.
+func projectName(projectID string) string {
+	if _, name, found := strings.Cut(projectID, ":"); found {
+		return name
+	}
+
+	return projectID
+}
@@ -0,0 +1,208 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// PrometheusServicePath is the path of the rancher-monitoring prometheus service proxied by the kubernetes API
+	PrometheusServicePath = "api/v1/namespaces/cattle-monitoring-system/services/http:rancher-monitoring-prometheus:9090/proxy"
+
+	prometheusQueryPath = "/api/v1/query"
+	prometheusFlagsPath = "/api/v1/status/flags"
+	successStatus       = "success"
+	vectorResultType    = "vector"
+)
+
+// Sample is a struct of a single sample of an instant vector returned by a prometheus query.
+type Sample struct {
+	Metric map[string]string
+	Value  float64
+}
+
+// flagsResponse is a private struct of the response of the prometheus flags API.
+type flagsResponse struct {
+	Status string            `json:"status"`
+	Data   map[string]string `json:"data"`
+}
+
+// queryResponse is a private struct of the response of the prometheus query API.
+type queryResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Value  []interface{}     `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// ClusterProxyPath is a helper function that returns the rancher proxy path of the service path in the cluster,
+// e.g. ClusterProxyPath("c-abcde", PrometheusServicePath).
+func ClusterProxyPath(clusterID, servicePath string) string {
+	return fmt.Sprintf("k8s/clusters/%s/%s", clusterID, servicePath)
+}
+
+// QueryPrometheus is a helper function that runs the instant query against the rancher-monitoring prometheus of the
+// cluster and returns the samples of the resulting vector.
+func QueryPrometheus(client *rancher.Client, clusterID, query string) ([]Sample, error) {
+	return queryPrometheus(client, clusterID, PrometheusServicePath, query)
+}
+
+// QueryPrometheusValue is a helper function that runs a query returning a single sample, e.g. an aggregation,
+// and returns its value.
+func QueryPrometheusValue(client *rancher.Client, clusterID, query string) (float64, error) {
+	samples, err := QueryPrometheus(client, clusterID, query)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(samples) != 1 {
+		return 0, fmt.Errorf("query %q returned %d samples, expected 1", query, len(samples))
+	}
+
+	return samples[0].Value, nil
+}
+
+// WaitForHealthyTargets is a helper function that waits until the rancher-monitoring prometheus of the cluster scrapes
+// at least one target matching the label matchers, e.g. `namespace="cattle-neuvector-system"`, and all of them are up.
+func WaitForHealthyTargets(client *rancher.Client, clusterID, labelMatchers string) error {
+	query := fmt.Sprintf("up{%s}", labelMatchers)
+
+	var samples []Sample
+	err := kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Timeout(), true, func(context.Context) (done bool, err error) {
+		samples, err = QueryPrometheus(client, clusterID, query)
+		if err != nil || len(samples) == 0 {
+			return false, nil
+		}
+
+		for _, sample := range samples {
+			if sample.Value != 1 {
+				return false, nil
+			}
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		var downTargets []string
+		for _, sample := range samples {
+			if sample.Value != 1 {
+				downTargets = append(downTargets, sample.Metric["instance"])
+			}
+		}
+
+		return fmt.Errorf("prometheus targets matching {%s} are not healthy, %d of %d are down %v: %w", labelMatchers, len(downTargets), len(samples), downTargets, err)
+	}
+
+	return nil
+}
+
+// GetPrometheusFlags is a helper function that returns the command line flags the rancher-monitoring prometheus of the
+// cluster runs with, by name without the leading dashes, e.g. storage.tsdb.retention.time.
+func GetPrometheusFlags(client *rancher.Client, clusterID string) (map[string]string, error) {
+	result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, ClusterProxyPath(clusterID, PrometheusServicePath)+prometheusFlagsPath, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if !result.Ok {
+		return nil, fmt.Errorf("flags of the prometheus of cluster %s are not available: %s", clusterID, result.Body)
+	}
+
+	response := &flagsResponse{}
+	err = json.Unmarshal([]byte(result.Body), response)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the flags of the prometheus of cluster %s: %w", clusterID, err)
+	}
+
+	if response.Status != successStatus {
+		return nil, fmt.Errorf("flags of the prometheus of cluster %s are not available: %s", clusterID, response.Status)
+	}
+
+	return response.Data, nil
+}
+
+// WaitForPrometheusFlag is a helper function that waits until the rancher-monitoring prometheus of the cluster runs with
+// the flag set to the value, e.g. once prometheus was rolled out with a new retention after a chart upgrade.
+func WaitForPrometheusFlag(client *rancher.Client, clusterID, flag, value string) error {
+	var current string
+	var lastErr error
+
+	return wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Timeout(), func(context.Context) (done bool, err error) {
+		var flags map[string]string
+		flags, lastErr = GetPrometheusFlags(client, clusterID)
+		if lastErr != nil {
+			return false, nil
+		}
+
+		current = flags[flag]
+
+		return current == value, nil
+	}, func() string {
+		if lastErr != nil {
+			return lastErr.Error()
+		}
+
+		return fmt.Sprintf("prometheus flag %s is %q, expected %q", flag, current, value)
+	})
+}
+
+// queryPrometheus is a private helper function that runs the instant query against the prometheus service of the
+// cluster and returns the samples of the resulting vector.
+func queryPrometheus(client *rancher.Client, clusterID, servicePath, query string) ([]Sample, error) {
+	path := ClusterProxyPath(clusterID, servicePath) + prometheusQueryPath + "?query=" + url.QueryEscape(query)
+
+	result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &queryResponse{}
+	err = json.Unmarshal([]byte(result.Body), response)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the response of query %q: %w", query, err)
+	}
+
+	if response.Status != successStatus {
+		return nil, fmt.Errorf("query %q failed: %s: %s", query, response.ErrorType, response.Error)
+	}
+
+	if response.Data.ResultType != vectorResultType {
+		return nil, fmt.Errorf("query %q returned a %s, expected a %s", query, response.Data.ResultType, vectorResultType)
+	}
+
+	var samples []Sample
+	for _, result := range response.Data.Result {
+		if len(result.Value) != 2 {
+			return nil, fmt.Errorf("query %q returned a malformed sample %v", query, result.Value)
+		}
+
+		valueString, ok := result.Value[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("query %q returned a malformed sample value %v", query, result.Value[1])
+		}
+
+		value, err := strconv.ParseFloat(valueString, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		samples = append(samples, Sample{Metric: result.Metric, Value: value})
+	}
+
+	return samples, nil
+}
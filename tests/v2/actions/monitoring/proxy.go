@@ -0,0 +1,102 @@
+package monitoring
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/pkg/config"
+)
+
+// The json/yaml config key for the proxy of the monitored cluster
+const ProxyConfigurationFileKey = "monitoringProxy"
+
+const (
+	notificationsSentQueryFormat   = `sum(alertmanager_notifications_total{integration=%q})`
+	notificationsFailedQueryFormat = `sum(alertmanager_notifications_failed_total{integration=%q})`
+	notificationsTimeout           = 5 * time.Minute
+)
+
+// ProxyConfig is the configuration of the HTTP(S) proxy the cluster reaches external services through, e.g.
+//
+//	monitoringProxy:
+//	  httpProxy: http://proxy.example.com:3128
+//	  httpsProxy: http://proxy.example.com:3128
+//	  noProxy: 127.0.0.0/8,10.0.0.0/8,cattle-system.svc,.svc,.cluster.local
+//
+// It should match the HTTP_PROXY, HTTPS_PROXY and NO_PROXY agent environment variables of the cluster. No proxy means
+// monitoring is validated without one.
+type ProxyConfig struct {
+	HTTPProxy  string `json:"httpProxy" yaml:"httpProxy"`
+	HTTPSProxy string `json:"httpsProxy" yaml:"httpsProxy"`
+	NoProxy    string `json:"noProxy" yaml:"noProxy"`
+}
+
+// GetProxyConfig is a helper function that reads the proxy configuration of the monitored cluster.
+func GetProxyConfig() *ProxyConfig {
+	proxyConfig := new(ProxyConfig)
+	config.LoadConfig(ProxyConfigurationFileKey, proxyConfig)
+
+	return proxyConfig
+}
+
+// Enabled returns true when the cluster reaches external services through a proxy.
+func (p *ProxyConfig) Enabled() bool {
+	return p.HTTPProxy != "" || p.HTTPSProxy != ""
+}
+
+// proxyURL is a private method that returns the proxy of the external receivers, which are usually served over HTTPS.
+func (p *ProxyConfig) proxyURL() string {
+	if p.HTTPSProxy != "" {
+		return p.HTTPSProxy
+	}
+
+	return p.HTTPProxy
+}
+
+// SetAlertmanagerProxy is a helper function that makes the rancher-monitoring alertmanager of the cluster send the
+// notifications of every receiver through the proxy, except to the hosts of its no proxy list, and waits until
+// alertmanager reloaded its configuration. Alertmanager ignores the proxy environment variables of the cluster, so
+// external receivers are unreachable without it. Nothing is changed when no proxy is configured.
+func SetAlertmanagerProxy(client *rancher.Client, clusterID string, proxyConfig *ProxyConfig) error {
+	if !proxyConfig.Enabled() {
+		return nil
+	}
+
+	return UpdateAlertmanagerConfig(client, clusterID, func(alertmanagerConfig *AlertmanagerConfig) *AlertmanagerConfig {
+		if alertmanagerConfig.Global == nil {
+			alertmanagerConfig.Global = &GlobalConfig{}
+		}
+
+		if alertmanagerConfig.Global.HTTPConfig == nil {
+			alertmanagerConfig.Global.HTTPConfig = &HTTPClientConfig{}
+		}
+
+		alertmanagerConfig.Global.HTTPConfig.ProxyURL = proxyConfig.proxyURL()
+		alertmanagerConfig.Global.HTTPConfig.NoProxy = proxyConfig.NoProxy
+
+		return alertmanagerConfig
+	})
+}
+
+// VerifyNotificationsSent is a helper function that checks the rancher-monitoring alertmanager of the cluster sent
+// notifications with the integration, e.g. "webhook" or "email", and none of them failed, which is how external
+// receivers reached through the proxy are validated. The alertmanager metrics are read from the rancher-monitoring
+// prometheus.
+func VerifyNotificationsSent(client *rancher.Client, clusterID, integration string) error {
+	err := AssertValueAbove(client, clusterID, fmt.Sprintf(notificationsSentQueryFormat, integration), 0, notificationsTimeout)
+	if err != nil {
+		return fmt.Errorf("alertmanager sent no %s notifications: %w", integration, err)
+	}
+
+	failed, err := QueryPrometheusValue(client, clusterID, fmt.Sprintf(notificationsFailedQueryFormat, integration))
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("alertmanager failed to send %v %s notifications, check the proxy of the cluster", failed, integration)
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/shepherd/clients/rancher"
+)
+
+// matchersMinVersion is the first alertmanager version that supports the matchers of routes; the match and match_re
+// of the versions before it are deprecated since.
+const matchersMinVersion = "0.22.0"
+
+// MatchType is the operator of a route matcher.
+type MatchType string
+
+const (
+	MatchEqual     MatchType = "="
+	MatchNotEqual  MatchType = "!="
+	MatchRegexp    MatchType = "=~"
+	MatchNotRegexp MatchType = "!~"
+)
+
+// Matcher is a struct of a condition on a label of the alerts a route matches.
+type Matcher struct {
+	Name  string
+	Type  MatchType
+	Value string
+}
+
+// String returns the matcher in the matchers syntax of alertmanager, e.g. team="qa".
+func (m Matcher) String() string {
+	return fmt.Sprintf("%s%s%q", m.Name, m.Type, m.Value)
+}
+
+// RouteBuilder is a builder of alertmanager routes that emits the legacy match and match_re syntax or the matchers one,
+// depending on the alertmanager version, so the routes tests add load on every chart version.
+type RouteBuilder struct {
+	route    Route
+	matchers []Matcher
+	children []*RouteBuilder
+	legacy   bool
+}
+
+// NewRouteBuilder is a constructor that returns the builder of a route to the receiver for the alertmanager version,
+// e.g. 0.27.0. Versions before 0.22.0 get the legacy match syntax.
+func NewRouteBuilder(receiver, alertmanagerVersion string) (*RouteBuilder, error) {
+	legacy, err := isLegacyMatchVersion(alertmanagerVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RouteBuilder{route: Route{Receiver: receiver}, legacy: legacy}, nil
+}
+
+// NewClusterRouteBuilder is a constructor that returns the builder of a route to the receiver for the version of the
+// rancher-monitoring alertmanager of the cluster.
+func NewClusterRouteBuilder(client *rancher.Client, clusterID, receiver string) (*RouteBuilder, error) {
+	alertmanagerVersion, err := GetAlertmanagerVersion(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRouteBuilder(receiver, alertmanagerVersion)
+}
+
+// Match adds a matcher of the alerts whose label equals the value.
+func (b *RouteBuilder) Match(name, value string) *RouteBuilder {
+	return b.Matcher(Matcher{Name: name, Type: MatchEqual, Value: value})
+}
+
+// MatchLabels adds a matcher of the alerts whose label equals the value, for every label.
+func (b *RouteBuilder) MatchLabels(labels map[string]string) *RouteBuilder {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		b.Match(name, labels[name])
+	}
+
+	return b
+}
+
+// MatchRegexp adds a matcher of the alerts whose label matches the regular expression.
+func (b *RouteBuilder) MatchRegexp(name, regexp string) *RouteBuilder {
+	return b.Matcher(Matcher{Name: name, Type: MatchRegexp, Value: regexp})
+}
+
+// Matcher adds the matcher. Negative matchers can't be expressed with the legacy syntax, so Build fails with them on
+// alertmanager versions before 0.22.0.
+func (b *RouteBuilder) Matcher(matcher Matcher) *RouteBuilder {
+	b.matchers = append(b.matchers, matcher)
+	return b
+}
+
+// GroupBy sets the labels the alerts of the route are grouped by.
+func (b *RouteBuilder) GroupBy(labels ...string) *RouteBuilder {
+	b.route.GroupByStr = labels
+	return b
+}
+
+// Timing sets the group wait, the group interval and the repeat interval of the route, the empty ones are inherited
+// from the parent route.
+func (b *RouteBuilder) Timing(groupWait, groupInterval, repeatInterval string) *RouteBuilder {
+	b.route.GroupWait = groupWait
+	b.route.GroupInterval = groupInterval
+	b.route.RepeatInterval = repeatInterval
+
+	return b
+}
+
+// Continue sets whether the alerts the route matches are matched against the next sibling routes too.
+func (b *RouteBuilder) Continue(continueMatching bool) *RouteBuilder {
+	b.route.Continue = continueMatching
+	return b
+}
+
+// Routes adds child routes, which are built with the syntax of the route.
+func (b *RouteBuilder) Routes(children ...*RouteBuilder) *RouteBuilder {
+	b.children = append(b.children, children...)
+	return b
+}
+
+// Build returns the route with its matchers in the syntax of the alertmanager version, and its child routes.
+func (b *RouteBuilder) Build() (*Route, error) {
+	route := b.route
+	route.Match = nil
+	route.MatchRE = nil
+	route.Matchers = nil
+	route.Routes = nil
+
+	if b.legacy {
+		var err error
+		route.Match, route.MatchRE, err = legacyMatch(b.matchers)
+		if err != nil {
+			return nil, fmt.Errorf("route to %s: %w", route.Receiver, err)
+		}
+	} else {
+		for _, matcher := range b.matchers {
+			route.Matchers = append(route.Matchers, matcher.String())
+		}
+	}
+
+	for _, child := range b.children {
+		child.legacy = b.legacy
+
+		childRoute, err := child.Build()
+		if err != nil {
+			return nil, err
+		}
+
+		route.Routes = append(route.Routes, childRoute)
+	}
+
+	return &route, nil
+}
+
+// GetAlertmanagerVersion is a helper function that returns the version of the rancher-monitoring alertmanager of the
+// cluster, e.g. 0.27.0, from its status API.
+func GetAlertmanagerVersion(client *rancher.Client, clusterID string) (string, error) {
+	path := ClusterProxyPath(clusterID, AlertmanagerServicePath) + alertmanagerStatusPath
+
+	result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, path, true)
+	if err != nil {
+		return "", err
+	}
+
+	if !result.Ok {
+		return "", fmt.Errorf("status of the alertmanager of cluster %s is not available: %s", clusterID, result.Body)
+	}
+
+	status := &alertmanagerStatus{}
+	err = json.Unmarshal([]byte(result.Body), status)
+	if err != nil {
+		return "", err
+	}
+
+	if status.VersionInfo.Version == "" {
+		return "", fmt.Errorf("alertmanager of cluster %s doesn't report its version", clusterID)
+	}
+
+	return status.VersionInfo.Version, nil
+}
+
+// isLegacyMatchVersion is a private helper function that returns true when the alertmanager version predates the
+// matchers of routes.
+func isLegacyMatchVersion(alertmanagerVersion string) (bool, error) {
+	version, err := semver.NewVersion(alertmanagerVersion)
+	if err != nil {
+		return false, fmt.Errorf("invalid alertmanager version %q: %w", alertmanagerVersion, err)
+	}
+
+	return version.LessThan(semver.MustParse(matchersMinVersion)), nil
+}
+
+// legacyMatch is a private helper function that returns the matchers in the legacy match and match_re syntax.
+func legacyMatch(matchers []Matcher) (map[string]string, map[string]string, error) {
+	var match, matchRE map[string]string
+	for _, matcher := range matchers {
+		switch matcher.Type {
+		case MatchEqual:
+			if match == nil {
+				match = map[string]string{}
+			}
+
+			match[matcher.Name] = matcher.Value
+		case MatchRegexp:
+			if matchRE == nil {
+				matchRE = map[string]string{}
+			}
+
+			matchRE[matcher.Name] = matcher.Value
+		default:
+			return nil, nil, fmt.Errorf("matcher %s needs alertmanager %s or later", matcher, matchersMinVersion)
+		}
+	}
+
+	return match, matchRE, nil
+}
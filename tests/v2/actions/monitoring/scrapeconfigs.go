@@ -0,0 +1,133 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"gopkg.in/yaml.v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// PrometheusName is the name of the prometheus resource of the rancher-monitoring chart
+	PrometheusName = "rancher-monitoring-prometheus"
+
+	additionalScrapeConfigsPrefix   = "additional-scrape-configs-"
+	additionalScrapeConfigsKey      = "scrape-configs.yaml"
+	scrapeConfigsSecretSuffixLength = 5
+	scrapeJobTimeout                = 3 * time.Minute
+)
+
+// PrometheusGroupVersionResource is the required Group Version Resource for accessing prometheus resources in a
+// cluster, using the dynamic client.
+var PrometheusGroupVersionResource = schema.GroupVersionResource{
+	Group:    "monitoring.coreos.com",
+	Version:  "v1",
+	Resource: "prometheuses",
+}
+
+// ScrapeConfig is a struct of a prometheus scrape job of static targets, e.g. an endpoint outside of the cluster.
+type ScrapeConfig struct {
+	JobName       string         `json:"job_name" yaml:"job_name"`
+	MetricsPath   string         `json:"metrics_path,omitempty" yaml:"metrics_path,omitempty"`
+	Scheme        string         `json:"scheme,omitempty" yaml:"scheme,omitempty"`
+	StaticConfigs []StaticConfig `json:"static_configs" yaml:"static_configs"`
+}
+
+// StaticConfig is a struct of the targets of a scrape job, as host:port addresses.
+type StaticConfig struct {
+	Targets []string `json:"targets" yaml:"targets"`
+}
+
+// SetAdditionalScrapeConfigs is a helper function that stores the scrape configs in a secret and sets it as the
+// additional scrape configs of the rancher-monitoring prometheus of the cluster. The shepherd monitoring options can't
+// carry them, so they are set on the installed chart. The secret and the setting are removed by the session of the client.
+func SetAdditionalScrapeConfigs(client *rancher.Client, clusterID string, scrapeConfigs []ScrapeConfig) error {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	scrapeConfigsBytes, err := yaml.Marshal(scrapeConfigs)
+	if err != nil {
+		return err
+	}
+
+	secretName := additionalScrapeConfigsPrefix + namegenerator.RandStringLower(scrapeConfigsSecretSuffixLength)
+	secretResource := dynamicClient.Resource(secretGroupVersionResource).Namespace(charts.RancherMonitoringNamespace)
+
+	_, err = secretResource.Create(context.TODO(), &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name":      secretName,
+			"namespace": charts.RancherMonitoringNamespace,
+		},
+		"stringData": map[string]interface{}{
+			additionalScrapeConfigsKey: string(scrapeConfigsBytes),
+		},
+	}}, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+
+	prometheusResource := dynamicClient.Resource(PrometheusGroupVersionResource).Namespace(charts.RancherMonitoringNamespace)
+
+	client.Session.RegisterCleanupFunc(func() error {
+		err := patchAdditionalScrapeConfigs(prometheusResource, nil)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		err = secretResource.Delete(context.TODO(), secretName, metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		return err
+	})
+
+	return patchAdditionalScrapeConfigs(prometheusResource, map[string]interface{}{
+		"name": secretName,
+		"key":  additionalScrapeConfigsKey,
+	})
+}
+
+// VerifyAdditionalScrapeJobs is a helper function that checks every job of the scrape configs appears in the
+// rancher-monitoring prometheus of the cluster with all of its targets up.
+func VerifyAdditionalScrapeJobs(client *rancher.Client, clusterID string, scrapeConfigs []ScrapeConfig) error {
+	for _, scrapeConfig := range scrapeConfigs {
+		err := AssertValueEquals(client, clusterID, fmt.Sprintf(`up{job=%q}`, scrapeConfig.JobName), 1, scrapeJobTimeout)
+		if err != nil {
+			return fmt.Errorf("additional scrape job %s is not up: %w", scrapeConfig.JobName, err)
+		}
+	}
+
+	return nil
+}
+
+// patchAdditionalScrapeConfigs is a private helper function that sets the secret key selector of the additional scrape
+// configs of the rancher-monitoring prometheus, or removes it when the selector is nil.
+func patchAdditionalScrapeConfigs(prometheusResource dynamic.ResourceInterface, secretKeySelector map[string]interface{}) error {
+	patchBytes, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"additionalScrapeConfigs": secretKeySelector,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = prometheusResource.Patch(context.TODO(), PrometheusName, k8stypes.MergePatchType, patchBytes, metav1.PatchOptions{})
+	return err
+}
@@ -0,0 +1,229 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/hardened"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/services"
+	"github.com/rancher/shepherd/extensions/workloads"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	smtpMockPrefix     = "smtp-mock-"
+	smtpMockImage      = "axllent/mailpit:v1.20"
+	smtpMockAppLabel   = "app"
+	smtpPort           = 1025
+	smtpAPIPort        = 8025
+	smtpPortName       = "smtp"
+	smtpAPIPortName    = "http"
+	smtpMessagesPath   = "/api/v1/messages"
+	smtpServicePath    = "api/v1/namespaces/%s/services/http:%s:%d/proxy"
+	emailSender        = "alertmanager@example.com"
+	emailTimeout       = 5 * time.Minute
+	emailGroupInterval = "10s"
+)
+
+// SMTPMock is a struct of a mock SMTP server deployed in a cluster, which accepts every email without authentication nor
+// TLS and serves the received ones on its HTTP API.
+type SMTPMock struct {
+	Name      string
+	Namespace string
+	ClusterID string
+}
+
+// SMTPMessage is a struct of an email received by the mock SMTP server.
+type SMTPMessage struct {
+	From    string
+	To      []string
+	Subject string
+	Snippet string
+}
+
+// smtpMessagesResponse is a private struct of the parts of the messages API of the mock SMTP server.
+type smtpMessagesResponse struct {
+	Messages []struct {
+		From struct {
+			Address string `json:"Address"`
+		} `json:"From"`
+		To []struct {
+			Address string `json:"Address"`
+		} `json:"To"`
+		Subject string `json:"Subject"`
+		Snippet string `json:"Snippet"`
+	} `json:"messages"`
+}
+
+// Smarthost returns the in-cluster address of the SMTP port of the mock SMTP server.
+func (s *SMTPMock) Smarthost() HostPort {
+	return HostPort{
+		Host: fmt.Sprintf("%s.%s.svc", s.Name, s.Namespace),
+		Port: strconv.Itoa(smtpPort),
+	}
+}
+
+// DeploySMTPMock is a helper function that deploys a mock SMTP server, mailpit, and its service in the namespace of the
+// cluster and waits for it to be ready. The deployment and the service are deleted by the session of the client.
+func DeploySMTPMock(client *rancher.Client, clusterID, namespace string) (*SMTPMock, error) {
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := namegenerator.AppendRandomString(smtpMockPrefix)
+	labels := map[string]string{smtpMockAppLabel: name}
+
+	container := workloads.NewContainer(name, smtpMockImage, corev1.PullIfNotPresent, nil, nil, nil, nil, nil)
+	container.Ports = []corev1.ContainerPort{
+		{Name: smtpPortName, ContainerPort: smtpPort},
+		{Name: smtpAPIPortName, ContainerPort: smtpAPIPort},
+	}
+
+	podTemplate := workloads.NewPodTemplate([]corev1.Container{container}, nil, nil, map[string]string{smtpMockAppLabel: name})
+	hardened.ApplyPodTemplate(&podTemplate)
+
+	deploymentTemplate := workloads.NewDeploymentTemplate(name, namespace, podTemplate, false, labels)
+
+	_, err = steveClient.SteveType(workloads.DeploymentSteveType).Create(deploymentTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceTemplate := services.NewServiceTemplate(name, namespace, corev1.ServiceTypeClusterIP, []corev1.ServicePort{
+		{Name: smtpPortName, Port: smtpPort},
+		{Name: smtpAPIPortName, Port: smtpAPIPort},
+	}, labels)
+
+	_, err = steveClient.SteveType(services.ServiceSteveType).Create(serviceTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	err = actionscharts.WatchAndWaitDeployments(client, clusterID, namespace, metav1.ListOptions{
+		FieldSelector: "metadata.name=" + name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SMTPMock{
+		Name:      name,
+		Namespace: namespace,
+		ClusterID: clusterID,
+	}, nil
+}
+
+// AddEmailReceiver is a helper function that adds an email receiver sending to the address through the mock SMTP server
+// to the rancher-monitoring alertmanager of the cluster, with a route of the alerts matching the matchers, e.g.
+// `alertname="Watchdog"`, and waits until alertmanager reloaded it.
+func AddEmailReceiver(client *rancher.Client, smtpMock *SMTPMock, receiverName, to string, matchers []string) error {
+	requireTLS := false
+
+	return UpdateAlertmanagerConfig(client, smtpMock.ClusterID, func(alertmanagerConfig *AlertmanagerConfig) *AlertmanagerConfig {
+		alertmanagerConfig.Receivers = append(alertmanagerConfig.Receivers, &Receiver{
+			Name: receiverName,
+			EmailConfigs: []*emailConfig{
+				{
+					To:         to,
+					From:       emailSender,
+					Smarthost:  smtpMock.Smarthost(),
+					RequireTLS: &requireTLS,
+				},
+			},
+		})
+
+		if alertmanagerConfig.Route == nil {
+			alertmanagerConfig.Route = &Route{}
+		}
+
+		alertmanagerConfig.Route.Routes = append(alertmanagerConfig.Route.Routes, &Route{
+			Receiver:      receiverName,
+			Matchers:      matchers,
+			GroupWait:     emailGroupInterval,
+			GroupInterval: emailGroupInterval,
+		})
+
+		return alertmanagerConfig
+	})
+}
+
+// GetSMTPMessages is a helper function that returns the emails received by the mock SMTP server, the latest first.
+func GetSMTPMessages(client *rancher.Client, smtpMock *SMTPMock) ([]SMTPMessage, error) {
+	servicePath := fmt.Sprintf(smtpServicePath, smtpMock.Namespace, smtpMock.Name, smtpAPIPort)
+
+	result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, ClusterProxyPath(smtpMock.ClusterID, servicePath)+smtpMessagesPath, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if !result.Ok {
+		return nil, fmt.Errorf("messages API of mock SMTP server %s is not available: %s", smtpMock.Name, result.Body)
+	}
+
+	response := &smtpMessagesResponse{}
+	err = json.Unmarshal([]byte(result.Body), response)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the messages of mock SMTP server %s: %w", smtpMock.Name, err)
+	}
+
+	var messages []SMTPMessage
+	for _, message := range response.Messages {
+		smtpMessage := SMTPMessage{
+			From:    message.From.Address,
+			Subject: message.Subject,
+			Snippet: message.Snippet,
+		}
+
+		for _, recipient := range message.To {
+			smtpMessage.To = append(smtpMessage.To, recipient.Address)
+		}
+
+		messages = append(messages, smtpMessage)
+	}
+
+	return messages, nil
+}
+
+// WaitForEmail is a helper function that waits until the mock SMTP server received an email to the address whose
+// subject contains the string, which may be empty, and returns it.
+func WaitForEmail(client *rancher.Client, smtpMock *SMTPMock, to, subjectContains string) (*SMTPMessage, error) {
+	var received *SMTPMessage
+	var messageCount int
+
+	err := wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(emailTimeout), func(context.Context) (done bool, err error) {
+		messages, err := GetSMTPMessages(client, smtpMock)
+		if err != nil {
+			return false, nil
+		}
+
+		messageCount = len(messages)
+		for i, message := range messages {
+			for _, recipient := range message.To {
+				if recipient == to && strings.Contains(message.Subject, subjectContains) {
+					received = &messages[i]
+					return true, nil
+				}
+			}
+		}
+
+		return false, nil
+	}, func() string {
+		return fmt.Sprintf("%d emails, none to %s with a subject containing %q", messageCount, to, subjectContains)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return received, nil
+}
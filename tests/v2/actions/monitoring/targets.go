@@ -0,0 +1,87 @@
+package monitoring
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/pkg/config"
+)
+
+// The json/yaml config key for the expected prometheus targets
+const TargetsConfigurationFileKey = "monitoringTargets"
+
+const jobHealthQuery = `min by (job) (up)`
+
+// TargetsConfig is the configuration of the scrape jobs the rancher-monitoring prometheus is expected to have, e.g.
+//
+//	monitoringTargets:
+//	  jobs:
+//	  - apiserver
+//	  - kube-etcd
+//	  - kubelet
+//
+// No jobs means the targets are not checked against an expected set.
+type TargetsConfig struct {
+	Jobs []string `json:"jobs" yaml:"jobs"`
+}
+
+// GetTargetsConfig is a helper function that reads the expected prometheus targets configuration.
+func GetTargetsConfig() *TargetsConfig {
+	targetsConfig := new(TargetsConfig)
+	config.LoadConfig(TargetsConfigurationFileKey, targetsConfig)
+
+	return targetsConfig
+}
+
+// VerifyExpectedTargets is a helper function that checks the scrape jobs of the rancher-monitoring prometheus of the
+// cluster are exactly the expected ones and every target of each job is up, so a missing exporter fails the check
+// instead of going unnoticed.
+func VerifyExpectedTargets(client *rancher.Client, clusterID string, jobs []string) error {
+	samples, err := QueryPrometheus(client, clusterID, jobHealthQuery)
+	if err != nil {
+		return err
+	}
+
+	var unexpectedJobs, downJobs []string
+	foundJobs := map[string]bool{}
+	for _, sample := range samples {
+		job := sample.Metric["job"]
+		foundJobs[job] = true
+
+		if !slices.Contains(jobs, job) {
+			unexpectedJobs = append(unexpectedJobs, job)
+		}
+
+		if sample.Value < 1 {
+			downJobs = append(downJobs, job)
+		}
+	}
+
+	var missingJobs []string
+	for _, job := range jobs {
+		if !foundJobs[job] {
+			missingJobs = append(missingJobs, job)
+		}
+	}
+
+	var errs []error
+	for _, jobsError := range []struct {
+		jobs    []string
+		message string
+	}{
+		{missingJobs, "missing"},
+		{unexpectedJobs, "unexpected"},
+		{downJobs, "with targets down"},
+	} {
+		if len(jobsError.jobs) > 0 {
+			sort.Strings(jobsError.jobs)
+			errs = append(errs, fmt.Errorf("prometheus jobs %s: %s", jobsError.message, strings.Join(jobsError.jobs, ", ")))
+		}
+	}
+
+	return errors.Join(errs...)
+}
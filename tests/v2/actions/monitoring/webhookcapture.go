@@ -0,0 +1,221 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	corev1 "k8s.io/api/core/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// WebhookCapturePort is the port the webhook capture container receives the alertmanager notifications and serves
+	// them on
+	WebhookCapturePort = 8001
+
+	webhookCaptureName         = "capture"
+	webhookCaptureImage        = "python:3.12-alpine"
+	webhookCapturePayloadsPath = "/payloads"
+	webhookCaptureServicePath  = "api/v1/namespaces/%s/services/http:%s:%d/proxy"
+	webhookAlertTimeout        = 5 * time.Minute
+
+	// webhookCaptureServer stores the body of every POST request and serves them all, oldest first, as a JSON array on
+	// GET /payloads
+	webhookCaptureServer = `
+import json, threading
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+
+payloads, lock = [], threading.Lock()
+
+class Handler(BaseHTTPRequestHandler):
+    def do_POST(self):
+        body = self.rfile.read(int(self.headers.get("Content-Length", 0)))
+        try:
+            payload = json.loads(body)
+        except ValueError:
+            payload = body.decode(errors="replace")
+        with lock:
+            payloads.append(payload)
+        self.send_response(200)
+        self.end_headers()
+
+    def do_GET(self):
+        if self.path != "/payloads":
+            self.send_response(404)
+            self.end_headers()
+            return
+        with lock:
+            body = json.dumps(payloads).encode()
+        self.send_response(200)
+        self.send_header("Content-Type", "application/json")
+        self.end_headers()
+        self.wfile.write(body)
+
+ThreadingHTTPServer(("", %d), Handler).serve_forever()
+`
+)
+
+// AlertWebhookPayload is a struct of the notification alertmanager posts to a webhook receiver.
+type AlertWebhookPayload struct {
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            []WebhookAlert    `json:"alerts"`
+}
+
+// WebhookAlert is a struct of an alert of a webhook notification.
+type WebhookAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// NewWebhookCaptureContainer is a constructor that returns the container of a webhook receiver that stores the
+// notifications it receives on WebhookCapturePort and serves them, so tests can assert on the labels and annotations of
+// the alerts. It is meant to be added to the pod of a webhook receiver fixture whose service exposes the port.
+func NewWebhookCaptureContainer() corev1.Container {
+	return corev1.Container{
+		Name:            webhookCaptureName,
+		Image:           webhookCaptureImage,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Command:         []string{"python3", "-u", "-c", fmt.Sprintf(webhookCaptureServer, WebhookCapturePort)},
+		Ports: []corev1.ContainerPort{
+			{
+				Name:          webhookCaptureName,
+				ContainerPort: WebhookCapturePort,
+				Protocol:      corev1.ProtocolTCP,
+			},
+		},
+	}
+}
+
+// WebhookCaptureURL returns the in-cluster URL alertmanager posts the notifications to for the webhook capture
+// container behind the service.
+func WebhookCaptureURL(namespace, serviceName string) string {
+	return fmt.Sprintf("http://%s.%s.svc:%s/", serviceName, namespace, strconv.Itoa(WebhookCapturePort))
+}
+
+// GetWebhookPayloads is a helper function that returns the notifications the webhook capture container behind the
+// service received, the oldest first, through the rancher proxy.
+func GetWebhookPayloads(client *rancher.Client, clusterID, namespace, serviceName string) ([]AlertWebhookPayload, error) {
+	servicePath := fmt.Sprintf(webhookCaptureServicePath, namespace, serviceName, WebhookCapturePort)
+
+	result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, ClusterProxyPath(clusterID, servicePath)+webhookCapturePayloadsPath, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if !result.Ok {
+		return nil, fmt.Errorf("payloads of webhook receiver %s/%s are not available: %s", namespace, serviceName, result.Body)
+	}
+
+	var payloads []AlertWebhookPayload
+	err = json.Unmarshal([]byte(result.Body), &payloads)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the payloads of webhook receiver %s/%s: %w", namespace, serviceName, err)
+	}
+
+	return payloads, nil
+}
+
+// WaitForWebhookAlert is a helper function that waits until the webhook capture container behind the service received
+// an alert having all the labels, e.g. the alertname and the labels of the rule, and returns it.
+func WaitForWebhookAlert(client *rancher.Client, clusterID, namespace, serviceName string, labels map[string]string) (*WebhookAlert, error) {
+	var received *WebhookAlert
+	var alertCount int
+
+	err := wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(webhookAlertTimeout), func(context.Context) (done bool, err error) {
+		payloads, err := GetWebhookPayloads(client, clusterID, namespace, serviceName)
+		if err != nil {
+			return false, nil
+		}
+
+		alertCount = 0
+		for _, payload := range payloads {
+			for i, alert := range payload.Alerts {
+				alertCount++
+				if hasLabels(alert.Labels, labels) {
+					received = &payload.Alerts[i]
+					return true, nil
+				}
+			}
+		}
+
+		return false, nil
+	}, func() string {
+		return fmt.Sprintf("%d alerts, none with labels %v", alertCount, labels)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return received, nil
+}
+
+// AssertWebhookAlertNotReceived is a helper function that checks the webhook capture container behind the service
+// receives no alert having all the labels for the duration, e.g. an inhibited or silenced alert, which should last at
+// least the group wait and group interval of its route so alertmanager had the chance to send it.
+func AssertWebhookAlertNotReceived(client *rancher.Client, clusterID, namespace, serviceName string, labels map[string]string, during time.Duration) error {
+	var received *WebhookAlert
+	var lastErr error
+
+	err := kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), during, true, func(context.Context) (done bool, err error) {
+		payloads, err := GetWebhookPayloads(client, clusterID, namespace, serviceName)
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+
+		lastErr = nil
+		for _, payload := range payloads {
+			for i, alert := range payload.Alerts {
+				if hasLabels(alert.Labels, labels) {
+					received = &payload.Alerts[i]
+					return true, nil
+				}
+			}
+		}
+
+		return false, nil
+	})
+	if received != nil {
+		return fmt.Errorf("webhook receiver %s/%s received the %s alert with labels %v", namespace, serviceName, received.Status, labels)
+	}
+
+	if !kwait.Interrupted(err) {
+		return err
+	}
+
+	if lastErr != nil {
+		return fmt.Errorf("payloads of webhook receiver %s/%s could not be checked: %w", namespace, serviceName, lastErr)
+	}
+
+	return nil
+}
+
+// hasLabels is a private helper function that returns true when the labels hold every expected label.
+func hasLabels(labels, expected map[string]string) bool {
+	for key, value := range expected {
+		if labels[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,113 @@
+package namer
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/rancher/rancher/tests/v2/actions/sessionid"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+)
+
+const (
+	// maxNameLength is the maximum length of a DNS-1123 label, which most kubernetes resource names must be
+	maxNameLength = 63
+	// sessionTagLength is the length of the tag of the session ID that every name carries
+	sessionTagLength = 6
+	randomLength     = 5
+	maxAttempts      = 10
+	defaultPrefix    = "test"
+	sessionIDPrefix  = "test-session-"
+)
+
+var (
+	invalidNameCharacters = regexp.MustCompile(`[^a-z0-9-]+`)
+
+	usedNamesMutex sync.Mutex
+	usedNames      = map[string]bool{}
+)
+
+// Namer is a struct that generates the names of the resources created by a test. Every name carries a tag of the
+// session ID of the client, so leaked resources can be attributed to the test session that created them, e.g. with the
+// audit log.
+type Namer struct {
+	sessionTag string
+}
+
+// New is a constructor that returns a namer tagging the names with the session ID of the client. When the client has
+// no session ID header, a random tag is used instead.
+func New(client *rancher.Client) *Namer {
+	sessionTag := strings.TrimPrefix(sessionid.GetSessionID(client), sessionIDPrefix)
+	sessionTag = sanitize(sessionTag)
+	if len(sessionTag) > sessionTagLength {
+		sessionTag = sessionTag[len(sessionTag)-sessionTagLength:]
+	}
+
+	if sessionTag == "" {
+		sessionTag = namegenerator.RandStringLower(sessionTagLength)
+	}
+
+	return &Namer{sessionTag: sessionTag}
+}
+
+// SessionTag returns the tag of the session ID the names of the namer carry.
+func (n *Namer) SessionTag() string {
+	return n.sessionTag
+}
+
+// Name returns a DNS-1123 label name made of the prefix, the session tag and a random string, e.g.
+// "alert-receiver-x7k2q9-abcde". The name is never returned twice by the namers of the test binary.
+func (n *Namer) Name(prefix string) string {
+	return n.NameWithSuffix(prefix, "")
+}
+
+// NameWithSuffix returns a DNS-1123 label name made of the prefix, the session tag, a random string and the suffix,
+// e.g. "alert-receiver-x7k2q9-abcde-sa". The prefix is shortened when the name would be longer than 63 characters. The
+// name is never returned twice by the namers of the test binary.
+func (n *Namer) NameWithSuffix(prefix, suffix string) string {
+	prefix = sanitize(prefix)
+	if prefix == "" {
+		prefix = defaultPrefix
+	}
+
+	suffix = sanitize(suffix)
+	if suffix != "" {
+		suffix = "-" + suffix
+	}
+
+	usedNamesMutex.Lock()
+	defer usedNamesMutex.Unlock()
+
+	// the random string is lengthened after every maxAttempts collisions, so an unused name is always found
+	length := randomLength
+	for attempt := 1; ; attempt++ {
+		name := n.buildName(prefix, suffix, length)
+		if !usedNames[name] {
+			usedNames[name] = true
+			return name
+		}
+
+		if attempt%maxAttempts == 0 {
+			length++
+		}
+	}
+}
+
+// buildName is a private method that returns the name made of the prefix, the session tag, a random string of the
+// length and the suffix, shortening the prefix so the name is at most 63 characters.
+func (n *Namer) buildName(prefix, suffix string, length int) string {
+	// the prefix is shortened so the session tag, the random string and the suffix always fit
+	maxPrefixLength := maxNameLength - len(n.sessionTag) - length - len(suffix) - 2
+	if len(prefix) > maxPrefixLength {
+		prefix = strings.Trim(prefix[:maxPrefixLength], "-")
+	}
+
+	return prefix + "-" + n.sessionTag + "-" + namegenerator.RandStringLower(length) + suffix
+}
+
+// sanitize is a private helper function that lowercases the string and replaces the characters a DNS-1123 label can't
+// hold with dashes.
+func sanitize(s string) string {
+	return strings.Trim(invalidNameCharacters.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
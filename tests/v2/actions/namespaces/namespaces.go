@@ -0,0 +1,112 @@
+package namespaces
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rancher/rancher/tests/v2/actions/downstream"
+	"github.com/rancher/rancher/tests/v2/actions/namer"
+	"github.com/rancher/rancher/tests/v2/actions/sessionid"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ProjectIDAnnotation is the annotation that binds a namespace to a rancher project, set to <clusterID>:<projectID>
+	ProjectIDAnnotation = "field.cattle.io/projectId"
+	// ProjectIDLabel is the label rancher sets to the ID of the project of a namespace once it has bound it
+	ProjectIDLabel = "field.cattle.io/projectId"
+
+	namespaceDeletionTimeout = 10 * time.Minute
+)
+
+// CreateTestNamespace is a helper function that creates a namespace with a unique name made of the prefix and the test
+// session tag, labeled with the session ID of the client and bound to the project, and waits until rancher bound it.
+// The namespace is deleted by the session of the client, which waits until it is gone so the next test doesn't race
+// its termination.
+func CreateTestNamespace(client *rancher.Client, project *management.Project, prefix string) (*corev1.Namespace, error) {
+	clientset, err := downstream.GetClientset(client, project.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{}
+	if sessionID := sessionid.GetSessionID(client); sessionID != "" {
+		labels[sessionid.LabelKey] = sessionID
+	}
+
+	namespace, err := clientset.CoreV1().Namespaces().Create(context.TODO(), &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        namer.New(client).Name(prefix),
+			Labels:      labels,
+			Annotations: map[string]string{ProjectIDAnnotation: project.ID},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		return DeleteNamespace(clientset, namespace.Name)
+	})
+
+	_, projectID, _ := strings.Cut(project.ID, ":")
+
+	err = wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Timeout(), func(ctx context.Context) (done bool, err error) {
+		current, err := clientset.CoreV1().Namespaces().Get(ctx, namespace.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		namespace = current
+
+		return namespace.Status.Phase == corev1.NamespaceActive && namespace.Labels[ProjectIDLabel] == projectID, nil
+	}, func() string {
+		return fmt.Sprintf("namespace %s is %s in project %q", namespace.Name, namespace.Status.Phase, namespace.Labels[ProjectIDLabel])
+	})
+	if err != nil {
+		return nil, fmt.Errorf("namespace %s was not bound to project %s: %w", namespace.Name, project.ID, err)
+	}
+
+	return namespace, nil
+}
+
+// DeleteNamespace is a helper function that deletes the namespace and waits until it is gone, including the time its
+// resources take to be finalized. Namespaces that are gone already are ignored.
+func DeleteNamespace(clientset *kubernetes.Clientset, name string) error {
+	err := clientset.CoreV1().Namespaces().Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var phase corev1.NamespacePhase
+
+	return wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(namespaceDeletionTimeout), func(ctx context.Context) (done bool, err error) {
+		namespace, err := clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		phase = namespace.Status.Phase
+
+		return false, nil
+	}, func() string {
+		return fmt.Sprintf("namespace %s is %s", name, phase)
+	})
+}
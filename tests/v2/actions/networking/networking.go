@@ -0,0 +1,146 @@
+package networking
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/extensions/provisioninginput"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	clusterCIDRKey = "cluster-cidr"
+	serviceCIDRKey = "service-cidr"
+	cidrSeparator  = ","
+)
+
+// SetDualStackCIDRs is a helper function that sets the pod and service CIDRs of the cluster config, one per IP family,
+// e.g. []string{"10.42.0.0/16", "2001:cafe:42::/56"}, and applies them with ApplyClusterCIDRs.
+func SetDualStackCIDRs(clusterConfig *clusters.ClusterConfig, clusterCIDRs, serviceCIDRs []string) error {
+	for _, cidr := range append(append([]string{}, clusterCIDRs...), serviceCIDRs...) {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return err
+		}
+	}
+
+	networking := &provisioninginput.Networking{}
+	if clusterConfig.Networking != nil {
+		*networking = *clusterConfig.Networking
+	}
+
+	networking.ClusterCIDR = strings.Join(clusterCIDRs, cidrSeparator)
+	networking.ServiceCIDR = strings.Join(serviceCIDRs, cidrSeparator)
+	clusterConfig.Networking = networking
+
+	ApplyClusterCIDRs(clusterConfig)
+
+	return nil
+}
+
+// ApplyClusterCIDRs is a helper function that copies the pod and service CIDRs of the networking config to the machine
+// global config of the cluster config, as the rke2 and k3s provisioning helpers don't read them from the networking config.
+// The advanced config is copied before it's changed, since it's shared with the provisioning input it was converted from.
+func ApplyClusterCIDRs(clusterConfig *clusters.ClusterConfig) {
+	if clusterConfig.Networking == nil || (clusterConfig.Networking.ClusterCIDR == "" && clusterConfig.Networking.ServiceCIDR == "") {
+		return
+	}
+
+	advanced := &provisioninginput.Advanced{}
+	if clusterConfig.Advanced != nil {
+		*advanced = *clusterConfig.Advanced
+	}
+
+	machineGlobalConfig := &rkev1.GenericMap{Data: map[string]interface{}{}}
+	if advanced.MachineGlobalConfig != nil {
+		for key, value := range advanced.MachineGlobalConfig.Data {
+			machineGlobalConfig.Data[key] = value
+		}
+	}
+
+	if clusterConfig.Networking.ClusterCIDR != "" {
+		machineGlobalConfig.Data[clusterCIDRKey] = clusterConfig.Networking.ClusterCIDR
+	}
+
+	if clusterConfig.Networking.ServiceCIDR != "" {
+		machineGlobalConfig.Data[serviceCIDRKey] = clusterConfig.Networking.ServiceCIDR
+	}
+
+	advanced.MachineGlobalConfig = machineGlobalConfig
+	clusterConfig.Advanced = advanced
+}
+
+// IsDualStack is a helper function that checks if the comma separated CIDRs cover both IP families.
+func IsDualStack(cidrs string) bool {
+	var hasIPv4, hasIPv6 bool
+	for _, cidr := range strings.Split(cidrs, cidrSeparator) {
+		ip, _, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			continue
+		}
+
+		if ip.To4() != nil {
+			hasIPv4 = true
+		} else {
+			hasIPv6 = true
+		}
+	}
+
+	return hasIPv4 && hasIPv6
+}
+
+// GetIPFamily is a helper function that returns the IP family of the address.
+func GetIPFamily(address string) (corev1.IPFamily, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return "", fmt.Errorf("invalid IP address %q", address)
+	}
+
+	if ip.To4() != nil {
+		return corev1.IPv4Protocol, nil
+	}
+
+	return corev1.IPv6Protocol, nil
+}
+
+// SelectAddress is a helper function that returns the first address of the IP family, e.g. to pick the IPv6 address
+// of a dual-stack node or service.
+func SelectAddress(addresses []string, family corev1.IPFamily) (string, error) {
+	for _, address := range addresses {
+		addressFamily, err := GetIPFamily(address)
+		if err != nil {
+			continue
+		}
+
+		if addressFamily == family {
+			return address, nil
+		}
+	}
+
+	return "", fmt.Errorf("no %s address in %v", family, addresses)
+}
+
+// HostPort is a helper function that joins the host and port, bracketing IPv6 addresses, e.g. [2001:db8::1]:30080.
+func HostPort(host string, port int32) string {
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
+
+// URL is a helper function that returns the URL of the path on the host and port, bracketing IPv6 addresses.
+func URL(scheme, host string, port int32, path string) string {
+	return fmt.Sprintf("%s://%s/%s", scheme, HostPort(host, port), strings.TrimPrefix(path, "/"))
+}
+
+// SetServiceIPFamilies is a helper function that sets the IP families of the service, requiring dual-stack when
+// both families are given.
+func SetServiceIPFamilies(service *corev1.Service, families ...corev1.IPFamily) {
+	policy := corev1.IPFamilyPolicySingleStack
+	if len(families) > 1 {
+		policy = corev1.IPFamilyPolicyRequireDualStack
+	}
+
+	service.Spec.IPFamilyPolicy = &policy
+	service.Spec.IPFamilies = families
+}
@@ -0,0 +1,121 @@
+package networking
+
+import (
+	"testing"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/extensions/provisioninginput"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyClusterCIDRs(t *testing.T) {
+	tests := []struct {
+		name       string
+		networking *provisioninginput.Networking
+		advanced   *provisioninginput.Advanced
+		want       map[string]interface{}
+	}{
+		{
+			name:       "no networking",
+			networking: nil,
+			want:       nil,
+		},
+		{
+			name:       "no CIDRs",
+			networking: &provisioninginput.Networking{},
+			want:       nil,
+		},
+		{
+			name:       "cluster and service CIDRs",
+			networking: &provisioninginput.Networking{ClusterCIDR: "10.42.0.0/16", ServiceCIDR: "10.43.0.0/16"},
+			want:       map[string]interface{}{clusterCIDRKey: "10.42.0.0/16", serviceCIDRKey: "10.43.0.0/16"},
+		},
+		{
+			name:       "cluster CIDR only",
+			networking: &provisioninginput.Networking{ClusterCIDR: "10.42.0.0/16,2001:cafe:42::/56"},
+			want:       map[string]interface{}{clusterCIDRKey: "10.42.0.0/16,2001:cafe:42::/56"},
+		},
+		{
+			name:       "machine global config is kept",
+			networking: &provisioninginput.Networking{ServiceCIDR: "10.43.0.0/16"},
+			advanced: &provisioninginput.Advanced{
+				MachineGlobalConfig: &rkev1.GenericMap{Data: map[string]interface{}{"cni": "calico", serviceCIDRKey: "10.96.0.0/12"}},
+			},
+			want: map[string]interface{}{"cni": "calico", serviceCIDRKey: "10.43.0.0/16"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusterConfig := &clusters.ClusterConfig{Networking: tt.networking, Advanced: tt.advanced}
+
+			ApplyClusterCIDRs(clusterConfig)
+
+			if tt.want == nil {
+				assert.Equal(t, tt.advanced, clusterConfig.Advanced)
+				return
+			}
+
+			assert.Equal(t, tt.want, clusterConfig.Advanced.MachineGlobalConfig.Data)
+		})
+	}
+}
+
+func TestApplyClusterCIDRsCopiesAdvanced(t *testing.T) {
+	advanced := &provisioninginput.Advanced{
+		MachineGlobalConfig: &rkev1.GenericMap{Data: map[string]interface{}{"cni": "calico"}},
+	}
+	clusterConfig := &clusters.ClusterConfig{
+		Networking: &provisioninginput.Networking{ClusterCIDR: "10.42.0.0/16"},
+		Advanced:   advanced,
+	}
+
+	ApplyClusterCIDRs(clusterConfig)
+
+	assert.Equal(t, map[string]interface{}{"cni": "calico"}, advanced.MachineGlobalConfig.Data)
+	assert.NotSame(t, advanced, clusterConfig.Advanced)
+}
+
+func TestIsDualStack(t *testing.T) {
+	tests := []struct {
+		name  string
+		cidrs string
+		want  bool
+	}{
+		{
+			name:  "empty",
+			cidrs: "",
+			want:  false,
+		},
+		{
+			name:  "IPv4 only",
+			cidrs: "10.42.0.0/16",
+			want:  false,
+		},
+		{
+			name:  "IPv6 only",
+			cidrs: "2001:cafe:42::/56",
+			want:  false,
+		},
+		{
+			name:  "both families",
+			cidrs: "10.42.0.0/16,2001:cafe:42::/56",
+			want:  true,
+		},
+		{
+			name:  "both families with spaces",
+			cidrs: "2001:cafe:42::/56, 10.42.0.0/16",
+			want:  true,
+		},
+		{
+			name:  "invalid CIDR is ignored",
+			cidrs: "10.42.0.0/16,not-a-cidr",
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsDualStack(tt.cidrs))
+		})
+	}
+}
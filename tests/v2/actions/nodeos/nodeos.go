@@ -0,0 +1,135 @@
+package nodeos
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	Linux   = "linux"
+	Windows = "windows"
+
+	// LinuxNodeTaintKey is the key of the taint rancher adds to the linux nodes of clusters that prefer windows workloads
+	LinuxNodeTaintKey = "cattle.io/os"
+
+	// LinuxImage is the default image of test workloads scheduled on linux nodes
+	LinuxImage = "nginx"
+	// WindowsImage is the default image of test workloads scheduled on windows nodes
+	WindowsImage = "mcr.microsoft.com/windows/servercore/iis"
+
+	// defaultArchitecture is the architecture of the nodes without the kubernetes.io/arch label
+	defaultArchitecture = "amd64"
+
+	nodeSteveType  = "node"
+	windowsDrive   = "C:"
+	localClusterID = "local"
+)
+
+// GetNodeOSes is a helper function that returns the names of the nodes of the cluster grouped by their operating system,
+// as reported by the kubernetes.io/os label.
+func GetNodeOSes(client *rancher.Client, clusterID string) (map[string][]string, error) {
+	return groupNodesByLabel(client, clusterID, corev1.LabelOSStable, Linux)
+}
+
+// GetNodeArchitectures is a helper function that returns the names of the nodes of the cluster grouped by their
+// architecture, as reported by the kubernetes.io/arch label.
+func GetNodeArchitectures(client *rancher.Client, clusterID string) (map[string][]string, error) {
+	return groupNodesByLabel(client, clusterID, corev1.LabelArchStable, defaultArchitecture)
+}
+
+// HasWindowsNodes is a helper function that checks if the cluster has at least one windows node.
+func HasWindowsNodes(client *rancher.Client, clusterID string) (bool, error) {
+	nodeOSes, err := GetNodeOSes(client, clusterID)
+	if err != nil {
+		return false, err
+	}
+
+	return len(nodeOSes[Windows]) > 0, nil
+}
+
+// SetPodTemplateOS is a helper function that schedules the pod template on nodes of the given operating system.
+// Pods pinned to linux also tolerate the taint rancher adds to linux nodes of windows preferred clusters.
+func SetPodTemplateOS(template *corev1.PodTemplateSpec, nodeOS string) error {
+	if nodeOS != Linux && nodeOS != Windows {
+		return fmt.Errorf("unsupported node operating system %q", nodeOS)
+	}
+
+	if template.Spec.NodeSelector == nil {
+		template.Spec.NodeSelector = map[string]string{}
+	}
+
+	template.Spec.NodeSelector[corev1.LabelOSStable] = nodeOS
+
+	if nodeOS == Linux {
+		template.Spec.Tolerations = append(template.Spec.Tolerations, corev1.Toleration{
+			Key:      LinuxNodeTaintKey,
+			Operator: corev1.TolerationOpEqual,
+			Value:    Linux,
+			Effect:   corev1.TaintEffectNoSchedule,
+		})
+	}
+
+	return nil
+}
+
+// DefaultImage is a helper function that returns the default test workload image of the operating system.
+func DefaultImage(nodeOS string) string {
+	if nodeOS == Windows {
+		return WindowsImage
+	}
+
+	return LinuxImage
+}
+
+// ShellCommand is a helper function that returns the container command running the script with the shell of the operating system.
+func ShellCommand(nodeOS, script string) []string {
+	if nodeOS == Windows {
+		return []string{"powershell.exe", "-Command", script}
+	}
+
+	return []string{"/bin/sh", "-c", script}
+}
+
+// ContainerPath is a helper function that joins the path elements into an absolute path inside a container of the operating system,
+// e.g. ContainerPath(Windows, "var", "log") returns C:\var\log.
+func ContainerPath(nodeOS string, elems ...string) string {
+	if nodeOS == Windows {
+		return windowsDrive + `\` + strings.Join(elems, `\`)
+	}
+
+	return path.Join(append([]string{"/"}, elems...)...)
+}
+
+// groupNodesByLabel is a private helper function that groups the names of the nodes of the cluster by the value of the label,
+// nodes without the label are grouped under the default value.
+func groupNodesByLabel(client *rancher.Client, clusterID, labelKey, defaultValue string) (map[string][]string, error) {
+	steveClient := client.Steve
+	if clusterID != localClusterID {
+		var err error
+		steveClient, err = client.Steve.ProxyDownstream(clusterID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	nodeList, err := steveClient.SteveType(nodeSteveType).List(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	groupedNodes := map[string][]string{}
+	for _, node := range nodeList.Data {
+		value := node.Labels[labelKey]
+		if value == "" {
+			value = defaultValue
+		}
+
+		groupedNodes[value] = append(groupedNodes[value], node.Name)
+	}
+
+	return groupedNodes, nil
+}
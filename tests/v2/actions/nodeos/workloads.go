@@ -0,0 +1,55 @@
+package nodeos
+
+import (
+	"fmt"
+
+	"github.com/rancher/shepherd/extensions/services"
+	"github.com/rancher/shepherd/extensions/workloads"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// NewContainer is a constructor that behaves as workloads.NewContainer for a container of the given operating system.
+// An empty image is replaced by the default image of the operating system, and the script, if any, is run with its shell.
+func NewContainer(nodeOS, containerName, image string, imagePullPolicy corev1.PullPolicy, volumeMounts []corev1.VolumeMount, envFrom []corev1.EnvFromSource, script string) corev1.Container {
+	if image == "" {
+		image = DefaultImage(nodeOS)
+	}
+
+	var command []string
+	if script != "" {
+		command = ShellCommand(nodeOS, script)
+	}
+
+	return workloads.NewContainer(containerName, image, imagePullPolicy, volumeMounts, envFrom, command, nil, nil)
+}
+
+// NewPodTemplate is a constructor that behaves as workloads.NewPodTemplate, scheduling the pod template on nodes of
+// the given operating system with SetPodTemplateOS.
+func NewPodTemplate(nodeOS string, containers []corev1.Container, volumes []corev1.Volume, imagePullSecrets []corev1.LocalObjectReference, labels map[string]string) (corev1.PodTemplateSpec, error) {
+	template := workloads.NewPodTemplate(containers, volumes, imagePullSecrets, labels)
+
+	err := SetPodTemplateOS(&template, nodeOS)
+	if err != nil {
+		return corev1.PodTemplateSpec{}, err
+	}
+
+	return template, nil
+}
+
+// NewServiceTemplate is a constructor that behaves as services.NewServiceTemplate for a service selecting pods of the
+// given operating system. Windows nodes don't support SCTP, so it returns an error for SCTP ports of windows services.
+func NewServiceTemplate(nodeOS, serviceName, namespaceName string, serviceType corev1.ServiceType, ports []corev1.ServicePort, selector map[string]string) (corev1.Service, error) {
+	if nodeOS != Linux && nodeOS != Windows {
+		return corev1.Service{}, fmt.Errorf("unsupported node operating system %q", nodeOS)
+	}
+
+	if nodeOS == Windows {
+		for _, port := range ports {
+			if port.Protocol == corev1.ProtocolSCTP {
+				return corev1.Service{}, fmt.Errorf("port %s of service %s uses SCTP, which windows nodes don't support", port.Name, serviceName)
+			}
+		}
+	}
+
+	return services.NewServiceTemplate(serviceName, namespaceName, serviceType, ports, selector), nil
+}
@@ -0,0 +1,183 @@
+package nodes
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/rancher/norman/types"
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	rkeExternalIPAnnotation = "rke.cattle.io/external-ip"
+	rkeInternalIPAnnotation = "rke.cattle.io/internal-ip"
+	k3sExternalIPAnnotation = "k3s.io/external-ip"
+	k3sInternalIPAnnotation = "k3s.io/internal-ip"
+
+	nodeSteveType  = "node"
+	localClusterID = "local"
+)
+
+// publicIPAnnotations are the annotations RKE1, RKE2 and K3s set to the public IP of a node, in order of preference.
+var publicIPAnnotations = []string{rkeExternalIPAnnotation, k3sExternalIPAnnotation}
+
+// privateIPAnnotations are the annotations RKE1, RKE2 and K3s set to the private IP of a node, in order of preference.
+var privateIPAnnotations = []string{rkeInternalIPAnnotation, k3sInternalIPAnnotation}
+
+// Addresses is a struct of the addresses of a node.
+type Addresses struct {
+	// Name is the name of the kubernetes node
+	Name string
+	// PublicIP is the address the node is reachable at from outside the cluster, it is the private IP when the node
+	// has no other address, e.g. on clusters whose nodes are all on the network of the test runner
+	PublicIP string
+	// PrivateIP is the address of the node on the network of the cluster
+	PrivateIP string
+	// Hostname is the hostname of the node, if reported
+	Hostname string
+}
+
+// GetNodeAddresses is a helper function that resolves the addresses of every node of the cluster, for RKE1, RKE2, K3s and
+// hosted clusters alike. The public IP is taken from, in order, the external IP annotations of RKE and K3s, the external
+// IP status address reported by the cloud provider and the external IP rancher reports for the machine of the node,
+// falling back to the private IP. The private IP is taken from the internal IP annotations, the internal IP status
+// address and the IP rancher reports.
+func GetNodeAddresses(client *rancher.Client, clusterID string) ([]Addresses, error) {
+	steveClient := client.Steve
+	if clusterID != localClusterID {
+		var err error
+		steveClient, err = client.Steve.ProxyDownstream(clusterID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	nodeList, err := steveClient.SteveType(nodeSteveType).List(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	machineIPs, err := getMachineIPs(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodeAddresses []Addresses
+	for _, nodeObject := range nodeList.Data {
+		node := &corev1.Node{}
+		err = v1.ConvertToK8sType(nodeObject.JSONResp, node)
+		if err != nil {
+			return nil, err
+		}
+
+		addresses := Addresses{
+			Name:      node.Name,
+			PublicIP:  firstNonEmpty(annotationValue(node, publicIPAnnotations), statusAddress(node, corev1.NodeExternalIP), machineIPs[node.Name].public),
+			PrivateIP: firstNonEmpty(annotationValue(node, privateIPAnnotations), statusAddress(node, corev1.NodeInternalIP), machineIPs[node.Name].private),
+			Hostname:  statusAddress(node, corev1.NodeHostName),
+		}
+
+		if addresses.PublicIP == "" {
+			addresses.PublicIP = addresses.PrivateIP
+		}
+
+		nodeAddresses = append(nodeAddresses, addresses)
+	}
+
+	return nodeAddresses, nil
+}
+
+// GetPublicIPs is a helper function that returns the public IP of every node of the cluster that has one.
+func GetPublicIPs(client *rancher.Client, clusterID string) ([]string, error) {
+	nodeAddresses, err := GetNodeAddresses(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	var publicIPs []string
+	for _, addresses := range nodeAddresses {
+		if addresses.PublicIP != "" {
+			publicIPs = append(publicIPs, addresses.PublicIP)
+		}
+	}
+
+	return publicIPs, nil
+}
+
+// GetRandomPublicIP is a helper function that returns the public IP of a random node of the cluster, e.g. to reach a
+// node port service from the test runner.
+func GetRandomPublicIP(client *rancher.Client, clusterID string) (string, error) {
+	publicIPs, err := GetPublicIPs(client, clusterID)
+	if err != nil {
+		return "", err
+	}
+
+	if len(publicIPs) == 0 {
+		return "", fmt.Errorf("no node of cluster %s has an address", clusterID)
+	}
+
+	return publicIPs[rand.Intn(len(publicIPs))], nil
+}
+
+// machineIP is a private struct of the addresses rancher reports for the machine of a node.
+type machineIP struct {
+	public  string
+	private string
+}
+
+// getMachineIPs is a private helper function that returns the addresses rancher reports for the machines of the nodes of
+// the cluster, by node name. They come from the node driver or the node agent, so they are set for clusters whose nodes
+// don't have the address annotations.
+func getMachineIPs(client *rancher.Client, clusterID string) (map[string]machineIP, error) {
+	nodeCollection, err := client.Management.Node.List(&types.ListOpts{Filters: map[string]interface{}{
+		"clusterId": clusterID,
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	machineIPs := map[string]machineIP{}
+	for _, node := range nodeCollection.Data {
+		machineIPs[node.NodeName] = machineIP{public: node.ExternalIPAddress, private: node.IPAddress}
+	}
+
+	return machineIPs, nil
+}
+
+// annotationValue is a private helper function that returns the value of the first of the annotations the node has,
+// annotations listing several addresses, e.g. dual-stack ones, are trimmed to their first address.
+func annotationValue(node *corev1.Node, annotations []string) string {
+	for _, annotation := range annotations {
+		if value := node.Annotations[annotation]; value != "" {
+			address, _, _ := strings.Cut(value, ",")
+			return address
+		}
+	}
+
+	return ""
+}
+
+// statusAddress is a private helper function that returns the first address of the type in the status of the node.
+func statusAddress(node *corev1.Node, addressType corev1.NodeAddressType) string {
+	for _, address := range node.Status.Addresses {
+		if address.Type == addressType {
+			return address.Address
+		}
+	}
+
+	return ""
+}
+
+// firstNonEmpty is a private helper function that returns the first of the values that is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,200 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/pkg/nodes"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const rebootCommand = "sudo reboot"
+
+// SystemNamespaces are the namespaces of the system charts whose pods on a rebooted node, e.g. node-exporter and
+// fluentbit, are waited for by RebootNode by default.
+var SystemNamespaces = []string{charts.RancherMonitoringNamespace, charts.RancherLoggingNamespace}
+
+// Rebooter is the interface of the ways to reboot a node, e.g. over SSH or through the API of its cloud provider.
+type Rebooter interface {
+	Reboot(nodeName string) error
+}
+
+// RebooterFunc is a function that reboots a node, e.g. one rebooting the instance of the node with the API of its cloud
+// provider, used as a Rebooter.
+type RebooterFunc func(nodeName string) error
+
+// Reboot calls the function with the name of the node.
+func (f RebooterFunc) Reboot(nodeName string) error {
+	return f(nodeName)
+}
+
+// SSHRebooter is a Rebooter that reboots the node over SSH. The SSH node can be built with
+// sshkeys.GetSSHNodeFromMachine.
+type SSHRebooter struct {
+	Node *nodes.Node
+}
+
+// Reboot runs the reboot command on the node. The connection is closed by the reboot, so the error of the command is
+// ignored, the reboot is confirmed by the boot ID of the node instead.
+func (r *SSHRebooter) Reboot(string) error {
+	_, _ = r.Node.ExecuteCommand(rebootCommand)
+
+	return nil
+}
+
+// RebootOptions is a struct of the options of RebootNode.
+type RebootOptions struct {
+	// Namespaces are the namespaces whose pods on the node are waited for after the reboot, SystemNamespaces when nil
+	Namespaces []string
+	// SLO is the time the node and its pods have to recover in, the default timeout of the timeouts package when zero
+	SLO time.Duration
+}
+
+// RebootNode is a helper function that reboots the node of the cluster with the rebooter and waits within the SLO of
+// the options for the node to rejoin the cluster, i.e. to be ready with a new boot ID, and then for its pods in the
+// namespaces of the options, e.g. the node-exporter and fluentbit pods of the system charts, to be ready again. The
+// reboot is detected from the boot ID rather than from the node going not ready, so a node rebooting faster than the
+// node monitor grace period isn't missed. It returns the time the recovery took.
+func RebootNode(client *rancher.Client, clusterID, nodeName string, rebooter Rebooter, rebootOptions *RebootOptions) (time.Duration, error) {
+	steveClient := client.Steve
+	if clusterID != localClusterID {
+		var err error
+		steveClient, err = client.Steve.ProxyDownstream(clusterID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	namespaces := SystemNamespaces
+	slo := timeouts.Timeout()
+	if rebootOptions != nil {
+		if rebootOptions.Namespaces != nil {
+			namespaces = rebootOptions.Namespaces
+		}
+
+		if rebootOptions.SLO > 0 {
+			slo = rebootOptions.SLO
+		}
+	}
+
+	node, err := getNode(steveClient, nodeName)
+	if err != nil {
+		return 0, err
+	}
+
+	bootID := node.Status.NodeInfo.BootID
+
+	logrus.Infof("Rebooting node %s", nodeName)
+
+	start := time.Now()
+	err = rebooter.Reboot(nodeName)
+	if err != nil {
+		return 0, fmt.Errorf("unable to reboot node %s: %w", nodeName, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), slo)
+	defer cancel()
+
+	err = kwait.PollUntilContextCancel(ctx, timeouts.PollInterval(), true, func(context.Context) (done bool, err error) {
+		node, err := getNode(steveClient, nodeName)
+		if err != nil {
+			return false, nil
+		}
+
+		return node.Status.NodeInfo.BootID != bootID && isNodeReady(node), nil
+	})
+	if err != nil {
+		return time.Since(start), fmt.Errorf("node %s did not rejoin the cluster within %s of its reboot: %w", nodeName, slo, err)
+	}
+
+	logrus.Infof("Node %s rejoined the cluster in %s", nodeName, time.Since(start).Round(time.Second))
+
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return time.Since(start), err
+	}
+
+	var unready []string
+	err = kwait.PollUntilContextCancel(ctx, timeouts.PollInterval(), true, func(ctx context.Context) (done bool, err error) {
+		unready = nil
+		for _, namespace := range namespaces {
+			podList, err := dynamicClient.Resource(corev1.SchemeGroupVersion.WithResource("pods")).Namespace(namespace).List(ctx, metav1.ListOptions{
+				FieldSelector: "spec.nodeName=" + nodeName,
+			})
+			if err != nil {
+				return false, nil
+			}
+
+			for _, unstructuredPod := range podList.Items {
+				pod := &corev1.Pod{}
+				err = v1.ConvertToK8sType(unstructuredPod.Object, pod)
+				if err != nil {
+					return false, err
+				}
+
+				if !isPodRecovered(pod, start) {
+					unready = append(unready, namespace+"/"+pod.Name)
+				}
+			}
+		}
+
+		return len(unready) == 0, nil
+	})
+	if err != nil {
+		sort.Strings(unready)
+		return time.Since(start), fmt.Errorf("pods of node %s did not recover within %s of its reboot, still not ready: %v: %w", nodeName, slo, unready, err)
+	}
+
+	logrus.Infof("Node %s and its pods recovered from the reboot in %s", nodeName, time.Since(start).Round(time.Second))
+
+	return time.Since(start), nil
+}
+
+// getNode is a private helper function that returns the node of the steve client.
+func getNode(steveClient *v1.Client, nodeName string) (*corev1.Node, error) {
+	nodeObject, err := steveClient.SteveType(nodeSteveType).ByID(nodeName)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &corev1.Node{}
+	err = v1.ConvertToK8sType(nodeObject.JSONResp, node)
+
+	return node, err
+}
+
+// isNodeReady is a private helper function that checks the ready condition of the node.
+func isNodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// isPodRecovered is a private helper function that checks the pod has completed, or became ready after the reboot, as
+// the ready condition of a pod is only updated once the kubelet of its node is back.
+func isPodRecovered(pod *corev1.Pod, rebootTime time.Time) bool {
+	if pod.Status.Phase == corev1.PodSucceeded {
+		return true
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue && condition.LastTransitionTime.Time.After(rebootTime)
+		}
+	}
+
+	return false
+}
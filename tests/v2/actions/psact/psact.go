@@ -0,0 +1,260 @@
+package psact
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apiv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/pkg/clientbase"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	// PrivilegedLevel is the pod security standard level that allows every pod
+	PrivilegedLevel = "privileged"
+	// BaselineLevel is the pod security standard level that prevents known privilege escalations
+	BaselineLevel = "baseline"
+	// RestrictedLevel is the pod security standard level that enforces pod hardening best practices
+	RestrictedLevel = "restricted"
+
+	latestVersion                 = "latest"
+	podSteveType                  = "pod"
+	provisioningNamespace         = "fleet-default"
+	provisioningSteveResourceType = "provisioning.cattle.io.cluster"
+	psactFieldName                = "defaultPodSecurityAdmissionConfigurationTemplateName"
+	podSecurityViolation          = "violates PodSecurity"
+	testPodPrefix                 = "psact-"
+	testPodImage                  = "registry.k8s.io/pause:3.9"
+	assignTimeout                 = 30 * time.Minute
+)
+
+// NewConfiguration is a constructor that returns a PSACT configuration enforcing, auditing and warning on the level for
+// every namespace but the exempted ones.
+func NewConfiguration(level string, exemptNamespaces ...string) *management.PodSecurityAdmissionConfigurationTemplateSpec {
+	return &management.PodSecurityAdmissionConfigurationTemplateSpec{
+		Defaults: &management.PodSecurityAdmissionConfigurationTemplateDefaults{
+			Enforce:        level,
+			EnforceVersion: latestVersion,
+			Audit:          level,
+			AuditVersion:   latestVersion,
+			Warn:           level,
+			WarnVersion:    latestVersion,
+		},
+		Exemptions: &management.PodSecurityAdmissionConfigurationTemplateExemptions{
+			Namespaces: exemptNamespaces,
+		},
+	}
+}
+
+// CreatePSACT is a helper function that creates a custom pod security admission configuration template with a random
+// name based on the prefix. The template is deleted by the session of the client.
+func CreatePSACT(client *rancher.Client, namePrefix string, configuration *management.PodSecurityAdmissionConfigurationTemplateSpec) (*management.PodSecurityAdmissionConfigurationTemplate, error) {
+	template, err := client.Management.PodSecurityAdmissionConfigurationTemplate.Create(&management.PodSecurityAdmissionConfigurationTemplate{
+		Name:          namegenerator.AppendRandomString(namePrefix),
+		Description:   "PSACT created by the validation suites",
+		Configuration: configuration,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		err := client.Management.PodSecurityAdmissionConfigurationTemplate.Delete(template)
+		if clientbase.IsNotFound(err) {
+			return nil
+		}
+
+		return err
+	})
+
+	return template, nil
+}
+
+// AssignPSACT is a helper function that sets the PSACT of the RKE1, RKE2 or K3s cluster, an empty name removing it, and
+// waits for the cluster to be ready again. The previous PSACT is assigned back by the session of the client, before the
+// template is deleted if it was created with CreatePSACT first.
+func AssignPSACT(client *rancher.Client, clusterName, psactName string) error {
+	previousPSACT, err := setPSACT(client, clusterName, psactName)
+	if err != nil {
+		return err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		_, err := setPSACT(client, clusterName, previousPSACT)
+		return err
+	})
+
+	return nil
+}
+
+// VerifyAdmission is a helper function that creates a privileged pod, or a pod compliant with the restricted level, in
+// the namespace of the cluster and checks pod security admission admits or denies it as expected. Admitted pods are
+// deleted right away.
+func VerifyAdmission(client *rancher.Client, clusterID, namespace string, privileged, expectAdmitted bool) error {
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return err
+	}
+
+	pod := newTestPod(namespace, privileged)
+
+	podObject, err := steveClient.SteveType(podSteveType).Create(pod)
+	if err == nil {
+		deleteErr := steveClient.SteveType(podSteveType).Delete(podObject)
+		if deleteErr != nil {
+			logrus.Warnf("Unable to delete pod %s/%s: %v", namespace, pod.Name, deleteErr)
+		}
+	}
+
+	switch {
+	case expectAdmitted && err != nil:
+		return fmt.Errorf("pod %s/%s (privileged: %t) was denied: %w", namespace, pod.Name, privileged, err)
+	case !expectAdmitted && err == nil:
+		return fmt.Errorf("pod %s/%s (privileged: %t) was admitted", namespace, pod.Name, privileged)
+	case !expectAdmitted && !strings.Contains(err.Error(), podSecurityViolation):
+		return fmt.Errorf("pod %s/%s (privileged: %t) was denied by something other than pod security admission: %w", namespace, pod.Name, privileged, err)
+	}
+
+	return nil
+}
+
+// VerifyEnforcement is a helper function that checks the namespace of the cluster admits the pods the level allows and
+// denies the others: a restricted pod is always admitted and a privileged pod only at the privileged level.
+func VerifyEnforcement(client *rancher.Client, clusterID, namespace, level string) error {
+	err := VerifyAdmission(client, clusterID, namespace, false, true)
+	if err != nil {
+		return err
+	}
+
+	return VerifyAdmission(client, clusterID, namespace, true, level == PrivilegedLevel)
+}
+
+// setPSACT is a private helper function that sets the PSACT of the cluster, waits for the cluster to be ready and
+// returns the previous PSACT.
+func setPSACT(client *rancher.Client, clusterName, psactName string) (string, error) {
+	adminClient, err := rancher.NewClient(client.RancherConfig.AdminToken, client.Session)
+	if err != nil {
+		return "", err
+	}
+
+	clusterMeta, err := clusters.NewClusterMeta(adminClient, clusterName)
+	if err != nil {
+		return "", err
+	}
+
+	logrus.Infof("Setting the PSACT of cluster %s to %q", clusterName, psactName)
+
+	if clusterMeta.Provider == clusters.KubernetesProviderRKE {
+		cluster, err := adminClient.Management.Cluster.ByID(clusterMeta.ID)
+		if err != nil {
+			return "", err
+		}
+
+		_, err = adminClient.Management.Cluster.Update(cluster, map[string]interface{}{
+			psactFieldName: psactName,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		return cluster.DefaultPodSecurityAdmissionConfigurationTemplateName, clusters.WaitClusterToBeUpgraded(adminClient, clusterMeta.ID)
+	}
+
+	id, err := clusters.GetV1ProvisioningClusterByName(adminClient, clusterName)
+	if err != nil {
+		return "", err
+	}
+
+	cluster, err := adminClient.Steve.SteveType(provisioningSteveResourceType).ByID(id)
+	if err != nil {
+		return "", err
+	}
+
+	clusterSpec := &apiv1.ClusterSpec{}
+	err = v1.ConvertToK8sType(cluster.Spec, clusterSpec)
+	if err != nil {
+		return "", err
+	}
+
+	previousPSACT := clusterSpec.DefaultPodSecurityAdmissionConfigurationTemplateName
+	clusterSpec.DefaultPodSecurityAdmissionConfigurationTemplateName = psactName
+
+	updatedCluster := *cluster
+	updatedCluster.Spec = *clusterSpec
+
+	_, err = adminClient.Steve.SteveType(provisioningSteveResourceType).Update(cluster, updatedCluster)
+	if err != nil {
+		return "", err
+	}
+
+	kubeProvisioningClient, err := adminClient.GetKubeAPIProvisioningClient()
+	if err != nil {
+		return "", err
+	}
+
+	err = kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Scale(assignTimeout), true, func(ctx context.Context) (done bool, err error) {
+		provisioningCluster, err := kubeProvisioningClient.Clusters(provisioningNamespace).Get(ctx, clusterName, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		return clusters.IsProvisioningClusterReady(watch.Event{Type: watch.Modified, Object: provisioningCluster})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return previousPSACT, nil
+}
+
+// newTestPod is a private constructor that returns a pod that runs with every privilege, or a pod that complies with
+// the restricted pod security standard.
+func newTestPod(namespace string, privileged bool) *corev1.Pod {
+	container := corev1.Container{
+		Name:  "pause",
+		Image: testPodImage,
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      namegenerator.AppendRandomString(testPodPrefix),
+			Namespace: namespace,
+		},
+	}
+
+	if privileged {
+		isPrivileged := true
+		container.SecurityContext = &corev1.SecurityContext{Privileged: &isPrivileged}
+		pod.Spec.HostNetwork = true
+	} else {
+		allowPrivilegeEscalation := false
+		runAsNonRoot := true
+		runAsUser := int64(65535)
+
+		container.SecurityContext = &corev1.SecurityContext{
+			AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+			Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+		}
+		pod.Spec.SecurityContext = &corev1.PodSecurityContext{
+			RunAsNonRoot:   &runAsNonRoot,
+			RunAsUser:      &runAsUser,
+			SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+		}
+	}
+
+	pod.Spec.Containers = []corev1.Container{container}
+
+	return pod
+}
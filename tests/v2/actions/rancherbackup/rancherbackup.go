@@ -0,0 +1,369 @@
+package rancherbackup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// The json/yaml config key for the storage location of the rancher-backup chart
+const ConfigurationFileKey = "rancherBackup"
+
+const (
+	// RancherBackupNamespace is the namespace the rancher-backup chart is installed in
+	RancherBackupNamespace = "cattle-resources-system"
+	// RancherBackupChartName is the name of the rancher-backup chart of the rancher charts repository and of its release
+	RancherBackupChartName = "rancher-backup"
+	// DefaultResourceSetName is the resource set of the rancher resources the rancher-backup chart creates
+	DefaultResourceSetName = "rancher-resource-set"
+
+	rancherBackupCRDChartName = "rancher-backup-crd"
+	localClusterID            = "local"
+	resourcesAPIVersion       = "resources.cattle.io/v1"
+	backupPrefix              = "backup-"
+	restorePrefix             = "restore-"
+	readyCondition            = "Ready"
+	reconcilingCondition      = "Reconciling"
+	conditionTrue             = "True"
+	errorReason               = "Error"
+	backupInstallTimeout      = 10 * time.Minute
+	backupTimeout             = 10 * time.Minute
+	restoreTimeout            = 20 * time.Minute
+)
+
+var (
+	// BackupGroupVersionResource is the required Group Version Resource for accessing rancher-backup backups in the
+	// local cluster, using the dynamic client.
+	BackupGroupVersionResource = schema.GroupVersionResource{
+		Group:    "resources.cattle.io",
+		Version:  "v1",
+		Resource: "backups",
+	}
+	// RestoreGroupVersionResource is the required Group Version Resource for accessing rancher-backup restores in the
+	// local cluster, using the dynamic client.
+	RestoreGroupVersionResource = schema.GroupVersionResource{
+		Group:    "resources.cattle.io",
+		Version:  "v1",
+		Resource: "restores",
+	}
+)
+
+// S3StorageLocation is a struct of the S3 compatible bucket backups are stored in.
+type S3StorageLocation struct {
+	// CredentialSecretName is the name of the secret with the accessKey and secretKey of the bucket, none when the
+	// nodes have an IAM role to access it
+	CredentialSecretName      string `json:"credentialSecretName" yaml:"credentialSecretName"`
+	CredentialSecretNamespace string `json:"credentialSecretNamespace" yaml:"credentialSecretNamespace"`
+	BucketName                string `json:"bucketName" yaml:"bucketName"`
+	// Folder is the folder of the bucket the backups are stored in, its root when empty
+	Folder   string `json:"folder" yaml:"folder"`
+	Region   string `json:"region" yaml:"region"`
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	// EndpointCA is the base64 encoded CA certificate of a self-signed endpoint
+	EndpointCA            string `json:"endpointCA" yaml:"endpointCA"`
+	InsecureTLSSkipVerify bool   `json:"insecureTLSSkipVerify" yaml:"insecureTLSSkipVerify"`
+}
+
+// StorageOpts is a struct of the default storage location of the rancher-backup chart, S3 or a persistent volume
+// when S3 is nil. It is read from the configuration file, e.g.
+//
+//	rancherBackup:
+//	  s3:
+//	    bucketName: rancher-backups
+//	    region: us-east-2
+//	    endpoint: s3.us-east-2.amazonaws.com
+//	    credentialSecretName: s3-creds
+//	    credentialSecretNamespace: default
+type StorageOpts struct {
+	S3 *S3StorageLocation `json:"s3" yaml:"s3"`
+	// StorageClassName is the storage class of the persistent volume, the default one when empty
+	StorageClassName string `json:"storageClassName" yaml:"storageClassName"`
+	// Size is the size of the persistent volume, 2Gi when empty
+	Size string `json:"size" yaml:"size"`
+}
+
+// GetStorageOpts returns the storage options of the configuration file.
+func GetStorageOpts() *StorageOpts {
+	storageOpts := new(StorageOpts)
+	config.LoadConfig(ConfigurationFileKey, storageOpts)
+
+	return storageOpts
+}
+
+// BackupOpts is a struct of the options of a backup.
+type BackupOpts struct {
+	// ResourceSetName is the resource set of the resources that are backed up, DefaultResourceSetName when empty
+	ResourceSetName string
+	// EncryptionConfigSecretName is the secret of the encryption configuration of the backup, none when empty
+	EncryptionConfigSecretName string
+	// S3 overrides the default storage location of the chart
+	S3 *S3StorageLocation
+}
+
+// RestoreOpts is a struct of the options of a restore.
+type RestoreOpts struct {
+	// Prune deletes the rancher resources that are not in the backup
+	Prune bool
+	// EncryptionConfigSecretName is the secret of the encryption configuration of the backup, none when empty
+	EncryptionConfigSecretName string
+	// S3 is the storage location of the backup, the default one of the chart when nil
+	S3 *S3StorageLocation
+}
+
+// Values returns the rancher-backup values of the default storage location.
+func (o *StorageOpts) Values() map[string]interface{} {
+	if o.S3 != nil {
+		s3Values := o.S3.object()
+		s3Values["enabled"] = true
+
+		return map[string]interface{}{"s3": s3Values}
+	}
+
+	size := o.Size
+	if size == "" {
+		size = "2Gi"
+	}
+
+	return map[string]interface{}{
+		"persistence": map[string]interface{}{
+			"enabled":      true,
+			"storageClass": o.StorageClassName,
+			"size":         size,
+		},
+	}
+}
+
+// InstallRancherBackupChart is a helper function that installs the rancher-backup CRD chart and the rancher-backup chart
+// in the cluster of the install options, the local one, with the storage options as the default storage location of
+// the backups, then waits for its operator. The latest version is installed when the install options have none. The
+// charts, and the cluster scoped resources they leave, are uninstalled by the session of the client. When the operation
+// options stream the logs, the logs of their helm operations are written to the test log while they install.
+func InstallRancherBackupChart(client *rancher.Client, installOptions *charts.InstallOptions, storageOpts *StorageOpts, operationOptions *actionscharts.OperationOptions) error {
+	for _, chartName := range []string{rancherBackupCRDChartName, RancherBackupChartName} {
+		var values map[string]interface{}
+		if chartName == RancherBackupChartName && storageOpts != nil {
+			values = storageOpts.Values()
+		}
+
+		err := actionscharts.InstallChart(client, &actionscharts.ChartInstallOptions{
+			InstallOptions: installOptions,
+			ChartName:      chartName,
+			Namespace:      RancherBackupNamespace,
+			Timeout:        backupInstallTimeout,
+			StreamLogs:     operationOptions.GetStreamLogs(),
+			ClusterScoped:  true,
+		}, values)
+		if err != nil {
+			return err
+		}
+	}
+
+	return actionscharts.WatchAndWaitWorkloads(client, installOptions.Cluster.ID, RancherBackupNamespace, metav1.ListOptions{})
+}
+
+// CreateBackup is a helper function that creates a one-time backup of the resources of the resource set of the options
+// and returns its name. The backup is deleted by the session of the client, its file is left in the storage location.
+func CreateBackup(client *rancher.Client, backupOpts *BackupOpts) (string, error) {
+	resourceSetName := backupOpts.ResourceSetName
+	if resourceSetName == "" {
+		resourceSetName = DefaultResourceSetName
+	}
+
+	spec := map[string]interface{}{
+		"resourceSetName": resourceSetName,
+	}
+
+	if backupOpts.EncryptionConfigSecretName != "" {
+		spec["encryptionConfigSecretName"] = backupOpts.EncryptionConfigSecretName
+	}
+
+	if backupOpts.S3 != nil {
+		spec["storageLocation"] = map[string]interface{}{"s3": backupOpts.S3.object()}
+	}
+
+	return createResource(client, BackupGroupVersionResource, "Backup", backupPrefix, spec)
+}
+
+// WaitForBackupComplete is a helper function that waits until the backup is ready and returns the name of its file in
+// the storage location. It fails as soon as the operator reports an error for the backup.
+func WaitForBackupComplete(client *rancher.Client, backupName string) (string, error) {
+	var filename string
+
+	err := waitForResource(client, BackupGroupVersionResource, backupName, backupTimeout, func(resource *unstructured.Unstructured) bool {
+		filename, _, _ = unstructured.NestedString(resource.Object, "status", "filename")
+		return filename != ""
+	})
+	if err != nil {
+		return "", err
+	}
+
+	logrus.Infof("Backup %s is complete with file %s", backupName, filename)
+
+	return filename, nil
+}
+
+// CreateRestore is a helper function that creates a restore of the backup file and returns its name. The restore is
+// deleted by the session of the client.
+func CreateRestore(client *rancher.Client, backupFilename string, restoreOpts *RestoreOpts) (string, error) {
+	spec := map[string]interface{}{
+		"backupFilename": backupFilename,
+		"prune":          restoreOpts.Prune,
+	}
+
+	if restoreOpts.EncryptionConfigSecretName != "" {
+		spec["encryptionConfigSecretName"] = restoreOpts.EncryptionConfigSecretName
+	}
+
+	if restoreOpts.S3 != nil {
+		spec["storageLocation"] = map[string]interface{}{"s3": restoreOpts.S3.object()}
+	}
+
+	return createResource(client, RestoreGroupVersionResource, "Restore", restorePrefix, spec)
+}
+
+// WaitForRestoreComplete is a helper function that waits until the restore is completed. It fails as soon as the
+// operator reports an error for the restore. The rancher API may be unavailable while the restore runs, so failed
+// requests are retried.
+func WaitForRestoreComplete(client *rancher.Client, restoreName string) error {
+	err := waitForResource(client, RestoreGroupVersionResource, restoreName, restoreTimeout, func(resource *unstructured.Unstructured) bool {
+		completion, _, _ := unstructured.NestedString(resource.Object, "status", "restoreCompletionTs")
+		return completion != ""
+	})
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Restore %s is complete", restoreName)
+
+	return nil
+}
+
+// createResource is a private helper function that creates the cluster scoped rancher-backup resource with the spec in
+// the local cluster and registers its deletion with the session of the client.
+func createResource(client *rancher.Client, groupVersionResource schema.GroupVersionResource, kind, prefix string, spec map[string]interface{}) (string, error) {
+	dynamicClient, err := getLocalDynamicClient(client)
+	if err != nil {
+		return "", err
+	}
+
+	resource := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": resourcesAPIVersion,
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name": namegenerator.AppendRandomString(prefix),
+		},
+		"spec": spec,
+	}}
+
+	resourceClient := dynamicClient.Resource(groupVersionResource)
+
+	created, err := resourceClient.Create(context.TODO(), resource, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", kind, err)
+	}
+
+	name := created.GetName()
+	client.Session.RegisterCleanupFunc(func() error {
+		err := resourceClient.Delete(context.TODO(), name, metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		return err
+	})
+
+	return name, nil
+}
+
+// waitForResource is a private helper function that waits until the rancher-backup resource is done, failing when its
+// ready or reconciling condition has the error reason.
+func waitForResource(client *rancher.Client, groupVersionResource schema.GroupVersionResource, name string, timeout time.Duration, isDone func(*unstructured.Unstructured) bool) error {
+	dynamicClient, err := getLocalDynamicClient(client)
+	if err != nil {
+		return err
+	}
+
+	var resource *unstructured.Unstructured
+	var lastErr error
+
+	return wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(timeout), func(ctx context.Context) (done bool, err error) {
+		resource, lastErr = dynamicClient.Resource(groupVersionResource).Get(ctx, name, metav1.GetOptions{})
+		if lastErr != nil {
+			return false, nil
+		}
+
+		conditions, _, _ := unstructured.NestedSlice(resource.Object, "status", "conditions")
+		for _, condition := range conditions {
+			conditionMap, ok := condition.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			conditionType := conditionMap["type"]
+			if (conditionType == readyCondition || conditionType == reconcilingCondition) && conditionMap["reason"] == errorReason {
+				return false, fmt.Errorf("%s %s failed: %v", groupVersionResource.Resource, name, conditionMap["message"])
+			}
+		}
+
+		return isDone(resource) && isConditionTrue(conditions, readyCondition), nil
+	}, func() string {
+		if resource == nil {
+			return fmt.Sprintf("%s %s has no status: %v", groupVersionResource.Resource, name, lastErr)
+		}
+
+		conditions, _, _ := unstructured.NestedSlice(resource.Object, "status", "conditions")
+
+		return fmt.Sprintf("%s %s is not done, conditions: %v", groupVersionResource.Resource, name, conditions)
+	})
+}
+
+// isConditionTrue is a private helper function that returns true when the condition of the type is true.
+func isConditionTrue(conditions []interface{}, conditionType string) bool {
+	for _, condition := range conditions {
+		conditionMap, ok := condition.(map[string]interface{})
+		if ok && conditionMap["type"] == conditionType {
+			return conditionMap["status"] == conditionTrue
+		}
+	}
+
+	return false
+}
+
+// getLocalDynamicClient is a private helper function that returns the dynamic client of the local cluster as the admin,
+// as backups and restores are cluster scoped.
+func getLocalDynamicClient(client *rancher.Client) (dynamic.Interface, error) {
+	adminClient, err := rancher.NewClient(client.RancherConfig.AdminToken, client.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	return adminClient.GetDownStreamClusterClient(localClusterID)
+}
+
+// object is a private method that returns the S3 storage location as it is set in the specs and the chart values.
+func (l *S3StorageLocation) object() map[string]interface{} {
+	return map[string]interface{}{
+		"credentialSecretName":      l.CredentialSecretName,
+		"credentialSecretNamespace": l.CredentialSecretNamespace,
+		"bucketName":                l.BucketName,
+		"folder":                    l.Folder,
+		"region":                    l.Region,
+		"endpoint":                  l.Endpoint,
+		"endpointCA":                l.EndpointCA,
+		"insecureTLSSkipVerify":     l.InsecureTLSSkipVerify,
+	}
+}
@@ -0,0 +1,302 @@
+package rancherupgrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/sirupsen/logrus"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+// The json/yaml config key for the rancher server upgrade
+const ConfigurationFileKey = "rancherUpgrade"
+
+// ErrHelmNotFound is the error UpgradeRancher returns when the helm command of the configuration is not installed, for
+// suites to skip on with errors.Is rather than fail on runners without helm.
+var ErrHelmNotFound = errors.New("helm command not found")
+
+const (
+	defaultChartRepoName = "rancher-latest"
+	defaultReleaseName   = "rancher"
+	defaultNamespace     = "cattle-system"
+	defaultHelmCommand   = "helm"
+	defaultTimeout       = 20 * time.Minute
+	apiPollInterval      = 10 * time.Second
+	// helmCommandGrace is how long the helm commands may run past the upgrade timeout, so helm reports its own timeout
+	helmCommandGrace = time.Minute
+
+	rancherChartName       = "rancher"
+	serverVersionSettingID = "server-version"
+)
+
+// Config is the configuration of the rancher server upgrade, e.g.
+//
+//	rancherUpgrade:
+//	  version: 2.9.1
+//	  kubeconfigPath: /path/to/local-cluster.yaml
+//	  kubeContext: local
+//	  chartRepoName: rancher-latest
+//	  chartRepoURL: https://releases.rancher.com/server-charts/latest
+//	  values:
+//	    auditLog.level: "1"
+//
+// The values of the installed release are reused, so only the values that change need to be set. The kubeconfig must
+// reach the local cluster directly, a kubeconfig proxied by rancher stops working while rancher restarts. The kube
+// context is the current one of the kubeconfig when it is not set.
+type Config struct {
+	Version        string            `json:"version" yaml:"version"`
+	ChartRepoName  string            `json:"chartRepoName" yaml:"chartRepoName"`
+	ChartRepoURL   string            `json:"chartRepoURL" yaml:"chartRepoURL"`
+	ReleaseName    string            `json:"releaseName" yaml:"releaseName"`
+	Namespace      string            `json:"namespace" yaml:"namespace"`
+	Values         map[string]string `json:"values" yaml:"values"`
+	ValuesFile     string            `json:"valuesFile" yaml:"valuesFile"`
+	HelmCommand    string            `json:"helmCommand" yaml:"helmCommand"`
+	KubeconfigPath string            `json:"kubeconfigPath" yaml:"kubeconfigPath"`
+	KubeContext    string            `json:"kubeContext" yaml:"kubeContext"`
+	Timeout        string            `json:"timeout" yaml:"timeout"`
+}
+
+// LoadConfig is a helper function that reads the rancher upgrade configuration and fills in the defaults.
+func LoadConfig() *Config {
+	upgradeConfig := new(Config)
+	config.LoadConfig(ConfigurationFileKey, upgradeConfig)
+	upgradeConfig.setDefaults()
+
+	return upgradeConfig
+}
+
+// UpgradeRancher is a helper function that upgrades the rancher server with helm, waits for the rollout and for the API
+// to serve the new version, and returns a new admin client. Clients created before the upgrade hold stale schemas,
+// they should be replaced with ReauthenticateClients. The helm commands are stopped if they run past the timeout of the
+// upgrade, and ErrHelmNotFound is returned when helm is not installed.
+func UpgradeRancher(client *rancher.Client, upgradeConfig *Config) (*rancher.Client, error) {
+	upgradeConfig.setDefaults()
+
+	err := upgradeConfig.lookPathHelm()
+	if err != nil {
+		return nil, err
+	}
+
+	if upgradeConfig.KubeconfigPath == "" {
+		return nil, fmt.Errorf("%s.kubeconfigPath is not set, the upgrade needs a kubeconfig of the local cluster that doesn't go through rancher", ConfigurationFileKey)
+	}
+
+	timeout, err := upgradeConfig.getTimeout()
+	if err != nil {
+		return nil, err
+	}
+
+	previousVersion, err := GetServerVersion(client)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+helmCommandGrace)
+	defer cancel()
+
+	if upgradeConfig.ChartRepoURL != "" {
+		err = runHelm(ctx, upgradeConfig, "repo", "add", upgradeConfig.ChartRepoName, upgradeConfig.ChartRepoURL, "--force-update")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = runHelm(ctx, upgradeConfig, "repo", "update", upgradeConfig.ChartRepoName)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Upgrading rancher from %s to %s", previousVersion, versionOrLatest(upgradeConfig.Version))
+	err = runHelm(ctx, upgradeConfig, upgradeConfig.upgradeArgs(timeout)...)
+	if err != nil {
+		return nil, err
+	}
+
+	upgradedClient, err := WaitForRancher(client, upgradeConfig.Version, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	currentVersion, err := GetServerVersion(upgradedClient)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Rancher upgraded from %s to %s", previousVersion, currentVersion)
+
+	return upgradedClient, nil
+}
+
+// WaitForRancher is a helper function that waits until the rancher API answers with a new admin client and, if the
+// expected version is set, the server reports it. It returns the new admin client.
+func WaitForRancher(client *rancher.Client, expectedVersion string, timeout time.Duration) (*rancher.Client, error) {
+	var upgradedClient *rancher.Client
+
+	err := kwait.PollUntilContextTimeout(context.TODO(), apiPollInterval, timeout, true, func(context.Context) (done bool, err error) {
+		adminClient, err := rancher.NewClient(client.RancherConfig.AdminToken, client.Session)
+		if err != nil {
+			return false, nil
+		}
+
+		isConnected, err := adminClient.IsConnected()
+		if err != nil || !isConnected {
+			return false, nil
+		}
+
+		if expectedVersion != "" {
+			serverVersion, err := GetServerVersion(adminClient)
+			if err != nil || !isSameVersion(serverVersion, expectedVersion) {
+				return false, nil
+			}
+		}
+
+		upgradedClient = adminClient
+
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rancher API is not available with version %s: %w", versionOrLatest(expectedVersion), err)
+	}
+
+	return upgradedClient, nil
+}
+
+// ReauthenticateClients is a helper function that returns new clients for the tokens of the given clients,
+// so they pick up the schemas of the upgraded rancher server.
+func ReauthenticateClients(clients ...*rancher.Client) ([]*rancher.Client, error) {
+	var reauthenticatedClients []*rancher.Client
+	for _, client := range clients {
+		reauthenticatedClient, err := client.ReLogin()
+		if err != nil {
+			return nil, err
+		}
+
+		reauthenticatedClients = append(reauthenticatedClients, reauthenticatedClient)
+	}
+
+	return reauthenticatedClients, nil
+}
+
+// GetServerVersion is a helper function that returns the version of the rancher server.
+func GetServerVersion(client *rancher.Client) (string, error) {
+	serverVersion, err := client.Management.Setting.ByID(serverVersionSettingID)
+	if err != nil {
+		return "", err
+	}
+
+	return serverVersion.Value, nil
+}
+
+// setDefaults is a private method that fills in the defaults of the unset fields.
+func (c *Config) setDefaults() {
+	if c.ChartRepoName == "" {
+		c.ChartRepoName = defaultChartRepoName
+	}
+
+	if c.ReleaseName == "" {
+		c.ReleaseName = defaultReleaseName
+	}
+
+	if c.Namespace == "" {
+		c.Namespace = defaultNamespace
+	}
+
+	if c.HelmCommand == "" {
+		c.HelmCommand = defaultHelmCommand
+	}
+}
+
+// getTimeout is a private method that returns the timeout of the upgrade scaled by the timeouts multiplier.
+func (c *Config) getTimeout() (time.Duration, error) {
+	if c.Timeout == "" {
+		return timeouts.Scale(defaultTimeout), nil
+	}
+
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil {
+		return 0, err
+	}
+
+	return timeouts.Scale(timeout), nil
+}
+
+// upgradeArgs is a private method that returns the arguments of the helm upgrade command.
+func (c *Config) upgradeArgs(timeout time.Duration) []string {
+	args := []string{
+		"upgrade", c.ReleaseName, c.ChartRepoName + "/" + rancherChartName,
+		"--namespace", c.Namespace,
+		"--reuse-values",
+		"--wait",
+		"--timeout", timeout.String(),
+	}
+
+	if c.Version != "" {
+		args = append(args, "--version", c.Version)
+	}
+
+	if c.ValuesFile != "" {
+		args = append(args, "--values", c.ValuesFile)
+	}
+
+	// values are sorted so the command is the same between runs
+	var keys []string
+	for key := range c.Values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		args = append(args, "--set", key+"="+c.Values[key])
+	}
+
+	return args
+}
+
+// lookPathHelm is a private method that returns ErrHelmNotFound when the helm command is not installed.
+func (c *Config) lookPathHelm() error {
+	_, err := exec.LookPath(c.HelmCommand)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrHelmNotFound, c.HelmCommand, err)
+	}
+
+	return nil
+}
+
+// runHelm is a private helper function that runs the helm command against the local cluster, killing it when the
+// context is done.
+func runHelm(ctx context.Context, upgradeConfig *Config, args ...string) error {
+	args = append(args, "--kubeconfig", upgradeConfig.KubeconfigPath)
+	if upgradeConfig.KubeContext != "" {
+		args = append(args, "--kube-context", upgradeConfig.KubeContext)
+	}
+
+	output, err := exec.CommandContext(ctx, upgradeConfig.HelmCommand, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %s", upgradeConfig.HelmCommand, args[0], err, string(output))
+	}
+
+	return nil
+}
+
+// isSameVersion is a private helper function that compares versions ignoring the v prefix, e.g. v2.9.1 and 2.9.1.
+func isSameVersion(version, otherVersion string) bool {
+	return strings.TrimPrefix(version, "v") == strings.TrimPrefix(otherVersion, "v")
+}
+
+// versionOrLatest is a private helper function that returns the version, or latest if it's not set.
+func versionOrLatest(version string) string {
+	if version == "" {
+		return "latest"
+	}
+
+	return version
+}
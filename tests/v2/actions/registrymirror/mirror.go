@@ -0,0 +1,335 @@
+package registrymirror
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	apiv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/extensions/unstructured"
+	"github.com/rancher/shepherd/extensions/workloads"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/sirupsen/logrus"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	copyJobPrefix                 = "mirror-images-"
+	copyCertsPath                 = "/registry-ca"
+	copyJobTimeout                = 15 * time.Minute
+	clusterRegistryTimeout        = 30 * time.Minute
+	provisioningNamespace         = "fleet-default"
+	provisioningSteveResourceType = "provisioning.cattle.io.cluster"
+	registryAuthSecretPrefix      = "registry-mirror-auth-"
+	dockerHubDomain               = "docker.io"
+	dockerHubLibrary              = "library/"
+)
+
+// MirroredImage returns the reference the nodes pull the mirrored copy of the image with, e.g.
+// docker.io/library/nginx:1.27 is pulled as <host>/library/nginx:1.27.
+func (r *Registry) MirroredImage(image string) string {
+	return r.Host + "/" + imageRepository(image)
+}
+
+// MirrorImages is a helper function that copies the images into the registry with a job running in its cluster, and
+// waits for the job to complete.
+func MirrorImages(client *rancher.Client, registry *Registry, images []string) error {
+	dynamicClient, err := client.GetDownStreamClusterClient(registry.ClusterID)
+	if err != nil {
+		return err
+	}
+
+	serviceHost := fmt.Sprintf("%s.%s.svc:%d", registryName, registry.Namespace, registryPort)
+
+	var copyCommands []string
+	for _, image := range images {
+		copyCommands = append(copyCommands, fmt.Sprintf("skopeo copy --all --dest-cert-dir %s --dest-creds %s:%s docker://%s docker://%s/%s",
+			copyCertsPath, registry.Username, registry.Password, image, serviceHost, imageRepository(image)))
+	}
+
+	jobName := copyJobPrefix + namegenerator.RandStringLower(5)
+	volumeMounts := []corev1.VolumeMount{{Name: registrySecretName, MountPath: copyCertsPath, ReadOnly: true}}
+	command := []string{"sh", "-c", strings.Join(copyCommands, " && ")}
+
+	job := workloads.NewJobTemplate(jobName, registry.Namespace)
+	job.TypeMeta = metav1.TypeMeta{APIVersion: batchv1.SchemeGroupVersion.String(), Kind: "Job"}
+	job.Spec.Template.Spec.Containers = []corev1.Container{
+		workloads.NewContainer(jobName, registry.copyImage, corev1.PullIfNotPresent, volumeMounts, nil, command, nil, nil),
+	}
+	// only the CA is mounted, skopeo would take the server key of the secret for a client certificate
+	job.Spec.Template.Spec.Volumes = []corev1.Volume{{
+		Name: registrySecretName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: registrySecretName,
+				Items:      []corev1.KeyToPath{{Key: caCertKey, Path: caCertKey}},
+			},
+		},
+	}}
+
+	jobResource := dynamicClient.Resource(batchv1.SchemeGroupVersion.WithResource("jobs")).Namespace(registry.Namespace)
+
+	_, err = jobResource.Create(context.TODO(), unstructured.MustToUnstructured(job), metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Mirroring %d images into registry %s", len(images), registry.Host)
+
+	return kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Scale(copyJobTimeout), true, func(ctx context.Context) (done bool, err error) {
+		jobObject, err := jobResource.Get(ctx, jobName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		copyJob := &batchv1.Job{}
+		err = v1.ConvertToK8sType(jobObject.Object, copyJob)
+		if err != nil {
+			return false, err
+		}
+
+		for _, condition := range copyJob.Status.Conditions {
+			if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+				return false, fmt.Errorf("job %s/%s mirroring the images failed: %s", registry.Namespace, jobName, condition.Message)
+			}
+		}
+
+		return copyJob.Status.Succeeded > 0, nil
+	})
+}
+
+// ConfigureClusterRegistry is a helper function that makes the nodes of the RKE2 or K3s cluster trust the CA of the
+// registry and authenticate to it, through the registries of the cluster, and waits for the cluster to be ready. The
+// registry may be deployed in another cluster. The previous registries are restored by the session of the client.
+func ConfigureClusterRegistry(client *rancher.Client, clusterName string, registry *Registry) error {
+	adminClient, err := rancher.NewClient(client.RancherConfig.AdminToken, client.Session)
+	if err != nil {
+		return err
+	}
+
+	authSecret, err := adminClient.Steve.SteveType(secretSteveType).Create(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: registryAuthSecretPrefix,
+			Namespace:    provisioningNamespace,
+		},
+		Type: corev1.SecretTypeBasicAuth,
+		StringData: map[string]string{
+			corev1.BasicAuthUsernameKey: registry.Username,
+			corev1.BasicAuthPasswordKey: registry.Password,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		err := adminClient.Steve.SteveType(secretSteveType).Delete(authSecret)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		return err
+	})
+
+	previousRegistries, err := setClusterRegistries(adminClient, clusterName, func(registries *rkev1.Registry) {
+		if registries.Configs == nil {
+			registries.Configs = map[string]rkev1.RegistryConfig{}
+		}
+
+		registries.Configs[registry.Host] = rkev1.RegistryConfig{
+			AuthConfigSecretName: authSecret.Name,
+			CABundle:             registry.CACert,
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		_, err := setClusterRegistries(adminClient, clusterName, func(registries *rkev1.Registry) {
+			*registries = *previousRegistries
+		})
+		return err
+	})
+
+	return nil
+}
+
+// CreateImagePullSecret is a helper function that creates an image pull secret of the registry in the namespace of the
+// cluster, for workloads that pull from it without the registry being configured on the nodes.
+func CreateImagePullSecret(client *rancher.Client, clusterID, namespace string, registry *Registry) (string, error) {
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return "", err
+	}
+
+	dockerConfig, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			registry.Host: map[string]string{
+				"username": registry.Username,
+				"password": registry.Password,
+				"auth":     base64.StdEncoding.EncodeToString([]byte(registry.Username + ":" + registry.Password)),
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := steveClient.SteveType(secretSteveType).Create(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: registryAuthSecretPrefix,
+			Namespace:    namespace,
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: dockerConfig,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return secret.Name, nil
+}
+
+// VerifyPulledFromMirror is a helper function that checks every pod matching the label selector in the namespace of the
+// cluster is running and all of its containers run images pulled from the registry.
+func VerifyPulledFromMirror(client *rancher.Client, clusterID, namespace, labelSelector string, registry *Registry) error {
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return err
+	}
+
+	podList, err := steveClient.SteveType(podSteveType).NamespacedSteveClient(namespace).List(map[string][]string{
+		"labelSelector": {labelSelector},
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(podList.Data) == 0 {
+		return fmt.Errorf("no pods in namespace %s match %q", namespace, labelSelector)
+	}
+
+	for _, podObject := range podList.Data {
+		pod := &corev1.Pod{}
+		err = v1.ConvertToK8sType(podObject.JSONResp, pod)
+		if err != nil {
+			return err
+		}
+
+		if pod.Status.Phase != corev1.PodRunning {
+			return fmt.Errorf("pod %s/%s is %s", namespace, pod.Name, pod.Status.Phase)
+		}
+
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			if !strings.HasPrefix(containerStatus.Image, registry.Host+"/") && !strings.HasPrefix(containerStatus.ImageID, registry.Host+"/") {
+				return fmt.Errorf("container %s of pod %s/%s runs image %s, which was not pulled from registry %s", containerStatus.Name, namespace, pod.Name, containerStatus.Image, registry.Host)
+			}
+		}
+	}
+
+	return nil
+}
+
+// setClusterRegistries is a private helper function that updates the registries of the RKE2 or K3s cluster, waits for
+// the cluster to be ready and returns the previous registries.
+func setClusterRegistries(adminClient *rancher.Client, clusterName string, update func(registries *rkev1.Registry)) (*rkev1.Registry, error) {
+	clusterMeta, err := clusters.NewClusterMeta(adminClient, clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	if clusterMeta.Provider == clusters.KubernetesProviderRKE {
+		return nil, fmt.Errorf("cluster %s is an RKE1 cluster, its private registries can't carry a CA", clusterName)
+	}
+
+	id, err := clusters.GetV1ProvisioningClusterByName(adminClient, clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster, err := adminClient.Steve.SteveType(provisioningSteveResourceType).ByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterSpec := &apiv1.ClusterSpec{}
+	err = v1.ConvertToK8sType(cluster.Spec, clusterSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	if clusterSpec.RKEConfig == nil {
+		return nil, fmt.Errorf("cluster %s has no RKE config", clusterName)
+	}
+
+	previousRegistries := &rkev1.Registry{}
+	if clusterSpec.RKEConfig.Registries != nil {
+		previousRegistries = clusterSpec.RKEConfig.Registries.DeepCopy()
+	} else {
+		clusterSpec.RKEConfig.Registries = &rkev1.Registry{}
+	}
+
+	update(clusterSpec.RKEConfig.Registries)
+
+	updatedCluster := *cluster
+	updatedCluster.Spec = *clusterSpec
+
+	_, err = adminClient.Steve.SteveType(provisioningSteveResourceType).Update(cluster, updatedCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	kubeProvisioningClient, err := adminClient.GetKubeAPIProvisioningClient()
+	if err != nil {
+		return nil, err
+	}
+
+	err = kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Scale(clusterRegistryTimeout), true, func(ctx context.Context) (done bool, err error) {
+		provisioningCluster, err := kubeProvisioningClient.Clusters(provisioningNamespace).Get(ctx, clusterName, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		return clusters.IsProvisioningClusterReady(watch.Event{Type: watch.Modified, Object: provisioningCluster})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return previousRegistries, nil
+}
+
+// imageRepository is a private helper function that returns the repository and tag of the image without its registry
+// domain, adding the library namespace of docker hub images without one.
+func imageRepository(image string) string {
+	domain, remainder, found := strings.Cut(image, "/")
+	if !found {
+		return dockerHubLibrary + image
+	}
+
+	if domain != "localhost" && !strings.ContainsAny(domain, ".:") {
+		return image
+	}
+
+	if domain == dockerHubDomain && !strings.Contains(remainder, "/") {
+		return dockerHubLibrary + remainder
+	}
+
+	return remainder
+}
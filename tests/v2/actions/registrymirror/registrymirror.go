@@ -0,0 +1,362 @@
+package registrymirror
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/unstructured"
+	"github.com/rancher/shepherd/extensions/workloads"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"golang.org/x/crypto/bcrypt"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/dynamic"
+)
+
+// The json/yaml config key for the registry mirror fixture
+const ConfigurationFileKey = "registryMirror"
+
+const (
+	defaultNamespace     = "registry-mirror"
+	defaultRegistryImage = "registry:2"
+	defaultCopyImage     = "quay.io/skopeo/stable:latest"
+	defaultUsername      = "mirror"
+
+	registryName         = "registry"
+	registryPort         = 5000
+	registrySecretName   = "registry-certs-auth"
+	registryCertsPath    = "/certs"
+	registryAuthPath     = "/auth"
+	htpasswdKey          = "htpasswd"
+	caCertKey            = "ca.crt"
+	passwordLength       = 20
+	certificateValidity  = 24 * time.Hour
+	nodeSteveType        = "node"
+	podSteveType         = "pod"
+	secretSteveType      = "secret"
+	registryLabelKey     = "app"
+	registryLabelValue   = "registry-mirror"
+	registryRealm        = "Registry Mirror"
+	certificateBlockType = "CERTIFICATE"
+	keyBlockType         = "EC PRIVATE KEY"
+)
+
+// Config is the configuration of the registry mirror fixture, e.g.
+//
+//	registryMirror:
+//	  images:
+//	  - docker.io/library/nginx:1.27
+//	  - docker.io/rancher/mirrored-library-busybox:1.36.1
+//	  registryImage: registry:2
+//	  copyImage: quay.io/skopeo/stable:latest
+//
+// The registry and copy images must be pullable by the cluster the registry is deployed in.
+type Config struct {
+	Images        []string `json:"images" yaml:"images"`
+	Namespace     string   `json:"namespace" yaml:"namespace"`
+	RegistryImage string   `json:"registryImage" yaml:"registryImage"`
+	CopyImage     string   `json:"copyImage" yaml:"copyImage"`
+}
+
+// Registry is a struct of a registry deployed by the fixture. Host is the address the nodes of the cluster pull from,
+// a node IP and the node port of the registry service. CACert is the PEM CA the TLS certificate of the registry is
+// signed with.
+type Registry struct {
+	ClusterID string
+	Namespace string
+	Host      string
+	Username  string
+	Password  string
+	CACert    []byte
+	copyImage string
+}
+
+// LoadConfig is a helper function that reads the registry mirror configuration and fills in the defaults.
+func LoadConfig() *Config {
+	mirrorConfig := new(Config)
+	config.LoadConfig(ConfigurationFileKey, mirrorConfig)
+
+	if mirrorConfig.Namespace == "" {
+		mirrorConfig.Namespace = defaultNamespace
+	}
+
+	if mirrorConfig.RegistryImage == "" {
+		mirrorConfig.RegistryImage = defaultRegistryImage
+	}
+
+	if mirrorConfig.CopyImage == "" {
+		mirrorConfig.CopyImage = defaultCopyImage
+	}
+
+	return mirrorConfig
+}
+
+// DeployRegistry is a helper function that deploys a registry with TLS and basic auth in the cluster, exposed on a node
+// port, and waits for it to be ready. The TLS certificate is signed by a CA generated for the registry and is valid for
+// the internal IPs of every node. The namespace of the registry is deleted by the session of the client.
+func DeployRegistry(client *rancher.Client, clusterID string, mirrorConfig *Config) (*Registry, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeIPs, err := getNodeIPs(steveClient)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = dynamicClient.Resource(corev1.SchemeGroupVersion.WithResource("namespaces")).Create(context.TODO(), unstructured.MustToUnstructured(&corev1.Namespace{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+		ObjectMeta: metav1.ObjectMeta{Name: mirrorConfig.Namespace},
+	}), metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		err := dynamicClient.Resource(corev1.SchemeGroupVersion.WithResource("namespaces")).Delete(context.TODO(), mirrorConfig.Namespace, metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		return err
+	})
+
+	nodePort, err := createRegistryService(dynamicClient, mirrorConfig.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := &Registry{
+		ClusterID: clusterID,
+		Namespace: mirrorConfig.Namespace,
+		Host:      fmt.Sprintf("%s:%d", nodeIPs[0], nodePort),
+		Username:  defaultUsername,
+		Password:  namegenerator.RandStringLower(passwordLength),
+		copyImage: mirrorConfig.CopyImage,
+	}
+
+	dnsNames := []string{
+		registryName,
+		fmt.Sprintf("%s.%s.svc", registryName, mirrorConfig.Namespace),
+		fmt.Sprintf("%s.%s.svc.cluster.local", registryName, mirrorConfig.Namespace),
+	}
+
+	caCert, cert, key, err := newCertificates(nodeIPs, dnsNames)
+	if err != nil {
+		return nil, err
+	}
+
+	registry.CACert = caCert
+
+	htpasswd, err := bcrypt.GenerateFromPassword([]byte(registry.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = dynamicClient.Resource(corev1.SchemeGroupVersion.WithResource("secrets")).Namespace(mirrorConfig.Namespace).Create(context.TODO(), unstructured.MustToUnstructured(&corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Name: registrySecretName, Namespace: mirrorConfig.Namespace},
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       cert,
+			corev1.TLSPrivateKeyKey: key,
+			caCertKey:               caCert,
+			htpasswdKey:             []byte(registry.Username + ":" + string(htpasswd)),
+		},
+	}), metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	err = createRegistryDeployment(dynamicClient, mirrorConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	err = actionscharts.WatchAndWaitDeployments(client, clusterID, mirrorConfig.Namespace, metav1.ListOptions{
+		LabelSelector: registryLabelKey + "=" + registryLabelValue,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return registry, nil
+}
+
+// createRegistryService is a private helper function that creates the node port service of the registry and returns
+// the node port it was assigned.
+func createRegistryService(dynamicClient dynamic.Interface, namespace string) (int32, error) {
+	serviceObject, err := dynamicClient.Resource(corev1.SchemeGroupVersion.WithResource("services")).Namespace(namespace).Create(context.TODO(), unstructured.MustToUnstructured(&corev1.Service{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{Name: registryName, Namespace: namespace},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeNodePort,
+			Selector: map[string]string{registryLabelKey: registryLabelValue},
+			Ports: []corev1.ServicePort{{
+				Name:       registryName,
+				Port:       registryPort,
+				TargetPort: intstr.FromInt(registryPort),
+			}},
+		},
+	}), metav1.CreateOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	service := &corev1.Service{}
+	err = v1.ConvertToK8sType(serviceObject.Object, service)
+	if err != nil {
+		return 0, err
+	}
+
+	return service.Spec.Ports[0].NodePort, nil
+}
+
+// createRegistryDeployment is a private helper function that creates the deployment of the registry, serving TLS with
+// the certificate of the registry secret and authenticating with its htpasswd.
+func createRegistryDeployment(dynamicClient dynamic.Interface, mirrorConfig *Config) error {
+	volumeMounts := []corev1.VolumeMount{
+		{Name: registrySecretName, MountPath: registryCertsPath, ReadOnly: true},
+		{Name: registrySecretName, MountPath: registryAuthPath, ReadOnly: true},
+	}
+
+	container := workloads.NewContainer(registryName, mirrorConfig.RegistryImage, corev1.PullIfNotPresent, volumeMounts, nil, nil, nil, nil)
+	container.Env = []corev1.EnvVar{
+		{Name: "REGISTRY_HTTP_TLS_CERTIFICATE", Value: registryCertsPath + "/" + corev1.TLSCertKey},
+		{Name: "REGISTRY_HTTP_TLS_KEY", Value: registryCertsPath + "/" + corev1.TLSPrivateKeyKey},
+		{Name: "REGISTRY_AUTH", Value: htpasswdKey},
+		{Name: "REGISTRY_AUTH_HTPASSWD_REALM", Value: registryRealm},
+		{Name: "REGISTRY_AUTH_HTPASSWD_PATH", Value: registryAuthPath + "/" + htpasswdKey},
+	}
+	container.Ports = []corev1.ContainerPort{{ContainerPort: registryPort}}
+
+	volumes := []corev1.Volume{{
+		Name: registrySecretName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: registrySecretName},
+		},
+	}}
+
+	labels := map[string]string{registryLabelKey: registryLabelValue}
+	podTemplate := workloads.NewPodTemplate([]corev1.Container{container}, volumes, nil, labels)
+	deployment := workloads.NewDeploymentTemplate(registryName, mirrorConfig.Namespace, podTemplate, false, labels)
+	deployment.TypeMeta = metav1.TypeMeta{APIVersion: appv1.SchemeGroupVersion.String(), Kind: "Deployment"}
+
+	_, err := dynamicClient.Resource(appv1.SchemeGroupVersion.WithResource("deployments")).Namespace(mirrorConfig.Namespace).Create(context.TODO(), unstructured.MustToUnstructured(deployment), metav1.CreateOptions{})
+	return err
+}
+
+// getNodeIPs is a private helper function that returns the internal IP of every node of the cluster.
+func getNodeIPs(steveClient *v1.Client) ([]string, error) {
+	nodeList, err := steveClient.SteveType(nodeSteveType).List(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodeIPs []string
+	for _, nodeObject := range nodeList.Data {
+		node := &corev1.Node{}
+		err = v1.ConvertToK8sType(nodeObject.JSONResp, node)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, address := range node.Status.Addresses {
+			if address.Type == corev1.NodeInternalIP {
+				nodeIPs = append(nodeIPs, address.Address)
+			}
+		}
+	}
+
+	if len(nodeIPs) == 0 {
+		return nil, fmt.Errorf("no node has an internal IP")
+	}
+
+	return nodeIPs, nil
+}
+
+// newCertificates is a private helper function that generates a CA and a server certificate signed by it for the IPs
+// and DNS names, returning the PEM CA certificate, server certificate and server key.
+func newCertificates(ips, dnsNames []string) (caCertPEM, certPEM, keyPEM []byte, err error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	notBefore := time.Now().Add(-time.Hour)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: registryRealm + " CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(certificateValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: registryName},
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(certificateValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     dnsNames,
+	}
+
+	for _, ip := range ips {
+		template.IPAddresses = append(template.IPAddresses, net.ParseIP(ip))
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, caTemplate, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return encodePEM(certificateBlockType, caDER), encodePEM(certificateBlockType, certDER), encodePEM(keyBlockType, keyDER), nil
+}
+
+// encodePEM is a private helper function that encodes the DER bytes as a PEM block of the type.
+func encodePEM(blockType string, der []byte) []byte {
+	var buffer bytes.Buffer
+	_ = pem.Encode(&buffer, &pem.Block{Type: blockType, Bytes: der})
+
+	return buffer.Bytes()
+}
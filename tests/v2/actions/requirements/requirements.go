@@ -0,0 +1,133 @@
+package requirements
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/rancher/rancher/tests/v2/actions/features"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/clients/rancher/catalog"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	nodeSteveType  = "node"
+	localClusterID = "local"
+)
+
+// Requirement is the function type of a requirement of a suite on the rancher server or the cluster under test. It
+// returns why the requirement is not met, or an empty string when it is; errors are failures to evaluate it.
+type Requirement func(client *rancher.Client, clusterID string) (unmetReason string, err error)
+
+// Require is a helper function that evaluates every requirement against the cluster, typically in SetupSuite, and skips
+// the test, or the whole suite from SetupSuite, with the reasons of all the requirements that are not met. Every
+// requirement is logged, so skipped suites say why in the test log. The test fails when a requirement can't be
+// evaluated.
+func Require(t *testing.T, client *rancher.Client, clusterID string, requirements ...Requirement) {
+	t.Helper()
+
+	var unmetReasons []string
+	for _, requirement := range requirements {
+		unmetReason, err := requirement(client, clusterID)
+		if err != nil {
+			t.Fatalf("failed to evaluate the requirements of %s: %v", t.Name(), err)
+		}
+
+		if unmetReason != "" {
+			logrus.Infof("Requirement of %s not met: %s", t.Name(), unmetReason)
+			unmetReasons = append(unmetReasons, unmetReason)
+		}
+	}
+
+	if len(unmetReasons) > 0 {
+		t.Skipf("Skipping %s, requirements not met: %s", t.Name(), strings.Join(unmetReasons, "; "))
+	}
+}
+
+// MinNodes is a constructor that returns the requirement that the cluster has at least the given number of nodes.
+func MinNodes(minNodes int) Requirement {
+	return func(client *rancher.Client, clusterID string) (string, error) {
+		steveClient := client.Steve
+		if clusterID != localClusterID {
+			var err error
+			steveClient, err = client.Steve.ProxyDownstream(clusterID)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		nodeList, err := steveClient.SteveType(nodeSteveType).List(nil)
+		if err != nil {
+			return "", err
+		}
+
+		if len(nodeList.Data) < minNodes {
+			return fmt.Sprintf("cluster %s has %d nodes, at least %d required", clusterID, len(nodeList.Data), minNodes), nil
+		}
+
+		return "", nil
+	}
+}
+
+// ChartVersions is a constructor that returns the requirement that the rancher charts repository has at least the given
+// number of versions of the chart, e.g. 2 to upgrade it from the version before the latest one.
+func ChartVersions(chartName string, minVersions int) Requirement {
+	return func(client *rancher.Client, _ string) (string, error) {
+		versions, err := client.Catalog.GetListChartVersions(chartName, catalog.RancherChartRepo)
+		if err != nil {
+			return "", err
+		}
+
+		if len(versions) < minVersions {
+			return fmt.Sprintf("chart %s has %d versions, at least %d required", chartName, len(versions), minVersions), nil
+		}
+
+		return "", nil
+	}
+}
+
+// ChartVersionAtLeast is a constructor that returns the requirement that the latest version of the chart in the rancher
+// charts repository is at least the given semantic version, e.g. ChartVersionAtLeast("rancher-monitoring", "103.0.0").
+func ChartVersionAtLeast(chartName, minVersion string) Requirement {
+	return func(client *rancher.Client, _ string) (string, error) {
+		constraint, err := semver.NewConstraint(">= " + minVersion)
+		if err != nil {
+			return "", err
+		}
+
+		latestVersion, err := client.Catalog.GetLatestChartVersion(chartName, catalog.RancherChartRepo)
+		if err != nil {
+			return "", err
+		}
+
+		version, err := semver.NewVersion(latestVersion)
+		if err != nil {
+			return "", fmt.Errorf("unable to parse version %s of chart %s: %w", latestVersion, chartName, err)
+		}
+
+		if !constraint.Check(version) {
+			return fmt.Sprintf("latest version of chart %s is %s, at least %s required", chartName, latestVersion, minVersion), nil
+		}
+
+		return "", nil
+	}
+}
+
+// FeatureFlag is a constructor that returns the requirement that the effective value of the rancher feature flag is the
+// given value.
+func FeatureFlag(featureName string, enabled bool) Requirement {
+	return func(client *rancher.Client, _ string) (string, error) {
+		value, err := features.GetFeatureFlag(client, featureName)
+		if err != nil {
+			return "", err
+		}
+
+		if value != enabled {
+			return fmt.Sprintf("feature flag %s is %t, %t required", featureName, value, enabled), nil
+		}
+
+		return "", nil
+	}
+}
@@ -0,0 +1,127 @@
+package rke1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rancher/norman/types"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
+	"github.com/rancher/shepherd/extensions/clusters"
+	nodepools "github.com/rancher/shepherd/extensions/rke1/nodepools"
+	"github.com/rancher/shepherd/pkg/clientbase"
+	"github.com/sirupsen/logrus"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	active               = "active"
+	hostnamePrefixFormat = "%s-%s-"
+)
+
+// CreateNodePool is a helper function that creates a node pool of the node template with the roles and quantity of the
+// node roles in the cluster. The hostname prefix of its nodes is the cluster ID followed by the pool name. The node pool
+// is deleted by the session of the client, which waits for it to be removed so its node template can be deleted next.
+func CreateNodePool(client *rancher.Client, clusterID, nodeTemplateID, poolName string, nodeRoles nodepools.NodeRoles) (*management.NodePool, error) {
+	nodePool, err := client.Management.NodePool.Create(&management.NodePool{
+		ClusterID:         clusterID,
+		NodeTemplateID:    nodeTemplateID,
+		HostnamePrefix:    fmt.Sprintf(hostnamePrefixFormat, clusterID, poolName),
+		ControlPlane:      nodeRoles.ControlPlane,
+		Etcd:              nodeRoles.Etcd,
+		Worker:            nodeRoles.Worker,
+		Quantity:          nodeRoles.Quantity,
+		DrainBeforeDelete: nodeRoles.DrainBeforeDelete,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		return DeleteNodePool(client, nodePool)
+	})
+
+	return nodePool, nil
+}
+
+// ListNodePools is a helper function that returns the node pools of the cluster.
+func ListNodePools(client *rancher.Client, clusterID string) ([]management.NodePool, error) {
+	nodePools, err := client.Management.NodePool.ListAll(&types.ListOpts{
+		Filters: map[string]interface{}{
+			"clusterId": clusterID,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return nodePools.Data, nil
+}
+
+// ScaleNodePool is a helper function that sets the quantity of the node pool and waits for the cluster to be active
+// with every node of the pool ready.
+func ScaleNodePool(client *rancher.Client, nodePool *management.NodePool, quantity int64) (*management.NodePool, error) {
+	logrus.Infof("Scaling node pool %s to %d nodes", nodePool.Name, quantity)
+
+	updatedNodePool, err := client.Management.NodePool.Update(nodePool, map[string]interface{}{
+		"quantity": quantity,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = WaitForNodePoolQuantity(client, updatedNodePool, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	return updatedNodePool, clusters.WaitForActiveRKE1Cluster(client, nodePool.ClusterID)
+}
+
+// WaitForNodePoolQuantity is a helper function that waits until the node pool has exactly the quantity of nodes and all
+// of them are active.
+func WaitForNodePoolQuantity(client *rancher.Client, nodePool *management.NodePool, quantity int64) error {
+	return kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Timeout(), true, func(context.Context) (done bool, err error) {
+		nodes, err := client.Management.Node.ListAll(&types.ListOpts{
+			Filters: map[string]interface{}{
+				"nodePoolId": nodePool.ID,
+			},
+		})
+		if err != nil {
+			return false, err
+		}
+
+		if int64(len(nodes.Data)) != quantity {
+			return false, nil
+		}
+
+		for _, node := range nodes.Data {
+			if node.State != active {
+				return false, nil
+			}
+		}
+
+		return true, nil
+	})
+}
+
+// DeleteNodePool is a helper function that deletes the node pool, if it still exists, and waits until it is removed.
+func DeleteNodePool(client *rancher.Client, nodePool *management.NodePool) error {
+	err := client.Management.NodePool.Delete(nodePool)
+	if clientbase.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Timeout(), true, func(context.Context) (done bool, err error) {
+		_, err = client.Management.NodePool.ByID(nodePool.ID)
+		if clientbase.IsNotFound(err) {
+			return true, nil
+		}
+
+		return false, err
+	})
+}
@@ -0,0 +1,104 @@
+package rke1
+
+import (
+	"fmt"
+
+	"github.com/rancher/norman/types"
+	"github.com/rancher/shepherd/clients/rancher"
+	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
+	"github.com/rancher/shepherd/extensions/provisioning"
+	"github.com/rancher/shepherd/extensions/rke1/nodetemplates"
+	"github.com/rancher/shepherd/pkg/clientbase"
+)
+
+// CreateProviderNodeTemplate is a helper function that creates the node template of the provider, e.g. aws or linode,
+// from its node template config, the same way the RKE1 provisioning suites do. The node template is deleted by the
+// session of the client.
+func CreateProviderNodeTemplate(client *rancher.Client, providerName string) (*nodetemplates.NodeTemplate, error) {
+	provider := provisioning.CreateRKE1Provider(providerName)
+	if provider.NodeTemplateFunc == nil {
+		return nil, fmt.Errorf("provider %s has no RKE1 node template", providerName)
+	}
+
+	nodeTemplate, err := provider.NodeTemplateFunc(client)
+	if err != nil {
+		return nil, err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		return DeleteNodeTemplate(client, nodeTemplate)
+	})
+
+	return nodeTemplate, nil
+}
+
+// CreateNodeTemplate is a helper function that creates the node template as it is given, for node templates that are not
+// built from a provider config. The node template is deleted by the session of the client.
+func CreateNodeTemplate(client *rancher.Client, nodeTemplate *nodetemplates.NodeTemplate) (*nodetemplates.NodeTemplate, error) {
+	createdNodeTemplate := &nodetemplates.NodeTemplate{}
+	err := client.Management.APIBaseClient.Ops.DoCreate(management.NodeTemplateType, nodeTemplate, createdNodeTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		return DeleteNodeTemplate(client, createdNodeTemplate)
+	})
+
+	return createdNodeTemplate, nil
+}
+
+// GetNodeTemplate is a helper function that returns the node template with the given ID.
+func GetNodeTemplate(client *rancher.Client, nodeTemplateID string) (*nodetemplates.NodeTemplate, error) {
+	nodeTemplate := &nodetemplates.NodeTemplate{}
+	err := client.Management.APIBaseClient.Ops.DoByID(management.NodeTemplateType, nodeTemplateID, nodeTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	return nodeTemplate, nil
+}
+
+// ListNodeTemplates is a helper function that returns the node templates of the node driver, e.g. amazonec2, or every
+// node template when the driver is empty.
+func ListNodeTemplates(client *rancher.Client, driver string) ([]nodetemplates.NodeTemplate, error) {
+	opts := &types.ListOpts{Filters: map[string]interface{}{}}
+	if driver != "" {
+		opts.Filters[management.NodeTemplateFieldDriver] = driver
+	}
+
+	var collection struct {
+		types.Collection
+		Data []nodetemplates.NodeTemplate `json:"data,omitempty"`
+	}
+
+	err := client.Management.APIBaseClient.Ops.DoList(management.NodeTemplateType, opts, &collection)
+	if err != nil {
+		return nil, err
+	}
+
+	return collection.Data, nil
+}
+
+// UpdateNodeTemplate is a helper function that updates the node template with the given fields, e.g.
+// map[string]interface{}{"engineInstallURL": url}, and returns the updated node template.
+func UpdateNodeTemplate(client *rancher.Client, nodeTemplate *nodetemplates.NodeTemplate, updates interface{}) (*nodetemplates.NodeTemplate, error) {
+	updatedNodeTemplate := &nodetemplates.NodeTemplate{}
+	err := client.Management.APIBaseClient.Ops.DoUpdate(management.NodeTemplateType, &nodeTemplate.Resource, updates, updatedNodeTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	return updatedNodeTemplate, nil
+}
+
+// DeleteNodeTemplate is a helper function that deletes the node template, if it still exists. Rancher refuses to delete
+// a node template that is still used by a node pool.
+func DeleteNodeTemplate(client *rancher.Client, nodeTemplate *nodetemplates.NodeTemplate) error {
+	err := client.Management.APIBaseClient.Ops.DoResourceDelete(management.NodeTemplateType, &nodeTemplate.Resource)
+	if clientbase.IsNotFound(err) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,140 @@
+package serviceaccounts
+
+import (
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/clusterrolebindings"
+	"github.com/rancher/shepherd/extensions/serviceaccounts"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	roleSteveType        = "rbac.authorization.k8s.io.role"
+	roleBindingSteveType = "rbac.authorization.k8s.io.rolebinding"
+)
+
+// CreateServiceAccount is a helper function that creates a service account in the namespace of the cluster for a test
+// workload and returns it. Its token is mounted in the pods using it even when the cluster disables the automount of
+// the default service accounts. The service account is deleted by the session of the client.
+func CreateServiceAccount(client *rancher.Client, clusterID, namespace, name string) (*corev1.ServiceAccount, error) {
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	automountToken := true
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		AutomountServiceAccountToken: &automountToken,
+	}
+
+	serviceAccountObject, err := steveClient.SteveType(serviceaccounts.ServiceAccountSteveType).Create(serviceAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	createdServiceAccount := &corev1.ServiceAccount{}
+	err = v1.ConvertToK8sType(serviceAccountObject.JSONResp, createdServiceAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	return createdServiceAccount, nil
+}
+
+// CreateServiceAccountWithRole is a helper function that creates a service account in the namespace of the cluster and a
+// role with the rules bound to it, both named after the service account, and returns the service account. The service
+// account, the role and the role binding are deleted by the session of the client.
+func CreateServiceAccountWithRole(client *rancher.Client, clusterID, namespace, name string, rules []rbacv1.PolicyRule) (*corev1.ServiceAccount, error) {
+	serviceAccount, err := CreateServiceAccount(client, clusterID, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Rules: rules,
+	}
+
+	_, err = steveClient.SteveType(roleSteveType).Create(role)
+	if err != nil {
+		return nil, err
+	}
+
+	roleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Subjects: []rbacv1.Subject{newSubject(serviceAccount)},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.SchemeGroupVersion.Group,
+			Kind:     "Role",
+			Name:     role.Name,
+		},
+	}
+
+	_, err = steveClient.SteveType(roleBindingSteveType).Create(roleBinding)
+	if err != nil {
+		return nil, err
+	}
+
+	return serviceAccount, nil
+}
+
+// CreateServiceAccountWithClusterRole is a helper function that creates a service account in the namespace of the
+// cluster, binds the existing cluster role to it, e.g. cluster-admin, and returns the service account. The service
+// account and the cluster role binding are deleted by the session of the client.
+func CreateServiceAccountWithClusterRole(client *rancher.Client, clusterID, namespace, name, clusterRoleName string) (*corev1.ServiceAccount, error) {
+	serviceAccount, err := CreateServiceAccount(client, clusterID, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	// cluster role bindings are not namespaced, so the namespace is part of the name to keep it unique
+	clusterRoleBinding := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: namespace + "-" + name,
+		},
+		Subjects: []rbacv1.Subject{newSubject(serviceAccount)},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.SchemeGroupVersion.Group,
+			Kind:     "ClusterRole",
+			Name:     clusterRoleName,
+		},
+	}
+
+	_, err = steveClient.SteveType(clusterrolebindings.ClusterRoleBindingSteveType).Create(clusterRoleBinding)
+	if err != nil {
+		return nil, err
+	}
+
+	return serviceAccount, nil
+}
+
+// newSubject is a private helper function that returns the role binding subject of the service account.
+func newSubject(serviceAccount *corev1.ServiceAccount) rbacv1.Subject {
+	return rbacv1.Subject{
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      serviceAccount.Name,
+		Namespace: serviceAccount.Namespace,
+	}
+}
@@ -0,0 +1,161 @@
+package sessionid
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/shepherd/pkg/session"
+)
+
+const (
+	// HeaderKey is the request header that carries the test session ID to the Rancher API.
+	HeaderKey = "X-Test-Session-ID"
+	// LabelKey is the label that carries the test session ID on the resources created for a test session.
+	LabelKey = "test.cattle.io/session-id"
+
+	sessionIDPrefix = "test-session-"
+	sessionIDLength = 12
+)
+
+// sessionHeader is a private struct of the session ID a client sends, shared by all the transports of the client so
+// it can be replaced at once.
+type sessionHeader struct {
+	mutex     sync.RWMutex
+	sessionID string
+}
+
+// getSessionID is a private method that returns the session ID the requests are sent with.
+func (h *sessionHeader) getSessionID() string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	return h.sessionID
+}
+
+// setSessionID is a private method that replaces the session ID the requests are sent with.
+func (h *sessionHeader) setSessionID(sessionID string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.sessionID = sessionID
+}
+
+// headerRoundTripper is a private http.RoundTripper that sets the session ID header on every request before
+// handing it to the wrapped transport.
+type headerRoundTripper struct {
+	header *sessionHeader
+	next   http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper. The request is cloned, as a RoundTripper must not modify the original request.
+func (h *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	clonedReq := req.Clone(req.Context())
+	clonedReq.Header.Set(HeaderKey, h.header.getSessionID())
+
+	return h.next.RoundTrip(clonedReq)
+}
+
+// NewSessionID is a helper function that returns a random session ID that can be used to correlate the API calls of a test.
+func NewSessionID() string {
+	return sessionIDPrefix + namegenerator.RandStringLower(sessionIDLength)
+}
+
+// NewClient is a helper function that behaves as rancher.NewClient, sending a new session ID header with the API calls
+// of the returned client, see SetSessionIDHeader, so the effects of the session can be found in the audit log.
+func NewClient(bearerToken string, session *session.Session) (*rancher.Client, error) {
+	client, err := rancher.NewClient(bearerToken, session)
+	if err != nil {
+		return nil, err
+	}
+
+	SetSessionIDHeader(client, NewSessionID())
+
+	return client, nil
+}
+
+// SetSessionIDHeader is a helper function that makes the rancher client send the session ID header with its API calls.
+// The header is set by the WrapTransport of the rest config of the client, so the catalog, dynamic and wrangler clients
+// built from it afterwards send it, e.g. the clients of GetClusterCatalogClient, GetDownStreamClusterClient and
+// DownStreamClusterWranglerContext, as well as by the http clients of Management.Ops and Steve.Ops. Clients built by
+// rancher.NewClient from the token of the client don't send it, use WithSession of this package instead of the one of
+// the client, nor do the steve clients returned by Steve.ProxyDownstream, use SetSteveSessionIDHeader.
+func SetSessionIDHeader(client *rancher.Client, sessionID string) {
+	// the transports of a client whose header was already set share its session header, only the ID is replaced
+	if roundTripper, ok := client.Management.Ops.Client.Transport.(*headerRoundTripper); ok {
+		roundTripper.header.setSessionID(sessionID)
+		return
+	}
+
+	header := &sessionHeader{sessionID: sessionID}
+
+	setHTTPClientHeader(client.Management.Ops.Client, header)
+	setHTTPClientHeader(client.Steve.Ops.Client, header)
+
+	if client.WranglerContext != nil && client.WranglerContext.RESTConfig != nil {
+		client.WranglerContext.RESTConfig.Wrap(func(next http.RoundTripper) http.RoundTripper {
+			return &headerRoundTripper{
+				header: header,
+				next:   next,
+			}
+		})
+	}
+}
+
+// SetSteveSessionIDHeader is a helper function that makes a steve client send the session ID header with its API calls,
+// e.g. a client returned by Steve.ProxyDownstream.
+func SetSteveSessionIDHeader(steveClient *v1.Client, sessionID string) {
+	header := &sessionHeader{}
+	if roundTripper, ok := steveClient.Ops.Client.Transport.(*headerRoundTripper); ok {
+		header = roundTripper.header
+	}
+
+	header.setSessionID(sessionID)
+	setHTTPClientHeader(steveClient.Ops.Client, header)
+}
+
+// GetSessionID is a helper function that returns the session ID set on the rancher client, or an empty string if the
+// header was never set.
+func GetSessionID(client *rancher.Client) string {
+	if roundTripper, ok := client.Management.Ops.Client.Transport.(*headerRoundTripper); ok {
+		return roundTripper.header.getSessionID()
+	}
+
+	return ""
+}
+
+// WithSession is a helper function that behaves as client.WithSession, setting the session ID header of the client,
+// if any, on the returned client.
+func WithSession(client *rancher.Client, session *session.Session) (*rancher.Client, error) {
+	sessionClient, err := client.WithSession(session)
+	if err != nil {
+		return nil, err
+	}
+
+	if sessionID := GetSessionID(client); sessionID != "" {
+		SetSessionIDHeader(sessionClient, sessionID)
+	}
+
+	return sessionClient, nil
+}
+
+// setHTTPClientHeader is a private helper function that wraps the transport of the http client with the session header.
+// When the transport is already wrapped, only its session header is replaced so transports are not wrapped more than once.
+func setHTTPClientHeader(httpClient *http.Client, header *sessionHeader) {
+	if roundTripper, ok := httpClient.Transport.(*headerRoundTripper); ok {
+		roundTripper.header = header
+		return
+	}
+
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	httpClient.Transport = &headerRoundTripper{
+		header: header,
+		next:   next,
+	}
+}
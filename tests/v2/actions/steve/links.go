@@ -0,0 +1,206 @@
+package steve
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/kubectl"
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/charts"
+)
+
+const (
+	// ViewLink is the link of every steve object to the object in the kubernetes API, through the rancher proxy for
+	// downstream clusters
+	ViewLink = "view"
+	// ShellLink is the link of the kubectl shell of a management.cattle.io.cluster object
+	ShellLink = "shell"
+
+	metricsPath = "metrics"
+)
+
+// LogOptions is a struct of the options of the logs of a pod.
+type LogOptions struct {
+	// Container is the container of the logs, required for pods with several containers
+	Container string
+	// TailLines is the number of lines from the end of the logs, all of them when zero
+	TailLines int64
+	// SinceSeconds only returns the logs of the last seconds, all of them when zero
+	SinceSeconds int64
+	// Previous returns the logs of the previous instance of the container, e.g. one that crashed
+	Previous bool
+}
+
+// query is a private method that returns the query parameters of the logs request.
+func (o *LogOptions) query() url.Values {
+	query := url.Values{}
+	if o == nil {
+		return query
+	}
+
+	if o.Container != "" {
+		query.Set("container", o.Container)
+	}
+
+	if o.TailLines > 0 {
+		query.Set("tailLines", strconv.FormatInt(o.TailLines, 10))
+	}
+
+	if o.SinceSeconds > 0 {
+		query.Set("sinceSeconds", strconv.FormatInt(o.SinceSeconds, 10))
+	}
+
+	if o.Previous {
+		query.Set("previous", "true")
+	}
+
+	return query
+}
+
+// GetLink is a helper function that returns the link of the steve object, e.g. ViewLink, or an error listing the links
+// the object has.
+func GetLink(object *v1.SteveAPIObject, name string) (string, error) {
+	link, ok := object.Links[name]
+	if ok && link != "" {
+		return link, nil
+	}
+
+	names := make([]string, 0, len(object.Links))
+	for linkName := range object.Links {
+		names = append(names, linkName)
+	}
+
+	sort.Strings(names)
+
+	return "", fmt.Errorf("%s %s has no %s link, only %s", object.Type, object.ID, name, strings.Join(names, ", "))
+}
+
+// GetLinkPath is a helper function that returns the path of the link of the steve object relative to the rancher host,
+// without the leading slash, e.g. to request it with GetChartCaseEndpoint.
+func GetLinkPath(object *v1.SteveAPIObject, name string) (string, error) {
+	link, err := GetLink(object, name)
+	if err != nil {
+		return "", err
+	}
+
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+
+	linkPath := strings.TrimPrefix(linkURL.EscapedPath(), "/")
+	if linkURL.RawQuery != "" {
+		linkPath += "?" + linkURL.RawQuery
+	}
+
+	return linkPath, nil
+}
+
+// FollowLink is a helper function that sends a GET request to the link of the steve object as the user of the client,
+// with the query parameters, and returns the response.
+func FollowLink(client *rancher.Client, object *v1.SteveAPIObject, name string, query url.Values) (*charts.GetChartCaseEndpointResult, error) {
+	linkPath, err := GetLinkPath(object, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return getPath(client, linkPath, query)
+}
+
+// GetPodLogs is a helper function that returns the logs of the steve pod object, read from the log subresource of its
+// view link.
+func GetPodLogs(client *rancher.Client, pod *v1.SteveAPIObject, logOptions *LogOptions) (string, error) {
+	viewPath, err := GetLinkPath(pod, ViewLink)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := getPath(client, viewPath+"/log", logOptions.query())
+	if err != nil {
+		return "", err
+	}
+
+	if !result.Ok {
+		return "", fmt.Errorf("unable to get the logs of pod %s: %s", pod.ID, result.Body)
+	}
+
+	return result.Body, nil
+}
+
+// GetProxyPath is a helper function that returns the path, relative to the rancher host, of the path of the port of
+// the steve service or pod object through the kubernetes API proxy of its view link, e.g.
+// k8s/clusters/c-m-abc/api/v1/namespaces/cattle-monitoring-system/services/http:rancher-monitoring-grafana:80/proxy
+// for the root path of the grafana service, instead of building the proxy path by hand.
+func GetProxyPath(object *v1.SteveAPIObject, port int, proxiedPath string) (string, error) {
+	viewPath, err := GetLinkPath(object, ViewLink)
+	if err != nil {
+		return "", err
+	}
+
+	collection, name := path.Split(viewPath)
+
+	return fmt.Sprintf("%shttp:%s:%d/proxy/%s", collection, name, port, strings.TrimPrefix(proxiedPath, "/")), nil
+}
+
+// GetProxied is a helper function that sends a GET request to the path of the port of the steve service or pod object
+// through the kubernetes API proxy, as the user of the client, and returns the response.
+func GetProxied(client *rancher.Client, object *v1.SteveAPIObject, port int, proxiedPath string) (*charts.GetChartCaseEndpointResult, error) {
+	proxyPath, err := GetProxyPath(object, port, proxiedPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return getPath(client, proxyPath, nil)
+}
+
+// GetMetrics is a helper function that returns the prometheus metrics the steve service or pod object serves on the
+// /metrics path of the port, read through the kubernetes API proxy.
+func GetMetrics(client *rancher.Client, object *v1.SteveAPIObject, port int) (string, error) {
+	result, err := GetProxied(client, object, port, metricsPath)
+	if err != nil {
+		return "", err
+	}
+
+	if !result.Ok {
+		return "", fmt.Errorf("unable to get the metrics of %s %s on port %d: %s", object.Type, object.ID, port, result.Body)
+	}
+
+	return result.Body, nil
+}
+
+// OpenShell is a helper function that opens the kubectl shell of the shell link of the steve
+// management.cattle.io.cluster object as the user of the client. The session is closed by the session of the client.
+func OpenShell(client *rancher.Client, cluster *v1.SteveAPIObject) (*kubectl.Shell, error) {
+	link, err := GetLink(cluster, ShellLink)
+	if err != nil {
+		return nil, err
+	}
+
+	return kubectl.OpenShellLink(client, link)
+}
+
+// getPath is a private helper function that sends a GET request to the path of the rancher host as the user of the
+// client, with the query parameters added to the ones of the path.
+func getPath(client *rancher.Client, requestPath string, query url.Values) (*charts.GetChartCaseEndpointResult, error) {
+	if len(query) > 0 {
+		separator := "?"
+		if strings.Contains(requestPath, "?") {
+			separator = "&"
+		}
+
+		requestPath += separator + query.Encode()
+	}
+
+	return actionscharts.GetEndpoint(client, &actionscharts.EndpointOptions{
+		Host:    client.RancherConfig.Host,
+		Path:    requestPath,
+		IsHTTPS: true,
+		Auth:    actionscharts.RancherAuth,
+	})
+}
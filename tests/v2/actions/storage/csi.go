@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"time"
+
+	actionscatalog "github.com/rancher/rancher/tests/v2/actions/catalog"
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/clients/rancher/catalog"
+	"github.com/rancher/shepherd/extensions/clusters"
+	r1vsphere "github.com/rancher/shepherd/extensions/rke1/nodetemplates/vsphere"
+	"github.com/rancher/shepherd/pkg/api/steve/catalog/types"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// EBSCSIStorageClassName is the default storage class created with the EBS CSI driver
+	EBSCSIStorageClassName = "ebs-csi-gp3"
+	// VsphereCSIStorageClassName is the default storage class created with the vSphere CSI driver
+	VsphereCSIStorageClassName = "vsphere-csi-sc"
+
+	ebsCSIRepoName           = "aws-ebs-csi-driver"
+	ebsCSIRepoURL            = "https://kubernetes-sigs.github.io/aws-ebs-csi-driver"
+	ebsCSIChartName          = "aws-ebs-csi-driver"
+	ebsCSILabelSelector      = "app.kubernetes.io/name=aws-ebs-csi-driver"
+	vsphereCSIChartName      = "rancher-vsphere-csi"
+	vsphereCSILabelSelector  = "app=vsphere-csi-controller"
+	csiNamespace             = "kube-system"
+	defaultStorageClassKey   = "storageclass.kubernetes.io/is-default-class"
+	serverURLSettingID       = "server-url"
+	defaultRegistrySettingID = "system-default-registry"
+	csiInstallTimeout        = 10 * time.Minute
+)
+
+// InstallEBSCSIDriver is a helper function that adds the upstream aws-ebs-csi-driver helm repository to the cluster,
+// installs the latest version of its chart with a default gp3 storage class and waits for the driver. The nodes need an
+// instance profile allowed to manage EBS volumes. The chart and the repository are removed by the session of the client.
+func InstallEBSCSIDriver(client *rancher.Client, clusterID string) error {
+	catalogClient, err := client.GetClusterCatalogClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	_, err = actionscatalog.CreateClusterRepo(client, clusterID, &actionscatalog.ClusterRepoOpts{Name: ebsCSIRepoName, URL: ebsCSIRepoURL})
+	if err != nil {
+		return err
+	}
+
+	version, err := catalogClient.GetLatestChartVersion(ebsCSIChartName, ebsCSIRepoName)
+	if err != nil {
+		return err
+	}
+
+	err = installCSIChart(client, clusterID, catalogClient, ebsCSIRepoName, &types.ChartInstall{
+		ChartName:   ebsCSIChartName,
+		ReleaseName: ebsCSIChartName,
+		Version:     version,
+		Values: map[string]interface{}{
+			"storageClasses": []interface{}{
+				map[string]interface{}{
+					"name": EBSCSIStorageClassName,
+					"annotations": map[string]interface{}{
+						defaultStorageClassKey: "true",
+					},
+					"volumeBindingMode": "WaitForFirstConsumer",
+					"parameters": map[string]interface{}{
+						"type": "gp3",
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return actionscharts.WatchAndWaitWorkloads(client, clusterID, csiNamespace, metav1.ListOptions{LabelSelector: ebsCSILabelSelector})
+}
+
+// InstallVsphereCSIDriver is a helper function that installs the latest rancher-vsphere-csi chart with a default storage
+// class on the datastore of the vSphere node template config, and waits for the driver. The cluster must run the vSphere
+// cloud provider already, in-tree or with the rancher-vsphere-cpi chart. The chart is uninstalled by the session of the
+// client.
+func InstallVsphereCSIDriver(client *rancher.Client, clusterName string) error {
+	cluster, err := clusters.NewClusterMeta(client, clusterName)
+	if err != nil {
+		return err
+	}
+
+	catalogClient, err := client.GetClusterCatalogClient(cluster.ID)
+	if err != nil {
+		return err
+	}
+
+	version, err := catalogClient.GetLatestChartVersion(vsphereCSIChartName, catalog.RancherChartRepo)
+	if err != nil {
+		return err
+	}
+
+	serverSetting, err := client.Management.Setting.ByID(serverURLSettingID)
+	if err != nil {
+		return err
+	}
+
+	registrySetting, err := client.Management.Setting.ByID(defaultRegistrySettingID)
+	if err != nil {
+		return err
+	}
+
+	vsphereTemplateConfig := r1vsphere.GetVsphereNodeTemplate()
+
+	err = installCSIChart(client, cluster.ID, catalogClient, catalog.RancherChartRepo, &types.ChartInstall{
+		Annotations: map[string]string{
+			"catalog.cattle.io/ui-source-repo":      catalog.RancherChartRepo,
+			"catalog.cattle.io/ui-source-repo-type": "cluster",
+		},
+		ChartName:   vsphereCSIChartName,
+		ReleaseName: vsphereCSIChartName,
+		Version:     version,
+		Values: map[string]interface{}{
+			"global": map[string]interface{}{
+				"cattle": map[string]string{
+					"clusterId":             cluster.ID,
+					"clusterName":           cluster.Name,
+					"systemDefaultRegistry": registrySetting.Value,
+					"url":                   serverSetting.Value,
+				},
+				"systemDefaultRegistry": registrySetting.Value,
+			},
+			"vCenter": map[string]interface{}{
+				"host":        vsphereTemplateConfig.Vcenter,
+				"port":        vsphereTemplateConfig.VcenterPort,
+				"datacenters": vsphereTemplateConfig.Datacenter,
+				"username":    vsphereTemplateConfig.Username,
+				"password":    vsphereTemplateConfig.Password,
+				"clusterId":   cluster.ID,
+			},
+			"storageClass": map[string]interface{}{
+				"enabled":      true,
+				"name":         VsphereCSIStorageClassName,
+				"isDefault":    true,
+				"datastoreURL": vsphereTemplateConfig.DatastoreURL,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return actionscharts.WatchAndWaitDeployments(client, cluster.ID, csiNamespace, metav1.ListOptions{LabelSelector: vsphereCSILabelSelector})
+}
+
+// installCSIChart is a private helper function that installs the CSI driver chart from the repository in the
+// kube-system namespace. The chart is uninstalled by the session of the client, which waits until it is removed.
+func installCSIChart(client *rancher.Client, clusterID string, catalogClient *catalog.Client, repoName string, chart *types.ChartInstall) error {
+	err := catalogClient.InstallChart(&types.ChartInstallAction{
+		Timeout:   &metav1.Duration{Duration: timeouts.Scale(csiInstallTimeout)},
+		Wait:      true,
+		Namespace: csiNamespace,
+		Charts:    []types.ChartInstall{*chart},
+	}, repoName)
+	if err != nil {
+		return err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		return actionscharts.UninstallChart(client, clusterID, csiNamespace, chart.ReleaseName)
+	})
+
+	logrus.Infof("Installed %s %s, waiting for the CSI driver", chart.ChartName, chart.Version)
+
+	return nil
+}
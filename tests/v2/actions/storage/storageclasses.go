@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/rancher/tests/v2/actions/wait"
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	storageClassSteveType = "storage.k8s.io.storageclass"
+	pvcSteveType          = "persistentvolumeclaim"
+	podSteveType          = "pod"
+	verifyNamespace       = "default"
+	verifyPrefix          = "storage-check-"
+	verifyImage           = "registry.k8s.io/pause:3.9"
+	verifyVolumeSize      = "1Gi"
+	volumeName            = "data"
+	bindTimeout           = 5 * time.Minute
+)
+
+// GetDefaultStorageClass is a helper function that returns the name of the storage class of the cluster annotated as the
+// default one, which persistent volume claims without a storage class use.
+func GetDefaultStorageClass(client *rancher.Client, clusterID string) (string, error) {
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return "", err
+	}
+
+	storageClassList, err := steveClient.SteveType(storageClassSteveType).List(nil)
+	if err != nil {
+		return "", err
+	}
+
+	for _, storageClassObject := range storageClassList.Data {
+		if storageClassObject.Annotations[defaultStorageClassKey] == "true" {
+			return storageClassObject.Name, nil
+		}
+	}
+
+	return "", fmt.Errorf("cluster %s has no default storage class", clusterID)
+}
+
+// VerifyStorageClass is a helper function that checks the storage class of the cluster dynamically provisions volumes:
+// it creates a persistent volume claim of the storage class, or of the default one when the name is empty, and a pod
+// mounting it, then waits for the claim to be bound. The claim and the pod are deleted by the session of the client.
+func VerifyStorageClass(client *rancher.Client, clusterID, storageClassName string) error {
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return err
+	}
+
+	if storageClassName == "" {
+		storageClassName, err = GetDefaultStorageClass(client, clusterID)
+		if err != nil {
+			return err
+		}
+	}
+
+	storageClassObject, err := steveClient.SteveType(storageClassSteveType).ByID(storageClassName)
+	if err != nil {
+		return err
+	}
+
+	storageClass := &storagev1.StorageClass{}
+	err = v1.ConvertToK8sType(storageClassObject.JSONResp, storageClass)
+	if err != nil {
+		return err
+	}
+
+	name := namegenerator.AppendRandomString(verifyPrefix)
+
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: verifyNamespace,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			StorageClassName: &storageClassName,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse(verifyVolumeSize),
+				},
+			},
+		},
+	}
+
+	pvcObject, err := steveClient.SteveType(pvcSteveType).Create(pvc)
+	if err != nil {
+		return err
+	}
+
+	// claims of WaitForFirstConsumer storage classes are only provisioned once a pod is scheduled with them
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: verifyNamespace,
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:         name,
+				Image:        verifyImage,
+				VolumeMounts: []corev1.VolumeMount{{Name: volumeName, MountPath: "/" + volumeName}},
+			}},
+			Volumes: []corev1.Volume{{
+				Name: volumeName,
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: name},
+				},
+			}},
+		},
+	}
+
+	_, err = steveClient.SteveType(podSteveType).Create(pod)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Waiting for claim %s of storage class %s (provisioner %s) to be bound", name, storageClassName, storageClass.Provisioner)
+
+	pvcStatus := &corev1.PersistentVolumeClaimStatus{}
+	err = wait.For(context.TODO(), timeouts.PollInterval(), timeouts.Scale(bindTimeout), func(context.Context) (done bool, err error) {
+		pvcObject, err := steveClient.SteveType(pvcSteveType).ByID(pvcObject.ID)
+		if err != nil {
+			return false, nil
+		}
+
+		err = v1.ConvertToK8sType(pvcObject.Status, pvcStatus)
+		if err != nil {
+			return false, err
+		}
+
+		return pvcStatus.Phase == corev1.ClaimBound, nil
+	}, func() string { return fmt.Sprintf("claim phase %q", pvcStatus.Phase) })
+	if err != nil {
+		return fmt.Errorf("claim %s of storage class %s was not bound: %w", pvcObject.ID, storageClassName, err)
+	}
+
+	return nil
+}
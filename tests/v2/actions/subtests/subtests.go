@@ -0,0 +1,88 @@
+package subtests
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/rancher/rancher/tests/v2/actions/sessionid"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	maxPrefixBaseLength = 20
+	prefixRandomLength  = 5
+)
+
+var invalidNameCharacters = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Func is the function type of a subtest run by RunParallel. The client has a session of its own, which is cleaned up
+// when the subtest ends, and the name prefix is unique to the subtest so the resources it creates don't collide.
+type Func func(t *testing.T, client *rancher.Client, namePrefix string)
+
+// Case is a struct of a single table driven subtest.
+type Case struct {
+	Name string
+	Run  Func
+}
+
+// RunParallel is a helper function that runs the subtest in parallel with the other parallel subtests of t.
+// The session of the subtest is created from the session of the client before the subtest is paused, as sessions
+// are not safe for concurrent use, so the parent session still cleans up whatever the subtest leaves behind.
+func RunParallel(t *testing.T, client *rancher.Client, name string, subtest Func) bool {
+	return t.Run(name, func(t *testing.T) {
+		subClient, subSession, err := CloneClient(client)
+		require.NoError(t, err)
+
+		t.Cleanup(subSession.Cleanup)
+		t.Parallel()
+
+		subtest(t, subClient, NamePrefix(t))
+	})
+}
+
+// RunParallelCases is a helper function that runs all table driven cases with RunParallel and waits for them to finish.
+func RunParallelCases(t *testing.T, client *rancher.Client, cases []Case) {
+	t.Run("parallel", func(t *testing.T) {
+		for _, tt := range cases {
+			RunParallel(t, client, tt.Name, tt.Run)
+		}
+	})
+}
+
+// CloneClient is a helper function that returns a copy of the client with a new sub-session of the session of the client.
+// It registers the sub-session in the parent session, so it must be called from the goroutine that owns the parent session.
+// The copy sends the session ID header of the client, if it has one.
+func CloneClient(client *rancher.Client) (*rancher.Client, *session.Session, error) {
+	subSession := client.Session.NewSession()
+
+	subClient, err := sessionid.WithSession(client, subSession)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return subClient, subSession, nil
+}
+
+// NamePrefix is a helper function that returns a prefix for the names of the resources created by a test, built
+// from the last element of the test name and a random string, e.g. "install-chart-x7k2q-".
+func NamePrefix(t *testing.T) string {
+	name := t.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	base := strings.Trim(invalidNameCharacters.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(base) > maxPrefixBaseLength {
+		base = strings.Trim(base[:maxPrefixBaseLength], "-")
+	}
+
+	if base == "" {
+		return namegenerator.RandStringLower(prefixRandomLength) + "-"
+	}
+
+	return base + "-" + namegenerator.RandStringLower(prefixRandomLength) + "-"
+}
@@ -0,0 +1,244 @@
+package sweeper
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/sirupsen/logrus"
+)
+
+// The json/yaml config key for the sweeper
+const ConfigurationFileKey = "sweeper"
+
+const (
+	defaultUserPrefix    = "testuser-"
+	defaultProjectPrefix = "testproject-"
+	defaultOlderThan     = 24 * time.Hour
+	adminUsername        = "admin"
+)
+
+// Config is the configuration of the sweeper, e.g.
+//
+//	sweeper:
+//	  userPrefixes:
+//	  - testuser-
+//	  projectPrefixes:
+//	  - testproject-
+//	  labels:
+//	    cattle.io/creator: test
+//	  olderThan: 24h
+//	  dryRun: true
+//
+// Users and projects are swept when their name starts with one of the prefixes, or when they have all the labels, and
+// they were created longer than olderThan ago, so the resources of the test sessions still running are kept. The
+// prefixes default to the ones of the shepherd user and project helpers and olderThan to 24h. With dryRun, the
+// resources are only reported.
+type Config struct {
+	UserPrefixes    []string          `json:"userPrefixes" yaml:"userPrefixes"`
+	ProjectPrefixes []string          `json:"projectPrefixes" yaml:"projectPrefixes"`
+	Labels          map[string]string `json:"labels" yaml:"labels"`
+	OlderThan       string            `json:"olderThan" yaml:"olderThan"`
+	DryRun          bool              `json:"dryRun" yaml:"dryRun"`
+}
+
+// Report is a struct of the IDs of the resources swept, or found when it is a dry run.
+type Report struct {
+	Tokens   []string
+	Users    []string
+	Projects []string
+}
+
+// LoadConfig is a helper function that loads the sweeper configuration from the config file, with its defaults.
+func LoadConfig() *Config {
+	sweeperConfig := new(Config)
+	config.LoadConfig(ConfigurationFileKey, sweeperConfig)
+
+	if len(sweeperConfig.UserPrefixes) == 0 {
+		sweeperConfig.UserPrefixes = []string{defaultUserPrefix}
+	}
+
+	if len(sweeperConfig.ProjectPrefixes) == 0 {
+		sweeperConfig.ProjectPrefixes = []string{defaultProjectPrefix}
+	}
+
+	return sweeperConfig
+}
+
+// Sweep is a helper function that removes the expired tokens and the users and projects left by previous test sessions
+// from the rancher server, so long-lived shared servers don't accumulate them. The client must be an admin client. It
+// keeps sweeping when a resource can't be deleted and returns the errors joined with the report of the rest.
+func Sweep(client *rancher.Client, sweeperConfig *Config) (*Report, error) {
+	olderThan, err := sweeperConfig.olderThan()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	var errs []error
+
+	report.Tokens, err = SweepExpiredTokens(client, sweeperConfig.DryRun)
+	errs = append(errs, err)
+
+	report.Projects, err = SweepProjects(client, sweeperConfig.ProjectPrefixes, sweeperConfig.Labels, olderThan, sweeperConfig.DryRun)
+	errs = append(errs, err)
+
+	report.Users, err = SweepUsers(client, sweeperConfig.UserPrefixes, sweeperConfig.Labels, olderThan, sweeperConfig.DryRun)
+	errs = append(errs, err)
+
+	logrus.Infof("Swept %d tokens, %d projects and %d users (dry run: %t)", len(report.Tokens), len(report.Projects), len(report.Users), sweeperConfig.DryRun)
+
+	return report, errors.Join(errs...)
+}
+
+// SweepExpiredTokens is a helper function that deletes the expired tokens of every user, except the token of the client,
+// and returns their IDs.
+func SweepExpiredTokens(client *rancher.Client, dryRun bool) ([]string, error) {
+	tokenList, err := client.Management.Token.ListAll(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var swept []string
+	var errs []error
+	for i := range tokenList.Data {
+		token := &tokenList.Data[i]
+		if !token.Expired || token.Current {
+			continue
+		}
+
+		err = sweep(dryRun, "token", token.ID, func() error {
+			return client.Management.Token.Delete(token)
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		swept = append(swept, token.ID)
+	}
+
+	return swept, errors.Join(errs...)
+}
+
+// SweepUsers is a helper function that deletes the users whose username starts with one of the prefixes, or which have
+// all the labels, created longer than olderThan ago, and returns their IDs. The admin user is never deleted.
+func SweepUsers(client *rancher.Client, prefixes []string, labels map[string]string, olderThan time.Duration, dryRun bool) ([]string, error) {
+	userList, err := client.Management.User.ListAll(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var swept []string
+	var errs []error
+	for i := range userList.Data {
+		user := &userList.Data[i]
+		if user.Username == adminUsername || !isStale(user.Username, user.Labels, user.Created, prefixes, labels, olderThan) {
+			continue
+		}
+
+		err = sweep(dryRun, "user", user.ID, func() error {
+			return client.Management.User.Delete(user)
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		swept = append(swept, user.ID)
+	}
+
+	return swept, errors.Join(errs...)
+}
+
+// SweepProjects is a helper function that deletes the projects of every cluster whose name starts with one of the
+// prefixes, or which have all the labels, created longer than olderThan ago, and returns their IDs.
+func SweepProjects(client *rancher.Client, prefixes []string, labels map[string]string, olderThan time.Duration, dryRun bool) ([]string, error) {
+	projectList, err := client.Management.Project.ListAll(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var swept []string
+	var errs []error
+	for i := range projectList.Data {
+		project := &projectList.Data[i]
+		if !isStale(project.Name, project.Labels, project.Created, prefixes, labels, olderThan) {
+			continue
+		}
+
+		err = sweep(dryRun, "project", project.ID, func() error {
+			return client.Management.Project.Delete(project)
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		swept = append(swept, project.ID)
+	}
+
+	return swept, errors.Join(errs...)
+}
+
+// olderThan is a private method that returns the parsed age of the resources to sweep, or its default.
+func (c *Config) olderThan() (time.Duration, error) {
+	if c.OlderThan == "" {
+		return defaultOlderThan, nil
+	}
+
+	olderThan, err := time.ParseDuration(c.OlderThan)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sweeper olderThan %q: %w", c.OlderThan, err)
+	}
+
+	return olderThan, nil
+}
+
+// isStale is a private helper function that returns true when the resource name starts with one of the prefixes, or
+// when the resource has all the labels, and it was created longer than olderThan ago. Resources whose creation time
+// can't be parsed are kept.
+func isStale(name string, resourceLabels map[string]string, created string, prefixes []string, labels map[string]string, olderThan time.Duration) bool {
+	createdTime, err := time.Parse(time.RFC3339, created)
+	if err != nil || time.Since(createdTime) < olderThan {
+		return false
+	}
+
+	for _, prefix := range prefixes {
+		if prefix != "" && strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	if len(labels) == 0 {
+		return false
+	}
+
+	for key, value := range labels {
+		if resourceLabels[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sweep is a private helper function that logs the resource and deletes it, unless it is a dry run.
+func sweep(dryRun bool, kind, id string, deleteFunc func() error) error {
+	if dryRun {
+		logrus.Infof("Found stale %s %s", kind, id)
+		return nil
+	}
+
+	logrus.Infof("Deleting stale %s %s", kind, id)
+
+	err := deleteFunc()
+	if err != nil {
+		return fmt.Errorf("unable to delete %s %s: %w", kind, id, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package timeouts
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/sirupsen/logrus"
+)
+
+// The json/yaml config key for the wait helpers timeouts
+const ConfigurationFileKey = "timeouts"
+
+const (
+	defaultPollInterval = 5 * time.Second
+	defaultTimeout      = 30 * time.Minute
+)
+
+// Config is the configuration of the poll interval and timeout used by the wait helpers, e.g.
+//
+//	timeouts:
+//	  pollInterval: 10s
+//	  timeout: 45m
+//	  multiplier: 2
+//
+// Durations are parsed with time.ParseDuration. Multiplier scales every timeout, including the ones passed explicitly
+// to Scale, so slow environments can be accommodated without editing the constants of the suites.
+type Config struct {
+	PollInterval string  `json:"pollInterval" yaml:"pollInterval"`
+	Timeout      string  `json:"timeout" yaml:"timeout"`
+	Multiplier   float64 `json:"multiplier" yaml:"multiplier"`
+}
+
+// Options is a struct of the poll interval and timeout of a single wait. Zero values fall back to the configured defaults.
+type Options struct {
+	PollInterval time.Duration
+	Timeout      time.Duration
+}
+
+var (
+	loadOnce     sync.Once
+	mutex        sync.RWMutex
+	pollInterval = defaultPollInterval
+	timeout      = defaultTimeout
+	multiplier   = 1.0
+)
+
+// PollInterval returns the default interval wait helpers poll at.
+func PollInterval() time.Duration {
+	load()
+
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	return pollInterval
+}
+
+// Timeout returns the default timeout of the wait helpers, already scaled by the multiplier.
+func Timeout() time.Duration {
+	load()
+
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	return time.Duration(float64(timeout) * multiplier)
+}
+
+// Scale returns the given timeout scaled by the configured multiplier. It is meant for the wait helpers that have
+// a timeout of their own instead of the default one.
+func Scale(duration time.Duration) time.Duration {
+	load()
+
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	return time.Duration(float64(duration) * multiplier)
+}
+
+// SetDefaults overrides the default poll interval and timeout for the rest of the process, values that aren't positive
+// are ignored.
+func SetDefaults(newPollInterval, newTimeout time.Duration) {
+	load()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if newPollInterval > 0 {
+		pollInterval = newPollInterval
+	}
+
+	if newTimeout > 0 {
+		timeout = newTimeout
+	}
+}
+
+// GetPollInterval returns the poll interval of the options, or the default one if it is not set. It is safe to call on nil options.
+func (o *Options) GetPollInterval() time.Duration {
+	if o == nil || o.PollInterval <= 0 {
+		return PollInterval()
+	}
+
+	return o.PollInterval
+}
+
+// GetTimeout returns the timeout of the options scaled by the multiplier, or the default one if it is not set.
+// It is safe to call on nil options.
+func (o *Options) GetTimeout() time.Duration {
+	if o == nil || o.Timeout <= 0 {
+		return Timeout()
+	}
+
+	return Scale(o.Timeout)
+}
+
+// load is a private function that reads the configuration file once.
+func load() {
+	loadOnce.Do(func() {
+		timeoutsConfig := new(Config)
+		config.LoadConfig(ConfigurationFileKey, timeoutsConfig)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if timeoutsConfig.PollInterval != "" {
+			parsed, err := time.ParseDuration(timeoutsConfig.PollInterval)
+			if err != nil {
+				logrus.Warnf("Ignoring invalid %s.pollInterval %q: %v", ConfigurationFileKey, timeoutsConfig.PollInterval, err)
+			} else if parsed <= 0 {
+				logrus.Warnf("Ignoring invalid %s.pollInterval %q: must be positive", ConfigurationFileKey, timeoutsConfig.PollInterval)
+			} else {
+				pollInterval = parsed
+			}
+		}
+
+		if timeoutsConfig.Timeout != "" {
+			parsed, err := time.ParseDuration(timeoutsConfig.Timeout)
+			if err != nil {
+				logrus.Warnf("Ignoring invalid %s.timeout %q: %v", ConfigurationFileKey, timeoutsConfig.Timeout, err)
+			} else if parsed <= 0 {
+				logrus.Warnf("Ignoring invalid %s.timeout %q: must be positive", ConfigurationFileKey, timeoutsConfig.Timeout)
+			} else {
+				timeout = parsed
+			}
+		}
+
+		if timeoutsConfig.Multiplier > 0 {
+			multiplier = timeoutsConfig.Multiplier
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package uiplugins
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	catalogv1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	actionscatalog "github.com/rancher/rancher/tests/v2/actions/catalog"
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/pkg/api/steve/catalog/types"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// UIPluginNamespace is the namespace the UI extensions are installed in
+	UIPluginNamespace = "cattle-ui-plugin-system"
+
+	localClusterID     = "local"
+	uiPluginIndexPath  = "v1/uiplugins"
+	installTimeout     = 5 * time.Minute
+	cacheStateCached   = "cached"
+	cacheStateDisabled = "disabled"
+)
+
+// UIPlugin is a struct of the UI extension chart to install from a cluster repository.
+type UIPlugin struct {
+	RepoName  string
+	ChartName string
+	Version   string
+	Values    map[string]interface{}
+}
+
+// CreateUIPluginRepo is a helper function that creates the cluster repository of the git repository hosting UI extension
+// charts, e.g. https://github.com/rancher/ui-plugin-examples, and waits until it is downloaded. The repository is
+// deleted by the session of the client.
+func CreateUIPluginRepo(client *rancher.Client, name, gitRepo, gitBranch string) error {
+	_, err := actionscatalog.CreateClusterRepo(client, localClusterID, &actionscatalog.ClusterRepoOpts{
+		Name:      name,
+		GitRepo:   gitRepo,
+		GitBranch: gitBranch,
+	})
+
+	return err
+}
+
+// InstallUIPlugin is a helper function that installs the UI extension chart in the local cluster and waits until the
+// UIPlugin it creates is cached by rancher. The chart is uninstalled by the session of the client.
+func InstallUIPlugin(client *rancher.Client, uiPlugin *UIPlugin) error {
+	catalogClient, err := client.GetClusterCatalogClient(localClusterID)
+	if err != nil {
+		return err
+	}
+
+	err = catalogClient.InstallChart(&types.ChartInstallAction{
+		Timeout:   &metav1.Duration{Duration: installTimeout},
+		Wait:      true,
+		Namespace: UIPluginNamespace,
+		Charts: []types.ChartInstall{{
+			ChartName:   uiPlugin.ChartName,
+			Version:     uiPlugin.Version,
+			ReleaseName: uiPlugin.ChartName,
+			Description: uiPlugin.ChartName,
+			Values:      uiPlugin.Values,
+		}},
+	}, uiPlugin.RepoName)
+	if err != nil {
+		return err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		return actionscharts.UninstallChart(client, localClusterID, UIPluginNamespace, uiPlugin.ChartName)
+	})
+
+	logrus.Infof("Installed UI extension %s %s, waiting for it to be cached", uiPlugin.ChartName, uiPlugin.Version)
+
+	return WaitForUIPlugin(client, uiPlugin.ChartName, uiPlugin.Version)
+}
+
+// WaitForUIPlugin is a helper function that waits until the UIPlugin of the extension has the version and is cached by
+// rancher, or has caching disabled, so it is served by the plugin index.
+func WaitForUIPlugin(client *rancher.Client, name, version string) error {
+	catalogClient, err := client.GetClusterCatalogClient(localClusterID)
+	if err != nil {
+		return err
+	}
+
+	return kwait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Timeout(), true, func(ctx context.Context) (done bool, err error) {
+		uiPlugin, err := catalogClient.UIPlugins(UIPluginNamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		if uiPlugin.Spec.Plugin.Version != version {
+			return false, nil
+		}
+
+		return uiPlugin.Status.CacheState == cacheStateCached || uiPlugin.Status.CacheState == cacheStateDisabled, nil
+	})
+}
+
+// GetUIPluginIndex is a helper function that returns the entries of the plugin index served by rancher to the client,
+// by extension name. Extensions that require authentication are only listed to authenticated clients.
+func GetUIPluginIndex(client *rancher.Client) (map[string]*catalogv1.UIPluginEntry, error) {
+	result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, uiPluginIndexPath, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if !result.Ok {
+		return nil, fmt.Errorf("unable to get the plugin index: %s", result.Body)
+	}
+
+	index := struct {
+		Entries map[string]*catalogv1.UIPluginEntry `json:"entries"`
+	}{}
+	err = json.Unmarshal([]byte(result.Body), &index)
+	if err != nil {
+		return nil, err
+	}
+
+	return index.Entries, nil
+}
+
+// VerifyUIPluginAvailable is a helper function that checks the extension is listed in the plugin index with the
+// version and that its entry point is served.
+func VerifyUIPluginAvailable(client *rancher.Client, name, version string) error {
+	index, err := GetUIPluginIndex(client)
+	if err != nil {
+		return err
+	}
+
+	entry, ok := index[name]
+	if !ok {
+		return fmt.Errorf("UI extension %s is not listed in the plugin index", name)
+	}
+
+	if entry.Version != version {
+		return fmt.Errorf("UI extension %s is listed with version %s, expected %s", name, entry.Version, version)
+	}
+
+	path := fmt.Sprintf("%s/%s/%s/plugin/%s-%s.umd.min.js", uiPluginIndexPath, name, version, name, version)
+
+	result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, path, true)
+	if err != nil {
+		return err
+	}
+
+	if !result.Ok {
+		return fmt.Errorf("the entry point of UI extension %s %s is not served", name, version)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package userpreferences
+
+import (
+	"fmt"
+
+	"github.com/rancher/norman/types"
+	"github.com/rancher/shepherd/clients/rancher"
+	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
+)
+
+const (
+	// AfterLoginRouteKey is the preference of the page the UI lands on after login, e.g. "home" or the JSON of a route
+	AfterLoginRouteKey = "after-login-route"
+	// LandingKey is the preference of the UI the user lands on, "vue" for the dashboard or "ember" for the cluster manager
+	LandingKey = "landing"
+	// ThemeKey is the preference of the UI theme, "ui-light", "ui-dark" or "ui-auto"
+	ThemeKey = "theme"
+	// ClusterKey is the preference of the cluster the UI last showed
+	ClusterKey = "cluster"
+	// LocaleKey is the preference of the UI language, e.g. "en-us"
+	LocaleKey = "locale"
+
+	nameFilter = "name"
+)
+
+// GetUserPreferences is a helper function that returns the preferences of the user of the client by name. The values
+// are the strings the UI stores, most of them JSON encoded, e.g. "\"ui-dark\"" for the theme.
+func GetUserPreferences(client *rancher.Client) (map[string]string, error) {
+	preferenceList, err := client.Management.Preference.ListAll(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	preferences := map[string]string{}
+	for _, preference := range preferenceList.Data {
+		preferences[preference.Name] = preference.Value
+	}
+
+	return preferences, nil
+}
+
+// GetUserPreference is a helper function that returns the value of the preference of the user of the client, or an
+// empty string when the preference is not set.
+func GetUserPreference(client *rancher.Client, name string) (string, error) {
+	preference, err := getPreference(client, name)
+	if err != nil || preference == nil {
+		return "", err
+	}
+
+	return preference.Value, nil
+}
+
+// SetUserPreference is a helper function that sets the value of the preference of the user of the client. The previous
+// value is restored by the session of the client, or the preference is deleted if it was not set.
+func SetUserPreference(client *rancher.Client, name, value string) error {
+	preference, err := getPreference(client, name)
+	if err != nil {
+		return err
+	}
+
+	if preference == nil {
+		createdPreference, err := client.Management.Preference.Create(&management.Preference{
+			Name:  name,
+			Value: value,
+		})
+		if err != nil {
+			return err
+		}
+
+		client.Session.RegisterCleanupFunc(func() error {
+			return client.Management.Preference.Delete(createdPreference)
+		})
+
+		return nil
+	}
+
+	previousValue := preference.Value
+
+	updatedPreference, err := client.Management.Preference.Update(preference, map[string]interface{}{"value": value})
+	if err != nil {
+		return err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		_, err := client.Management.Preference.Update(updatedPreference, map[string]interface{}{"value": previousValue})
+		return err
+	})
+
+	return nil
+}
+
+// SetUserPreferences is a helper function that sets the values of the preferences of the user of the client, restoring
+// the previous ones with the session of the client like SetUserPreference.
+func SetUserPreferences(client *rancher.Client, preferences map[string]string) error {
+	for name, value := range preferences {
+		err := SetUserPreference(client, name, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// VerifyUserPreferences is a helper function that checks the user of the client has the expected preferences. To check
+// they persist across logins or upgrades, the client must log in again first, e.g. with client.AsUser.
+func VerifyUserPreferences(client *rancher.Client, expectedPreferences map[string]string) error {
+	preferences, err := GetUserPreferences(client)
+	if err != nil {
+		return err
+	}
+
+	for name, expectedValue := range expectedPreferences {
+		value, ok := preferences[name]
+		if !ok {
+			return fmt.Errorf("preference %s is not set, expected %q", name, expectedValue)
+		}
+
+		if value != expectedValue {
+			return fmt.Errorf("preference %s is %q, expected %q", name, value, expectedValue)
+		}
+	}
+
+	return nil
+}
+
+// getPreference is a private helper function that returns the preference of the user of the client, or nil when it is
+// not set.
+func getPreference(client *rancher.Client, name string) (*management.Preference, error) {
+	preferenceList, err := client.Management.Preference.List(&types.ListOpts{
+		Filters: map[string]interface{}{
+			nameFilter: name,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, preference := range preferenceList.Data {
+		if preference.Name == name {
+			return &preferenceList.Data[i], nil
+		}
+	}
+
+	return nil, nil
+}
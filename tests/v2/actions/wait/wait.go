@@ -0,0 +1,43 @@
+package wait
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Predicate is the condition a wait polls. Returning an error stops the wait with it, returning false keeps polling.
+type Predicate func(ctx context.Context) (done bool, err error)
+
+// For is a helper function that polls the predicate at the interval, immediately first, until it is done, it returns an
+// error, the timeout expires or the context is canceled. A zero interval or timeout falls back to the configured
+// defaults of the timeouts package. When the wait times out or is canceled, the returned error holds the description
+// of the last observed state returned by describe, which may be nil, so failures say what was waited for and what was
+// seen instead. The error wraps the one of the context, so kwait.Interrupted still reports it.
+func For(ctx context.Context, interval, timeout time.Duration, predicate Predicate, describe func() string) error {
+	if interval == 0 {
+		interval = timeouts.PollInterval()
+	}
+
+	if timeout == 0 {
+		timeout = timeouts.Timeout()
+	}
+
+	err := kwait.PollUntilContextTimeout(ctx, interval, timeout, true, kwait.ConditionWithContextFunc(predicate))
+	if err == nil {
+		return nil
+	}
+
+	if !kwait.Interrupted(err) {
+		return err
+	}
+
+	if describe == nil {
+		return fmt.Errorf("timed out after %s: %w", timeout, err)
+	}
+
+	return fmt.Errorf("timed out after %s, last observed %s: %w", timeout, describe(), err)
+}
@@ -0,0 +1,178 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
+	"github.com/rancher/shepherd/extensions/clusters"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/sirupsen/logrus"
+)
+
+// The names of the rancher-webhook admission webhooks the denials of this package are checked against
+const (
+	GlobalRolesWebhookName                 = "rancher.cattle.io.globalroles.management.cattle.io"
+	ClusterRoleTemplateBindingsWebhookName = "rancher.cattle.io.clusterroletemplatebindings.management.cattle.io"
+	ProvisioningClustersWebhookName        = "rancher.cattle.io.clusters.provisioning.cattle.io"
+)
+
+const (
+	adminGlobalRoleID             = "admin"
+	clusterOwnerRoleTemplateID    = "cluster-owner"
+	creatorIDAnnotation           = "field.cattle.io/creatorId"
+	provisioningSteveResourceType = "provisioning.cattle.io.cluster"
+
+	admissionWebhookMessage = "admission webhook"
+	deniedRequestMessage    = "denied the request"
+	deniedRequestFormat     = "admission webhook %q denied the request"
+	escalationMessage       = `not currently held|escalat`
+	creatorIDMessage        = "creatorId annotation cannot be changed"
+)
+
+// Denial is a struct of an operation the rancher-webhook is expected to deny. The webhook name is optional, the message
+// is a regular expression matched against the rejection.
+type Denial struct {
+	Name    string
+	Attempt func(client *rancher.Client) error
+	Webhook string
+	Message string
+}
+
+// VerifyDenied is a helper function that checks the error is a rejection of the webhook, any rancher-webhook when the
+// name is empty, whose message matches the regular expression.
+func VerifyDenied(err error, webhookName, message string) error {
+	if err == nil {
+		return errors.New("the request was not denied by the webhook")
+	}
+
+	errMessage := err.Error()
+	if webhookName != "" && !strings.Contains(errMessage, fmt.Sprintf(deniedRequestFormat, webhookName)) {
+		return fmt.Errorf("the request was not denied by webhook %s: %w", webhookName, err)
+	}
+
+	if !strings.Contains(errMessage, admissionWebhookMessage) || !strings.Contains(errMessage, deniedRequestMessage) {
+		return fmt.Errorf("the request was not denied by a webhook: %w", err)
+	}
+
+	matched, err := regexp.MatchString(message, errMessage)
+	if err != nil {
+		return err
+	}
+
+	if !matched {
+		return fmt.Errorf("the rejection %q does not match %q", errMessage, message)
+	}
+
+	return nil
+}
+
+// VerifyDenials is a helper function that attempts the operations as the client and checks each of them is denied,
+// returning the errors of all the operations that were not.
+func VerifyDenials(client *rancher.Client, denials ...Denial) error {
+	var errs []error
+	for _, denial := range denials {
+		logrus.Infof("Verifying the webhook denies: %s", denial.Name)
+
+		err := VerifyDenied(denial.Attempt(client), denial.Webhook, denial.Message)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", denial.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// UpdateAdminGlobalRole is a constructor that returns the denial of making the admin global role a default of new
+// users, which a user that is not an admin, e.g. a restricted admin, must not be able to do.
+func UpdateAdminGlobalRole() Denial {
+	return Denial{
+		Name: "update of the admin global role",
+		Attempt: func(client *rancher.Client) error {
+			adminRole, err := client.Management.GlobalRole.ByID(adminGlobalRoleID)
+			if err != nil {
+				return err
+			}
+
+			updatedAdminRole := *adminRole
+			updatedAdminRole.NewUserDefault = true
+
+			_, err = client.Management.GlobalRole.Update(adminRole, updatedAdminRole)
+			return err
+		},
+		Webhook: GlobalRolesWebhookName,
+	}
+}
+
+// CreateEscalatingGlobalRole is a constructor that returns the denial of creating a global role granting every
+// permission, which escalates the privileges of a user that is not an admin.
+func CreateEscalatingGlobalRole() Denial {
+	return Denial{
+		Name: "creation of an escalating global role",
+		Attempt: func(client *rancher.Client) error {
+			_, err := client.Management.GlobalRole.Create(&management.GlobalRole{
+				Name: namegen.AppendRandomString("escalating"),
+				Rules: []management.PolicyRule{{
+					APIGroups: []string{"*"},
+					Resources: []string{"*"},
+					Verbs:     []string{"*"},
+				}},
+			})
+			return err
+		},
+		Webhook: GlobalRolesWebhookName,
+		Message: escalationMessage,
+	}
+}
+
+// BindClusterOwner is a constructor that returns the denial of binding the user as owner of the cluster, which
+// escalates the privileges of a user that is not an owner of the cluster.
+func BindClusterOwner(clusterID, userID string) Denial {
+	return Denial{
+		Name: "binding of the cluster owner role",
+		Attempt: func(client *rancher.Client) error {
+			_, err := client.Management.ClusterRoleTemplateBinding.Create(&management.ClusterRoleTemplateBinding{
+				ClusterID:      clusterID,
+				RoleTemplateID: clusterOwnerRoleTemplateID,
+				UserID:         userID,
+			})
+			return err
+		},
+		Webhook: ClusterRoleTemplateBindingsWebhookName,
+		Message: escalationMessage,
+	}
+}
+
+// ChangeClusterCreator is a constructor that returns the denial of changing the creator annotation of the provisioning
+// cluster, which is immutable for every user.
+func ChangeClusterCreator(clusterName string) Denial {
+	return Denial{
+		Name: "change of the creator of the cluster",
+		Attempt: func(client *rancher.Client) error {
+			id, err := clusters.GetV1ProvisioningClusterByName(client, clusterName)
+			if err != nil {
+				return err
+			}
+
+			cluster, err := client.Steve.SteveType(provisioningSteveResourceType).ByID(id)
+			if err != nil {
+				return err
+			}
+
+			updatedCluster := *cluster
+			updatedCluster.ObjectMeta.Annotations = map[string]string{}
+			for key, value := range cluster.ObjectMeta.Annotations {
+				updatedCluster.ObjectMeta.Annotations[key] = value
+			}
+			updatedCluster.ObjectMeta.Annotations[creatorIDAnnotation] = namegen.AppendRandomString("u")
+
+			_, err = client.Steve.SteveType(provisioningSteveResourceType).Update(cluster, updatedCluster)
+			return err
+		},
+		Webhook: ProvisioningClustersWebhookName,
+		Message: regexp.QuoteMeta(creatorIDMessage),
+	}
+}
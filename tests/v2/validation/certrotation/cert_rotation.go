@@ -1,7 +1,6 @@
 package certrotation
 
 import (
-	"context"
 	"errors"
 	"io"
 	"net/http"
@@ -9,23 +8,17 @@
 	"strings"
 
 	"github.com/rancher/norman/types"
-	apiv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
-	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	actionscertrotation "github.com/rancher/rancher/tests/v2/actions/certrotation"
 	"github.com/rancher/shepherd/clients/rancher"
 	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
 	v1 "github.com/rancher/shepherd/clients/rancher/v1"
 	"github.com/rancher/shepherd/extensions/clusters"
-	"github.com/rancher/shepherd/extensions/defaults"
-	"github.com/rancher/shepherd/extensions/provisioning"
 	"github.com/rancher/shepherd/extensions/sshkeys"
 	"github.com/rancher/shepherd/pkg/nodes"
-	"github.com/rancher/shepherd/pkg/wait"
 	"github.com/sirupsen/logrus"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
-	namespace                     = "fleet-default"
 	provisioningSteveResourceType = "provisioning.cattle.io.cluster"
 	machineSteveResourceType      = "cluster.x-k8s.io.machine"
 	machineSteveAnnotation        = "cluster.x-k8s.io/machine"
@@ -54,12 +47,6 @@ func rotateCerts(client *rancher.Client, clusterName string) error {
 		return err
 	}
 
-	clusterSpec := &apiv1.ClusterSpec{}
-	err = v1.ConvertToK8sType(cluster.Spec, clusterSpec)
-	if err != nil {
-		return err
-	}
-
 	clusterID, err := clusters.GetClusterIDByName(client, clusterName)
 	if err != nil {
 		return err
@@ -94,60 +81,8 @@ func rotateCerts(client *rancher.Client, clusterName string) error {
 		nodeCertificates[node.ID] = newCertificate
 	}
 
-	updatedCluster := *cluster
-	generation := int64(1)
-	if clusterSpec.RKEConfig.RotateCertificates != nil {
-		generation = clusterSpec.RKEConfig.RotateCertificates.Generation + 1
-	}
-
-	clusterSpec.RKEConfig.RotateCertificates = &rkev1.RotateCertificates{
-		Generation: generation,
-	}
-
-	updatedCluster.Spec = *clusterSpec
-
-	_, err = client.Steve.SteveType(provisioningSteveResourceType).Update(cluster, updatedCluster)
-	if err != nil {
-		return err
-	}
-
-	logrus.Infof("updated cluster, certs are rotating...")
-	kubeRKEClient, err := client.GetKubeAPIRKEClient()
-	if err != nil {
-		return err
-	}
-
-	result, err := kubeRKEClient.RKEControlPlanes(namespace).Watch(context.TODO(), metav1.ListOptions{
-		FieldSelector:  "metadata.name=" + clusterName,
-		TimeoutSeconds: &defaults.WatchTimeoutSeconds,
-	})
-	if err != nil {
-		return err
-	}
-
-	checkFunc := provisioning.CertRotationCompleteCheckFunc(generation)
-	logrus.Infof("waiting for certs to rotate, checking status now...")
-	err = wait.WatchWait(result, checkFunc)
-	if err != nil {
-		return err
-	}
-
-	kubeProvisioningClient, err := client.GetKubeAPIProvisioningClient()
-	if err != nil {
-		return err
-	}
-
-	clusterWait, err := kubeProvisioningClient.Clusters("fleet-default").Watch(context.TODO(), metav1.ListOptions{
-		FieldSelector:  "metadata.name=" + clusterName,
-		TimeoutSeconds: &defaults.WatchTimeoutSeconds,
-	})
-	if err != nil {
-		return err
-	}
-
-	clusterCheckFunc := clusters.IsProvisioningClusterReady
-	logrus.Infof("waiting for cluster to become active again, checking status now...")
-	err = wait.WatchWait(clusterWait, clusterCheckFunc)
+	logrus.Infof("rotating certs, waiting for the cluster to become active again...")
+	err = actionscertrotation.RotateCertificates(client, clusterName)
 	if err != nil {
 		return err
 	}
@@ -196,27 +131,8 @@ func rotateRKE1Certs(client *rancher.Client, clusterName string) error {
 		nodeCertificates[node.ID] = newCertificate
 	}
 
-	cluster, err := client.Management.Cluster.ByID(clusterID)
-	if err != nil {
-		return err
-	}
-
-	_, err = client.Management.Cluster.ActionRotateCertificates(
-		cluster,
-		&management.RotateCertificateInput{CACertificates: false, Services: ""},
-	)
-	if err != nil {
-		return err
-	}
-
-	logrus.Infof("updated cluster, certs are rotating...")
-
-	adminClient, err := rancher.NewClient(client.RancherConfig.AdminToken, client.Session)
-	if err != nil {
-		return err
-	}
-
-	err = clusters.WaitClusterToBeUpgraded(adminClient, clusterID)
+	logrus.Infof("rotating certs, waiting for the cluster to become active again...")
+	err = actionscertrotation.RotateCertificates(client, clusterName)
 	if err != nil {
 		return err
 	}
@@ -5,19 +5,21 @@
 import (
 	"os"
 	"testing"
+	"time"
 
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/clientcache"
+	"github.com/rancher/rancher/tests/v2/actions/gatekeeper"
+	"github.com/rancher/rancher/tests/v2/actions/sessionid"
 	"github.com/rancher/shepherd/clients/rancher"
 	"github.com/rancher/shepherd/clients/rancher/catalog"
 	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
-	v1 "github.com/rancher/shepherd/clients/rancher/v1"
 	"github.com/rancher/shepherd/extensions/charts"
-	"github.com/rancher/shepherd/extensions/clusters"
 	"github.com/rancher/shepherd/extensions/namespaces"
 	"github.com/rancher/shepherd/pkg/session"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type GateKeeperTestSuite struct {
@@ -36,7 +38,7 @@ func (g *GateKeeperTestSuite) SetupSuite() {
 	testSession := session.NewSession()
 	g.session = testSession
 
-	client, err := rancher.NewClient("", testSession)
+	client, err := sessionid.NewClient("", testSession)
 	require.NoError(g.T(), err)
 
 	g.client = client
@@ -46,7 +48,7 @@ func (g *GateKeeperTestSuite) SetupSuite() {
 	require.NotEmptyf(g.T(), clusterName, "Cluster name to install is not set")
 
 	// Get cluster meta
-	cluster, err := clusters.NewClusterMeta(client, clusterName)
+	cluster, err := clientcache.NewClusterMeta(client, clusterName)
 	require.NoError(g.T(), err)
 
 	// get latest version of gatekeeper chart
@@ -74,74 +76,48 @@ func (g *GateKeeperTestSuite) TestGatekeeperChart() {
 	subSession := g.session.NewSession()
 	defer subSession.Cleanup()
 
-	client, err := g.client.WithSession(subSession)
+	client, err := sessionid.WithSession(g.client, subSession)
 	require.NoError(g.T(), err)
 
 	g.T().Log("Installing latest version of gatekeeper chart")
-	err = charts.InstallRancherGatekeeperChart(client, g.gatekeeperChartInstallOptions)
-	require.NoError(g.T(), err)
-
-	g.T().Log("Waiting for gatekeeper chart deployments to have expected number of available replicas")
-	err = charts.WatchAndWaitDeployments(client, g.project.ClusterID, charts.RancherGatekeeperNamespace, metav1.ListOptions{})
-	require.NoError(g.T(), err)
-
-	g.T().Log("Waiting for gatekeeper chart DaemonSets to have expected number of available nodes")
-	err = charts.WatchAndWaitDaemonSets(client, g.project.ClusterID, charts.RancherGatekeeperNamespace, metav1.ListOptions{})
+	err = gatekeeper.InstallRancherGatekeeperChart(client, g.gatekeeperChartInstallOptions, &actionscharts.OperationOptions{StreamLogs: true})
 	require.NoError(g.T(), err)
 
 	g.T().Log("Applying constraint")
 	readYamlFile, err := os.ReadFile("./resources/opa-k8srequiredlabels.yaml")
 	require.NoError(g.T(), err)
-	yamlInput := &management.ImportClusterYamlInput{
-		DefaultNamespace: charts.RancherGatekeeperNamespace,
-		YAML:             string(readYamlFile),
-	}
 
-	// get the cluster
-	cluster, err := client.Management.Cluster.ByID(g.project.ClusterID)
-	require.NoError(g.T(), err)
-	// Use ActionImportYaml to the apply the constraint yaml file
-	_, err = client.Management.Cluster.ActionImportYaml(cluster, yamlInput)
+	constraintAppliedAt := time.Now()
+	constraint, err := gatekeeper.ApplyConstraint(client, g.project.ClusterID, string(readYamlFile))
 	require.NoError(g.T(), err)
 
 	g.T().Log("Create a namespace that doesn't have the proper label and assert that creation fails with the expected error")
 	_, err = namespaces.CreateNamespace(client, RancherDisallowedNamespace, "{}", map[string]string{}, map[string]string{}, g.project)
 	assert.ErrorContains(g.T(), err, "Bad response statusCode [403]. Status [403 Forbidden].")
+
 	g.T().Log("Waiting for gatekeeper audit to finish")
-	err = getAuditTimestamp(client, g.project)
+	auditResults, err := gatekeeper.WaitForAuditResults(client, g.project.ClusterID, constraint, constraintAppliedAt)
 	require.NoError(g.T(), err)
 
 	steveClient, err := client.Steve.ProxyDownstream(g.project.ClusterID)
 	require.NoError(g.T(), err)
 
-	// now that audit has run, get the list of constraints again
-	constraintList, err := steveClient.SteveType(ConstraintResourceSteveType).List(nil)
-	require.NoError(g.T(), err)
-
-	// parse list of constraints
-	constraintsStatusType := &ConstraintStatus{}
-	constraintStatus := constraintList.Data[0].Status
-	err = v1.ConvertToK8sType(constraintStatus, constraintsStatusType)
-	require.NoError(g.T(), err)
-
 	g.T().Log("getting list of all namespaces")
 	namespacesList, err := steveClient.SteveType(namespaces.NamespaceSteveType).List(nil)
 	require.NoError(g.T(), err)
 
-	g.T().Log("getting list of namespaces with violations...")
-	totalViolations := constraintsStatusType.TotalViolations
 	// get the number of namespaces
 	totalNamespaces := len(namespacesList.Data)
 
 	g.T().Log("Asserting that all namespaces violate the constraint")
-	assert.EqualValues(g.T(), totalNamespaces, totalViolations)
+	assert.EqualValues(g.T(), totalNamespaces, auditResults.TotalViolations)
 }
 
 func (g *GateKeeperTestSuite) TestUpgradeGatekeeperChart() {
 	subSession := g.session.NewSession()
 	defer subSession.Cleanup()
 
-	client, err := g.client.WithSession(subSession)
+	client, err := sessionid.WithSession(g.client, subSession)
 	require.NoError(g.T(), err)
 
 	// Change gatekeeper install option version to previous version of the latest version
@@ -159,7 +135,7 @@ func (g *GateKeeperTestSuite) TestUpgradeGatekeeperChart() {
 	g.gatekeeperChartInstallOptions.Version = versionBeforeLatest
 
 	g.T().Log("Checking if the gatekeeper chart is installed with one of the previous versions")
-	initialGatekeeperChart, err := charts.GetChartStatus(client, g.project.ClusterID, charts.RancherGatekeeperNamespace, charts.RancherGatekeeperName)
+	initialGatekeeperChart, err := actionscharts.GetChartStatus(client, g.project.ClusterID, charts.RancherGatekeeperNamespace, charts.RancherGatekeeperName)
 	require.NoError(g.T(), err)
 
 	if initialGatekeeperChart.IsAlreadyInstalled && initialGatekeeperChart.ChartDetails.Spec.Chart.Metadata.Version == versionLatest {
@@ -168,19 +144,11 @@ func (g *GateKeeperTestSuite) TestUpgradeGatekeeperChart() {
 
 	if !initialGatekeeperChart.IsAlreadyInstalled {
 		g.T().Log("Installing gatekeeper chart with the version before the latest version")
-		err = charts.InstallRancherGatekeeperChart(client, g.gatekeeperChartInstallOptions)
-		require.NoError(g.T(), err)
-
-		g.T().Log("Waiting gatekeeper chart deployments to have expected number of available replicas")
-		err = charts.WatchAndWaitDeployments(client, g.project.ClusterID, charts.RancherGatekeeperNamespace, metav1.ListOptions{})
-		require.NoError(g.T(), err)
-
-		g.T().Log("Waiting gatekeeper chart DaemonSets to have expected number of available nodes")
-		err = charts.WatchAndWaitDaemonSets(client, g.project.ClusterID, charts.RancherGatekeeperNamespace, metav1.ListOptions{})
+		err = gatekeeper.InstallRancherGatekeeperChart(client, g.gatekeeperChartInstallOptions, &actionscharts.OperationOptions{StreamLogs: true})
 		require.NoError(g.T(), err)
 	}
 
-	gatekeeperChartPreUpgrade, err := charts.GetChartStatus(client, g.project.ClusterID, charts.RancherGatekeeperNamespace, charts.RancherGatekeeperName)
+	gatekeeperChartPreUpgrade, err := actionscharts.GetChartStatus(client, g.project.ClusterID, charts.RancherGatekeeperNamespace, charts.RancherGatekeeperName)
 	require.NoError(g.T(), err)
 
 	// Validate current version of rancher-gatekeeper is one of the versions before latest
@@ -191,18 +159,10 @@ func (g *GateKeeperTestSuite) TestUpgradeGatekeeperChart() {
 	require.NoError(g.T(), err)
 
 	g.T().Log("Upgrading gatekeeper chart to the latest version")
-	err = charts.UpgradeRancherGatekeeperChart(client, g.gatekeeperChartInstallOptions)
-	require.NoError(g.T(), err)
-
-	g.T().Log("Waiting for gatekeeper chart deployments to have expected number of available replicas after upgrade")
-	err = charts.WatchAndWaitDeployments(client, g.project.ClusterID, charts.RancherGatekeeperNamespace, metav1.ListOptions{})
-	require.NoError(g.T(), err)
-
-	g.T().Log("Waiting gatekeeper chart DaemonSets to have expected number of available nodes after upgrade")
-	err = charts.WatchAndWaitDaemonSets(client, g.project.ClusterID, charts.RancherGatekeeperNamespace, metav1.ListOptions{})
+	err = gatekeeper.UpgradeRancherGatekeeperChart(client, g.gatekeeperChartInstallOptions, &actionscharts.OperationOptions{StreamLogs: true})
 	require.NoError(g.T(), err)
 
-	gatekeeperChartPostUpgrade, err := charts.GetChartStatus(client, g.project.ClusterID, charts.RancherGatekeeperNamespace, charts.RancherGatekeeperName)
+	gatekeeperChartPostUpgrade, err := actionscharts.GetChartStatus(client, g.project.ClusterID, charts.RancherGatekeeperNamespace, charts.RancherGatekeeperName)
 	require.NoError(g.T(), err)
 
 	g.T().Log("Comparing installed and desired gatekeeper versions")
@@ -7,6 +7,8 @@
 	"strings"
 
 	settings "github.com/rancher/rancher/pkg/settings"
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/sessionid"
 	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
 	"github.com/rancher/shepherd/extensions/charts"
 	namespaces "github.com/rancher/shepherd/extensions/namespaces"
@@ -20,7 +22,7 @@ func (n *GateKeeperTestSuite) TestGateKeeperAllowedNamespaces() {
 	subSession := n.session.NewSession()
 	defer subSession.Cleanup()
 
-	client, err := n.client.WithSession(subSession)
+	client, err := sessionid.WithSession(n.client, subSession)
 	require.NoError(n.T(), err)
 
 	if !client.Flags.GetValue(environmentflag.GatekeeperAllowedNamespaces) {
@@ -32,11 +34,11 @@ func (n *GateKeeperTestSuite) TestGateKeeperAllowedNamespaces() {
 	require.NoError(n.T(), err)
 
 	n.T().Log("Waiting for gatekeeper chart deployments to have expected number of available replicas")
-	err = charts.WatchAndWaitDeployments(client, n.project.ClusterID, charts.RancherGatekeeperNamespace, metav1.ListOptions{})
+	err = actionscharts.WatchAndWaitDeployments(client, n.project.ClusterID, charts.RancherGatekeeperNamespace, metav1.ListOptions{})
 	require.NoError(n.T(), err)
 
 	n.T().Log("Waiting for gatekeeper chart DaemonSets to have expected number of available nodes")
-	err = charts.WatchAndWaitDaemonSets(client, n.project.ClusterID, charts.RancherGatekeeperNamespace, metav1.ListOptions{})
+	err = actionscharts.WatchAndWaitDaemonSets(client, n.project.ClusterID, charts.RancherGatekeeperNamespace, metav1.ListOptions{})
 	require.NoError(n.T(), err)
 
 	n.T().Log("creating constraint template")
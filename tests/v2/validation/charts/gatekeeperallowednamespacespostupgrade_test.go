@@ -6,6 +6,7 @@
 	"strings"
 
 	settings "github.com/rancher/rancher/pkg/settings"
+	"github.com/rancher/rancher/tests/v2/actions/sessionid"
 	namespaces "github.com/rancher/shepherd/extensions/namespaces"
 	"github.com/rancher/shepherd/pkg/environmentflag"
 	"github.com/stretchr/testify/require"
@@ -16,7 +17,7 @@ func (n *GateKeeperTestSuite) TestGateKeeperAllowedNamespacesPostUpgrade() {
 	subSession := n.session.NewSession()
 	defer subSession.Cleanup()
 
-	client, err := n.client.WithSession(subSession)
+	client, err := sessionid.WithSession(n.client, subSession)
 	require.NoError(n.T(), err)
 
 	if !client.Flags.GetValue(environmentflag.GatekeeperAllowedNamespaces) {
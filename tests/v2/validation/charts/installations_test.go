@@ -5,6 +5,9 @@
 import (
 	"testing"
 
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/clientcache"
+	"github.com/rancher/rancher/tests/v2/actions/sessionid"
 	"github.com/rancher/shepherd/clients/rancher"
 	"github.com/rancher/shepherd/clients/rancher/catalog"
 	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
@@ -35,7 +38,7 @@ func (i *InstallationTestSuite) SetupSuite() {
 	testSession := session.NewSession()
 	i.session = testSession
 
-	client, err := rancher.NewClient("", testSession)
+	client, err := sessionid.NewClient("", testSession)
 	require.NoError(i.T(), err)
 
 	i.client = client
@@ -45,7 +48,7 @@ func (i *InstallationTestSuite) SetupSuite() {
 	require.NotEmptyf(i.T(), clusterName, "Cluster name to install is not set")
 
 	// Get cluster meta
-	cluster, err := clusters.NewClusterMeta(client, clusterName)
+	cluster, err := clientcache.NewClusterMeta(client, clusterName)
 	require.NoError(i.T(), err)
 
 	i.cluster = cluster
@@ -66,11 +69,13 @@ func (i *InstallationTestSuite) SetupSuite() {
 }
 
 func (i *InstallationTestSuite) TestInstallMonitoringChart() {
-	client, err := i.client.WithSession(i.session)
+	client, err := sessionid.WithSession(i.client, i.session)
 	require.NoError(i.T(), err)
 
+	actionscharts.SkipUnsupportedChart(i.T(), client, i.project.ClusterID, charts.RancherMonitoringName)
+
 	i.T().Log("Checking if the monitoring chart is already installed")
-	initialMonitoringChart, err := charts.GetChartStatus(client, i.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
+	initialMonitoringChart, err := actionscharts.GetChartStatus(client, i.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
 	require.NoError(i.T(), err)
 
 	if !initialMonitoringChart.IsAlreadyInstalled {
@@ -96,31 +101,78 @@ func (i *InstallationTestSuite) TestInstallMonitoringChart() {
 		err = charts.InstallRancherMonitoringChart(client, monitoringInstOpts, monitoringOpts)
 		require.NoError(i.T(), err)
 
-		i.T().Log("Waiting monitoring chart deployments to have expected number of available replicas")
-		err = charts.WatchAndWaitDeployments(client, i.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
+		i.T().Log("Waiting monitoring chart deployments, DaemonSets and StatefulSets to be ready")
+		err = actionscharts.WatchAndWaitWorkloads(client, i.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
 		require.NoError(i.T(), err)
+	}
+
+	isUsingRegistry, err := registries.CheckAllClusterPodsForRegistryPrefix(client, i.cluster.ID, i.registrySetting.Value)
+	require.NoError(i.T(), err)
+	assert.Truef(i.T(), isUsingRegistry, "Checking if using correct registry prefix")
+}
 
-		i.T().Log("Waiting monitoring chart DaemonSets to have expected number of available nodes")
-		err = charts.WatchAndWaitDaemonSets(client, i.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
+func (i *InstallationTestSuite) TestInstallMonitoringChartOnClusters() {
+	subSession := i.session.NewSession()
+	defer subSession.Cleanup()
+
+	client, err := sessionid.WithSession(i.client, subSession)
+	require.NoError(i.T(), err)
+
+	downstreamClusterNames, err := clusters.ListDownstreamClusters(client)
+	require.NoError(i.T(), err)
+
+	var clusterNames []string
+	for _, clusterName := range downstreamClusterNames {
+		cluster, err := clientcache.NewClusterMeta(client, clusterName)
 		require.NoError(i.T(), err)
 
-		i.T().Log("Waiting monitoring chart StatefulSets to have expected number of ready replicas")
-		err = charts.WatchAndWaitStatefulSets(client, i.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
+		monitoringChart, err := actionscharts.GetChartStatus(client, cluster.ID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
 		require.NoError(i.T(), err)
+
+		if !monitoringChart.IsAlreadyInstalled {
+			clusterNames = append(clusterNames, clusterName)
+		}
 	}
 
-	isUsingRegistry, err := registries.CheckAllClusterPodsForRegistryPrefix(client, i.cluster.ID, i.registrySetting.Value)
+	if len(clusterNames) < 2 {
+		i.T().Skip("Installing the monitoring chart in multiple clusters requires at least two downstream clusters without it")
+	}
+
+	latestMonitoringVersion, err := client.Catalog.GetLatestChartVersion(charts.RancherMonitoringName, catalog.RancherChartRepo)
+	require.NoError(i.T(), err)
+
+	monitoringOpts := &charts.RancherMonitoringOpts{
+		IngressNginx:      true,
+		ControllerManager: true,
+		Etcd:              true,
+		Proxy:             true,
+		Scheduler:         true,
+	}
+
+	i.T().Logf("Installing monitoring chart with version [%v] in clusters %v", latestMonitoringVersion, clusterNames)
+	results, err := actionscharts.InstallChartOnClusters(client, clusterNames, projectName, latestMonitoringVersion, func(client *rancher.Client, installOptions *charts.InstallOptions) error {
+		err := charts.InstallRancherMonitoringChart(client, installOptions, monitoringOpts)
+		if err != nil {
+			return err
+		}
+
+		return actionscharts.WatchAndWaitWorkloads(client, installOptions.Cluster.ID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
+	})
+	for _, result := range results {
+		i.T().Logf("Monitoring chart install in cluster [%v] took %v", result.ClusterName, result.Duration)
+	}
 	require.NoError(i.T(), err)
-	assert.Truef(i.T(), isUsingRegistry, "Checking if using correct registry prefix")
 }
 
 func (i *InstallationTestSuite) TestInstallAlertingChart() {
 	i.TestInstallMonitoringChart()
 
-	client, err := i.client.WithSession(i.session)
+	client, err := sessionid.WithSession(i.client, i.session)
 	require.NoError(i.T(), err)
 
-	alertingChart, err := charts.GetChartStatus(client, i.project.ClusterID, charts.RancherAlertingNamespace, charts.RancherAlertingName)
+	actionscharts.SkipUnsupportedChart(i.T(), client, i.project.ClusterID, charts.RancherAlertingName)
+
+	alertingChart, err := actionscharts.GetChartStatus(client, i.project.ClusterID, charts.RancherAlertingNamespace, charts.RancherAlertingName)
 	require.NoError(i.T(), err)
 
 	if !alertingChart.IsAlreadyInstalled {
@@ -150,10 +202,12 @@ func (i *InstallationTestSuite) TestInstallAlertingChart() {
 }
 
 func (i *InstallationTestSuite) TestInstallLoggingChart() {
-	client, err := i.client.WithSession(i.session)
+	client, err := sessionid.WithSession(i.client, i.session)
 	require.NoError(i.T(), err)
 
-	loggingChart, err := charts.GetChartStatus(client, i.project.ClusterID, charts.RancherLoggingNamespace, charts.RancherLoggingName)
+	actionscharts.SkipUnsupportedChart(i.T(), client, i.project.ClusterID, charts.RancherLoggingName)
+
+	loggingChart, err := actionscharts.GetChartStatus(client, i.project.ClusterID, charts.RancherLoggingNamespace, charts.RancherLoggingName)
 	require.NoError(i.T(), err)
 
 	if !loggingChart.IsAlreadyInstalled {
@@ -167,12 +221,12 @@ func (i *InstallationTestSuite) TestInstallLoggingChart() {
 			ProjectID: i.project.ID,
 		}
 
-		loggingChartFeatureOption := &charts.RancherLoggingOpts{
+		loggingChartFeatureOption := &actionscharts.RancherLoggingOpts{
 			AdditionalLoggingSources: true,
 		}
 
 		i.T().Logf("Installing logging chart with the latest version in cluster [%v] with version [%v]", i.cluster.Name, latestLoggingVersion)
-		err = charts.InstallRancherLoggingChart(client, loggingChartInstallOption, loggingChartFeatureOption)
+		err = actionscharts.InstallRancherLoggingChart(client, loggingChartInstallOption, loggingChartFeatureOption, &actionscharts.OperationOptions{StreamLogs: true})
 		require.NoError(i.T(), err)
 	}
 
@@ -184,10 +238,12 @@ func (i *InstallationTestSuite) TestInstallLoggingChart() {
 func (i *InstallationTestSuite) TestInstallIstioChart() {
 	i.TestInstallMonitoringChart()
 
-	client, err := i.client.WithSession(i.session)
+	client, err := sessionid.WithSession(i.client, i.session)
 	require.NoError(i.T(), err)
 
-	istioChart, err := charts.GetChartStatus(client, i.project.ClusterID, charts.RancherIstioNamespace, charts.RancherIstioName)
+	actionscharts.SkipUnsupportedChart(i.T(), client, i.project.ClusterID, charts.RancherIstioName)
+
+	istioChart, err := actionscharts.GetChartStatus(client, i.project.ClusterID, charts.RancherIstioNamespace, charts.RancherIstioName)
 	require.NoError(i.T(), err)
 
 	if !istioChart.IsAlreadyInstalled {
@@ -1,18 +1,15 @@
 package charts
 
 import (
-	"context"
 	"strings"
-	"time"
 	"unicode"
 
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
 	"github.com/rancher/shepherd/clients/rancher"
 	v1 "github.com/rancher/shepherd/clients/rancher/v1"
 	"github.com/rancher/shepherd/extensions/charts"
-	"github.com/rancher/shepherd/extensions/ingresses"
 	"github.com/rancher/shepherd/extensions/workloads"
 	appv1 "k8s.io/api/apps/v1"
-	kubewait "k8s.io/apimachinery/pkg/util/wait"
 )
 
 const (
@@ -62,25 +59,17 @@ func getChartCaseEndpointUntilBodyHas(client *rancher.Client, host, path, bodyPa
 		}, str)
 	}
 
-	err = kubewait.PollUntilContextTimeout(context.TODO(), 500*time.Millisecond, 2*time.Minute, true, func(context.Context) (ongoing bool, err error) {
-		bodyString, err := ingresses.GetExternalIngressResponse(client, host, path, false)
-		if err != nil {
-			return ongoing, err
-		}
-
-		trimmedBody := trimAllSpaces(bodyString)
-		if strings.Contains(trimmedBody, bodyPart) {
-			found = true
-			return !ongoing, nil
-		}
+	// the gateway is exposed on the nodes, the token of the client must not be sent to it
+	options := &actionscharts.EndpointOptions{Host: host, Path: path, Auth: actionscharts.NoAuth}
 
-		return
-	})
+	_, err = actionscharts.WaitForEndpointWithOptions(client, options, func(result *charts.GetChartCaseEndpointResult) bool {
+		return strings.Contains(trimAllSpaces(result.Body), bodyPart)
+	}, endpointTimeout)
 	if err != nil {
 		return false, err
 	}
 
-	return
+	return true, nil
 }
 
 // listIstioDeployments is a private helper function
@@ -3,20 +3,22 @@
 package charts
 
 import (
+	"errors"
 	"fmt"
-	"math/rand"
 	"os"
 	"strings"
 	"testing"
 
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/clientcache"
+	"github.com/rancher/rancher/tests/v2/actions/nodes"
+	"github.com/rancher/rancher/tests/v2/actions/sessionid"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-	"github.com/rancher/norman/types"
 	"github.com/rancher/shepherd/clients/rancher"
 	"github.com/rancher/shepherd/clients/rancher/catalog"
 	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
 	"github.com/rancher/shepherd/extensions/charts"
-	"github.com/rancher/shepherd/extensions/clusters"
 	"github.com/rancher/shepherd/extensions/ingresses"
 	"github.com/rancher/shepherd/extensions/namespaces"
 	"github.com/rancher/shepherd/pkg/session"
@@ -35,6 +37,7 @@ type IstioTestSuite struct {
 }
 
 func (i *IstioTestSuite) TearDownSuite() {
+	actionscharts.CleanupSharedCharts()
 	i.session.Cleanup()
 }
 
@@ -42,7 +45,7 @@ func (i *IstioTestSuite) SetupSuite() {
 	testSession := session.NewSession()
 	i.session = testSession
 
-	client, err := rancher.NewClient("", testSession)
+	client, err := sessionid.NewClient("", testSession)
 	require.NoError(i.T(), err)
 
 	i.client = client
@@ -52,7 +55,7 @@ func (i *IstioTestSuite) SetupSuite() {
 	require.NotEmptyf(i.T(), clusterName, "Cluster name to install is not set")
 
 	// Get cluster meta
-	cluster, err := clusters.NewClusterMeta(client, clusterName)
+	cluster, err := clientcache.NewClusterMeta(client, clusterName)
 	require.NoError(i.T(), err)
 
 	// Change kiali and jaeger paths if it's not local cluster
@@ -79,10 +82,12 @@ func (i *IstioTestSuite) SetupSuite() {
 
 	i.chartInstallOptions = &chartInstallOptions{
 		monitoring: &charts.InstallOptions{
+			Cluster:   cluster,
 			Version:   latestMonitoringVersion,
 			ProjectID: createdProject.ID,
 		},
 		istio: &charts.InstallOptions{
+			Cluster:   cluster,
 			Version:   latestIstioVersion,
 			ProjectID: createdProject.ID,
 		},
@@ -112,46 +117,30 @@ func (i *IstioTestSuite) TestIstioChart() {
 	subSession := i.session.NewSession()
 	defer subSession.Cleanup()
 
-	client, err := i.client.WithSession(subSession)
+	client, err := sessionid.WithSession(i.client, subSession)
 	require.NoError(i.T(), err)
 
-	i.T().Log("Checking if the monitoring chart is installed")
-	monitoringChart, err := charts.GetChartStatus(client, i.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
-	require.NoError(i.T(), err)
-
-	if !monitoringChart.IsAlreadyInstalled {
-		i.T().Log("Installing monitoring chart")
-		err = charts.InstallRancherMonitoringChart(client, i.chartInstallOptions.monitoring, i.chartFeatureOptions.monitoring)
-		require.NoError(i.T(), err)
-
-		i.T().Log("Waiting monitoring chart deployments to have expected number of available replicas")
-		err = charts.WatchAndWaitDeployments(client, i.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
-		require.NoError(i.T(), err)
-
-		i.T().Log("Waiting monitoring chart DaemonSets to have expected number of available nodes")
-		err = charts.WatchAndWaitDaemonSets(client, i.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
-		require.NoError(i.T(), err)
-
-		i.T().Log("Waiting monitoring chart StatefulSets to have expected number of ready replicas")
-		err = charts.WatchAndWaitStatefulSets(client, i.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
-		require.NoError(i.T(), err)
-	}
+	i.requireMonitoringChart(client)
 
 	i.T().Log("Checking if the istio chart is installed")
-	istioChart, err := charts.GetChartStatus(client, i.project.ClusterID, charts.RancherIstioNamespace, charts.RancherIstioName)
+	istioChart, err := actionscharts.GetChartStatus(client, i.project.ClusterID, charts.RancherIstioNamespace, charts.RancherIstioName)
 	require.NoError(i.T(), err)
 
 	if !istioChart.IsAlreadyInstalled {
 		i.T().Log("Installing istio chart with the latest version")
-		err = charts.InstallRancherIstioChart(client, i.chartInstallOptions.istio, i.chartFeatureOptions.istio)
+		err = actionscharts.InstallRancherIstioChart(client, i.chartInstallOptions.istio, i.chartFeatureOptions.istio, &actionscharts.OperationOptions{StreamLogs: true})
+		require.NoError(i.T(), err)
+
+		i.T().Log("Waiting istiod and the enabled istio components to be ready")
+		err = actionscharts.WaitForIstio(client, i.project.ClusterID, i.chartFeatureOptions.istio)
 		require.NoError(i.T(), err)
 
 		i.T().Log("Waiting istio chart deployments to have expected number of available replicas")
-		err = charts.WatchAndWaitDeployments(client, i.project.ClusterID, charts.RancherIstioNamespace, metav1.ListOptions{})
+		err = actionscharts.WatchAndWaitDeployments(client, i.project.ClusterID, charts.RancherIstioNamespace, metav1.ListOptions{})
 		require.NoError(i.T(), err)
 
 		i.T().Log("Waiting istio chart DaemonSets to have expected number of available nodes")
-		err = charts.WatchAndWaitDaemonSets(client, i.project.ClusterID, charts.RancherIstioNamespace, metav1.ListOptions{})
+		err = actionscharts.WatchAndWaitDaemonSets(client, i.project.ClusterID, charts.RancherIstioNamespace, metav1.ListOptions{})
 		require.NoError(i.T(), err)
 	}
 
@@ -173,28 +162,19 @@ func (i *IstioTestSuite) TestIstioChart() {
 	require.NoError(i.T(), err)
 
 	i.T().Log("Waiting example app deployments to have expected number of available replicas")
-	err = charts.WatchAndWaitDeployments(client, i.project.ClusterID, exampleAppNamespaceName, metav1.ListOptions{})
+	err = actionscharts.WatchAndWaitDeployments(client, i.project.ClusterID, exampleAppNamespaceName, metav1.ListOptions{})
 	require.NoError(i.T(), err)
 
 	i.T().Log("Validating kiali and jaeger endpoints are accessible")
-	kialiResult, err := ingresses.IsIngressExternallyAccessible(client, client.RancherConfig.Host, kialiPath, true)
-	require.NoError(i.T(), err)
-	assert.True(i.T(), kialiResult)
+	_, err = actionscharts.WaitForEndpoint(client, client.RancherConfig.Host, kialiPath, true, actionscharts.ExpectHealthy, endpointTimeout)
+	assert.NoError(i.T(), err)
 
-	tracingResult, err := ingresses.IsIngressExternallyAccessible(client, client.RancherConfig.Host, tracingPath, true)
-	require.NoError(i.T(), err)
-	assert.True(i.T(), tracingResult)
+	_, err = actionscharts.WaitForEndpoint(client, client.RancherConfig.Host, tracingPath, true, actionscharts.ExpectHealthy, endpointTimeout)
+	assert.NoError(i.T(), err)
 
-	// Get a random worker node' public external IP of a specific cluster
-	nodeCollection, err := client.Management.Node.List(&types.ListOpts{Filters: map[string]interface{}{
-		"clusterId": i.project.ClusterID,
-	}})
+	// Get the public IP of a random node of a specific cluster
+	randWorkerNodePublicIP, err := nodes.GetRandomPublicIP(client, i.project.ClusterID)
 	require.NoError(i.T(), err)
-	workerNodePublicIPs := []string{}
-	for _, node := range nodeCollection.Data {
-		workerNodePublicIPs = append(workerNodePublicIPs, node.Annotations["rke.cattle.io/external-ip"])
-	}
-	randWorkerNodePublicIP := workerNodePublicIPs[rand.Intn(len(workerNodePublicIPs))]
 	istioGatewayHost := randWorkerNodePublicIP + ":" + exampleAppPort
 
 	i.T().Log("Validating example app is accessible")
@@ -220,33 +200,13 @@ func (i *IstioTestSuite) TestUpgradeIstioChart() {
 	subSession := i.session.NewSession()
 	defer subSession.Cleanup()
 
-	client, err := i.client.WithSession(subSession)
+	client, err := sessionid.WithSession(i.client, subSession)
 	require.NoError(i.T(), err)
 
 	steveclient, err := client.Steve.ProxyDownstream(i.project.ClusterID)
 	require.NoError(i.T(), err)
 
-	i.T().Log("Checking if the monitoring chart is installed")
-	monitoringChart, err := charts.GetChartStatus(client, i.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
-	require.NoError(i.T(), err)
-
-	if !monitoringChart.IsAlreadyInstalled {
-		i.T().Log("Installing monitoring chart with the latest version")
-		err = charts.InstallRancherMonitoringChart(client, i.chartInstallOptions.monitoring, i.chartFeatureOptions.monitoring)
-		require.NoError(i.T(), err)
-
-		i.T().Log("Waiting monitoring chart deployments to have expected number of available replicas")
-		err = charts.WatchAndWaitDeployments(client, i.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
-		require.NoError(i.T(), err)
-
-		i.T().Log("Waiting monitoring chart DaemonSets to have expected number of available nodes")
-		err = charts.WatchAndWaitDaemonSets(client, i.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
-		require.NoError(i.T(), err)
-
-		i.T().Log("Waiting monitoring chart StatefulSets to have expected number of ready replicas")
-		err = charts.WatchAndWaitStatefulSets(client, i.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
-		require.NoError(i.T(), err)
-	}
+	i.requireMonitoringChart(client)
 
 	// Change istio install option version to previous version of the latest version
 	versionsList, err := client.Catalog.GetListChartVersions(charts.RancherIstioName, catalog.RancherChartRepo)
@@ -257,7 +217,7 @@ func (i *IstioTestSuite) TestUpgradeIstioChart() {
 	i.chartInstallOptions.istio.Version = versionBeforeLatest
 
 	i.T().Log("Checking if the istio chart is installed with one of the previous versions")
-	initialIstioChart, err := charts.GetChartStatus(client, i.project.ClusterID, charts.RancherIstioNamespace, charts.RancherIstioName)
+	initialIstioChart, err := actionscharts.GetChartStatus(client, i.project.ClusterID, charts.RancherIstioNamespace, charts.RancherIstioName)
 	require.NoError(i.T(), err)
 
 	if initialIstioChart.IsAlreadyInstalled && initialIstioChart.ChartDetails.Spec.Chart.Metadata.Version == versionLatest {
@@ -266,19 +226,23 @@ func (i *IstioTestSuite) TestUpgradeIstioChart() {
 
 	if !initialIstioChart.IsAlreadyInstalled {
 		i.T().Log("Installing istio chart with the last but one version")
-		err = charts.InstallRancherIstioChart(client, i.chartInstallOptions.istio, i.chartFeatureOptions.istio)
+		err = actionscharts.InstallRancherIstioChart(client, i.chartInstallOptions.istio, i.chartFeatureOptions.istio, &actionscharts.OperationOptions{StreamLogs: true})
+		require.NoError(i.T(), err)
+
+		i.T().Log("Waiting istiod and the enabled istio components to be ready")
+		err = actionscharts.WaitForIstio(client, i.project.ClusterID, i.chartFeatureOptions.istio)
 		require.NoError(i.T(), err)
 
 		i.T().Log("Waiting istio chart deployments to have expected number of available replicas")
-		err = charts.WatchAndWaitDeployments(client, i.project.ClusterID, charts.RancherIstioNamespace, metav1.ListOptions{})
+		err = actionscharts.WatchAndWaitDeployments(client, i.project.ClusterID, charts.RancherIstioNamespace, metav1.ListOptions{})
 		require.NoError(i.T(), err)
 
 		i.T().Log("Waiting istio chart DaemonSets to have expected number of available nodes")
-		err = charts.WatchAndWaitDaemonSets(client, i.project.ClusterID, charts.RancherIstioNamespace, metav1.ListOptions{})
+		err = actionscharts.WatchAndWaitDaemonSets(client, i.project.ClusterID, charts.RancherIstioNamespace, metav1.ListOptions{})
 		require.NoError(i.T(), err)
 	}
 
-	istioChartPreUpgrade, err := charts.GetChartStatus(client, i.project.ClusterID, charts.RancherIstioNamespace, charts.RancherIstioName)
+	istioChartPreUpgrade, err := actionscharts.GetChartStatus(client, i.project.ClusterID, charts.RancherIstioNamespace, charts.RancherIstioName)
 	require.NoError(i.T(), err)
 
 	// Validate current version of rancheristio is one of the versions before latest
@@ -301,18 +265,22 @@ func (i *IstioTestSuite) TestUpgradeIstioChart() {
 	require.NoError(i.T(), err)
 
 	i.T().Log("Upgrading istio chart with the latest version")
-	err = charts.UpgradeRancherIstioChart(client, i.chartInstallOptions.istio, i.chartFeatureOptions.istio)
+	err = actionscharts.UpgradeRancherIstioChart(client, i.chartInstallOptions.istio, i.chartFeatureOptions.istio, &actionscharts.OperationOptions{StreamLogs: true})
+	require.NoError(i.T(), err)
+
+	i.T().Log("Waiting istiod and the enabled istio components to be ready after upgrade")
+	err = actionscharts.WaitForIstio(client, i.project.ClusterID, i.chartFeatureOptions.istio)
 	require.NoError(i.T(), err)
 
 	i.T().Log("Waiting istio chart deployments to have expected number of available replicas after upgrade")
-	err = charts.WatchAndWaitDeployments(client, i.project.ClusterID, charts.RancherIstioNamespace, metav1.ListOptions{})
+	err = actionscharts.WatchAndWaitDeployments(client, i.project.ClusterID, charts.RancherIstioNamespace, metav1.ListOptions{})
 	require.NoError(i.T(), err)
 
 	i.T().Log("Waiting istio chart DaemonSets to have expected number of available nodes after upgrade")
-	err = charts.WatchAndWaitDaemonSets(client, i.project.ClusterID, charts.RancherIstioNamespace, metav1.ListOptions{})
+	err = actionscharts.WatchAndWaitDaemonSets(client, i.project.ClusterID, charts.RancherIstioNamespace, metav1.ListOptions{})
 	require.NoError(i.T(), err)
 
-	istioChartPostUpgrade, err := charts.GetChartStatus(client, i.project.ClusterID, charts.RancherIstioNamespace, charts.RancherIstioName)
+	istioChartPostUpgrade, err := actionscharts.GetChartStatus(client, i.project.ClusterID, charts.RancherIstioNamespace, charts.RancherIstioName)
 	require.NoError(i.T(), err)
 
 	// Compare rancheristio versions
@@ -332,6 +300,18 @@ func (i *IstioTestSuite) TestUpgradeIstioChart() {
 	}
 }
 
+// requireMonitoringChart requires the monitoring chart from the shared fixture registry, so both cases reuse one
+// install, removed by TearDownSuite. The case is skipped when the installed chart doesn't satisfy the monitoring options
+// of the suite.
+func (i *IstioTestSuite) requireMonitoringChart(client *rancher.Client) {
+	i.T().Log("Requiring the shared monitoring chart")
+	err := actionscharts.RequireSharedChart(client, i.chartInstallOptions.monitoring, actionscharts.NewRancherMonitoringFixture(i.chartFeatureOptions.monitoring, nil))
+	if errors.Is(err, actionscharts.ErrFixtureOptionsNotSatisfied) {
+		i.T().Skip(err.Error())
+	}
+	require.NoError(i.T(), err)
+}
+
 func TestIstioTestSuite(t *testing.T) {
 	suite.Run(t, new(IstioTestSuite))
 }
@@ -0,0 +1,104 @@
+//go:build (validation || infra.rke1 || cluster.any || stress) && !infra.any && !infra.aks && !infra.eks && !infra.gke && !infra.rke2k3s && !sanity && !extended
+
+package charts
+
+import (
+	"testing"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/clientcache"
+	"github.com/rancher/rancher/tests/v2/actions/longhorn"
+	"github.com/rancher/rancher/tests/v2/actions/sessionid"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/clients/rancher/catalog"
+	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	longhornVolumeNamespace = "default"
+	longhornVolumeSize      = "1Gi"
+)
+
+type LonghornTestSuite struct {
+	suite.Suite
+	client              *rancher.Client
+	session             *session.Session
+	project             *management.Project
+	chartInstallOptions *charts.InstallOptions
+}
+
+func (l *LonghornTestSuite) TearDownSuite() {
+	l.session.Cleanup()
+}
+
+func (l *LonghornTestSuite) SetupSuite() {
+	testSession := session.NewSession()
+	l.session = testSession
+
+	client, err := sessionid.NewClient("", testSession)
+	require.NoError(l.T(), err)
+
+	l.client = client
+
+	// Get clusterName from config yaml
+	clusterName := client.RancherConfig.ClusterName
+	require.NotEmptyf(l.T(), clusterName, "Cluster name to install is not set")
+
+	// Get cluster meta
+	cluster, err := clientcache.NewClusterMeta(client, clusterName)
+	require.NoError(l.T(), err)
+
+	// Get latest versions of the longhorn chart
+	latestLonghornVersion, err := client.Catalog.GetLatestChartVersion(longhorn.LonghornChartName, catalog.RancherChartRepo)
+	require.NoError(l.T(), err)
+
+	// Get project system projectId
+	project, err := clientcache.GetProjectByName(client, cluster.ID, projectName)
+	require.NoError(l.T(), err)
+
+	l.project = project
+	require.NotEmpty(l.T(), l.project)
+
+	l.chartInstallOptions = &charts.InstallOptions{
+		Cluster:   cluster,
+		Version:   latestLonghornVersion,
+		ProjectID: l.project.ID,
+	}
+}
+
+func (l *LonghornTestSuite) TestLonghornChart() {
+	subSession := l.session.NewSession()
+	defer subSession.Cleanup()
+
+	client, err := sessionid.WithSession(l.client, subSession)
+	require.NoError(l.T(), err)
+
+	actionscharts.SkipUnsupportedChart(l.T(), client, l.project.ClusterID, longhorn.LonghornChartName)
+
+	l.T().Log("Checking if the longhorn chart is already installed")
+	initialLonghornChart, err := actionscharts.GetChartStatus(client, l.project.ClusterID, longhorn.LonghornNamespace, longhorn.LonghornChartName)
+	require.NoError(l.T(), err)
+
+	if !initialLonghornChart.IsAlreadyInstalled {
+		l.T().Log("Installing longhorn chart with the latest version")
+		err = longhorn.InstallLonghornChart(client, l.chartInstallOptions, nil)
+		require.NoError(l.T(), err)
+	}
+
+	l.T().Log("Creating a volume of the longhorn storage class")
+	volumeName, err := longhorn.CreateVolume(client, l.project.ClusterID, longhornVolumeNamespace, longhornVolumeSize)
+	require.NoError(l.T(), err)
+
+	l.T().Logf("Waiting longhorn volume %s to be healthy across its replicas", volumeName)
+	err = longhorn.WaitForHealthyVolume(client, l.project.ClusterID, volumeName)
+	assert.NoError(l.T(), err)
+}
+
+func TestLonghornTestSuite(t *testing.T) {
+	suite.Run(t, new(LonghornTestSuite))
+}
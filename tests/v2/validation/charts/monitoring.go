@@ -2,28 +2,30 @@
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/url"
 	"time"
 
-	"github.com/rancher/rancher/tests/v2/validation/charts/resources"
-	"gopkg.in/yaml.v2"
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/configmaps"
+	"github.com/rancher/rancher/tests/v2/actions/hardened"
+	"github.com/rancher/rancher/tests/v2/actions/monitoring"
+	"github.com/rancher/rancher/tests/v2/actions/namer"
+	"github.com/rancher/rancher/tests/v2/actions/nodeos"
+	"github.com/rancher/rancher/tests/v2/actions/serviceaccounts"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
 
 	"github.com/pkg/errors"
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/rancher/norman/types"
 	"github.com/rancher/shepherd/clients/rancher"
 	v1 "github.com/rancher/shepherd/clients/rancher/v1"
 	"github.com/rancher/shepherd/extensions/charts"
-	"github.com/rancher/shepherd/extensions/clusterrolebindings"
-	"github.com/rancher/shepherd/extensions/configmaps"
-	"github.com/rancher/shepherd/extensions/ingresses"
-	"github.com/rancher/shepherd/extensions/serviceaccounts"
+	"github.com/rancher/shepherd/extensions/clusters"
 	"github.com/rancher/shepherd/extensions/workloads"
 	"github.com/rancher/shepherd/pkg/namegenerator"
 	corev1 "k8s.io/api/core/v1"
-	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	kubewait "k8s.io/apimachinery/pkg/util/wait"
@@ -32,12 +34,10 @@
 const (
 	// Project that example app and charts are installed in
 	projectName = "System"
-	// Secret path that contains encoded alert manager config
-	secretPath = "alertmanager.yaml"
-	// Secret ID that points alert manager secret
-	alertManagerSecretID = charts.RancherMonitoringNamespace + "/" + charts.RancherMonitoringAlertSecret
 	// Default random string length for random name generation
 	defaultRandStringLength = 5
+	// Prefix of the name of the webhook receiver namespace
+	webhookReceiverNamespacePrefix = "webhook-namespace"
 	// Webhook deployment annotation key that is being watched
 	webhookReceiverAnnotationKey = "didReceiveRequestFromAlertmanager"
 	// Webhook deployment annotation value that is being watched
@@ -46,6 +46,26 @@
 	prometheusRulesSteveType = "monitoring.coreos.com.prometheusrule"
 	// rancherShellSettingID is the setting ID that used to grab rancher/shell image
 	rancherShellSettingID = "shell-image"
+	// Timeout to wait for a chart endpoint to become healthy after install
+	endpointTimeout = 2 * time.Minute
+	// Average value of the custom metric per pod the autoscaler of the custom metrics case targets
+	customMetricTargetAverage = "1"
+	// Maximum replicas of the autoscaler of the custom metrics case
+	customMetricMaxReplicas = int32(3)
+	// Retention of prometheus set through the values of the custom values case
+	customPrometheusRetention = "2d"
+	// Prometheus flag of the retention
+	prometheusRetentionFlag = "storage.tsdb.retention.time"
+	// Label of the severity of the alerts of the inhibition case
+	severityLabel = "severity"
+	// Duration the inhibited alert of the inhibition case is checked not to be delivered for
+	inhibitedAlertCheckDuration = 2 * time.Minute
+	// Time the cluster of the reconnection case has to report its agent disconnected and to reconnect
+	clusterAgentSLO = 5 * time.Minute
+	// Time a proxied endpoint of a disconnected cluster has to respond with an error
+	unavailableEndpointBudget = 30 * time.Second
+	// Replicas of prometheus and alertmanager of the high availability case
+	monitoringHAReplicas = 2
 	// Kubeconfig that linked to webhook deployment
 	kubeConfig = `
 apiVersion: v1
@@ -84,26 +104,89 @@
 	// Rancher monitoring chart prometheus targets API path
 	prometheusTargetsPathAPI = prometheusPath + "/api/v1/targets"
 	// Webhook receiver kubernetes object names
-	webhookReceiverNamespaceName  = "webhook-namespace-" + namegenerator.RandStringLower(defaultRandStringLength)
 	webhookReceiverDeploymentName = "webhook-" + namegenerator.RandStringLower(defaultRandStringLength)
 	webhookReceiverServiceName    = "webhook-service-" + namegenerator.RandStringLower(defaultRandStringLength)
 	// Label that is used to identify webhook and rule
 	ruleLabel = map[string]string{"team": "qa"}
 )
 
+// alertWebhookReceiver is a private struct of the webhook receiver deployment the alertmanager of a test sends its alerts to.
+type alertWebhookReceiver struct {
+	namespace      string
+	deploymentName string
+	// host is the node IP and node port the receiver is reached at from outside the cluster
+	host string
+}
+
+// monitoringPaths is a private struct of the rancher proxy paths of the monitoring chart services in a cluster.
+type monitoringPaths struct {
+	alertManager         string
+	grafana              string
+	prometheusGraph      string
+	prometheusRules      string
+	prometheusTargets    string
+	prometheusTargetsAPI string
+}
+
+// newMonitoringPaths is a private constructor that prefixes the monitoring chart paths with the cluster ID where needed.
+// The package paths are left untouched, so suites and parallel subtests targeting different clusters don't interfere.
+func newMonitoringPaths(cluster *clusters.ClusterMeta) *monitoringPaths {
+	clusterPrefix := fmt.Sprintf("k8s/clusters/%s/", cluster.ID)
+
+	paths := &monitoringPaths{
+		alertManager:         alertManagerPath,
+		grafana:              grafanaPath,
+		prometheusGraph:      clusterPrefix + prometheusGraphPath,
+		prometheusRules:      clusterPrefix + prometheusRulesPath,
+		prometheusTargets:    clusterPrefix + prometheusTargetsPath,
+		prometheusTargetsAPI: prometheusTargetsPathAPI,
+	}
+
+	// Change alert manager and grafana paths if it's not local cluster
+	if !cluster.IsLocal {
+		paths.alertManager = clusterPrefix + paths.alertManager
+		paths.grafana = clusterPrefix + paths.grafana
+		paths.prometheusTargetsAPI = clusterPrefix + paths.prometheusTargetsAPI
+	}
+
+	return paths
+}
+
+// countWorkerNodes is a private helper function that returns the number of worker nodes of the cluster.
+func countWorkerNodes(client *rancher.Client, clusterID string) (int, error) {
+	nodeCollection, err := client.Management.Node.List(&types.ListOpts{Filters: map[string]interface{}{
+		"clusterId": clusterID,
+	}})
+	if err != nil {
+		return 0, err
+	}
+
+	workers := 0
+	for _, node := range nodeCollection.Data {
+		if node.Worker {
+			workers++
+		}
+	}
+
+	return workers, nil
+}
+
 // waitUnknownPrometheusTargets is a private helper function
 // that awaits the unknown Prometheus targets to be resolved until the timeout by using Prometheus API.
-func waitUnknownPrometheusTargets(client *rancher.Client) error {
+func waitUnknownPrometheusTargets(client *rancher.Client, prometheusTargetsAPIPath string) error {
 	checkUnknownPrometheusTargets := func() (bool, error) {
 		var statusInit bool
 		var unknownTargets []string
-		bodyString, err := ingresses.GetExternalIngressResponse(client, client.RancherConfig.Host, prometheusTargetsPathAPI, true)
+		result, err := actionscharts.GetChartCaseEndpoint(client, client.RancherConfig.Host, prometheusTargetsAPIPath, true)
 		if err != nil {
 			return statusInit, err
 		}
+		if !result.Ok {
+			return statusInit, errors.New("failed to get a healthy response from prometheus targets API")
+		}
 
 		var mapResponse map[string]interface{}
-		if err = json.Unmarshal([]byte(bodyString), &mapResponse); err != nil {
+		if err = json.Unmarshal([]byte(result.Body), &mapResponse); err != nil {
 			return statusInit, err
 		}
 		if mapResponse["status"] != "success" {
@@ -122,7 +205,7 @@ func waitUnknownPrometheusTargets(client *rancher.Client) error {
 		return len(unknownTargets) == 0, nil
 	}
 
-	return kubewait.PollUntilContextTimeout(context.TODO(), 500*time.Millisecond, 2*time.Minute, true, func(context.Context) (ongoing bool, err error) {
+	return kubewait.PollUntilContextTimeout(context.TODO(), timeouts.PollInterval(), timeouts.Scale(2*time.Minute), true, func(context.Context) (ongoing bool, err error) {
 		result, err := checkUnknownPrometheusTargets()
 		if err != nil {
 			return ongoing, err
@@ -138,132 +221,126 @@ func waitUnknownPrometheusTargets(client *rancher.Client) error {
 
 // checkPrometheusTargets is a private helper function
 // that checks if all active prometheus targets are healthy by using prometheus API.
-func checkPrometheusTargets(client *rancher.Client) (bool, error) {
+func checkPrometheusTargets(client *rancher.Client, prometheusTargetsAPIPath string) (bool, error) {
 	var statusInit bool
-	var downTargets []string
 
-	err := waitUnknownPrometheusTargets(client)
+	err := waitUnknownPrometheusTargets(client, prometheusTargetsAPIPath)
 	if err != nil {
 		return statusInit, err
 	}
 
-	bodyString, err := ingresses.GetExternalIngressResponse(client, client.RancherConfig.Host, prometheusTargetsPathAPI, true)
+	err = actionscharts.AssertChartCaseEndpoint(client, client.RancherConfig.Host, prometheusTargetsAPIPath, true,
+		actionscharts.JSONPathEquals("status", "success"),
+		actionscharts.JSONPathMinLength("data.activeTargets", 1),
+		actionscharts.JSONPathEach("data.activeTargets", "health", "up"),
+	)
 	if err != nil {
-		return statusInit, err
-	}
-
-	var mapResponse map[string]interface{}
-	if err = json.Unmarshal([]byte(bodyString), &mapResponse); err != nil {
-		return statusInit, err
-	}
-
-	if mapResponse["status"] != "success" {
-		return statusInit, errors.New("failed to get targets from prometheus")
-	}
-
-	activeTargets := mapResponse["data"].(map[string]interface{})["activeTargets"].([]interface{})
-	if len(activeTargets) < 1 {
-		return false, errors.New("failed to find any active targets")
-	}
-
-	for _, target := range activeTargets {
-		targetMap := target.(map[string]interface{})
-		if targetMap["health"].(string) == "down" {
-			downTargets = append(downTargets, targetMap["labels"].(map[string]interface{})["instance"].(string))
-		}
-	}
-	statusInit = len(downTargets) == 0
-
-	if !statusInit {
-		return statusInit, errors.Wrapf(err, "All active target(s) are not healthy: %v", downTargets)
+		return statusInit, errors.Wrap(err, "All active target(s) are not healthy")
 	}
 
-	return statusInit, nil
+	return true, nil
 }
 
 // editAlertReceiver is a private helper function
-// that edits alert config structure to be used by the webhook receiver.
-func editAlertReceiver(alertConfigByte []byte, originURL *url.URL) ([]byte, error) {
-	alertConfig := &resources.AlertmanagerConfig{}
-	err := yaml.Unmarshal(alertConfigByte, alertConfig)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to unmarshal alert config")
-	}
-
-	vsendresolved := false
+// that returns the alert config mutation adding the webhook receiver.
+// When the cluster is behind a proxy, the receiver is reached through the global proxy of the alertmanager.
+// The capture container of the receiver is reached in-cluster, without proxy.
+func editAlertReceiver(originURL *url.URL, captureURL string, proxyConfig *monitoring.ProxyConfig) func(alertConfig *monitoring.AlertmanagerConfig) *monitoring.AlertmanagerConfig {
+	return func(alertConfig *monitoring.AlertmanagerConfig) *monitoring.AlertmanagerConfig {
+		vsendresolved := false
+
+		httpConfig := &monitoring.HTTPClientConfig{
+			ProxyURL: originURL.String(),
+		}
+		if proxyConfig.Enabled() {
+			httpConfig = nil
+		}
 
-	alertConfig.Global = &resources.GlobalConfig{
-		ResolveTimeout: alertConfig.Global.ResolveTimeout,
-	}
-	alertConfig.Receivers = append(alertConfig.Receivers, &resources.Receiver{
-		Name: webhookReceiverDeploymentName,
-		WebhookConfigs: []*resources.WebhookConfig{
-			{
-				VSendResolved: &vsendresolved,
-				HTTPConfig: &resources.HTTPClientConfig{
-					ProxyURL: originURL.String(),
+		alertConfig.Global = &monitoring.GlobalConfig{
+			ResolveTimeout: alertConfig.Global.ResolveTimeout,
+			HTTPConfig:     alertConfig.Global.HTTPConfig,
+		}
+		alertConfig.Receivers = append(alertConfig.Receivers, &monitoring.Receiver{
+			Name: webhookReceiverDeploymentName,
+			WebhookConfigs: []*monitoring.WebhookConfig{
+				{
+					VSendResolved: &vsendresolved,
+					HTTPConfig:    httpConfig,
+					URL:           originURL.String(),
+				},
+				{
+					VSendResolved: &vsendresolved,
+					HTTPConfig:    &monitoring.HTTPClientConfig{},
+					URL:           captureURL,
 				},
-				URL: originURL.String(),
 			},
-		},
-	})
+		})
 
-	byteAlertConfig, err := yaml.Marshal(alertConfig)
-	if err != nil {
-		return nil, err
+		return alertConfig
 	}
-
-	dst := make([]byte, base64.StdEncoding.EncodedLen(len(byteAlertConfig)))
-	base64.StdEncoding.Encode(dst, byteAlertConfig)
-
-	return byteAlertConfig, nil
 }
 
 // editAlertRoute is a private helper function
-// that edits alert config structure to be used by the webhook receiver.
-func editAlertRoute(alertConfigByte []byte) ([]byte, error) {
-	alertConfig := &resources.AlertmanagerConfig{}
-	err := yaml.Unmarshal(alertConfigByte, alertConfig)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to unmarshal alert config")
-	}
+// that returns the edit of the alert config structure adding the route of the webhook receiver.
+func editAlertRoute(route *monitoring.Route) func(alertConfig *monitoring.AlertmanagerConfig) *monitoring.AlertmanagerConfig {
+	return func(alertConfig *monitoring.AlertmanagerConfig) *monitoring.AlertmanagerConfig {
+		alertConfig.Global = &monitoring.GlobalConfig{
+			ResolveTimeout: alertConfig.Global.ResolveTimeout,
+			HTTPConfig:     alertConfig.Global.HTTPConfig,
+		}
 
-	alertConfig.Global = &resources.GlobalConfig{
-		ResolveTimeout: alertConfig.Global.ResolveTimeout,
-	}
+		webhookRoute := *route
+		webhookRoute.GroupWait = alertConfig.Route.GroupWait
+		webhookRoute.GroupInterval = alertConfig.Route.GroupInterval
+		webhookRoute.RepeatInterval = alertConfig.Route.RepeatInterval
 
-	alertConfig.Route.Routes = append(alertConfig.Route.Routes, &resources.Route{
-		Receiver:       webhookReceiverDeploymentName,
-		Match:          ruleLabel,
-		GroupWait:      alertConfig.Route.GroupWait,
-		GroupInterval:  alertConfig.Route.GroupInterval,
-		RepeatInterval: alertConfig.Route.RepeatInterval,
-	})
+		alertConfig.Route.Routes = append(alertConfig.Route.Routes, &webhookRoute)
 
-	byteAlertConfig, err := yaml.Marshal(alertConfig)
-	if err != nil {
-		return nil, err
+		return alertConfig
 	}
-	dst := make([]byte, base64.StdEncoding.EncodedLen(len(byteAlertConfig)))
-	base64.StdEncoding.Encode(dst, byteAlertConfig)
+}
 
-	return byteAlertConfig, nil
+// removeAlertReceiver is a private helper function
+// that returns the alert config mutation removing the receiver with the given name and the routes to it.
+func removeAlertReceiver(receiverName string) func(alertConfig *monitoring.AlertmanagerConfig) *monitoring.AlertmanagerConfig {
+	return func(alertConfig *monitoring.AlertmanagerConfig) *monitoring.AlertmanagerConfig {
+		var receivers []*monitoring.Receiver
+		for _, receiver := range alertConfig.Receivers {
+			if receiver.Name != receiverName {
+				receivers = append(receivers, receiver)
+			}
+		}
+		alertConfig.Receivers = receivers
+
+		if alertConfig.Route != nil {
+			var routes []*monitoring.Route
+			for _, route := range alertConfig.Route.Routes {
+				if route.Receiver != receiverName {
+					routes = append(routes, route)
+				}
+			}
+			alertConfig.Route.Routes = routes
+		}
+
+		return alertConfig
+	}
 }
 
 // createPrometheusRule is a private helper function
-// that creates a prometheus rule to be used by the webhook receiver.
-func createPrometheusRule(client *rancher.Client, clusterID string) error {
-	ruleName := "webhook-rule-" + namegenerator.RandStringLower(defaultRandStringLength)
-	alertName := "alert-" + namegenerator.RandStringLower(defaultRandStringLength)
+// that creates a prometheus rule to be used by the webhook receiver and returns the name of its alert.
+func createPrometheusRule(client *rancher.Client, clusterID string) (string, error) {
+	resourceNamer := namer.New(client)
+	ruleName := resourceNamer.Name("webhook-rule")
+	alertName := resourceNamer.Name("alert")
 
 	_, err := client.ReLogin()
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	steveclient, err := client.Steve.ProxyDownstream(clusterID)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	prometheusRule := &monitoringv1.PrometheusRule{
@@ -289,59 +366,79 @@ func createPrometheusRule(client *rancher.Client, clusterID string) error {
 	}
 	_, err = steveclient.SteveType(prometheusRulesSteveType).Create(prometheusRule)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	return nil
+	return alertName, nil
 }
 
-// createWebhookReceiverDeployment is a private helper function that creates a service account, cluster role binding, and deployment for webhook receiver.
-// The deployment has two different containers with a shared volume, one for kubectl commands, and the other one to receive requests and write access logs to the shared empty dir volume.
-// Container that uses rancher/shell has a mounted volume to use the kubeconfig of the cluster. And it watches the access logs until a request from "alermanager" is received.
-// When the request is received it sets its deployment annotation "didReceiveRequestFromAlertmanager" to "true" while the annotations being watched by the test itself.
-func createAlertWebhookReceiverDeployment(client *rancher.Client, clusterID, namespace, deploymentName string) (*v1.SteveAPIObject, error) {
-	serviceAccountName := "alert-receiver-sa-" + namegenerator.RandStringLower(defaultRandStringLength)
-	clusterRoleBindingName := "alert-receiver-cluster-admin-" + namegenerator.RandStringLower(defaultRandStringLength)
-	configMapName := "alert-receiver-cm-" + namegenerator.RandStringLower(defaultRandStringLength)
+// createInhibitionPrometheusRule is a private helper function
+// that creates a prometheus rule firing a critical source alert and a warning target alert, both with the labels of the
+// webhook route, and returns the names of the source and target alerts.
+func createInhibitionPrometheusRule(client *rancher.Client, clusterID string) (string, string, error) {
+	resourceNamer := namer.New(client)
+	ruleName := resourceNamer.Name("inhibition-rule")
+	sourceAlertName := resourceNamer.Name("source-alert")
+	targetAlertName := resourceNamer.Name("target-alert")
 
 	steveclient, err := client.Steve.ProxyDownstream(clusterID)
 	if err != nil {
-		return nil, err
+		return "", "", err
 	}
 
-	// Create webhook receiver service account
-	serviceAccount := &corev1.ServiceAccount{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      serviceAccountName,
-			Namespace: namespace,
-		},
-	}
+	newRule := func(alertName, severity string) monitoringv1.Rule {
+		labels := map[string]string{severityLabel: severity}
+		for key, value := range ruleLabel {
+			labels[key] = value
+		}
 
-	_, err = steveclient.SteveType(serviceaccounts.ServiceAccountSteveType).Create(serviceAccount)
-	if err != nil {
-		return nil, err
+		return monitoringv1.Rule{
+			Alert:  alertName,
+			Expr:   intstr.IntOrString{Type: intstr.String, StrVal: "vector(0)"},
+			Labels: labels,
+			For:    "0s",
+		}
 	}
 
-	// Create webhook receiver cluster role binding
-	clusterRoleBinding := &rbacv1.ClusterRoleBinding{
+	prometheusRule := &monitoringv1.PrometheusRule{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: clusterRoleBindingName,
+			Name:      ruleName,
+			Namespace: charts.RancherMonitoringNamespace,
 		},
-		Subjects: []rbacv1.Subject{
-			{
-				Kind:      "ServiceAccount",
-				Name:      serviceAccount.Name,
-				Namespace: namespace,
+		Spec: monitoringv1.PrometheusRuleSpec{
+			Groups: []monitoringv1.RuleGroup{
+				{
+					Name:  ruleName,
+					Rules: []monitoringv1.Rule{newRule(sourceAlertName, "critical"), newRule(targetAlertName, "warning")},
+				},
 			},
 		},
-		RoleRef: rbacv1.RoleRef{
-			APIGroup: rbacv1.SchemeGroupVersion.Group,
-			Kind:     "ClusterRole",
-			Name:     "cluster-admin",
-		},
+	}
+	_, err = steveclient.SteveType(prometheusRulesSteveType).Create(prometheusRule)
+	if err != nil {
+		return "", "", err
+	}
+
+	return sourceAlertName, targetAlertName, nil
+}
+
+// createWebhookReceiverDeployment is a private helper function that creates a service account bound to cluster-admin, a config map, and deployment for webhook receiver.
+// The deployment has two different containers with a shared volume, one for kubectl commands, and the other one to receive requests and write access logs to the shared empty dir volume.
+// A third container stores the payloads of the notifications it receives and serves them, so the test can assert on the content of the alerts.
+// Container that uses rancher/shell has a mounted volume to use the kubeconfig of the cluster. And it watches the access logs until a request from "alermanager" is received.
+// When the request is received it sets its deployment annotation "didReceiveRequestFromAlertmanager" to "true" while the annotations being watched by the test itself.
+func createAlertWebhookReceiverDeployment(client *rancher.Client, clusterID, namespace, deploymentName string) (*v1.SteveAPIObject, error) {
+	resourceNamer := namer.New(client)
+	serviceAccountName := resourceNamer.Name("alert-receiver-sa")
+	configMapName := resourceNamer.Name("alert-receiver-cm")
+
+	steveclient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return nil, err
 	}
 
-	_, err = steveclient.SteveType(clusterrolebindings.ClusterRoleBindingSteveType).Create(clusterRoleBinding)
+	// Create webhook receiver service account bound to cluster-admin
+	serviceAccount, err := serviceaccounts.CreateServiceAccountWithClusterRole(client, clusterID, namespace, serviceAccountName, "cluster-admin")
 	if err != nil {
 		return nil, err
 	}
@@ -350,18 +447,7 @@ func createAlertWebhookReceiverDeployment(client *rancher.Client, clusterID, nam
 	labels["workload.user.cattle.io/workloadselector"] = fmt.Sprintf("apps.deployment-%v-%v", namespace, deploymentName)
 
 	// Create webhook receiver config map
-	configmap := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      configMapName,
-			Namespace: namespace,
-			Labels:    labels,
-		},
-		Data: map[string]string{
-			"config": kubeConfig,
-		},
-	}
-
-	_, err = steveclient.SteveType(configmaps.ConfigMapSteveType).Create(configmap)
+	configmap, err := configmaps.CreateConfigMap(client, clusterID, namespace, configMapName, map[string]string{"config": kubeConfig}, labels)
 	if err != nil {
 		return nil, err
 	}
@@ -393,24 +479,28 @@ func createAlertWebhookReceiverDeployment(client *rancher.Client, clusterID, nam
 							deploymentName, namespace, webhookReceiverAnnotationKey, webhookReceiverAnnotationValue,
 						),
 					},
+					Env: []corev1.EnvVar{
+						{Name: "KUBECONFIG", Value: "/kube/config"},
+					},
 					SecurityContext: &corev1.SecurityContext{
 						RunAsUser:  &runAsUser,
 						RunAsGroup: &runAsGroup,
 					},
 					VolumeMounts: []corev1.VolumeMount{
-						{Name: "config", MountPath: "/root/usr/share/.kube/"},
+						{Name: "config", MountPath: "/kube/"},
 						{Name: "logs", MountPath: "/traefik"},
 					},
 				},
+				monitoring.NewWebhookCaptureContainer(),
 				{
 					Name:  "traefik",
 					Image: "traefik:latest",
 					Args: []string{
-						"--entrypoints.web.address=:80", "--api.dashboard=true", "--api.insecure=true", "--accesslog=true", "--accesslog.filepath=/var/log/traefik/access.log", "--log.level=INFO", "--accesslog.fields.headers.defaultmode=keep",
+						"--entrypoints.web.address=:8000", "--api.dashboard=true", "--api.insecure=true", "--accesslog=true", "--accesslog.filepath=/var/log/traefik/access.log", "--log.level=INFO", "--accesslog.fields.headers.defaultmode=keep",
 					},
 					Ports: []corev1.ContainerPort{
 						{
-							ContainerPort: 80,
+							ContainerPort: 8000,
 							Protocol:      corev1.ProtocolTCP,
 						},
 						{
@@ -442,6 +532,16 @@ func createAlertWebhookReceiverDeployment(client *rancher.Client, clusterID, nam
 		},
 	}
 
+	// The receiver runs non-root with a restricted security context unless restricted pods are opted out, so it is
+	// admitted on hardened clusters; its ports are above 1024 as traefik can't bind lower ones without root
+	hardened.ApplyPodTemplate(&podSpecTemplate)
+
+	// The receiver images are linux only, pin it to linux nodes in case the cluster has windows nodes
+	err = nodeos.SetPodTemplateOS(&podSpecTemplate, nodeos.Linux)
+	if err != nil {
+		return nil, err
+	}
+
 	isCattleLabeled := true
 	deploymentTemplate := workloads.NewDeploymentTemplate(deploymentName, namespace, podSpecTemplate, isCattleLabeled, nil)
 	deployment, err := steveclient.SteveType(workloads.DeploymentSteveType).Create(deploymentTemplate)
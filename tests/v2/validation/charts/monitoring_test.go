@@ -4,21 +4,30 @@
 
 import (
 	"fmt"
-	"math/rand"
 	"net/url"
 	"testing"
-
-	"github.com/rancher/norman/types"
+	"time"
+
+	catalogv1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"github.com/rancher/rancher/tests/v2/actions/chaos"
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/clientcache"
+	"github.com/rancher/rancher/tests/v2/actions/hardened"
+	"github.com/rancher/rancher/tests/v2/actions/monitoring"
+	actionsnamespaces "github.com/rancher/rancher/tests/v2/actions/namespaces"
+	"github.com/rancher/rancher/tests/v2/actions/networking"
+	"github.com/rancher/rancher/tests/v2/actions/nodeos"
+	"github.com/rancher/rancher/tests/v2/actions/nodes"
+	"github.com/rancher/rancher/tests/v2/actions/requirements"
+	"github.com/rancher/rancher/tests/v2/actions/sessionid"
+	"github.com/rancher/rancher/tests/v2/actions/subtests"
+	"github.com/rancher/rancher/tests/v2/actions/timeouts"
 	"github.com/rancher/shepherd/clients/rancher"
 	"github.com/rancher/shepherd/clients/rancher/catalog"
 	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
 	v1 "github.com/rancher/shepherd/clients/rancher/v1"
 	"github.com/rancher/shepherd/extensions/charts"
-	"github.com/rancher/shepherd/extensions/clusters"
 	"github.com/rancher/shepherd/extensions/ingresses"
-	"github.com/rancher/shepherd/extensions/namespaces"
-	"github.com/rancher/shepherd/extensions/projects"
-	"github.com/rancher/shepherd/extensions/secrets"
 	"github.com/rancher/shepherd/extensions/services"
 	"github.com/rancher/shepherd/pkg/session"
 	"github.com/stretchr/testify/assert"
@@ -26,6 +35,7 @@
 	"github.com/stretchr/testify/suite"
 	appv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -36,6 +46,8 @@ type MonitoringTestSuite struct {
 	project             *management.Project
 	chartInstallOptions *charts.InstallOptions
 	chartFeatureOptions *charts.RancherMonitoringOpts
+	proxyConfig         *monitoring.ProxyConfig
+	paths               *monitoringPaths
 }
 
 func (m *MonitoringTestSuite) TearDownSuite() {
@@ -46,7 +58,7 @@ func (m *MonitoringTestSuite) SetupSuite() {
 	testSession := session.NewSession()
 	m.session = testSession
 
-	client, err := rancher.NewClient("", testSession)
+	client, err := sessionid.NewClient("", testSession)
 	require.NoError(m.T(), err)
 
 	m.client = client
@@ -56,27 +68,18 @@ func (m *MonitoringTestSuite) SetupSuite() {
 	require.NotEmptyf(m.T(), clusterName, "Cluster name to install is not set")
 
 	// Get cluster meta
-	cluster, err := clusters.NewClusterMeta(client, clusterName)
+	cluster, err := clientcache.NewClusterMeta(client, clusterName)
 	require.NoError(m.T(), err)
 
-	// Change alert manager and grafana paths if it's not local cluster
-	if !cluster.IsLocal {
-		alertManagerPath = fmt.Sprintf("k8s/clusters/%s/%s", cluster.ID, alertManagerPath)
-		grafanaPath = fmt.Sprintf("k8s/clusters/%s/%s", cluster.ID, grafanaPath)
-		prometheusTargetsPathAPI = fmt.Sprintf("k8s/clusters/%s/%s", cluster.ID, prometheusTargetsPathAPI)
-	}
-
-	// Change prometheus paths to use the clusterID
-	prometheusGraphPath = fmt.Sprintf("k8s/clusters/%s/%s", cluster.ID, prometheusGraphPath)
-	prometheusRulesPath = fmt.Sprintf("k8s/clusters/%s/%s", cluster.ID, prometheusRulesPath)
-	prometheusTargetsPath = fmt.Sprintf("k8s/clusters/%s/%s", cluster.ID, prometheusTargetsPath)
+	// Get the monitoring chart paths of the cluster
+	m.paths = newMonitoringPaths(cluster)
 
 	// Get latest versions of the monitoring chart
 	latestMonitoringVersion, err := client.Catalog.GetLatestChartVersion(charts.RancherMonitoringName, catalog.RancherChartRepo)
 	require.NoError(m.T(), err)
 
 	// Get project system projectId
-	project, err := projects.GetProjectByName(client, cluster.ID, projectName)
+	project, err := clientcache.GetProjectByName(client, cluster.ID, projectName)
 	require.NoError(m.T(), err)
 
 	m.project = project
@@ -87,184 +90,347 @@ func (m *MonitoringTestSuite) SetupSuite() {
 		Version:   latestMonitoringVersion,
 		ProjectID: m.project.ID,
 	}
-	m.chartFeatureOptions = &charts.RancherMonitoringOpts{
+	m.chartFeatureOptions = hardened.AdjustMonitoringOpts(&charts.RancherMonitoringOpts{
 		IngressNginx:      true,
 		ControllerManager: true,
 		Etcd:              true,
 		Proxy:             true,
 		Scheduler:         true,
-	}
+	})
+	m.proxyConfig = monitoring.GetProxyConfig()
 }
 
 func (m *MonitoringTestSuite) TestMonitoringChart() {
 	subSession := m.session.NewSession()
 	defer subSession.Cleanup()
 
-	client, err := m.client.WithSession(subSession)
-	require.NoError(m.T(), err)
-
-	steveclient, err := client.Steve.ProxyDownstream(m.project.ClusterID)
+	client, err := sessionid.WithSession(m.client, subSession)
 	require.NoError(m.T(), err)
 
 	m.T().Log("Checking if the monitoring chart is already installed")
-	initialMonitoringChart, err := charts.GetChartStatus(client, m.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
+	initialMonitoringChart, err := actionscharts.GetChartStatus(client, m.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
 	require.NoError(m.T(), err)
 
 	if !initialMonitoringChart.IsAlreadyInstalled {
-		m.T().Log("Installing monitoring chart")
-		err = charts.InstallRancherMonitoringChart(client, m.chartInstallOptions, m.chartFeatureOptions)
-		require.NoError(m.T(), err)
+		usageBefore, err := monitoring.TakeUsageSnapshot(client, m.project.ClusterID, time.Time{})
+		if err != nil {
+			m.T().Logf("Not reporting the footprint of the monitoring chart: %v", err)
+		}
 
-		m.T().Log("Waiting monitoring chart deployments to have expected number of available replicas")
-		err = charts.WatchAndWaitDeployments(client, m.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
+		m.T().Log("Installing monitoring chart")
+		err = actionscharts.InstallRancherMonitoringChart(client, m.chartInstallOptions, m.chartFeatureOptions, &actionscharts.OperationOptions{StreamLogs: true})
 		require.NoError(m.T(), err)
 
-		m.T().Log("Waiting monitoring chart DaemonSets to have expected number of available nodes")
-		err = charts.WatchAndWaitDaemonSets(client, m.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
+		m.T().Log("Waiting monitoring chart deployments, DaemonSets and StatefulSets to be ready")
+		err = actionscharts.WatchAndWaitWorkloads(client, m.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
 		require.NoError(m.T(), err)
 
-		m.T().Log("Waiting monitoring chart StatefulSets to have expected number of ready replicas")
-		err = charts.WatchAndWaitStatefulSets(client, m.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
-		require.NoError(m.T(), err)
+		if usageBefore != nil {
+			usageAfter, err := monitoring.TakeUsageSnapshot(client, m.project.ClusterID, time.Now())
+			if err != nil {
+				m.T().Logf("Not reporting the footprint of the monitoring chart: %v", err)
+			} else {
+				m.T().Log(monitoring.NewFootprintReport(charts.RancherMonitoringName, m.chartInstallOptions.Version, usageBefore, usageAfter).String())
+			}
+		}
 	}
 
-	paths := []string{alertManagerPath, grafanaPath, prometheusGraphPath, prometheusRulesPath, prometheusTargetsPath}
-	for _, path := range paths {
-		m.T().Logf("Validating %s is accessible", path)
-		result, err := ingresses.IsIngressExternallyAccessible(client, client.RancherConfig.Host, path, true)
-		assert.NoError(m.T(), err)
-		assert.True(m.T(), result)
+	m.T().Log("Validating the monitoring chart endpoints are accessible")
+	subtests.RunParallelCases(m.T(), client, m.endpointCases())
+
+	if latencyBudget := actionscharts.GetEndpointLatencyBudget(); latencyBudget != nil {
+		for _, path := range []string{m.paths.grafana, m.paths.prometheusGraph} {
+			m.T().Logf("Validating %s responds within its latency budget", path)
+			latency, err := actionscharts.AssertChartCaseEndpointLatency(client, client.RancherConfig.Host, path, true, latencyBudget)
+			if latency != nil {
+				m.T().Log(latency.String())
+			}
+			assert.NoError(m.T(), err)
+		}
 	}
+}
+
+func (m *MonitoringTestSuite) TestMonitoringDatasourcesAndTargets() {
+	subSession := m.session.NewSession()
+	defer subSession.Cleanup()
+
+	client, err := sessionid.WithSession(m.client, subSession)
+	require.NoError(m.T(), err)
+
+	m.requireMonitoringChart(client)
+
+	m.T().Log("Validating Grafana datasources are healthy")
+	err = monitoring.VerifyGrafanaDatasources(client, m.project.ClusterID)
+	assert.NoError(m.T(), err)
 
 	m.T().Log("Validating all Prometheus active targets are up")
-	prometheusTargetsResult, err := checkPrometheusTargets(client)
+	prometheusTargetsResult, err := checkPrometheusTargets(client, m.paths.prometheusTargetsAPI)
 	assert.NoError(m.T(), err)
 	assert.True(m.T(), prometheusTargetsResult)
 
-	m.T().Log("Creating webhook receiver's namespace")
-	webhookReceiverNamespace, err := namespaces.CreateNamespace(client, webhookReceiverNamespaceName, "{}", map[string]string{}, map[string]string{}, m.project)
+	if targetsConfig := monitoring.GetTargetsConfig(); len(targetsConfig.Jobs) > 0 {
+		m.T().Log("Validating the Prometheus jobs are exactly the expected ones")
+		err = monitoring.VerifyExpectedTargets(client, m.project.ClusterID, targetsConfig.Jobs)
+		assert.NoError(m.T(), err)
+	}
+}
+
+func (m *MonitoringTestSuite) TestMonitoringWebhookAlert() {
+	subSession := m.session.NewSession()
+	defer subSession.Cleanup()
+
+	client, err := sessionid.WithSession(m.client, subSession)
 	require.NoError(m.T(), err)
 
-	m.T().Log("Creating alert webhook receiver deployment and its resources")
-	alertWebhookReceiverDeploymentResp, err := createAlertWebhookReceiverDeployment(client, m.project.ClusterID, webhookReceiverNamespace.Name, webhookReceiverDeploymentName)
+	m.requireMonitoringChart(client)
+
+	webhookReceiver := m.createAlertWebhookReceiver(client)
+
+	m.T().Logf("Creating prometheus rule")
+	alertName, err := createPrometheusRule(client, m.project.ClusterID)
 	require.NoError(m.T(), err)
-	assert.Equal(m.T(), alertWebhookReceiverDeploymentResp.Name, webhookReceiverDeploymentName)
 
-	m.T().Log("Waiting webhook receiver deployment to have expected number of available replicas")
-	err = charts.WatchAndWaitDeployments(client, m.project.ClusterID, webhookReceiverNamespace.Name, metav1.ListOptions{})
+	m.T().Logf("Validating traefik is accessible externally")
+	result, err := ingresses.IsIngressExternallyAccessible(client, webhookReceiver.host, "dashboard", false)
+	assert.NoError(m.T(), err)
+	assert.True(m.T(), result)
+
+	m.T().Logf("Validating alertmanager sent alert to webhook receiver")
+	err = charts.WatchAndWaitDeploymentForAnnotation(client, m.project.ClusterID, webhookReceiver.namespace, webhookReceiver.deploymentName, webhookReceiverAnnotationKey, webhookReceiverAnnotationValue)
 	require.NoError(m.T(), err)
 
-	alertWebhookReceiverDeploymentSpec := &appv1.DeploymentSpec{}
-	err = v1.ConvertToK8sType(alertWebhookReceiverDeploymentResp.Spec, alertWebhookReceiverDeploymentSpec)
+	m.T().Logf("Validating the alert received by the webhook receiver has the labels of the rule")
+	expectedLabels := map[string]string{"alertname": alertName}
+	for key, value := range ruleLabel {
+		expectedLabels[key] = value
+	}
+
+	webhookAlert, err := monitoring.WaitForWebhookAlert(client, m.project.ClusterID, webhookReceiver.namespace, webhookReceiverServiceName, expectedLabels)
 	require.NoError(m.T(), err)
+	assert.Equal(m.T(), "firing", webhookAlert.Status)
 
-	m.T().Log("Creating node port service for webhook receiver deployment")
-	webhookServiceTemplate := corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      webhookReceiverServiceName,
-			Namespace: webhookReceiverNamespace.Name,
-		},
-		Spec: corev1.ServiceSpec{
-			Type: corev1.ServiceTypeNodePort,
-			Ports: []corev1.ServicePort{
-				{
-					Name: "port",
-					Port: 8080,
-				},
-			},
-			Selector: alertWebhookReceiverDeploymentSpec.Template.Labels,
-		},
+	if m.proxyConfig.Enabled() {
+		m.T().Logf("Validating alertmanager sent the webhook notifications without failures behind the proxy")
+		err = monitoring.VerifyNotificationsSent(client, m.project.ClusterID, "webhook")
+		assert.NoError(m.T(), err)
 	}
-	webhookReceiverServiceResp, err := steveclient.SteveType(services.ServiceSteveType).Create(webhookServiceTemplate)
+}
+
+func (m *MonitoringTestSuite) TestMonitoringAlertInhibition() {
+	subSession := m.session.NewSession()
+	defer subSession.Cleanup()
+
+	client, err := sessionid.WithSession(m.client, subSession)
 	require.NoError(m.T(), err)
 
-	webhookReceiverServiceSpec := &corev1.ServiceSpec{}
-	err = v1.ConvertToK8sType(webhookReceiverServiceResp.Spec, webhookReceiverServiceSpec)
+	m.requireMonitoringChart(client)
+
+	webhookReceiver := m.createAlertWebhookReceiver(client)
+
+	m.T().Logf("Adding an inhibit rule muting the warning alerts while a critical alert of the same team fires")
+	inhibitRule, err := monitoring.NewClusterInhibitRule(client, m.project.ClusterID,
+		[]monitoring.Matcher{{Name: severityLabel, Type: monitoring.MatchEqual, Value: "critical"}},
+		[]monitoring.Matcher{{Name: severityLabel, Type: monitoring.MatchEqual, Value: "warning"}},
+		"team")
 	require.NoError(m.T(), err)
 
-	// Get a random worker node' public external IP of a specific cluster
-	nodeCollection, err := client.Management.Node.List(&types.ListOpts{Filters: map[string]interface{}{
-		"clusterId": m.project.ClusterID,
-	}})
+	err = monitoring.UpdateAlertmanagerConfig(client, m.project.ClusterID, monitoring.AddInhibitRules(inhibitRule))
 	require.NoError(m.T(), err)
-	workerNodePublicIPs := []string{}
-	for _, node := range nodeCollection.Data {
-		workerNodePublicIPs = append(workerNodePublicIPs, node.Annotations["rke.cattle.io/external-ip"])
-	}
-	randWorkerNodePublicIP := workerNodePublicIPs[rand.Intn(len(workerNodePublicIPs))]
 
-	// Get URL and string versions of origin with random node' public IP
-	hostWithProtocol := fmt.Sprintf("http://%v:%v", randWorkerNodePublicIP, webhookReceiverServiceSpec.Ports[0].NodePort)
-	urlOfHost, err := url.Parse(hostWithProtocol)
+	client.Session.RegisterCleanupFunc(func() error {
+		return monitoring.UpdateAlertmanagerConfig(client, m.project.ClusterID, monitoring.RemoveInhibitRules(inhibitRule))
+	})
+
+	m.T().Logf("Creating prometheus rule firing the source and target alerts of the inhibit rule")
+	sourceAlertName, targetAlertName, err := createInhibitionPrometheusRule(client, m.project.ClusterID)
 	require.NoError(m.T(), err)
 
-	m.T().Logf("Getting alert manager secret to edit receiver")
-	alertManagerSecretResp, err := steveclient.SteveType(secrets.SecretSteveType).ByID(alertManagerSecretID)
+	m.T().Logf("Validating the source alert %s is delivered to the webhook receiver", sourceAlertName)
+	_, err = monitoring.WaitForWebhookAlert(client, m.project.ClusterID, webhookReceiver.namespace, webhookReceiverServiceName, map[string]string{"alertname": sourceAlertName})
 	require.NoError(m.T(), err)
 
-	alertManagerSecret := &corev1.Secret{}
-	err = v1.ConvertToK8sType(alertManagerSecretResp.JSONResp, alertManagerSecret)
+	m.T().Logf("Validating the target alert %s is inhibited", targetAlertName)
+	_, err = monitoring.WaitForInhibitedAlert(client, m.project.ClusterID, map[string]string{"alertname": targetAlertName})
 	require.NoError(m.T(), err)
 
-	m.T().Logf("Editing alert manager secret receivers")
-	encodedAlertConfigWithReceiver, err := editAlertReceiver(alertManagerSecret.Data[secretPath], urlOfHost)
+	m.T().Logf("Validating the target alert %s is not delivered to the webhook receiver", targetAlertName)
+	err = monitoring.AssertWebhookAlertNotReceived(client, m.project.ClusterID, webhookReceiver.namespace, webhookReceiverServiceName, map[string]string{"alertname": targetAlertName}, inhibitedAlertCheckDuration)
+	assert.NoError(m.T(), err)
+}
+
+func (m *MonitoringTestSuite) TestCustomMetricsHPA() {
+	subSession := m.session.NewSession()
+	defer subSession.Cleanup()
+
+	client, err := sessionid.WithSession(m.client, subSession)
 	require.NoError(m.T(), err)
 
-	alertManagerSecret.Data[secretPath] = encodedAlertConfigWithReceiver
+	m.requireMonitoringChart(client)
 
-	editedReceiverSecretResp, err := steveclient.SteveType(secrets.SecretSteveType).Update(alertManagerSecretResp, alertManagerSecret)
+	m.T().Log("Installing prometheus-adapter")
+	err = monitoring.InstallPrometheusAdapter(client, m.project.ClusterID)
 	require.NoError(m.T(), err)
-	assert.Equal(m.T(), editedReceiverSecretResp.Name, charts.RancherMonitoringAlertSecret)
 
-	m.T().Logf("Creating prometheus rule")
-	err = createPrometheusRule(client, m.project.ClusterID)
+	m.T().Log("Deploying an application exposing a request counter")
+	loadConfig := monitoring.GetLoadConfig()
+	loadConfig.Namespaces = 1
+	loadConfig.PodsPerNamespace = 1
+
+	load, err := monitoring.GenerateLoad(client, m.project, loadConfig)
 	require.NoError(m.T(), err)
 
-	m.T().Logf("Getting alert manager secret to edit routes")
-	alertManagerSecretResp, err = steveclient.SteveType(secrets.SecretSteveType).ByID(alertManagerSecretID)
+	appNamespace := load.Namespaces[0]
+
+	m.T().Logf("Validating the custom metrics API serves %s of the application pods", monitoring.LoadCustomMetric)
+	_, err = monitoring.WaitForPodCustomMetric(client, m.project.ClusterID, appNamespace, monitoring.LoadCustomMetric)
 	require.NoError(m.T(), err)
 
-	err = v1.ConvertToK8sType(alertManagerSecretResp.JSONResp, alertManagerSecret)
+	m.T().Log("Creating a horizontal pod autoscaler on the custom metric")
+	_, err = monitoring.CreateCustomMetricHPA(client, m.project.ClusterID, appNamespace, load.Name, monitoring.LoadCustomMetric, resource.MustParse(customMetricTargetAverage), customMetricMaxReplicas)
 	require.NoError(m.T(), err)
 
-	m.T().Logf("Editing alert manager secret routes")
-	encodedAlertConfigWithRoute, err := editAlertRoute(alertManagerSecret.Data[secretPath])
+	m.T().Log("Validating the horizontal pod autoscaler scales on the custom metric")
+	err = monitoring.VerifyCustomMetricHPA(client, m.project.ClusterID, appNamespace, load.Name)
+	assert.NoError(m.T(), err)
+}
+
+func (m *MonitoringTestSuite) TestMonitoringChartCustomValues() {
+	subSession := m.session.NewSession()
+	defer subSession.Cleanup()
+
+	client, err := sessionid.WithSession(m.client, subSession)
 	require.NoError(m.T(), err)
 
-	alertManagerSecret.Data[secretPath] = encodedAlertConfigWithRoute
+	customValues := map[string]interface{}{
+		"prometheus": map[string]interface{}{
+			"prometheusSpec": map[string]interface{}{
+				"retention": customPrometheusRetention,
+			},
+		},
+	}
+
+	m.requireMonitoringChart(client)
+
+	m.T().Log("Upgrading the values of the monitoring chart with custom values")
+	restoreValues := m.mergeMonitoringValues(client, customValues)
+	defer restoreValues()
+
+	m.T().Log("Validating prometheus runs with the custom retention")
+	err = monitoring.WaitForPrometheusFlag(client, m.project.ClusterID, prometheusRetentionFlag, customPrometheusRetention)
+	assert.NoError(m.T(), err)
+}
 
-	editedRouteSecretResp, err := steveclient.SteveType(secrets.SecretSteveType).Update(alertManagerSecretResp, alertManagerSecret)
+func (m *MonitoringTestSuite) TestPrometheusPersistence() {
+	subSession := m.session.NewSession()
+	defer subSession.Cleanup()
+
+	client, err := sessionid.WithSession(m.client, subSession)
 	require.NoError(m.T(), err)
-	assert.Equal(m.T(), editedRouteSecretResp.Name, charts.RancherMonitoringAlertSecret)
 
-	m.T().Logf("Validating traefik is accessible externally")
-	host := fmt.Sprintf("%v:%v", randWorkerNodePublicIP, webhookReceiverServiceSpec.Ports[0].NodePort)
-	result, err := ingresses.IsIngressExternallyAccessible(client, host, "dashboard", false)
+	persistenceValues := (&actionscharts.PrometheusPersistenceOpts{}).Values()
+
+	m.requireMonitoringChart(client)
+
+	m.T().Log("Upgrading the values of the monitoring chart with the persistent storage of prometheus")
+	restoreValues := m.mergeMonitoringValues(client, persistenceValues)
+	defer restoreValues()
+
+	m.T().Log("Validating prometheus keeps its series across a restart")
+	err = monitoring.VerifyPrometheusPersistence(client, m.project)
 	assert.NoError(m.T(), err)
-	assert.True(m.T(), result)
+}
 
-	m.T().Logf("Validating alertmanager sent alert to webhook receiver")
-	err = charts.WatchAndWaitDeploymentForAnnotation(client, m.project.ClusterID, webhookReceiverNamespace.Name, alertWebhookReceiverDeploymentResp.Name, webhookReceiverAnnotationKey, webhookReceiverAnnotationValue)
+func (m *MonitoringTestSuite) TestMonitoringHA() {
+	subSession := m.session.NewSession()
+	defer subSession.Cleanup()
+
+	client, err := sessionid.WithSession(m.client, subSession)
 	require.NoError(m.T(), err)
+
+	workers, err := countWorkerNodes(client, m.project.ClusterID)
+	require.NoError(m.T(), err)
+
+	if workers < monitoringHAReplicas {
+		m.T().Skipf("Skipping the high availability case, the cluster has %d worker nodes for %d replicas", workers, monitoringHAReplicas)
+	}
+
+	initialMonitoringChart, err := actionscharts.GetChartStatus(client, m.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
+	require.NoError(m.T(), err)
+
+	if initialMonitoringChart.IsAlreadyInstalled {
+		m.T().Skip("Skipping the high availability case, monitoring chart is already installed")
+	}
+
+	haOpts := &actionscharts.MonitoringHAOpts{
+		PrometheusReplicas:   monitoringHAReplicas,
+		AlertmanagerReplicas: monitoringHAReplicas,
+		PodAntiAffinity:      actionscharts.PodAntiAffinityHard,
+		PodDisruptionBudget:  true,
+	}
+
+	m.T().Logf("Installing monitoring chart with %d replicas of prometheus and alertmanager", monitoringHAReplicas)
+	installOptions := &actionscharts.InstallOptions{InstallOptions: m.chartInstallOptions, Values: haOpts.Values()}
+	err = actionscharts.InstallRancherMonitoringChartWithValues(client, installOptions, m.chartFeatureOptions, &actionscharts.OperationOptions{StreamLogs: true})
+	require.NoError(m.T(), err)
+
+	m.T().Log("Validating the high availability values landed in the release")
+	err = actionscharts.AssertValuesEqual(client, m.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName, haOpts.Values())
+	require.NoError(m.T(), err)
+
+	m.T().Log("Waiting monitoring chart deployments, DaemonSets and StatefulSets to be ready")
+	err = actionscharts.WatchAndWaitWorkloads(client, m.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
+	require.NoError(m.T(), err)
+
+	m.T().Log("Validating the replicas, pod anti-affinity and pod disruption budgets of prometheus and alertmanager")
+	err = monitoring.VerifyMonitoringHA(client, m.project.ClusterID, haOpts)
+	assert.NoError(m.T(), err)
+}
+
+func (m *MonitoringTestSuite) TestGrafanaAuth() {
+	subSession := m.session.NewSession()
+	defer subSession.Cleanup()
+
+	client, err := sessionid.WithSession(m.client, subSession)
+	require.NoError(m.T(), err)
+
+	m.requireMonitoringChart(client)
+
+	authOptsList := []*actionscharts.GrafanaAuthOpts{
+		{AnonymousEnabled: false, UsersRole: actionscharts.GrafanaViewer},
+		{AnonymousEnabled: true, AnonymousRole: actionscharts.GrafanaViewer, UsersRole: actionscharts.GrafanaViewer},
+	}
+
+	for _, authOpts := range authOptsList {
+		m.T().Logf("Configuring grafana with anonymous access %t, anonymous role %q and users role %q", authOpts.AnonymousEnabled, authOpts.AnonymousRole, authOpts.UsersRole)
+		err = actionscharts.ConfigureGrafanaAuth(client, m.project.ClusterID, authOpts)
+		require.NoError(m.T(), err)
+
+		m.T().Log("Validating the grafana access through the proxy")
+		err = monitoring.VerifyGrafanaAuth(client, m.project.ClusterID, authOpts)
+		assert.NoError(m.T(), err)
+	}
 }
 
 func (m *MonitoringTestSuite) TestUpgradeMonitoringChart() {
 	subSession := m.session.NewSession()
 	defer subSession.Cleanup()
 
-	client, err := m.client.WithSession(subSession)
+	client, err := sessionid.WithSession(m.client, subSession)
 	require.NoError(m.T(), err)
 
+	requirements.Require(m.T(), client, m.project.ClusterID, requirements.ChartVersions(charts.RancherMonitoringName, 2))
+
 	m.T().Log("Checking if the monitoring chart is installed with one of the previous versions")
-	initialMonitoringChart, err := charts.GetChartStatus(client, m.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
+	initialMonitoringChart, err := actionscharts.GetChartStatus(client, m.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
+	require.NoError(m.T(), err)
+
+	// Change monitoring install option version to the latest version before the latest version
+	versionLatest, err := client.Catalog.GetLatestChartVersion(charts.RancherMonitoringName, catalog.RancherChartRepo)
 	require.NoError(m.T(), err)
 
-	// Change monitoring install option version to previous version of the latest version
-	versionsList, err := client.Catalog.GetListChartVersions(charts.RancherMonitoringName, catalog.RancherChartRepo)
+	versionConstraintBeforeLatest := "<" + versionLatest
+	versionBeforeLatest, err := actionscharts.GetLatestChartVersionMatching(client, charts.RancherMonitoringName, catalog.RancherChartRepo, versionConstraintBeforeLatest)
 	require.NoError(m.T(), err)
-	require.Greaterf(m.T(), len(versionsList), 1, "There should be at least 2 versions of the monitoring chart")
-	versionLatest := versionsList[0]
-	versionBeforeLatest := versionsList[1]
 	m.chartInstallOptions.Version = versionBeforeLatest
 
 	if initialMonitoringChart.IsAlreadyInstalled && initialMonitoringChart.ChartDetails.Spec.Chart.Metadata.Version == versionLatest {
@@ -273,42 +439,299 @@ func (m *MonitoringTestSuite) TestUpgradeMonitoringChart() {
 
 	if !initialMonitoringChart.IsAlreadyInstalled {
 		m.T().Log("Installing monitoring chart with the last but one version")
-		err = charts.InstallRancherMonitoringChart(client, m.chartInstallOptions, m.chartFeatureOptions)
-		require.NoError(m.T(), err)
-
-		m.T().Log("Waiting monitoring chart deployments to have expected number of available replicas")
-		err = charts.WatchAndWaitDeployments(client, m.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
+		err = actionscharts.InstallRancherMonitoringChart(client, m.chartInstallOptions, m.chartFeatureOptions, &actionscharts.OperationOptions{StreamLogs: true})
 		require.NoError(m.T(), err)
 
-		m.T().Log("Waiting monitoring chart DaemonSets to have expected number of available nodes")
-		err = charts.WatchAndWaitDaemonSets(client, m.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
-		require.NoError(m.T(), err)
-
-		m.T().Log("Waiting monitoring chart StatefulSets to have expected number of ready replicas")
-		err = charts.WatchAndWaitStatefulSets(client, m.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
+		m.T().Log("Waiting monitoring chart deployments, DaemonSets and StatefulSets to be ready")
+		err = actionscharts.WatchAndWaitWorkloads(client, m.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
 		require.NoError(m.T(), err)
 	}
 
-	monitoringChartPreUpgrade, err := charts.GetChartStatus(client, m.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
+	monitoringChartPreUpgrade, err := actionscharts.GetChartStatus(client, m.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
 	require.NoError(m.T(), err)
 
 	// Validate current version of rancher monitoring is one of the versions before latest
 	chartVersionPreUpgrade := monitoringChartPreUpgrade.ChartDetails.Spec.Chart.Metadata.Version
-	assert.Contains(m.T(), versionsList[1:], chartVersionPreUpgrade)
-
-	m.chartInstallOptions.Version, err = client.Catalog.GetLatestChartVersion(charts.RancherMonitoringName, catalog.RancherChartRepo)
+	versionsBeforeLatest, err := actionscharts.GetChartVersionsMatching(client, charts.RancherMonitoringName, catalog.RancherChartRepo, versionConstraintBeforeLatest)
 	require.NoError(m.T(), err)
+	assert.Contains(m.T(), versionsBeforeLatest, chartVersionPreUpgrade)
+
+	m.chartInstallOptions.Version = versionLatest
 
 	m.T().Log("Upgrading monitoring chart with the latest version")
-	err = charts.UpgradeRancherMonitoringChart(client, m.chartInstallOptions, m.chartFeatureOptions)
+	err = actionscharts.UpgradeRancherMonitoringChart(client, m.chartInstallOptions, m.chartFeatureOptions, &actionscharts.OperationOptions{StreamLogs: true})
 	require.NoError(m.T(), err)
 
-	monitoringChartPostUpgrade, err := charts.GetChartStatus(client, m.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
+	monitoringChartPostUpgrade, err := actionscharts.GetChartStatus(client, m.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
 	require.NoError(m.T(), err)
 
 	// Compare rancher monitoring versions
 	chartVersionPostUpgrade := monitoringChartPostUpgrade.ChartDetails.Spec.Chart.Metadata.Version
 	assert.Equal(m.T(), m.chartInstallOptions.Version, chartVersionPostUpgrade)
+
+	// Validate the release history holds the revision before the upgrade, superseded by the deployed upgrade
+	history, err := actionscharts.GetReleaseHistory(client, m.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
+	require.NoError(m.T(), err)
+	require.NotEmpty(m.T(), history)
+	assert.Equal(m.T(), chartVersionPostUpgrade, history[len(history)-1].ChartVersion)
+	assert.Equal(m.T(), catalogv1.StatusDeployed, history[len(history)-1].State)
+
+	preUpgradeRevision := monitoringChartPreUpgrade.ChartDetails.Spec.Version
+	for _, revision := range history {
+		if revision.Revision == preUpgradeRevision {
+			assert.Equal(m.T(), chartVersionPreUpgrade, revision.ChartVersion)
+			assert.Equal(m.T(), catalogv1.StatusSuperseded, revision.State)
+		}
+	}
+
+	m.T().Logf("Rolling back monitoring chart to revision %d", monitoringChartPreUpgrade.ChartDetails.Spec.Version)
+	err = actionscharts.RollbackChart(client, m.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName, monitoringChartPreUpgrade.ChartDetails.Spec.Version)
+	require.NoError(m.T(), err)
+
+	m.T().Log("Waiting monitoring chart deployments, DaemonSets and StatefulSets to be ready")
+	err = actionscharts.WatchAndWaitWorkloads(client, m.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
+	require.NoError(m.T(), err)
+
+	monitoringChartPostRollback, err := actionscharts.GetChartStatus(client, m.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
+	require.NoError(m.T(), err)
+
+	// Compare rancher monitoring versions
+	chartVersionPostRollback := monitoringChartPostRollback.ChartDetails.Spec.Chart.Metadata.Version
+	assert.Equal(m.T(), chartVersionPreUpgrade, chartVersionPostRollback)
+}
+
+func (m *MonitoringTestSuite) TestMonitoringVersionMatrix() {
+	subSession := m.session.NewSession()
+	defer subSession.Cleanup()
+
+	client, err := sessionid.WithSession(m.client, subSession)
+	require.NoError(m.T(), err)
+
+	versions, err := actionscharts.GetMonitoringMatrixVersions(client)
+	require.NoError(m.T(), err)
+
+	if len(versions) == 0 {
+		m.T().Skip("Skipping the version matrix case, no monitoring chart versions are configured")
+	}
+
+	initialMonitoringChart, err := actionscharts.GetChartStatus(client, m.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
+	require.NoError(m.T(), err)
+
+	if initialMonitoringChart.IsAlreadyInstalled {
+		m.T().Skip("Skipping the version matrix case, monitoring chart is already installed")
+	}
+
+	m.T().Logf("Running the monitoring chart versions %v", versions)
+	results, err := actionscharts.RunMonitoringVersionMatrix(client, m.chartInstallOptions, m.chartFeatureOptions, versions, func(client *rancher.Client, clusterID, version string) error {
+		return monitoring.WaitForHealthyTargets(client, clusterID, fmt.Sprintf("namespace=%q", charts.RancherMonitoringNamespace))
+	})
+	require.NoError(m.T(), err)
+
+	m.T().Logf("Results of the monitoring chart versions:\n%s", results)
+	assert.Empty(m.T(), results.Failed(), "monitoring chart versions failed")
+}
+
+func (m *MonitoringTestSuite) TestClusterAgentReconnection() {
+	subSession := m.session.NewSession()
+	defer subSession.Cleanup()
+
+	client, err := sessionid.WithSession(m.client, subSession)
+	require.NoError(m.T(), err)
+
+	if m.chartInstallOptions.Cluster.IsLocal {
+		m.T().Skip("Skipping the reconnection case, the agent of the local cluster can't be disconnected")
+	}
+
+	cluster, err := client.Management.Cluster.ByID(m.project.ClusterID)
+	require.NoError(m.T(), err)
+
+	if cluster.LocalClusterAuthEndpoint == nil || !cluster.LocalClusterAuthEndpoint.Enabled {
+		m.T().Skip("Skipping the reconnection case, the authorized cluster endpoint of the cluster is not enabled")
+	}
+
+	m.requireMonitoringChart(client)
+
+	endpoints := []*actionscharts.EndpointOptions{
+		{Host: client.RancherConfig.Host, Path: m.paths.grafana, IsHTTPS: true},
+		{Host: client.RancherConfig.Host, Path: m.paths.prometheusGraph, IsHTTPS: true},
+	}
+
+	m.T().Log("Disconnecting the agent of the cluster")
+	disconnection, err := chaos.DisconnectClusterAgent(client, m.project.ClusterID, timeouts.Scale(clusterAgentSLO))
+	require.NoError(m.T(), err)
+
+	for _, endpoint := range endpoints {
+		m.T().Logf("Validating %s fails gracefully while the cluster is disconnected", endpoint.Path)
+		err = chaos.AssertEndpointUnavailable(client, endpoint, unavailableEndpointBudget)
+		assert.NoError(m.T(), err)
+	}
+
+	m.T().Log("Reconnecting the agent of the cluster")
+	reconnectionTime, err := chaos.ReconnectClusterAgent(client, disconnection, timeouts.Scale(clusterAgentSLO))
+	require.NoError(m.T(), err)
+	m.T().Logf("Cluster reconnected in %s", reconnectionTime.Round(time.Second))
+
+	for _, endpoint := range endpoints {
+		m.T().Logf("Waiting %s to recover", endpoint.Path)
+		recoveryTime, err := chaos.WaitForEndpointRecovery(client, endpoint, timeouts.Scale(endpointTimeout))
+		assert.NoError(m.T(), err)
+		m.T().Logf("%s recovered in %s", endpoint.Path, recoveryTime.Round(time.Second))
+	}
+}
+
+// requireMonitoringChart installs the monitoring chart with the client when it is not installed yet, so it is removed by
+// the session of the client, and waits for its workloads to be ready.
+func (m *MonitoringTestSuite) requireMonitoringChart(client *rancher.Client) {
+	m.T().Log("Checking if the monitoring chart is already installed")
+	initialMonitoringChart, err := actionscharts.GetChartStatus(client, m.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
+	require.NoError(m.T(), err)
+
+	if !initialMonitoringChart.IsAlreadyInstalled {
+		m.T().Log("Installing monitoring chart")
+		err = actionscharts.InstallRancherMonitoringChart(client, m.chartInstallOptions, m.chartFeatureOptions, &actionscharts.OperationOptions{StreamLogs: true})
+		require.NoError(m.T(), err)
+	}
+
+	m.T().Log("Waiting monitoring chart deployments, DaemonSets and StatefulSets to be ready")
+	err = actionscharts.WatchAndWaitWorkloads(client, m.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
+	require.NoError(m.T(), err)
+}
+
+// createAlertWebhookReceiver deploys the alert webhook receiver with the client and routes the alerts with the labels of
+// the rule to it. The receiver and its route are removed from the alertmanager configuration by the session of the
+// client, so the next tests can add theirs.
+func (m *MonitoringTestSuite) createAlertWebhookReceiver(client *rancher.Client) *alertWebhookReceiver {
+	steveclient, err := client.Steve.ProxyDownstream(m.project.ClusterID)
+	require.NoError(m.T(), err)
+
+	if m.proxyConfig.Enabled() {
+		m.T().Log("Setting the proxy of the alertmanager receivers")
+		err = monitoring.SetAlertmanagerProxy(client, m.project.ClusterID, m.proxyConfig)
+		require.NoError(m.T(), err)
+	}
+
+	m.T().Log("Creating webhook receiver's namespace")
+	webhookReceiverNamespace, err := actionsnamespaces.CreateTestNamespace(client, m.project, webhookReceiverNamespacePrefix)
+	require.NoError(m.T(), err)
+
+	m.T().Log("Creating alert webhook receiver deployment and its resources")
+	alertWebhookReceiverDeploymentResp, err := createAlertWebhookReceiverDeployment(client, m.project.ClusterID, webhookReceiverNamespace.Name, webhookReceiverDeploymentName)
+	require.NoError(m.T(), err)
+	assert.Equal(m.T(), alertWebhookReceiverDeploymentResp.Name, webhookReceiverDeploymentName)
+
+	m.T().Log("Waiting webhook receiver deployment to have expected number of available replicas")
+	err = actionscharts.WatchAndWaitDeployments(client, m.project.ClusterID, webhookReceiverNamespace.Name, metav1.ListOptions{})
+	require.NoError(m.T(), err)
+
+	alertWebhookReceiverDeploymentSpec := &appv1.DeploymentSpec{}
+	err = v1.ConvertToK8sType(alertWebhookReceiverDeploymentResp.Spec, alertWebhookReceiverDeploymentSpec)
+	require.NoError(m.T(), err)
+
+	m.T().Log("Creating node port service for webhook receiver deployment")
+	webhookServicePorts := []corev1.ServicePort{
+		{
+			Name: "port",
+			Port: 8080,
+		},
+		{
+			Name: "capture",
+			Port: monitoring.WebhookCapturePort,
+		},
+	}
+	webhookServiceTemplate, err := nodeos.NewServiceTemplate(nodeos.Linux, webhookReceiverServiceName, webhookReceiverNamespace.Name, corev1.ServiceTypeNodePort, webhookServicePorts, alertWebhookReceiverDeploymentSpec.Template.Labels)
+	require.NoError(m.T(), err)
+
+	webhookReceiverServiceResp, err := steveclient.SteveType(services.ServiceSteveType).Create(webhookServiceTemplate)
+	require.NoError(m.T(), err)
+
+	webhookReceiverServiceSpec := &corev1.ServiceSpec{}
+	err = v1.ConvertToK8sType(webhookReceiverServiceResp.Spec, webhookReceiverServiceSpec)
+	require.NoError(m.T(), err)
+
+	// Get the public IP of a random node of a specific cluster
+	randWorkerNodePublicIP, err := nodes.GetRandomPublicIP(client, m.project.ClusterID)
+	require.NoError(m.T(), err)
+
+	// Get URL and string versions of origin with random node' public IP
+	host := networking.HostPort(randWorkerNodePublicIP, webhookReceiverServiceSpec.Ports[0].NodePort)
+	urlOfHost, err := url.Parse(fmt.Sprintf("http://%v", host))
+	require.NoError(m.T(), err)
+
+	m.T().Logf("Editing alert manager secret receivers")
+	captureURL := monitoring.WebhookCaptureURL(webhookReceiverNamespace.Name, webhookReceiverServiceName)
+	err = monitoring.UpdateAlertmanagerConfig(client, m.project.ClusterID, editAlertReceiver(urlOfHost, captureURL, m.proxyConfig))
+	require.NoError(m.T(), err)
+
+	client.Session.RegisterCleanupFunc(func() error {
+		return monitoring.UpdateAlertmanagerConfig(client, m.project.ClusterID, removeAlertReceiver(webhookReceiverDeploymentName))
+	})
+
+	m.T().Logf("Building the webhook receiver route for the alertmanager version")
+	routeBuilder, err := monitoring.NewClusterRouteBuilder(client, m.project.ClusterID, webhookReceiverDeploymentName)
+	require.NoError(m.T(), err)
+
+	webhookRoute, err := routeBuilder.MatchLabels(ruleLabel).Build()
+	require.NoError(m.T(), err)
+
+	m.T().Logf("Editing alert manager secret routes")
+	err = monitoring.UpdateAlertmanagerConfig(client, m.project.ClusterID, editAlertRoute(webhookRoute))
+	require.NoError(m.T(), err)
+
+	return &alertWebhookReceiver{
+		namespace:      webhookReceiverNamespace.Name,
+		deploymentName: alertWebhookReceiverDeploymentResp.Name,
+		host:           host,
+	}
+}
+
+// endpointCases is a private method that returns a parallel subtest for each monitoring chart endpoint of the cluster.
+func (m *MonitoringTestSuite) endpointCases() []subtests.Case {
+	endpoints := []struct {
+		name string
+		path string
+	}{
+		{"alertmanager", m.paths.alertManager},
+		{"grafana", m.paths.grafana},
+		{"prometheus-graph", m.paths.prometheusGraph},
+		{"prometheus-rules", m.paths.prometheusRules},
+		{"prometheus-targets", m.paths.prometheusTargets},
+	}
+
+	cases := make([]subtests.Case, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		path := endpoint.path
+		cases = append(cases, subtests.Case{
+			Name: endpoint.name,
+			Run: func(t *testing.T, client *rancher.Client, _ string) {
+				t.Logf("Validating %s is accessible", path)
+				_, err := actionscharts.WaitForEndpoint(client, client.RancherConfig.Host, path, true, actionscharts.ExpectHealthy, endpointTimeout)
+				assert.NoError(t, err)
+			},
+		})
+	}
+
+	return cases
+}
+
+// mergeMonitoringValues upgrades the monitoring chart with its values deeply merged with the given values and waits for
+// its workloads. It returns the function restoring the previous values, to defer before the session of the test is
+// cleaned up, as the chart may be used by the next tests.
+func (m *MonitoringTestSuite) mergeMonitoringValues(client *rancher.Client, values map[string]interface{}) (restoreValues func()) {
+	restore, err := actionscharts.MergeReleaseValues(client, m.project.ClusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName, values)
+	require.NoError(m.T(), err)
+
+	m.T().Log("Waiting monitoring chart deployments, DaemonSets and StatefulSets to be ready")
+	err = actionscharts.WatchAndWaitWorkloads(client, m.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
+	require.NoError(m.T(), err)
+
+	return func() {
+		m.T().Log("Restoring the values of the monitoring chart")
+		err := restore()
+		require.NoError(m.T(), err)
+
+		err = actionscharts.WatchAndWaitWorkloads(client, m.project.ClusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
+		require.NoError(m.T(), err)
+	}
 }
 
 func TestMonitoringTestSuite(t *testing.T) {
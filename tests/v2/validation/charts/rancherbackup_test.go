@@ -0,0 +1,104 @@
+//go:build (validation || infra.any || cluster.any || stress) && !sanity && !extended
+
+package charts
+
+import (
+	"testing"
+
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/clientcache"
+	"github.com/rancher/rancher/tests/v2/actions/rancherbackup"
+	"github.com/rancher/rancher/tests/v2/actions/sessionid"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/clients/rancher/catalog"
+	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
+	"github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RancherBackupTestSuite struct {
+	suite.Suite
+	client              *rancher.Client
+	session             *session.Session
+	project             *management.Project
+	chartInstallOptions *charts.InstallOptions
+	storageOpts         *rancherbackup.StorageOpts
+}
+
+func (r *RancherBackupTestSuite) TearDownSuite() {
+	r.session.Cleanup()
+}
+
+func (r *RancherBackupTestSuite) SetupSuite() {
+	testSession := session.NewSession()
+	r.session = testSession
+
+	client, err := sessionid.NewClient("", testSession)
+	require.NoError(r.T(), err)
+
+	r.client = client
+
+	// rancher-backup backs up the rancher server, so it is installed in the local cluster
+	cluster, err := clientcache.NewClusterMeta(client, localCluster)
+	require.NoError(r.T(), err)
+
+	// Get latest versions of the rancher-backup chart
+	latestBackupVersion, err := client.Catalog.GetLatestChartVersion(rancherbackup.RancherBackupChartName, catalog.RancherChartRepo)
+	require.NoError(r.T(), err)
+
+	// Get project system projectId
+	project, err := clientcache.GetProjectByName(client, cluster.ID, projectName)
+	require.NoError(r.T(), err)
+
+	r.project = project
+	require.NotEmpty(r.T(), r.project)
+
+	r.chartInstallOptions = &charts.InstallOptions{
+		Cluster:   cluster,
+		Version:   latestBackupVersion,
+		ProjectID: r.project.ID,
+	}
+
+	r.storageOpts = rancherbackup.GetStorageOpts()
+}
+
+func (r *RancherBackupTestSuite) TestBackupAndRestore() {
+	subSession := r.session.NewSession()
+	defer subSession.Cleanup()
+
+	client, err := sessionid.WithSession(r.client, subSession)
+	require.NoError(r.T(), err)
+
+	r.T().Log("Checking if the rancher-backup chart is already installed")
+	initialBackupChart, err := actionscharts.GetChartStatus(client, r.project.ClusterID, rancherbackup.RancherBackupNamespace, rancherbackup.RancherBackupChartName)
+	require.NoError(r.T(), err)
+
+	if !initialBackupChart.IsAlreadyInstalled {
+		r.T().Log("Installing rancher-backup chart with the latest version")
+		err = rancherbackup.InstallRancherBackupChart(client, r.chartInstallOptions, r.storageOpts, &actionscharts.OperationOptions{StreamLogs: true})
+		require.NoError(r.T(), err)
+	}
+
+	r.T().Log("Creating a backup of the rancher resources")
+	backupName, err := rancherbackup.CreateBackup(client, &rancherbackup.BackupOpts{S3: r.storageOpts.S3})
+	require.NoError(r.T(), err)
+
+	r.T().Logf("Waiting backup %s to complete", backupName)
+	backupFilename, err := rancherbackup.WaitForBackupComplete(client, backupName)
+	require.NoError(r.T(), err)
+	require.NotEmpty(r.T(), backupFilename)
+
+	r.T().Logf("Restoring backup file %s without pruning", backupFilename)
+	restoreName, err := rancherbackup.CreateRestore(client, backupFilename, &rancherbackup.RestoreOpts{S3: r.storageOpts.S3})
+	require.NoError(r.T(), err)
+
+	r.T().Logf("Waiting restore %s to complete", restoreName)
+	err = rancherbackup.WaitForRestoreComplete(client, restoreName)
+	require.NoError(r.T(), err)
+}
+
+func TestRancherBackupTestSuite(t *testing.T) {
+	suite.Run(t, new(RancherBackupTestSuite))
+}
@@ -10,7 +10,6 @@
 const (
 	resourceName    = "rancher.cattle.io"
 	restrictedAdmin = "restricted-admin"
-	admin           = "admin"
 	localCluster    = "local"
 )
 
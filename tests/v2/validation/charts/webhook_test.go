@@ -6,10 +6,13 @@
 	"strings"
 	"testing"
 
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
+	"github.com/rancher/rancher/tests/v2/actions/clientcache"
+	"github.com/rancher/rancher/tests/v2/actions/sessionid"
+	actionswebhook "github.com/rancher/rancher/tests/v2/actions/webhook"
 	"github.com/rancher/shepherd/clients/rancher"
 	"github.com/rancher/shepherd/clients/rancher/catalog"
 	"github.com/rancher/shepherd/extensions/charts"
-	"github.com/rancher/shepherd/extensions/clusters"
 	"github.com/rancher/shepherd/extensions/kubeconfig"
 	"github.com/rancher/shepherd/extensions/workloads/pods"
 
@@ -38,7 +41,7 @@ func (w *WebhookTestSuite) SetupSuite() {
 	testSession := session.NewSession()
 	w.session = testSession
 
-	client, err := rancher.NewClient("", testSession)
+	client, err := sessionid.NewClient("", testSession)
 	require.NoError(w.T(), err)
 
 	w.client = client
@@ -62,14 +65,14 @@ func (w *WebhookTestSuite) TestWebhookChart() {
 
 	for _, tt := range tests {
 
-		clusterID, err := clusters.GetClusterIDByName(w.client, tt.cluster)
+		clusterID, err := clientcache.GetClusterIDByName(w.client, tt.cluster)
 		require.NoError(w.T(), err)
 
 		w.Run("Verify the version of webhook on "+tt.cluster, func() {
 			subSession := w.session.NewSession()
 			defer subSession.Cleanup()
 
-			initialWebhookChart, err := charts.GetChartStatus(w.client, clusterID, charts.RancherWebhookNamespace, charts.RancherWebhookName)
+			initialWebhookChart, err := actionscharts.GetChartStatus(w.client, clusterID, charts.RancherWebhookNamespace, charts.RancherWebhookName)
 			require.NoError(w.T(), err)
 			chartVersion := initialWebhookChart.ChartDetails.Spec.Chart.Metadata.Version
 			require.NoError(w.T(), err)
@@ -117,15 +120,8 @@ func (w *WebhookTestSuite) TestWebhookEscalationCheck() {
 		restrictedAdminClient, err := w.client.AsUser(newUser)
 		require.NoError(w.T(), err)
 
-		getAdminRole, err := restrictedAdminClient.Management.GlobalRole.ByID(admin)
-		require.NoError(w.T(), err)
-		updatedAdminRole := *getAdminRole
-		updatedAdminRole.NewUserDefault = true
-
-		_, err = restrictedAdminClient.Management.GlobalRole.Update(getAdminRole, updatedAdminRole)
-		require.Error(w.T(), err)
-		errMessage := "admission webhook \"rancher.cattle.io.globalroles.management.cattle.io\" denied the request"
-		assert.Contains(w.T(), err.Error(), errMessage)
+		err = actionswebhook.VerifyDenials(restrictedAdminClient, actionswebhook.UpdateAdminGlobalRole())
+		assert.NoError(w.T(), err)
 	})
 }
 
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/rancher/tests/v2/validation/pipeline/qase/testcase"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	failStatus           = "fail"
+	passStatus           = "pass"
+	skipStatus           = "skip"
+	runAction            = "run"
+	outputAction         = "output"
+	testResultsJSON      = "results.json"
+	htmlReportFile       = "report.html"
+	reportTitleEnvVar    = "HTML_REPORT_TITLE"
+	diagnosticsURLEnvVar = "HTML_REPORT_DIAGNOSTICS_URL"
+)
+
+var (
+	reportTitle    = os.Getenv(reportTitleEnvVar)
+	diagnosticsURL = os.Getenv(diagnosticsURLEnvVar)
+)
+
+// step is a single test or subtest result rendered as one row of the report.
+type step struct {
+	Name       string
+	Anchor     string
+	Status     string
+	Elapsed    float64
+	Output     string
+	Diagnostic string
+}
+
+// suite groups all steps that share the same top level test, which for our validation runs is the testify suite.
+type suite struct {
+	Name    string
+	Package string
+	Status  string
+	Elapsed float64
+	Passed  int
+	Failed  int
+	Skipped int
+	Steps   []*step
+}
+
+// report is the data structure that is passed to the html template.
+type report struct {
+	Title   string
+	Passed  int
+	Failed  int
+	Skipped int
+	Elapsed float64
+	Suites  []*suite
+}
+
+func main() {
+	testOutputs, err := readTestOutputs(testResultsJSON)
+	if err != nil {
+		logrus.Fatalf("error reading test results: %v", err)
+	}
+
+	title := reportTitle
+	if title == "" {
+		title = "Validation Results"
+	}
+
+	summary := buildReport(title, testOutputs)
+
+	file, err := os.Create(htmlReportFile)
+	if err != nil {
+		logrus.Fatalf("error creating html report: %v", err)
+	}
+	defer file.Close()
+
+	if err = reportTemplate.Execute(file, summary); err != nil {
+		logrus.Fatalf("error writing html report: %v", err)
+	}
+
+	logrus.Infof("html report written to %s: %d passed, %d failed, %d skipped", htmlReportFile, summary.Passed, summary.Failed, summary.Skipped)
+}
+
+// readTestOutputs reads the gotestsum JSON output line by line.
+func readTestOutputs(fileName string) ([]testcase.GoTestOutput, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fscanner := bufio.NewScanner(file)
+	fscanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	testOutputs := []testcase.GoTestOutput{}
+	for fscanner.Scan() {
+		var testOutput testcase.GoTestOutput
+		err = yaml.Unmarshal(fscanner.Bytes(), &testOutput)
+		if err != nil {
+			return nil, err
+		}
+
+		testOutputs = append(testOutputs, testOutput)
+	}
+
+	return testOutputs, fscanner.Err()
+}
+
+// buildReport groups the raw go test events into suites and steps, preserving the order the tests were run in.
+func buildReport(title string, testOutputs []testcase.GoTestOutput) *report {
+	summary := &report{Title: title}
+	suites := map[string]*suite{}
+	steps := map[string]*step{}
+
+	for _, testOutput := range testOutputs {
+		if testOutput.Test == "" {
+			continue
+		}
+
+		// suites of the same name in different packages are reported apart
+		suiteName := strings.Split(testOutput.Test, "/")[0]
+		suiteKey := testOutput.Package + "/" + suiteName
+		testSuite, ok := suites[suiteKey]
+		if !ok {
+			testSuite = &suite{Name: suiteName, Package: testOutput.Package}
+			suites[suiteKey] = testSuite
+			summary.Suites = append(summary.Suites, testSuite)
+		}
+
+		if testOutput.Test == suiteName {
+			switch testOutput.Action {
+			case passStatus, failStatus, skipStatus:
+				testSuite.Status = testOutput.Action
+				testSuite.Elapsed = parseElapsed(testOutput.Elapsed)
+			}
+
+			continue
+		}
+
+		stepKey := testOutput.Package + "/" + testOutput.Test
+		testStep, ok := steps[stepKey]
+		if !ok {
+			testStep = &step{
+				Name:   strings.TrimPrefix(testOutput.Test, suiteName+"/"),
+				Anchor: anchorName(stepKey),
+			}
+			steps[stepKey] = testStep
+			testSuite.Steps = append(testSuite.Steps, testStep)
+		}
+
+		switch testOutput.Action {
+		case runAction:
+		case outputAction:
+			testStep.Output += testOutput.Output
+		case passStatus, failStatus, skipStatus:
+			testStep.Status = testOutput.Action
+			testStep.Elapsed = parseElapsed(testOutput.Elapsed)
+		}
+	}
+
+	for _, testSuite := range summary.Suites {
+		for _, testStep := range testSuite.Steps {
+			// a step without a terminal action means the run timed out or panicked while it was running
+			if testStep.Status == "" {
+				testStep.Status = failStatus
+			}
+
+			if diagnosticsURL != "" {
+				testStep.Diagnostic = fmt.Sprintf("%s/%s", strings.TrimSuffix(diagnosticsURL, "/"), testStep.Anchor)
+			}
+
+			switch testStep.Status {
+			case passStatus:
+				testSuite.Passed++
+			case failStatus:
+				testSuite.Failed++
+			case skipStatus:
+				testSuite.Skipped++
+			}
+		}
+
+		if testSuite.Status == "" {
+			testSuite.Status = failStatus
+			if testSuite.Failed == 0 && testSuite.Passed > 0 {
+				testSuite.Status = passStatus
+			}
+		}
+
+		summary.Passed += testSuite.Passed
+		summary.Failed += testSuite.Failed
+		summary.Skipped += testSuite.Skipped
+		summary.Elapsed += testSuite.Elapsed
+	}
+
+	// failed suites are listed first so they are the first thing seen when opening the report
+	sort.SliceStable(summary.Suites, func(i, j int) bool {
+		return summary.Suites[i].Status == failStatus && summary.Suites[j].Status != failStatus
+	})
+
+	return summary
+}
+
+// parseElapsed converts the elapsed seconds reported by go test, returning zero when it is not set.
+func parseElapsed(elapsed string) float64 {
+	if elapsed == "" {
+		return 0
+	}
+
+	seconds, err := strconv.ParseFloat(elapsed, 64)
+	if err != nil {
+		return 0
+	}
+
+	return seconds
+}
+
+// anchorName converts a test name qualified by its package to a value that can be used as an html id. The package
+// is part of it, so tests with the same name in different packages don't share an anchor.
+func anchorName(testName string) string {
+	return strings.NewReplacer("/", "-", " ", "_").Replace(testName)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"seconds": func(elapsed float64) string {
+		return fmt.Sprintf("%.2fs", elapsed)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+.pass { color: #2e7d32; }
+.fail { color: #c62828; font-weight: bold; }
+.skip { color: #757575; }
+pre { white-space: pre-wrap; background: #f5f5f5; padding: 8px; max-height: 400px; overflow: auto; }
+</style>
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+<p><span class="pass">{{ .Passed }} passed</span>, <span class="fail">{{ .Failed }} failed</span>, <span class="skip">{{ .Skipped }} skipped</span> in {{ seconds .Elapsed }}</p>
+{{ range .Suites }}
+<h2 class="{{ .Status }}">{{ .Name }} ({{ .Status }}, {{ seconds .Elapsed }})</h2>
+<p>{{ .Package }}: {{ .Passed }} passed, {{ .Failed }} failed, {{ .Skipped }} skipped</p>
+<table>
+<tr><th>Step</th><th>Status</th><th>Time</th><th>Diagnostics</th></tr>
+{{ range .Steps }}
+<tr>
+<td>{{ .Name }}</td>
+<td class="{{ .Status }}">{{ .Status }}</td>
+<td>{{ seconds .Elapsed }}</td>
+<td><a href="#{{ .Anchor }}">output</a>{{ if .Diagnostic }} | <a href="{{ .Diagnostic }}">diagnostics</a>{{ end }}</td>
+</tr>
+{{ end }}
+</table>
+{{ range .Steps }}
+<details id="{{ .Anchor }}"{{ if eq .Status "fail" }} open{{ end }}>
+<summary class="{{ .Status }}">{{ .Name }}</summary>
+<pre>{{ .Output }}</pre>
+</details>
+{{ end }}
+{{ end }}
+</body>
+</html>
+`))
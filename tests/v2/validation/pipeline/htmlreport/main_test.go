@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/rancher/rancher/tests/v2/validation/pipeline/qase/testcase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnchorName(t *testing.T) {
+	tests := []struct {
+		name     string
+		testName string
+		want     string
+	}{
+		{
+			name:     "top level test",
+			testName: "github.com/rancher/rancher/tests/v2/validation/charts/TestMonitoringTestSuite",
+			want:     "github.com-rancher-rancher-tests-v2-validation-charts-TestMonitoringTestSuite",
+		},
+		{
+			name:     "subtest",
+			testName: "charts/TestMonitoringTestSuite/TestMonitoringChart/grafana",
+			want:     "charts-TestMonitoringTestSuite-TestMonitoringChart-grafana",
+		},
+		{
+			name:     "test name with spaces",
+			testName: "charts/TestSuite/install chart",
+			want:     "charts-TestSuite-install_chart",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, anchorName(tt.testName))
+		})
+	}
+}
+
+func TestBuildReportAnchorsPerPackage(t *testing.T) {
+	testOutputs := []testcase.GoTestOutput{
+		{Action: runAction, Package: "validation/charts", Test: "TestSuite/TestInstall"},
+		{Action: passStatus, Package: "validation/charts", Test: "TestSuite/TestInstall"},
+		{Action: runAction, Package: "validation/upgrade", Test: "TestSuite/TestInstall"},
+		{Action: failStatus, Package: "validation/upgrade", Test: "TestSuite/TestInstall"},
+	}
+
+	summary := buildReport("report", testOutputs)
+	require.Len(t, summary.Suites, 2)
+
+	anchors := map[string]bool{}
+	for _, testSuite := range summary.Suites {
+		require.Len(t, testSuite.Steps, 1)
+		anchors[testSuite.Steps[0].Anchor] = true
+	}
+
+	assert.Equal(t, map[string]bool{
+		"validation-charts-TestSuite-TestInstall":  true,
+		"validation-upgrade-TestSuite-TestInstall": true,
+	}, anchors)
+}
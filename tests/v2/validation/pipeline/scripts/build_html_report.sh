@@ -0,0 +1,5 @@
+#!/bin/bash
+set -e
+cd $(dirname $0)/../../../../../
+echo "building html report bin"
+env GOOS=linux GOARCH=amd64 CGO_ENABLED=0 go build -o tests/v2/validation/htmlreport ./tests/v2/validation/pipeline/htmlreport
@@ -9,6 +9,8 @@
 
 	"github.com/rancher/rancher/pkg/api/scheme"
 	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/rancher/tests/v2/actions/cloudprovider"
+	"github.com/rancher/rancher/tests/v2/actions/networking"
 	"github.com/rancher/shepherd/clients/corral"
 	"github.com/rancher/shepherd/clients/rancher"
 	"github.com/rancher/shepherd/clients/rancher/catalog"
@@ -107,6 +109,9 @@ func RunTestPermutations(s *suite.Suite, testNamePrefix string, client *rancher.
 			for _, cni := range provisioningConfig.CNIs {
 
 				testClusterConfig = clusters.ConvertConfigToClusterConfig(provisioningConfig)
+				if provisioningConfig.Networking != nil {
+					networking.ApplyClusterCIDRs(testClusterConfig)
+				}
 				testClusterConfig.CNI = cni
 				name = testNamePrefix + " Node Provider: " + nodeProviderName + " Kubernetes version: " + kubeVersion + " cni: " + cni
 
@@ -214,7 +219,7 @@ func RunPostClusterCloudProviderChecks(t *testing.T, client *rancher.Client, clu
 				clusterMeta, err := clusters.NewClusterMeta(client, rke1ClusterObject.Name)
 				require.NoError(t, err)
 
-				err = CreateAndInstallAWSExternalCharts(client, clusterMeta, false)
+				err = cloudprovider.InstallAWSCloudControllerManager(client, clusterMeta, false)
 				require.NoError(t, err)
 
 				podErrors := pods.StatusPods(client, rke1ClusterObject.ID)
@@ -4,6 +4,7 @@
 	"time"
 
 	cis "github.com/rancher/cis-operator/pkg/apis/cis.cattle.io/v1"
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
 	"github.com/rancher/shepherd/clients/rancher"
 	"github.com/rancher/shepherd/extensions/charts"
 	"github.com/rancher/shepherd/extensions/defaults"
@@ -31,19 +32,19 @@ func SetupCISBenchmarkChart(client *rancher.Client, projectClusterID string, cha
 	}
 
 	logrus.Infof("Waiting for CIS Benchmark chart deployments to have expected number of available replicas...")
-	err = charts.WatchAndWaitDeployments(client, projectClusterID, benchmarkNamespace, metav1.ListOptions{})
+	err = actionscharts.WatchAndWaitDeployments(client, projectClusterID, benchmarkNamespace, metav1.ListOptions{})
 	if err != nil {
 		return err
 	}
 
 	logrus.Infof("Waiting for CIS Benchmark chart DaemonSets to have expected number of available nodes...")
-	err = charts.WatchAndWaitDaemonSets(client, projectClusterID, benchmarkNamespace, metav1.ListOptions{})
+	err = actionscharts.WatchAndWaitDaemonSets(client, projectClusterID, benchmarkNamespace, metav1.ListOptions{})
 	if err != nil {
 		return err
 	}
 
 	logrus.Infof("Waiting for CIS Benchmark chart StatefulSets to have expected number of ready replicas...")
-	err = charts.WatchAndWaitStatefulSets(client, projectClusterID, benchmarkNamespace, metav1.ListOptions{})
+	err = actionscharts.WatchAndWaitStatefulSets(client, projectClusterID, benchmarkNamespace, metav1.ListOptions{})
 	if err != nil {
 		return err
 	}
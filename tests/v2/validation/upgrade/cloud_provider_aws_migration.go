@@ -6,6 +6,7 @@
 
 	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
 	v1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/rancher/tests/v2/actions/cloudprovider"
 	"github.com/rancher/rancher/tests/v2/validation/provisioning/permutations"
 	"github.com/rancher/shepherd/clients/rancher"
 	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
@@ -168,7 +169,7 @@ func rke1AWSCloudProviderMigration(t *testing.T, client *rancher.Client, cluster
 	clusterMeta, err := clusters.NewClusterMeta(client, status.ClusterName)
 	require.NoError(t, err)
 
-	err = permutations.CreateAndInstallAWSExternalCharts(client, clusterMeta, true)
+	err = cloudprovider.InstallAWSCloudControllerManager(client, clusterMeta, true)
 	require.NoError(t, err)
 
 	newRKE1Cluster = rke1Cluster
@@ -9,6 +9,7 @@
 
 	"github.com/rancher/norman/types"
 	"github.com/rancher/rancher/pkg/api/scheme"
+	"github.com/rancher/rancher/tests/v2/actions/hardened"
 	"github.com/rancher/shepherd/clients/rancher"
 	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
 	v1 "github.com/rancher/shepherd/clients/rancher/v1"
@@ -27,6 +28,7 @@
 	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	kubewait "k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
 )
@@ -108,8 +110,9 @@ func newServiceTemplate(serviceName, namespaceName string, selector map[string]s
 	serviceType := corev1.ServiceTypeNodePort
 	ports := []corev1.ServicePort{
 		{
-			Name: servicePortName,
-			Port: servicePortNumber,
+			Name:       servicePortName,
+			Port:       servicePortNumber,
+			TargetPort: intstr.FromInt32(hardened.TargetPort(servicePortNumber)),
 		},
 	}
 
@@ -119,14 +122,14 @@ func newServiceTemplate(serviceName, namespaceName string, selector map[string]s
 // newTestContainerMinimal is a private constructor that returns container for minimal workload creations
 func newTestContainerMinimal() corev1.Container {
 	pullPolicy := corev1.PullAlways
-	return workloads.NewContainer(containerName, containerImage, pullPolicy, nil, nil, nil, nil, nil)
+	return hardened.NewContainer(containerName, containerImage, pullPolicy, nil, nil, nil, nil, nil)
 }
 
 // newPodTemplateWithTestContainer is a private constructor that returns pod template spec for workload creations
 func newPodTemplateWithTestContainer() corev1.PodTemplateSpec {
 	testContainer := newTestContainerMinimal()
 	containers := []corev1.Container{testContainer}
-	return workloads.NewPodTemplate(containers, nil, nil, nil)
+	return hardened.NewPodTemplate(containers, nil, nil, nil)
 }
 
 // newPodTemplateWithSecretVolume is a private constructor that returns pod template spec with volume option for workload creations
@@ -145,7 +148,7 @@ func newPodTemplateWithSecretVolume(secretName string) corev1.PodTemplateSpec {
 		},
 	}
 
-	return workloads.NewPodTemplate(containers, volumes, nil, nil)
+	return hardened.NewPodTemplate(containers, volumes, nil, nil)
 }
 
 // newPodTemplateWithSecretEnvironmentVariable is a private constructor that returns pod template spec with envFrom option for workload creations
@@ -158,10 +161,10 @@ func newPodTemplateWithSecretEnvironmentVariable(secretName string) corev1.PodTe
 			},
 		},
 	}
-	container := workloads.NewContainer(containerName, containerImage, pullPolicy, nil, envFrom, nil, nil, nil)
+	container := hardened.NewContainer(containerName, containerImage, pullPolicy, nil, envFrom, nil, nil, nil)
 	containers := []corev1.Container{container}
 
-	return workloads.NewPodTemplate(containers, nil, nil, nil)
+	return hardened.NewPodTemplate(containers, nil, nil, nil)
 }
 
 // waitUntilIngressIsAccessible waits until the ingress is accessible
@@ -5,6 +5,7 @@
 import (
 	"testing"
 
+	actionscharts "github.com/rancher/rancher/tests/v2/actions/charts"
 	"github.com/rancher/shepherd/clients/rancher"
 	"github.com/rancher/shepherd/clients/rancher/catalog"
 	v1 "github.com/rancher/shepherd/clients/rancher/v1"
@@ -103,7 +104,7 @@ func (u *UpgradeWorkloadTestSuite) testPreUpgradeSingleCluster(clusterName strin
 	assert.Equal(u.T(), createdDeployment.Name, names.random["deploymentName"])
 
 	u.T().Logf("Waiting deployment [%v] to have expected number of available replicas", names.random["deploymentName"])
-	err = charts.WatchAndWaitDeployments(client, project.ClusterID, namespace.Name, metav1.ListOptions{})
+	err = actionscharts.WatchAndWaitDeployments(client, project.ClusterID, namespace.Name, metav1.ListOptions{})
 	require.NoError(u.T(), err)
 
 	u.T().Logf("Creating a daemonset with the test container with name [%v]", names.random["daemonsetName"])
@@ -113,7 +114,7 @@ func (u *UpgradeWorkloadTestSuite) testPreUpgradeSingleCluster(clusterName strin
 	assert.Equal(u.T(), createdDaemonSet.Name, names.random["daemonsetName"])
 
 	u.T().Logf("Waiting daemonset [%v] to have expected number of available replicas", names.random["daemonsetName"])
-	err = charts.WatchAndWaitDaemonSets(client, project.ClusterID, namespace.Name, metav1.ListOptions{})
+	err = actionscharts.WatchAndWaitDaemonSets(client, project.ClusterID, namespace.Name, metav1.ListOptions{})
 	require.NoError(u.T(), err)
 
 	u.T().Logf("Validating daemonset[%v] available replicas number is equal to worker nodes number in the cluster [%v]", names.random["daemonsetName"], project.ClusterID)
@@ -141,7 +142,7 @@ func (u *UpgradeWorkloadTestSuite) testPreUpgradeSingleCluster(clusterName strin
 	assert.Equal(u.T(), createdDaemonSetWithSecretVolume.Name, names.random["daemonsetNameForVolumeSecret"])
 
 	u.T().Logf("Waiting daemonset [%v] to have expected number of available replicas", names.random["daemonsetNameForVolumeSecret"])
-	err = charts.WatchAndWaitDaemonSets(client, project.ClusterID, namespace.Name, metav1.ListOptions{})
+	err = actionscharts.WatchAndWaitDaemonSets(client, project.ClusterID, namespace.Name, metav1.ListOptions{})
 	require.NoError(u.T(), err)
 
 	u.T().Logf("Validating daemonset [%v] available replicas number is equal to worker nodes number in the cluster [%v]", names.random["daemonsetNameForVolumeSecret"], project.ClusterID)
@@ -162,7 +163,7 @@ func (u *UpgradeWorkloadTestSuite) testPreUpgradeSingleCluster(clusterName strin
 	assert.Equal(u.T(), createdDaemonSetEnvironmentVariableSecret.Name, names.random["daemonsetNameForEnvironmentVariableSecret"])
 
 	u.T().Logf("Waiting daemonset [%v] to have expected number of available replicas", names.random["daemonsetNameForEnvironmentVariableSecret"])
-	err = charts.WatchAndWaitDaemonSets(client, project.ClusterID, namespace.Name, metav1.ListOptions{})
+	err = actionscharts.WatchAndWaitDaemonSets(client, project.ClusterID, namespace.Name, metav1.ListOptions{})
 	require.NoError(u.T(), err)
 
 	u.T().Logf("Validating daemonset [%v] available replicas number is equal to worker nodes number in the cluster [%v]", names.random["daemonsetNameForEnvironmentVariableSecret"], project.ClusterID)
@@ -255,7 +256,7 @@ func (u *UpgradeWorkloadTestSuite) testPreUpgradeSingleCluster(clusterName strin
 		u.T().Log("Charts tests are enabled")
 
 		u.T().Logf("Checking if the logging chart is installed in cluster [%v]", project.ClusterID)
-		loggingChart, err := charts.GetChartStatus(client, project.ClusterID, charts.RancherLoggingNamespace, charts.RancherLoggingName)
+		loggingChart, err := actionscharts.GetChartStatus(client, project.ClusterID, charts.RancherLoggingNamespace, charts.RancherLoggingName)
 		require.NoError(u.T(), err)
 
 		if !loggingChart.IsAlreadyInstalled {
@@ -381,7 +382,7 @@ func (u *UpgradeWorkloadTestSuite) testPostUpgradeSingleCluster(clusterName stri
 		u.T().Logf("Chart tests are enabled")
 
 		u.T().Logf("Checking if the logging chart is installed")
-		loggingChart, err := charts.GetChartStatus(client, project.ClusterID, charts.RancherLoggingNamespace, charts.RancherLoggingName)
+		loggingChart, err := actionscharts.GetChartStatus(client, project.ClusterID, charts.RancherLoggingNamespace, charts.RancherLoggingName)
 		require.NoError(u.T(), err)
 		assert.True(u.T(), loggingChart.IsAlreadyInstalled)
 	}